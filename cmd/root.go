@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/LyricTian/gin-admin/v10/internal/bootstrap"
+	"github.com/urfave/cli/v2"
+)
+
+// The function defines a CLI command to manage the root user, the password is set in the database or
+// printed as a bcrypt hash for the configuration.
+func RootCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "root",
+		Usage: "Manage the root user",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "password",
+				Usage: "Set or rotate the root password",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "workdir",
+						Aliases:     []string{"d"},
+						Usage:       "Working directory",
+						DefaultText: "configs",
+						Value:       "configs",
+					},
+					&cli.StringFlag{
+						Name:        "config",
+						Aliases:     []string{"c"},
+						Usage:       "Runtime configuration files or directory (relative to workdir, multiple separated by commas)",
+						DefaultText: "dev",
+						Value:       "dev",
+					},
+					&cli.StringFlag{
+						Name:    "password",
+						Aliases: []string{"p"},
+						Usage:   "New password (read from stdin if empty)",
+					},
+					&cli.BoolFlag{
+						Name:  "hash",
+						Usage: "Print the hash for General.Root.Password instead of saving the password to the database",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					password := c.String("password")
+					if password == "" {
+						fmt.Print("New password: ")
+						line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+						if err != nil && line == "" {
+							return err
+						}
+						password = strings.TrimRight(line, "\r\n")
+					}
+					if password == "" {
+						return errors.New("password is required")
+					}
+
+					runCfg := bootstrap.RunConfig{
+						WorkDir: c.String("workdir"),
+						Configs: c.String("config"),
+					}
+					if c.Bool("hash") {
+						hashPass, err := bootstrap.HashRootPassword(context.Background(), runCfg, password)
+						if err != nil {
+							return err
+						}
+						fmt.Println(hashPass)
+						return nil
+					}
+
+					if err := bootstrap.SetRootPassword(context.Background(), runCfg, password); err != nil {
+						return err
+					}
+					fmt.Println("root password updated")
+					return nil
+				},
+			},
+		},
+	}
+}
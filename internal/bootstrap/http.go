@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/LyricTian/gin-admin/v10/internal/config"
+	rbacschema "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/internal/utility/prom"
 	"github.com/LyricTian/gin-admin/v10/internal/wirex"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
@@ -30,9 +31,18 @@ func startHTTPServer(ctx context.Context, injector *wirex.Injector) (func(), err
 	}
 
 	e := gin.New()
+	// The client IP (used by the logs, rate limiter, API keys and casbin conditions) is taken from X-Forwarded-For
+	// only if the request comes from the trusted proxies
+	if err := e.SetTrustedProxies(config.C.General.HTTP.TrustedProxies); err != nil {
+		return nil, err
+	}
 	e.GET("/health", func(c *gin.Context) {
 		util.ResOK(c)
 	})
+	e.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		util.ResJSON(c, http.StatusOK, injector.Auth.JWKS())
+	})
 	e.Use(middleware.RecoveryWithConfig(middleware.RecoveryConfig{
 		Skip: config.C.Middleware.Recovery.Skip,
 	}))
@@ -55,6 +65,11 @@ func startHTTPServer(ctx context.Context, injector *wirex.Injector) (func(), err
 		return nil, err
 	}
 
+	// Collect the routes for the menu resources
+	if err := injector.M.RBAC.RouteAPI.RouteBIZ.Register(ctx, e.Routes(), allowedPrefixes); err != nil {
+		return nil, err
+	}
+
 	// Register swagger
 	if !config.C.General.DisableSwagger {
 		e.StaticFile("/openapi.json", filepath.Join(config.C.General.WorkDir, "openapi.json"))
@@ -146,6 +161,14 @@ func useHTTPMiddlewares(_ context.Context, e *gin.Engine, injector *wirex.Inject
 		RootID:              config.C.General.Root.ID,
 	}))
 
+	e.Use(middleware.TenantWithConfig(middleware.TenantConfig{
+		AllowedPathPrefixes: allowedPrefixes,
+		HeaderKey:           config.C.Middleware.Tenant.HeaderKey,
+		ParseTenantID: func(c *gin.Context, code string) (string, error) {
+			return injector.M.Tenant.TenantAPI.TenantBIZ.GetIDByCode(c.Request.Context(), code)
+		},
+	}))
+
 	e.Use(middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
 		Enable:              config.C.Middleware.RateLimiter.Enable,
 		AllowedPathPrefixes: allowedPrefixes,
@@ -171,17 +194,35 @@ func useHTTPMiddlewares(_ context.Context, e *gin.Engine, injector *wirex.Inject
 		SkippedPathPrefixes: config.C.Middleware.Casbin.SkippedPathPrefixes,
 		Skipper: func(c *gin.Context) bool {
 			if config.C.Middleware.Casbin.Disable ||
-				util.FromIsRootUser(c.Request.Context()) {
+				util.FromIsRootUser(c.Request.Context()) ||
+				util.FromIsTenantAdmin(c.Request.Context()) {
 				return true
 			}
 			return false
 		},
-		GetEnforcer: func(c *gin.Context) *casbin.Enforcer {
+		GetEnforcer: func(c *gin.Context) *casbin.SyncedEnforcer {
 			return injector.M.RBAC.Casbinx.GetEnforcer()
 		},
 		GetSubjects: func(c *gin.Context) []string {
 			return util.FromUserCache(c.Request.Context()).RoleIDs
 		},
+		GetDomain: func(c *gin.Context) string {
+			tenantID, _ := util.FromTenantID(c.Request.Context())
+			return rbacschema.CasbinDomain(tenantID)
+		},
+		GetScopes: func(c *gin.Context) ([]string, bool) {
+			return util.FromAPIKeyScopes(c.Request.Context())
+		},
+		GetElevatedSubjects: func(c *gin.Context) []string {
+			return util.FromUserCache(c.Request.Context()).ElevatedRoleIDs
+		},
+		OnElevated: func(c *gin.Context, explain []string) {
+			ctx := logging.NewTag(c.Request.Context(), logging.TagKeyElevation)
+			logging.Context(ctx).Info("Role elevation used",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.Strings("policy", explain))
+		},
 	}))
 
 	if config.C.Util.Prometheus.Enable {
@@ -0,0 +1,44 @@
+package bootstrap
+
+import (
+	"context"
+	"strings"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/wirex"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
+)
+
+// Convert the plain password to the one sent by the clients (md5 hash with Security.Password.ClientHashed).
+func rootLoginPassword(password string) string {
+	if config.C.Security.Password.ClientHashed {
+		return hash.MD5String(password)
+	}
+	return password
+}
+
+// HashRootPassword generates the bcrypt hash of the root password for General.Root.Password in the configuration.
+func HashRootPassword(ctx context.Context, runCfg RunConfig, password string) (string, error) {
+	config.MustLoad(runCfg.WorkDir, strings.Split(runCfg.Configs, ",")...)
+	return hash.GeneratePassword(rootLoginPassword(password))
+}
+
+// SetRootPassword sets or rotates the root password stored in the database, which overrides the configuration.
+func SetRootPassword(ctx context.Context, runCfg RunConfig, password string) error {
+	config.MustLoad(runCfg.WorkDir, strings.Split(runCfg.Configs, ",")...)
+	config.C.General.WorkDir = runCfg.WorkDir
+	config.C.PreLoad()
+
+	injector, cleanInjectorFn, err := wirex.BuildInjector(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanInjectorFn()
+
+	if config.C.Storage.DB.AutoMigrate {
+		if err := injector.M.RBAC.AutoMigrate(ctx); err != nil {
+			return err
+		}
+	}
+	return injector.M.RBAC.LoginAPI.LoginBIZ.RootBIZ.SetPassword(ctx, rootLoginPassword(password))
+}
@@ -12,6 +12,7 @@ type Config struct {
 	General    General
 	Storage    Storage
 	Middleware Middleware
+	Security   Security
 	Util       Util
 	Dictionary Dictionary
 }
@@ -35,12 +36,14 @@ type General struct {
 		IdleTimeout     int    `default:"10"` // seconds
 		CertFile        string
 		KeyFile         string
+		TrustedProxies  []string // Proxies (IPs or CIDRs) trusted to set the client IP by X-Forwarded-For, none if empty
 	}
 	Root struct {
-		ID       string `default:"root"`
-		Username string `default:"admin"`
-		Password string
-		Name     string `default:"Admin"`
+		ID           string `default:"root"`
+		Username     string `default:"admin"`
+		Password     string // Bcrypt hash of the login password, overridden by the one set with `root password` command
+		Name         string `default:"Admin"`
+		DisableLogin bool   // Disable the login of the root user (including the issued tokens)
 	}
 }
 
@@ -83,11 +86,16 @@ type Storage struct {
 
 type Util struct {
 	Captcha struct {
-		Length    int    `default:"4"`
-		Width     int    `default:"400"`
-		Height    int    `default:"160"`
-		CacheType string `default:"memory"` // memory/redis
-		Redis     struct {
+		Type                 string `default:"digits"` // digits/math/audio/slider
+		Length               int    `default:"4"`
+		Width                int    `default:"400"`
+		Height               int    `default:"160"`
+		Expired              int    `default:"600"` // seconds
+		Language             string `default:"en"`  // Language of the audio captcha (en/ja/ru/zh)
+		SliderTolerance      int    `default:"5"`   // Maximum distance of the slider answer (pixels)
+		RequireAfterFailures int    // Only required after n failed logins of the username or client IP (0 means always)
+		CacheType            string `default:"memory"` // memory/redis
+		Redis                struct {
 			Addr      string
 			Username  string
 			Password  string
@@ -104,10 +112,19 @@ type Util struct {
 		LogMethods     []string
 		DefaultCollect bool
 	}
+	Mail struct {
+		Host     string
+		Port     int `default:"25"`
+		FromName string
+		FromMail string
+		Username string // Anonymous if empty
+		Password string
+	}
 }
 
 type Dictionary struct {
-	UserCacheExp int `default:"4"` // hours
+	UserCacheExp          int `default:"4"`  // hours
+	UserRoleSweepInterval int `default:"60"` // seconds, clear the user caches when the time-bounded roles start or end
 }
 
 func (c *Config) IsDebug() bool {
@@ -1,8 +1,14 @@
 package config
 
 const (
-	CacheNSForUser = "user"
-	CacheNSForRole = "role"
+	CacheNSForUser              = "user"
+	CacheNSForRole              = "role"
+	CacheNSForMFA               = "mfa"
+	CacheNSForLoginFailure      = "login-failure"
+	CacheNSForOIDC              = "oidc"
+	CacheNSForPasswordReset     = "password-reset"
+	CacheNSForEmailVerification = "email-verification"
+	CacheNSForTenant            = "tenant"
 )
 
 const (
@@ -12,5 +18,20 @@ const (
 const (
 	ErrInvalidTokenID            = "com.invalid.token"
 	ErrInvalidCaptchaID          = "com.invalid.captcha"
+	ErrCaptchaRequiredID         = "com.captcha.required"
 	ErrInvalidUsernameOrPassword = "com.invalid.username-or-password"
+	ErrInvalidMFACodeID          = "com.invalid.mfa-code"
+	ErrInvalidMFATokenID         = "com.invalid.mfa-token"
+	ErrAccountLockedID           = "com.account.locked"
+	ErrTooManyLoginAttemptsID    = "com.login.too-many-attempts"
+	ErrPasswordPolicyID          = "com.password.policy"
+	ErrMustChangePasswordID      = "com.password.must-change"
+	ErrInvalidOIDCStateID        = "com.invalid.oidc-state"
+	ErrOIDCLoginFailedID         = "com.oidc.login-failed"
+	ErrInvalidResetTokenID       = "com.invalid.reset-token"
+	ErrTooManyResetRequestsID    = "com.password.too-many-resets"
+	ErrInvalidEmailCodeID        = "com.invalid.email-code"
+	ErrImpersonationBlockedID    = "com.impersonation.blocked"
+	ErrTenantNotFoundID          = "com.tenant.not-found"
+	ErrTenantSuspendedID         = "com.tenant.suspended"
 )
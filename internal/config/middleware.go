@@ -35,10 +35,13 @@ type Middleware struct {
 	Auth struct {
 		Disable             bool
 		SkippedPathPrefixes []string
-		SigningMethod       string `default:"HS512"`    // HS256/HS384/HS512
-		SigningKey          string `default:"XnEsT0S@"` // secret key
-		OldSigningKey       string // old secret key (for migration)
-		Expired             int    `default:"86400"` // seconds
+		SigningMethod       string   `default:"HS512"`    // HS256/HS384/HS512/RS256/RS384/RS512/PS256/PS384/PS512/ES256/ES384/ES512/EdDSA
+		SigningKey          string   `default:"XnEsT0S@"` // secret key (for HMAC)
+		OldSigningKey       string   // old secret key (for migration)
+		PrivateKeyFile      string   // PEM private key file (for RSA/ECDSA/EdDSA, relative to workdir)
+		OldPublicKeyFiles   []string // PEM public key files of retired key pairs (for migration)
+		Expired             int      `default:"7200"`   // seconds (access token)
+		RefreshExpired      int      `default:"604800"` // seconds (refresh token)
 		Store               struct {
 			Type      string `default:"memory"` // memory/badger/redis
 			Delimiter string `default:":"`      // delimiter for key
@@ -56,6 +59,9 @@ type Middleware struct {
 			}
 		}
 	}
+	Tenant struct {
+		HeaderKey string `default:"X-Tenant"` // header of the tenant code (the default tenant if empty)
+	}
 	RateLimiter struct {
 		Enable              bool
 		SkippedPathPrefixes []string
@@ -82,7 +88,8 @@ type Middleware struct {
 		LoadThread          int    `default:"2"`
 		AutoLoadInterval    int    `default:"3"` // seconds
 		ModelFile           string `default:"rbac_model.conf"`
-		GenPolicyFile       string `default:"gen_rbac_policy.csv"`
+		ChangeRetention     int    `default:"86400"` // seconds, the older policy change log is deleted on startup
+		ChangeLookback      int    `default:"60"`    // seconds, the changes may be committed out of order within the window
 	}
 	Static struct {
 		Dir string // Static files directory (From command arguments)
@@ -0,0 +1,118 @@
+package config
+
+type Security struct {
+	MFA struct {
+		Issuer           string `default:"ginadmin"` // Issuer shown in the authenticator app
+		Skew             int    `default:"1"`        // Number of time steps (30s) accepted before and after the current one
+		ChallengeExpired int    `default:"300"`      // Expired time of the login challenge (seconds)
+		MaxAttempts      int    `default:"5"`        // Maximum code attempts of a login challenge
+		RecoveryCodes    int    `default:"10"`       // Number of one-time recovery codes
+	}
+	Lockout struct {
+		Enable             bool `default:"true"`
+		FailureWindow      int  `default:"900"`   // Failures are counted within the window (seconds)
+		DelayAfterFailures int  `default:"3"`     // Start delaying the next attempt after n failures
+		DelayBase          int  `default:"1"`     // Delay of the first delayed attempt, doubled on each failure (seconds)
+		MaxDelay           int  `default:"60"`    // seconds
+		MaxFailures        int  `default:"5"`     // Lock the account after n failures
+		MaxIPFailures      int  `default:"20"`    // Lock the client IP after n failures (any username)
+		LockDuration       int  `default:"900"`   // Lock time, doubled on each subsequent lock (seconds)
+		MaxLockDuration    int  `default:"86400"` // seconds
+	}
+	Password struct {
+		// The client sends the MD5 hash instead of the plain password (the default of the frontend),
+		// the length and character classes can only be checked with plain passwords.
+		ClientHashed     bool `default:"true"`
+		MinLength        int  `default:"8"`
+		RequireUppercase bool
+		RequireLowercase bool
+		RequireDigit     bool
+		RequireSymbol    bool
+		HistorySize      int `default:"5"` // Disallow reusing the last n passwords
+		MaxAge           int // Password expires after n days, the user must change it (0 means never)
+	}
+	OIDC struct {
+		StateExpired int `default:"600"` // Expired time of the authorization request (seconds)
+		Providers    []OIDCProvider
+	}
+	LDAP struct {
+		Enable             bool
+		URL                string // ldap://host:389 or ldaps://host:636
+		StartTLS           bool
+		InsecureSkipVerify bool
+		Timeout            int    `default:"10"` // seconds
+		BindDN             string // Service account to search the users and groups (anonymous if empty)
+		BindPassword       string
+		BaseDN             string
+		UserFilter         string `default:"(uid=%s)"` // %s is replaced by the username
+		UsernameAttr       string `default:"uid"`
+		NameAttr           string `default:"cn"`
+		EmailAttr          string `default:"mail"`
+		PhoneAttr          string `default:"telephoneNumber"`
+		MemberOfAttr       string `default:"memberOf"`
+		GroupBaseDN        string // Search the groups by member when set, otherwise the groups are taken from MemberOfAttr
+		GroupFilter        string `default:"(member=%s)"` // %s is replaced by the user DN
+		GroupNameAttr      string `default:"cn"`
+		DefaultRoleCodes   []string
+		GroupRoles         []LDAPGroupRole
+	}
+	PasswordReset struct {
+		Enable        bool
+		Expired       int    `default:"1800"` // Lifetime of the reset token (seconds)
+		URL           string // Link of the reset page, {token} is replaced by the token (the token is sent as a code if empty)
+		MaxRequests   int    `default:"5"`    // Maximum reset requests of an account or a client IP in the window
+		RequestWindow int    `default:"3600"` // seconds
+	}
+	EmailVerification struct {
+		Expired        int `default:"900"` // Lifetime of the confirmation code (seconds)
+		MaxAttempts    int `default:"5"`   // The code is invalidated after the failed attempts
+		ResendInterval int `default:"60"`  // Minimum interval to send a new code (seconds)
+	}
+	APIKey struct {
+		Prefix       string `default:"ga_"` // Prefix of the keys, which distinguishes them from the JWTs
+		MaxPerUser   int    `default:"20"`
+		MaxExpiresIn int    `default:"365"` // Maximum lifetime of the keys (days, 0 means unlimited)
+	}
+	Impersonation struct {
+		Enable              bool
+		RoleCodes           []string // Roles allowed to impersonate other users besides root
+		Expired             int      `default:"1800"` // Lifetime of the impersonation token, which cannot be refreshed (seconds)
+		ReadOnly            bool     // Only GET requests are allowed during impersonation
+		BlockedPathPrefixes []string `default:"[\"POST /api/v1/users\",\"PUT /api/v1/users\",\"DELETE /api/v1/users\",\"PATCH /api/v1/users\",\"/api/v1/roles\",\"/api/v1/menus\",\"/api/v1/permissions\",\"/api/v1/tenants\",\"/api/v1/loggers\"]"` // Blocked during impersonation, optionally prefixed by the method (e.g. "DELETE /api/v1/users")
+	}
+	Elevation struct {
+		Enable            bool
+		RoleCodes         []string // Roles which can be requested for a bounded window (all roles if empty)
+		ApproverRoleCodes []string // Roles allowed to approve the requests besides root and the tenant administrator
+		MaxDuration       int      `default:"480"` // Maximum window of the elevation (minutes)
+	}
+}
+
+// Roles of the users in the LDAP group
+type LDAPGroupRole struct {
+	Group     string // DN or name of the group (case-insensitive, "*" matches any characters)
+	RoleCodes []string
+}
+
+// OpenID Connect identity provider for the external login
+type OIDCProvider struct {
+	Name             string // Unique name used in the URL path
+	DisplayName      string
+	Issuer           string
+	ClientID         string
+	ClientSecret     string
+	RedirectURL      string // Frontend page that receives the code and state, then calls the callback API
+	Scopes           []string
+	UsernameClaim    string   // Claim used as the username of the provisioned users (default: preferred_username)
+	AutoProvision    bool     // Create the user on the first login
+	DefaultRoleCodes []string // Roles of the provisioned users
+}
+
+func (c *Security) GetOIDCProvider(name string) (OIDCProvider, bool) {
+	for _, item := range c.OIDC.Providers {
+		if item.Name == name {
+			return item, true
+		}
+	}
+	return OIDCProvider{}, false
+}
@@ -3,7 +3,9 @@ package mods
 import (
 	"context"
 
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 )
@@ -16,16 +18,27 @@ const (
 var Set = wire.NewSet(
 	wire.Struct(new(Mods), "*"),
 	rbac.Set,
+	org.Set,
+	tenant.Set,
 )
 
 type Mods struct {
-	RBAC *rbac.RBAC
+	RBAC   *rbac.RBAC
+	ORG    *org.ORG
+	Tenant *tenant.Tenant
 }
 
 func (a *Mods) Init(ctx context.Context) error {
+	// The tenants are required to initialize the data of each tenant
+	if err := a.Tenant.Init(ctx); err != nil {
+		return err
+	}
 	if err := a.RBAC.Init(ctx); err != nil {
 		return err
 	}
+	if err := a.ORG.Init(ctx); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -43,6 +56,12 @@ func (a *Mods) RegisterRouters(ctx context.Context, e *gin.Engine) error {
 	if err := a.RBAC.RegisterV1Routers(ctx, v1); err != nil {
 		return err
 	}
+	if err := a.ORG.RegisterV1Routers(ctx, v1); err != nil {
+		return err
+	}
+	if err := a.Tenant.RegisterV1Routers(ctx, v1); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -51,6 +70,12 @@ func (a *Mods) Release(ctx context.Context) error {
 	if err := a.RBAC.Release(ctx); err != nil {
 		return err
 	}
+	if err := a.ORG.Release(ctx); err != nil {
+		return err
+	}
+	if err := a.Tenant.Release(ctx); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Department of the organization
+type Department struct {
+	DepartmentBIZ *biz.Department
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Query department tree data
+// @Param name query string false "Name of department"
+// @Param status query string false "Status of department (disabled, enabled)"
+// @Success 200 {object} util.ResponseResult{data=[]schema.Department}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments [get]
+func (a *Department) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.DepartmentQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.DepartmentBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Get department record by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult{data=schema.Department}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments/{id} [get]
+func (a *Department) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.DepartmentBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Create department record
+// @Param body body schema.DepartmentForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.Department}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments [post]
+func (a *Department) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.DepartmentForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.DepartmentBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Update department record by ID
+// @Param id path string true "unique id"
+// @Param body body schema.DepartmentForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments/{id} [put]
+func (a *Department) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.DepartmentForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.DepartmentBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Delete department record by ID (including the children)
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments/{id} [delete]
+func (a *Department) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.DepartmentBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Query the members of the department
+// @Param id path string true "unique id"
+// @Param current query int true "pagination index" default(1)
+// @Param pageSize query int true "pagination size" default(10)
+// @Success 200 {object} util.ResponseResult{data=[]schema.DepartmentUser}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments/{id}/users [get]
+func (a *Department) QueryUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.DepartmentUserQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.DepartmentBIZ.QueryUsers(ctx, c.Param("id"), params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Add the users to the department
+// @Param id path string true "unique id"
+// @Param body body schema.DepartmentUserForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments/{id}/users [post]
+func (a *Department) AddUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.DepartmentUserForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.DepartmentBIZ.AddUsers(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags DepartmentAPI
+// @Security ApiKeyAuth
+// @Summary Remove the user from the department
+// @Param id path string true "unique id"
+// @Param user_id path string true "user id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/departments/{id}/users/{user_id} [delete]
+func (a *Department) RemoveUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.DepartmentBIZ.RemoveUser(ctx, c.Param("id"), c.Param("user_id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
@@ -0,0 +1,126 @@
+package biz
+
+import (
+	"context"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	rbacdal "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	rbacschema "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+// Data scope permissions of the roles
+type DataScope struct {
+	RoleDAL           *rbacdal.Role
+	DepartmentDAL     *dal.Department
+	DepartmentUserDAL *dal.DepartmentUser
+}
+
+// Get the data scope of the current user merged from the scopes of the enabled roles, ok is false if all data is
+// visible (the root user, the administrator of the tenant, a request without user or a role with the scope of all
+// data).
+func (a *DataScope) Get(ctx context.Context) (util.DataScope, bool, error) {
+	var dataScope util.DataScope
+
+	userID := util.FromUserID(ctx)
+	if userID == "" || util.FromIsRootUser(ctx) || util.FromIsTenantAdmin(ctx) {
+		return dataScope, false, nil
+	}
+
+	roleIDs := util.FromUserCache(ctx).RoleIDs
+	if len(roleIDs) == 0 {
+		dataScope.UserID = userID
+		return dataScope, true, nil
+	}
+
+	roleResult, err := a.RoleDAL.Query(ctx, rbacschema.RoleQueryParam{
+		InIDs:  roleIDs,
+		Status: rbacschema.RoleStatusEnabled,
+	}, rbacschema.RoleQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "data_scope", "data_scope_department_ids"},
+		},
+	})
+	if err != nil {
+		return dataScope, false, err
+	}
+
+	var (
+		departmentIDs []string
+		own           bool
+		children      bool
+	)
+	for _, role := range roleResult.Data {
+		switch role.DataScope {
+		case rbacschema.RoleDataScopeAll, "":
+			return dataScope, false, nil
+		case rbacschema.RoleDataScopeSelf:
+			dataScope.UserID = userID
+		case rbacschema.RoleDataScopeDepartment:
+			own = true
+		case rbacschema.RoleDataScopeDepartmentAndChildren:
+			own = true
+			children = true
+		case rbacschema.RoleDataScopeCustom:
+			departmentIDs = append(departmentIDs, role.DataScopeDepartmentIDs...)
+		}
+	}
+
+	if own {
+		ownIDs, err := a.getOwnDepartmentIDs(ctx, userID, children)
+		if err != nil {
+			return dataScope, false, err
+		}
+		departmentIDs = append(departmentIDs, ownIDs...)
+	}
+
+	seen := make(map[string]bool, len(departmentIDs))
+	for _, id := range departmentIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		dataScope.DepartmentIDs = append(dataScope.DepartmentIDs, id)
+	}
+	return dataScope, true, nil
+}
+
+// Get the departments of the user, including all of their children if required.
+func (a *DataScope) getOwnDepartmentIDs(ctx context.Context, userID string, children bool) ([]string, error) {
+	departmentUserResult, err := a.DepartmentUserDAL.Query(ctx, schema.DepartmentUserQueryParam{
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	departmentIDs := departmentUserResult.Data.ToDepartmentIDs()
+	if !children || len(departmentIDs) == 0 {
+		return departmentIDs, nil
+	}
+
+	childResult, err := a.DepartmentDAL.Query(ctx, schema.DepartmentQueryParam{
+		InAncestorIDs: departmentIDs,
+	}, schema.DepartmentQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(departmentIDs, childResult.Data.ToIDs()...), nil
+}
+
+// Create a new context with the data scope of the current user, the queries with the data scope helper of the
+// data access objects are restricted to the visible records.
+func (a *DataScope) NewContext(ctx context.Context) (context.Context, error) {
+	dataScope, ok, err := a.Get(ctx)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return ctx, nil
+	}
+	return util.NewDataScope(ctx, dataScope), nil
+}
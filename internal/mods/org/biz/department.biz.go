@@ -0,0 +1,324 @@
+package biz
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	rbacdal "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+// Department of the organization
+type Department struct {
+	Trans             *util.Trans
+	DepartmentDAL     *dal.Department
+	DepartmentUserDAL *dal.DepartmentUser
+	UserDAL           *rbacdal.User
+}
+
+// Query departments from the data access object based on the provided parameters and options.
+func (a *Department) Query(ctx context.Context, params schema.DepartmentQueryParam) (*schema.DepartmentQueryResult, error) {
+	params.Pagination = false
+
+	result, err := a.DepartmentDAL.Query(ctx, params, schema.DepartmentQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: schema.DepartmentsOrderParams,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if params.LikeName != "" {
+		result.Data, err = a.appendChildren(ctx, result.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Data = result.Data.ToTree()
+	return result, nil
+}
+
+func (a *Department) appendChildren(ctx context.Context, data schema.Departments) (schema.Departments, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	existsInData := func(id string) bool {
+		for _, item := range data {
+			if item.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, item := range data {
+		childResult, err := a.DepartmentDAL.Query(ctx, schema.DepartmentQueryParam{
+			ParentPathPrefix: item.ParentPath + item.ID + util.TreePathDelimiter,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range childResult.Data {
+			if existsInData(child.ID) {
+				continue
+			}
+			data = append(data, child)
+		}
+	}
+
+	if parentIDs := data.SplitParentIDs(); len(parentIDs) > 0 {
+		parentResult, err := a.DepartmentDAL.Query(ctx, schema.DepartmentQueryParam{
+			InIDs: parentIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parentResult.Data {
+			if existsInData(p.ID) {
+				continue
+			}
+			data = append(data, p)
+		}
+	}
+	sort.Sort(data)
+
+	return data, nil
+}
+
+// Get the specified department from the data access object.
+func (a *Department) Get(ctx context.Context, id string) (*schema.Department, error) {
+	department, err := a.DepartmentDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if department == nil {
+		return nil, errors.NotFound("", "Department not found")
+	}
+	return department, nil
+}
+
+// Create a new department in the data access object.
+func (a *Department) Create(ctx context.Context, formItem *schema.DepartmentForm) (*schema.Department, error) {
+	department := &schema.Department{
+		ID:        util.NewXID(),
+		CreatedAt: time.Now(),
+	}
+
+	if parentID := formItem.ParentID; parentID != "" {
+		parent, err := a.DepartmentDAL.Get(ctx, parentID)
+		if err != nil {
+			return nil, err
+		} else if parent == nil {
+			return nil, errors.NotFound("", "Parent not found")
+		}
+		department.ParentPath = parent.ParentPath + parent.ID + util.TreePathDelimiter
+	}
+
+	if exists, err := a.DepartmentDAL.ExistsCodeByParentID(ctx, formItem.Code, formItem.ParentID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errors.BadRequest("", "Department code already exists at the same level")
+	}
+
+	if err := formItem.FillTo(department); err != nil {
+		return nil, err
+	}
+
+	if err := a.DepartmentDAL.Create(ctx, department); err != nil {
+		return nil, err
+	}
+	return department, nil
+}
+
+// Update the specified department in the data access object.
+func (a *Department) Update(ctx context.Context, id string, formItem *schema.DepartmentForm) error {
+	department, err := a.DepartmentDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if department == nil {
+		return errors.NotFound("", "Department not found")
+	}
+
+	oldParentPath := department.ParentPath
+	oldStatus := department.Status
+	var childData schema.Departments
+	if department.ParentID != formItem.ParentID {
+		if parentID := formItem.ParentID; parentID != "" {
+			parent, err := a.DepartmentDAL.Get(ctx, parentID)
+			if err != nil {
+				return err
+			} else if parent == nil {
+				return errors.NotFound("", "Parent not found")
+			} else if parent.ID == id || strings.HasPrefix(parent.ParentPath, oldParentPath+id+util.TreePathDelimiter) {
+				return errors.BadRequest("", "Department cannot be moved under itself or its children")
+			}
+			department.ParentPath = parent.ParentPath + parent.ID + util.TreePathDelimiter
+		} else {
+			department.ParentPath = ""
+		}
+
+		childResult, err := a.DepartmentDAL.Query(ctx, schema.DepartmentQueryParam{
+			ParentPathPrefix: oldParentPath + department.ID + util.TreePathDelimiter,
+		}, schema.DepartmentQueryOptions{
+			QueryOptions: util.QueryOptions{
+				SelectFields: []string{"id", "parent_path"},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		childData = childResult.Data
+	}
+
+	if department.Code != formItem.Code || department.ParentID != formItem.ParentID {
+		if exists, err := a.DepartmentDAL.ExistsCodeByParentID(ctx, formItem.Code, formItem.ParentID); err != nil {
+			return err
+		} else if exists {
+			return errors.BadRequest("", "Department code already exists at the same level")
+		}
+	}
+
+	if err := formItem.FillTo(department); err != nil {
+		return err
+	}
+	department.UpdatedAt = time.Now()
+
+	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if oldStatus != formItem.Status {
+			oldPath := oldParentPath + department.ID + util.TreePathDelimiter
+			if err := a.DepartmentDAL.UpdateStatusByParentPath(ctx, oldPath, formItem.Status); err != nil {
+				return err
+			}
+		}
+
+		for _, child := range childData {
+			oldPath := oldParentPath + department.ID + util.TreePathDelimiter
+			newPath := department.ParentPath + department.ID + util.TreePathDelimiter
+			err := a.DepartmentDAL.UpdateParentPath(ctx, child.ID, strings.Replace(child.ParentPath, oldPath, newPath, 1))
+			if err != nil {
+				return err
+			}
+		}
+
+		return a.DepartmentDAL.Update(ctx, department)
+	})
+}
+
+// Delete the specified department and its children from the data access object.
+func (a *Department) Delete(ctx context.Context, id string) error {
+	department, err := a.DepartmentDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if department == nil {
+		return errors.NotFound("", "Department not found")
+	}
+
+	childResult, err := a.DepartmentDAL.Query(ctx, schema.DepartmentQueryParam{
+		ParentPathPrefix: department.ParentPath + department.ID + util.TreePathDelimiter,
+	}, schema.DepartmentQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.delete(ctx, id); err != nil {
+			return err
+		}
+
+		for _, child := range childResult.Data {
+			if err := a.delete(ctx, child.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (a *Department) delete(ctx context.Context, id string) error {
+	if err := a.DepartmentDAL.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := a.DepartmentUserDAL.DeleteByDepartmentID(ctx, id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Query the members of the specified department.
+func (a *Department) QueryUsers(ctx context.Context, id string, params schema.DepartmentUserQueryParam) (*schema.DepartmentUserQueryResult, error) {
+	if exists, err := a.DepartmentDAL.Exists(ctx, id); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.NotFound("", "Department not found")
+	}
+
+	params.Pagination = true
+	params.DepartmentID = id
+	return a.DepartmentUserDAL.Query(ctx, params, schema.DepartmentUserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "a.created_at", Direction: util.DESC},
+			},
+		},
+		JoinUser: true,
+	})
+}
+
+// Add the users to the specified department, the existing members are skipped.
+func (a *Department) AddUsers(ctx context.Context, id string, formItem *schema.DepartmentUserForm) error {
+	if exists, err := a.DepartmentDAL.Exists(ctx, id); err != nil {
+		return err
+	} else if !exists {
+		return errors.NotFound("", "Department not found")
+	}
+
+	for _, userID := range formItem.UserIDs {
+		if exists, err := a.UserDAL.Exists(ctx, userID); err != nil {
+			return err
+		} else if !exists {
+			return errors.NotFound("", "User not found")
+		}
+	}
+
+	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+		for _, userID := range formItem.UserIDs {
+			if exists, err := a.DepartmentUserDAL.Exists(ctx, id, userID); err != nil {
+				return err
+			} else if exists {
+				continue
+			}
+
+			departmentUser := &schema.DepartmentUser{
+				ID:           util.NewXID(),
+				DepartmentID: id,
+				UserID:       userID,
+				CreatedAt:    time.Now(),
+			}
+			if err := a.DepartmentUserDAL.Create(ctx, departmentUser); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove the user from the specified department.
+func (a *Department) RemoveUser(ctx context.Context, id, userID string) error {
+	if exists, err := a.DepartmentUserDAL.Exists(ctx, id, userID); err != nil {
+		return err
+	} else if !exists {
+		return errors.NotFound("", "User is not a member of the department")
+	}
+	return a.DepartmentUserDAL.Delete(ctx, id, userID)
+}
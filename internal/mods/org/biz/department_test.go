@@ -0,0 +1,125 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	rbacdal "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	rbacschema "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDepartment(t *testing.T) *Department {
+	db := newTestDB(t, new(schema.Department), new(schema.DepartmentUser), new(rbacschema.User))
+	mustCreate(t, db, &rbacschema.User{ID: "u1", Username: "u1", Source: rbacschema.UserSourceLocal, Status: rbacschema.UserStatusActivated})
+	return &Department{
+		Trans:             &util.Trans{DB: db},
+		DepartmentDAL:     &dal.Department{DB: db},
+		DepartmentUserDAL: &dal.DepartmentUser{DB: db},
+		UserDAL:           &rbacdal.User{DB: db},
+	}
+}
+
+func isNotFound(err error) bool {
+	return err != nil && errors.FromError(err).Code == 404
+}
+
+func TestDepartment(t *testing.T) {
+	departmentBIZ := newTestDepartment(t)
+	ctx := context.Background()
+
+	formItem := &schema.DepartmentForm{
+		Code:        "rd",
+		Name:        "R&D",
+		Description: "Research and development",
+		Sequence:    9,
+		Status:      schema.DepartmentStatusEnabled,
+	}
+	department, err := departmentBIZ.Create(ctx, formItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, department.ID)
+	assert.Equal(t, formItem.Code, department.Code)
+	assert.Equal(t, formItem.Name, department.Name)
+	assert.Equal(t, formItem.Description, department.Description)
+	assert.Equal(t, formItem.Sequence, department.Sequence)
+	assert.Equal(t, formItem.Status, department.Status)
+
+	_, err = departmentBIZ.Create(ctx, formItem)
+	assert.Equal(t, int32(400), errors.FromError(err).Code, "the code is unique at the same level")
+
+	child, err := departmentBIZ.Create(ctx, &schema.DepartmentForm{
+		Code:     "backend",
+		Name:     "Backend",
+		Status:   schema.DepartmentStatusEnabled,
+		ParentID: department.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, department.ID+util.TreePathDelimiter, child.ParentPath)
+
+	result, err := departmentBIZ.Query(ctx, schema.DepartmentQueryParam{})
+	if assert.NoError(t, err) && assert.Len(t, result.Data, 1) {
+		assert.Len(t, *result.Data[0].Children, 1)
+	}
+
+	// A department cannot be moved under its children
+	err = departmentBIZ.Update(ctx, department.ID, &schema.DepartmentForm{
+		Code:     department.Code,
+		Name:     department.Name,
+		Status:   department.Status,
+		ParentID: child.ID,
+	})
+	assert.Equal(t, int32(400), errors.FromError(err).Code)
+
+	// The status is changed with the children
+	formItem.Name = "R&D 1"
+	formItem.Status = schema.DepartmentStatusDisabled
+	assert.NoError(t, departmentBIZ.Update(ctx, department.ID, formItem))
+	getChild, err := departmentBIZ.Get(ctx, child.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, schema.DepartmentStatusDisabled, getChild.Status)
+	}
+
+	// The children and the members are deleted with the department
+	assert.NoError(t, departmentBIZ.AddUsers(ctx, child.ID, &schema.DepartmentUserForm{UserIDs: []string{"u1"}}))
+	assert.NoError(t, departmentBIZ.Delete(ctx, department.ID))
+	_, err = departmentBIZ.Get(ctx, department.ID)
+	assert.True(t, isNotFound(err), err)
+	_, err = departmentBIZ.Get(ctx, child.ID)
+	assert.True(t, isNotFound(err), err)
+	exists, err := departmentBIZ.DepartmentUserDAL.Exists(ctx, child.ID, "u1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestDepartmentUsers(t *testing.T) {
+	departmentBIZ := newTestDepartment(t)
+	ctx := context.Background()
+
+	department, err := departmentBIZ.Create(ctx, &schema.DepartmentForm{Code: "rd", Name: "R&D", Status: schema.DepartmentStatusEnabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The existing members are skipped
+	formItem := &schema.DepartmentUserForm{UserIDs: []string{"u1"}}
+	assert.NoError(t, departmentBIZ.AddUsers(ctx, department.ID, formItem))
+	assert.NoError(t, departmentBIZ.AddUsers(ctx, department.ID, formItem))
+	result, err := departmentBIZ.QueryUsers(ctx, department.ID, schema.DepartmentUserQueryParam{})
+	if assert.NoError(t, err) && assert.Len(t, result.Data, 1) {
+		assert.Equal(t, "u1", result.Data[0].UserID)
+	}
+
+	assert.True(t, isNotFound(departmentBIZ.AddUsers(ctx, department.ID, &schema.DepartmentUserForm{UserIDs: []string{"missing"}})))
+	assert.True(t, isNotFound(departmentBIZ.AddUsers(ctx, "missing", formItem)))
+
+	assert.NoError(t, departmentBIZ.RemoveUser(ctx, department.ID, "u1"))
+	assert.True(t, isNotFound(departmentBIZ.RemoveUser(ctx, department.ID, "u1")))
+}
@@ -0,0 +1,49 @@
+package dal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Columns of the records restricted by the data scope
+type DataScopeColumns struct {
+	DepartmentColumn string // Department of the record (From Department.ID)
+	UserColumn       string // Owner of the record (From User.ID)
+}
+
+// Restrict the query to the records visible in the data scope of the context, the query is returned as is if all data
+// is visible. A record belongs to the departments by the department column, or by the memberships of its owner if the
+// department column is empty.
+func WithDataScope(ctx context.Context, defDB, db *gorm.DB, columns DataScopeColumns) *gorm.DB {
+	dataScope, ok := util.FromDataScope(ctx)
+	if !ok {
+		return db
+	}
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if v := dataScope.DepartmentIDs; len(v) > 0 {
+		if columns.DepartmentColumn != "" {
+			conds = append(conds, columns.DepartmentColumn+" IN (?)")
+			args = append(args, v)
+		} else if columns.UserColumn != "" {
+			userQuery := GetDepartmentUserDB(ctx, defDB).Where("department_id IN (?)", v).Select("user_id")
+			conds = append(conds, columns.UserColumn+" IN (?)")
+			args = append(args, userQuery)
+		}
+	}
+	if v := dataScope.UserID; v != "" && columns.UserColumn != "" {
+		conds = append(conds, columns.UserColumn+" = ?")
+		args = append(args, v)
+	}
+
+	if len(conds) == 0 {
+		return db.Where("1 = 0")
+	}
+	return db.Where("("+strings.Join(conds, " OR ")+")", args...)
+}
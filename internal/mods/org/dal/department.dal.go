@@ -0,0 +1,136 @@
+package dal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get department storage instance
+func GetDepartmentDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.Department))
+}
+
+// Department of the organization
+type Department struct {
+	DB *gorm.DB
+}
+
+// Query departments from the database based on the provided parameters and options.
+func (a *Department) Query(ctx context.Context, params schema.DepartmentQueryParam, opts ...schema.DepartmentQueryOptions) (*schema.DepartmentQueryResult, error) {
+	var opt schema.DepartmentQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetDepartmentDB(ctx, a.DB)
+
+	if v := params.InIDs; len(v) > 0 {
+		db = db.Where("id IN ?", v)
+	}
+	if v := params.LikeName; len(v) > 0 {
+		db = db.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("status = ?", v)
+	}
+	if v := params.ParentID; len(v) > 0 {
+		db = db.Where("parent_id = ?", v)
+	}
+	if v := params.ParentPathPrefix; len(v) > 0 {
+		db = db.Where("parent_path LIKE ?", v+"%")
+	}
+	if v := params.InAncestorIDs; len(v) > 0 {
+		var (
+			conds []string
+			args  []interface{}
+		)
+		for _, id := range v {
+			conds = append(conds, "parent_path LIKE ? OR parent_path LIKE ?")
+			args = append(args, id+util.TreePathDelimiter+"%", "%"+util.TreePathDelimiter+id+util.TreePathDelimiter+"%")
+		}
+		db = db.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+
+	var list schema.Departments
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.DepartmentQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+// Get the specified department from the database.
+func (a *Department) Get(ctx context.Context, id string, opts ...schema.DepartmentQueryOptions) (*schema.Department, error) {
+	var opt schema.DepartmentQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.Department)
+	ok, err := util.FindOne(ctx, GetDepartmentDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Checks if the specified department exists in the database.
+func (a *Department) Exists(ctx context.Context, id string) (bool, error) {
+	ok, err := util.Exists(ctx, GetDepartmentDB(ctx, a.DB).Where("id=?", id))
+	return ok, errors.WithStack(err)
+}
+
+// Count the departments of the specified IDs in the database.
+func (a *Department) CountByIDs(ctx context.Context, ids []string) (int64, error) {
+	var count int64
+	result := GetDepartmentDB(ctx, a.DB).Where("id IN ?", ids).Count(&count)
+	return count, errors.WithStack(result.Error)
+}
+
+// Checks if a department with the specified `code` exists under the specified `parentID` in the database.
+func (a *Department) ExistsCodeByParentID(ctx context.Context, code, parentID string) (bool, error) {
+	ok, err := util.Exists(ctx, GetDepartmentDB(ctx, a.DB).Where("code=? AND parent_id=?", code, parentID))
+	return ok, errors.WithStack(err)
+}
+
+// Create a new department.
+func (a *Department) Create(ctx context.Context, item *schema.Department) error {
+	result := GetDepartmentDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Update the specified department in the database.
+func (a *Department) Update(ctx context.Context, item *schema.Department) error {
+	result := GetDepartmentDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+// Delete the specified department from the database.
+func (a *Department) Delete(ctx context.Context, id string) error {
+	result := GetDepartmentDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.Department))
+	return errors.WithStack(result.Error)
+}
+
+// Updates the parent path of the specified department.
+func (a *Department) UpdateParentPath(ctx context.Context, id, parentPath string) error {
+	result := GetDepartmentDB(ctx, a.DB).Where("id=?", id).Update("parent_path", parentPath)
+	return errors.WithStack(result.Error)
+}
+
+// Updates the status of all departments whose parent path starts with the provided parent path.
+func (a *Department) UpdateStatusByParentPath(ctx context.Context, parentPath, status string) error {
+	result := GetDepartmentDB(ctx, a.DB).Where("parent_path like ?", parentPath+"%").Update("status", status)
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,97 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	rbacschema "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get department user storage instance
+func GetDepartmentUserDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.DepartmentUser))
+}
+
+// Membership of the users in the departments
+type DepartmentUser struct {
+	DB *gorm.DB
+}
+
+// Query department users from the database based on the provided parameters and options.
+func (a *DepartmentUser) Query(ctx context.Context, params schema.DepartmentUserQueryParam, opts ...schema.DepartmentUserQueryOptions) (*schema.DepartmentUserQueryResult, error) {
+	var opt schema.DepartmentUserQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetDepartmentUserDB(ctx, a.DB).Table(fmt.Sprintf("%s AS a", new(schema.DepartmentUser).TableName()))
+	selects := []string{"a.*"}
+	if opt.JoinDepartment {
+		db = db.Joins(fmt.Sprintf("left join %s b on a.department_id=b.id", new(schema.Department).TableName()))
+		selects = append(selects, "b.name as department_name")
+	}
+	if opt.JoinUser {
+		db = db.Joins(fmt.Sprintf("left join %s c on a.user_id=c.id", new(rbacschema.User).TableName()))
+		selects = append(selects, "c.username as username", "c.name as user_name")
+	}
+	if len(selects) > 1 {
+		db = db.Select(selects)
+	}
+
+	if v := params.DepartmentID; len(v) > 0 {
+		db = db.Where("a.department_id = ?", v)
+	}
+	if v := params.InDepartmentIDs; len(v) > 0 {
+		db = db.Where("a.department_id IN (?)", v)
+	}
+	if v := params.UserID; len(v) > 0 {
+		db = db.Where("a.user_id = ?", v)
+	}
+	if v := params.InUserIDs; len(v) > 0 {
+		db = db.Where("a.user_id IN (?)", v)
+	}
+
+	var list schema.DepartmentUsers
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.DepartmentUserQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+// Checks if the user is a member of the department.
+func (a *DepartmentUser) Exists(ctx context.Context, departmentID, userID string) (bool, error) {
+	ok, err := util.Exists(ctx, GetDepartmentUserDB(ctx, a.DB).Where("department_id=? AND user_id=?", departmentID, userID))
+	return ok, errors.WithStack(err)
+}
+
+// Create a new department user.
+func (a *DepartmentUser) Create(ctx context.Context, item *schema.DepartmentUser) error {
+	result := GetDepartmentUserDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Remove the user from the department.
+func (a *DepartmentUser) Delete(ctx context.Context, departmentID, userID string) error {
+	result := GetDepartmentUserDB(ctx, a.DB).Where("department_id=? AND user_id=?", departmentID, userID).Delete(new(schema.DepartmentUser))
+	return errors.WithStack(result.Error)
+}
+
+func (a *DepartmentUser) DeleteByDepartmentID(ctx context.Context, departmentID string) error {
+	result := GetDepartmentUserDB(ctx, a.DB).Where("department_id=?", departmentID).Delete(new(schema.DepartmentUser))
+	return errors.WithStack(result.Error)
+}
+
+func (a *DepartmentUser) DeleteByUserID(ctx context.Context, userID string) error {
+	result := GetDepartmentUserDB(ctx, a.DB).Where("user_id=?", userID).Delete(new(schema.DepartmentUser))
+	return errors.WithStack(result.Error)
+}
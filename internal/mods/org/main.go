@@ -0,0 +1,51 @@
+package org
+
+import (
+	"context"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/api"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ORG struct {
+	DB            *gorm.DB
+	DepartmentAPI *api.Department
+}
+
+func (a *ORG) AutoMigrate(ctx context.Context) error {
+	return a.DB.AutoMigrate(
+		new(schema.Department),
+		new(schema.DepartmentUser),
+	)
+}
+
+func (a *ORG) Init(ctx context.Context) error {
+	if config.C.Storage.DB.AutoMigrate {
+		if err := a.AutoMigrate(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *ORG) RegisterV1Routers(ctx context.Context, v1 *gin.RouterGroup) error {
+	department := v1.Group("departments")
+	{
+		department.GET("", a.DepartmentAPI.Query)
+		department.GET(":id", a.DepartmentAPI.Get)
+		department.POST("", a.DepartmentAPI.Create)
+		department.PUT(":id", a.DepartmentAPI.Update)
+		department.DELETE(":id", a.DepartmentAPI.Delete)
+		department.GET(":id/users", a.DepartmentAPI.QueryUsers)
+		department.POST(":id/users", a.DepartmentAPI.AddUsers)
+		department.DELETE(":id/users/:user_id", a.DepartmentAPI.RemoveUser)
+	}
+	return nil
+}
+
+func (a *ORG) Release(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+// Membership of the users in the departments
+type DepartmentUser struct {
+	ID             string    `json:"id" gorm:"size:20;primarykey"`                 // Unique ID
+	TenantID       string    `json:"tenant_id" gorm:"size:20;index;default:''"`    // From Tenant.ID (empty for the default tenant)
+	DepartmentID   string    `json:"department_id" gorm:"size:20;index"`           // From Department.ID
+	UserID         string    `json:"user_id" gorm:"size:20;index"`                 // From User.ID
+	CreatedAt      time.Time `json:"created_at" gorm:"index;"`                     // Create time
+	UpdatedAt      time.Time `json:"updated_at" gorm:"index;"`                     // Update time
+	DepartmentName string    `json:"department_name" gorm:"<-:false;-:migration;"` // From Department.Name
+	Username       string    `json:"username" gorm:"<-:false;-:migration;"`        // From User.Username
+	UserName       string    `json:"user_name" gorm:"<-:false;-:migration;"`       // From User.Name
+}
+
+func (a *DepartmentUser) TableName() string {
+	return config.C.FormatTableName("department_user")
+}
+
+// Defining the query parameters for the `DepartmentUser` struct.
+type DepartmentUserQueryParam struct {
+	util.PaginationParam
+	DepartmentID    string   `form:"-"` // From Department.ID
+	InDepartmentIDs []string `form:"-"` // From Department.ID
+	UserID          string   `form:"-"` // From User.ID
+	InUserIDs       []string `form:"-"` // From User.ID
+}
+
+// Defining the query options for the `DepartmentUser` struct.
+type DepartmentUserQueryOptions struct {
+	util.QueryOptions
+	JoinDepartment bool // Join department table
+	JoinUser       bool // Join user table
+}
+
+// Defining the query result for the `DepartmentUser` struct.
+type DepartmentUserQueryResult struct {
+	Data       DepartmentUsers
+	PageResult *util.PaginationResult
+}
+
+// Defining the slice of `DepartmentUser` struct.
+type DepartmentUsers []*DepartmentUser
+
+func (a DepartmentUsers) ToDepartmentIDs() []string {
+	var ids []string
+	for _, item := range a {
+		ids = append(ids, item.DepartmentID)
+	}
+	return ids
+}
+
+// Defining the data structure for adding users to a department.
+type DepartmentUserForm struct {
+	UserIDs []string `json:"user_ids" binding:"required,min=1"` // From User.ID
+}
+
+// A validation function for the `DepartmentUserForm` struct.
+func (a *DepartmentUserForm) Validate() error {
+	return nil
+}
@@ -0,0 +1,18 @@
+package org
+
+import (
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/api"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
+	"github.com/google/wire"
+)
+
+// Collection of wire providers
+var Set = wire.NewSet(
+	wire.Struct(new(ORG), "*"),
+	wire.Struct(new(dal.Department), "*"),
+	wire.Struct(new(biz.Department), "*"),
+	wire.Struct(new(api.Department), "*"),
+	wire.Struct(new(dal.DepartmentUser), "*"),
+	wire.Struct(new(biz.DataScope), "*"),
+)
@@ -8,7 +8,12 @@ import (
 )
 
 type Login struct {
-	LoginBIZ *biz.Login
+	LoginBIZ             *biz.Login
+	LoginOIDCBIZ         *biz.LoginOIDC
+	APIKeyBIZ            *biz.APIKey
+	PasswordResetBIZ     *biz.PasswordReset
+	EmailVerificationBIZ *biz.EmailVerification
+	ImpersonationBIZ     *biz.Impersonation
 }
 
 // @Tags LoginAPI
@@ -26,16 +31,18 @@ func (a *Login) GetCaptcha(c *gin.Context) {
 }
 
 // @Tags LoginAPI
-// @Summary Response captcha image
+// @Summary Response captcha image (or audio)
 // @Param id query string true "Captcha ID"
+// @Param part query string false "Part of the captcha (image/audio/piece), the default part of the type if empty"
 // @Param reload query number false "Reload captcha image (reload=1)"
 // @Produce image/png
+// @Produce audio/wav
 // @Success 200 "Captcha image"
 // @Failure 404 {object} util.ResponseResult
 // @Router /api/v1/captcha/image [get]
 func (a *Login) ResponseCaptcha(c *gin.Context) {
 	ctx := c.Request.Context()
-	err := a.LoginBIZ.ResponseCaptcha(ctx, c.Writer, c.Query("id"), c.Query("reload") == "1")
+	err := a.LoginBIZ.ResponseCaptcha(ctx, c.Writer, c.Query("id"), c.Query("part"), c.Query("reload") == "1")
 	if err != nil {
 		util.ResError(c, err)
 	}
@@ -64,6 +71,149 @@ func (a *Login) Login(c *gin.Context) {
 	util.ResSuccess(c, data)
 }
 
+// @Tags LoginAPI
+// @Summary Complete the login with the 2FA code (TOTP or recovery code)
+// @Param body body schema.LoginMFAForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.LoginToken}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/login/2fa [post]
+func (a *Login) LoginMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.LoginMFAForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.LoginBIZ.LoginMFA(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Summary Generate the 2FA secret during login (2FA is required by the roles but not enrolled)
+// @Param body body schema.LoginMFAEnrollForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.UserTOTPEnrollment}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/login/2fa/enroll [post]
+func (a *Login) LoginEnrollMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.LoginMFAEnrollForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.LoginBIZ.LoginEnrollMFA(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Summary Query the identity providers of the external login
+// @Success 200 {object} util.ResponseResult{data=[]schema.LoginOIDCProvider}
+// @Router /api/v1/login/oidc [get]
+func (a *Login) QueryOIDCProviders(c *gin.Context) {
+	ctx := c.Request.Context()
+	util.ResSuccess(c, a.LoginOIDCBIZ.QueryProviders(ctx))
+}
+
+// @Tags LoginAPI
+// @Summary Start the external login, redirect the user agent to the returned URL
+// @Param provider path string true "Name of the identity provider"
+// @Success 200 {object} util.ResponseResult{data=schema.LoginOIDCAuthorization}
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/login/oidc/{provider} [get]
+func (a *Login) AuthorizeOIDC(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.LoginOIDCBIZ.Authorize(ctx, c.Param("provider"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Summary Complete the external login with the authorization code
+// @Param provider path string true "Name of the identity provider"
+// @Param body body schema.LoginOIDCCallbackForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.LoginToken}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/login/oidc/{provider}/callback [post]
+func (a *Login) LoginOIDC(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.LoginOIDCCallbackForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.LoginOIDCBIZ.Callback(ctx, c.Param("provider"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Summary Request a password reset email (the response is the same whether or not the account exists)
+// @Param body body schema.ForgotPasswordForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 429 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/password/forgot [post]
+func (a *Login) ForgotPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.ForgotPasswordForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.PasswordResetBIZ.Forgot(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags LoginAPI
+// @Summary Reset password with the token from the reset email
+// @Param body body schema.ResetPasswordForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/password/reset [post]
+func (a *Login) ResetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.ResetPasswordForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.PasswordResetBIZ.Reset(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
 // @Tags LoginAPI
 // @Security ApiKeyAuth
 // @Summary Logout system
@@ -81,15 +231,22 @@ func (a *Login) Logout(c *gin.Context) {
 }
 
 // @Tags LoginAPI
-// @Security ApiKeyAuth
-// @Summary Refresh current access token
+// @Summary Exchange the refresh token for a new token pair
+// @Param body body schema.RefreshTokenForm true "Request body"
 // @Success 200 {object} util.ResponseResult{data=schema.LoginToken}
+// @Failure 400 {object} util.ResponseResult
 // @Failure 401 {object} util.ResponseResult
 // @Failure 500 {object} util.ResponseResult
 // @Router /api/v1/current/refresh-token [post]
 func (a *Login) RefreshToken(c *gin.Context) {
 	ctx := c.Request.Context()
-	data, err := a.LoginBIZ.RefreshToken(ctx)
+	item := new(schema.RefreshTokenForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.LoginBIZ.RefreshToken(ctx, item)
 	if err != nil {
 		util.ResError(c, err)
 		return
@@ -158,9 +315,9 @@ func (a *Login) QueryMenus(c *gin.Context) {
 
 // @Tags LoginAPI
 // @Security ApiKeyAuth
-// @Summary Update current user info
+// @Summary Update current user info (a new email is updated after it is confirmed)
 // @Param body body schema.UpdateCurrentUser true "Request body"
-// @Success 200 {object} util.ResponseResult
+// @Success 200 {object} util.ResponseResult{data=schema.UpdateCurrentUserResult}
 // @Failure 400 {object} util.ResponseResult
 // @Failure 401 {object} util.ResponseResult
 // @Failure 500 {object} util.ResponseResult
@@ -168,15 +325,298 @@ func (a *Login) QueryMenus(c *gin.Context) {
 func (a *Login) UpdateUser(c *gin.Context) {
 	ctx := c.Request.Context()
 	item := new(schema.UpdateCurrentUser)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.LoginBIZ.UpdateUser(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Send the confirmation code to the unverified email of the current user
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 429 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/email/verification [post]
+func (a *Login) SendEmailVerification(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.EmailVerificationBIZ.SendCurrent(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Confirm the (new) email of the current user with the code
+// @Param body body schema.EmailVerificationForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/email/verification [put]
+func (a *Login) ConfirmEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.EmailVerificationForm)
 	if err := util.ParseJSON(c, item); err != nil {
 		util.ResError(c, err)
 		return
 	}
 
-	err := a.LoginBIZ.UpdateUser(ctx, item)
+	err := a.EmailVerificationBIZ.Confirm(ctx, item)
 	if err != nil {
 		util.ResError(c, err)
 		return
 	}
 	util.ResOK(c)
 }
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Query active sessions of the current user
+// @Success 200 {object} util.ResponseResult{data=[]schema.UserSession}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/sessions [get]
+func (a *Login) QuerySessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.LoginBIZ.QuerySessions(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Revoke a session of the current user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/sessions/{id} [delete]
+func (a *Login) RevokeSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.LoginBIZ.RevokeSession(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Get the 2FA status of the current user
+// @Success 200 {object} util.ResponseResult{data=schema.UserTOTPStatus}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/2fa [get]
+func (a *Login) GetMFAStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.LoginBIZ.GetMFAStatus(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Start the 2FA enrollment of the current user (returns the secret and provisioning URI)
+// @Success 200 {object} util.ResponseResult{data=schema.UserTOTPEnrollment}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/2fa [post]
+func (a *Login) EnrollMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.LoginBIZ.EnrollMFA(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Verify the enrollment with a code and enable the 2FA of the current user
+// @Param body body schema.UserTOTPCodeForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.UserTOTPRecoveryCodes}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/2fa [put]
+func (a *Login) EnableMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.UserTOTPCodeForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.LoginBIZ.EnableMFA(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Disable the 2FA of the current user
+// @Param body body schema.UserTOTPCodeForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/2fa [delete]
+func (a *Login) DisableMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.UserTOTPCodeForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.LoginBIZ.DisableMFA(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Regenerate the 2FA recovery codes of the current user
+// @Param body body schema.UserTOTPCodeForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.UserTOTPRecoveryCodes}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/2fa/recovery-codes [post]
+func (a *Login) RegenerateMFARecoveryCodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.UserTOTPCodeForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.LoginBIZ.RegenerateMFARecoveryCodes(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Query API keys of the current user
+// @Success 200 {object} util.ResponseResult{data=[]schema.APIKey}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/api-keys [get]
+func (a *Login) QueryAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.APIKeyBIZ.Query(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Create API key for the current user (the key is only returned once)
+// @Param body body schema.APIKeyForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.APIKeyCreated}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/api-keys [post]
+func (a *Login) CreateAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.APIKeyForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.APIKeyBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Revoke API key of the current user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/api-keys/{id} [delete]
+func (a *Login) DeleteAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.APIKeyBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags LoginAPI
+// @Security ApiKeyAuth
+// @Summary Impersonate a user (root or Security.Impersonation.RoleCodes), logout with the token ends the impersonation
+// @Param body body schema.ImpersonateForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.LoginToken}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/impersonation [post]
+func (a *Login) Impersonate(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.ImpersonateForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.ImpersonationBIZ.Start(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Permission checks for RBAC
+type Permission struct {
+	PermissionBIZ *biz.Permission
+}
+
+// @Tags PermissionAPI
+// @Security ApiKeyAuth
+// @Summary Explain the permission check of a request
+// @Param user query string true "User ID or username"
+// @Param method query string true "HTTP method"
+// @Param path query string true "API request path (e.g. /api/v1/users/1)"
+// @Success 200 {object} util.ResponseResult{data=schema.PermissionExplain}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/permissions/explain [get]
+func (a *Permission) Explain(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.PermissionExplainParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.PermissionBIZ.Explain(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// @Tags PermissionAPI
+// @Security ApiKeyAuth
+// @Summary Report the effective menus and API resources of a user
+// @Param user query string true "User ID or username"
+// @Param format query string false "Report format (json, csv)"
+// @Success 200 {object} util.ResponseResult{data=schema.PermissionUserReport}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/permissions/reports/user [get]
+func (a *Permission) ReportUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.PermissionUserReportParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.PermissionBIZ.ReportUser(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	if params.Format == schema.PermissionReportFormatCSV {
+		util.ResCSV(c, "permissions_"+result.Username+".csv", result.ToCSV())
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// @Tags PermissionAPI
+// @Security ApiKeyAuth
+// @Summary Report the roles and users which can access an API resource or a menu
+// @Param method query string false "HTTP method (required with path)"
+// @Param path query string false "API request path (required with method)"
+// @Param menu_id query string false "Menu ID (instead of method and path)"
+// @Param format query string false "Report format (json, csv)"
+// @Success 200 {object} util.ResponseResult{data=schema.PermissionAccessReport}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/permissions/reports/access [get]
+func (a *Permission) ReportAccess(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.PermissionAccessReportParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := params.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.PermissionBIZ.ReportAccess(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	if params.Format == schema.PermissionReportFormatCSV {
+		util.ResCSV(c, "access.csv", result.ToCSV())
+		return
+	}
+	util.ResSuccess(c, result)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Separation of duties constraints between roles
+type RoleConstraint struct {
+	RoleConstraintBIZ *biz.RoleConstraint
+}
+
+// @Tags RoleConstraintAPI
+// @Security ApiKeyAuth
+// @Summary Query role constraint list
+// @Param current query int true "pagination index" default(1)
+// @Param pageSize query int true "pagination size" default(10)
+// @Param type query string false "Type of constraint (exclusive, max_holders)"
+// @Param role_id query string false "Either of the roles of constraint"
+// @Success 200 {object} util.ResponseResult{data=[]schema.RoleConstraint}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/role-constraints [get]
+func (a *RoleConstraint) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.RoleConstraintQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.RoleConstraintBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+// @Tags RoleConstraintAPI
+// @Security ApiKeyAuth
+// @Summary Query the constraints broken by the current role assignments
+// @Success 200 {object} util.ResponseResult{data=[]schema.RoleConstraintViolation}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/role-constraints/violations [get]
+func (a *RoleConstraint) QueryViolations(c *gin.Context) {
+	ctx := c.Request.Context()
+	result, err := a.RoleConstraintBIZ.QueryViolations(ctx)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// @Tags RoleConstraintAPI
+// @Security ApiKeyAuth
+// @Summary Get role constraint record by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult{data=schema.RoleConstraint}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/role-constraints/{id} [get]
+func (a *RoleConstraint) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.RoleConstraintBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+// @Tags RoleConstraintAPI
+// @Security ApiKeyAuth
+// @Summary Create role constraint record
+// @Param body body schema.RoleConstraintForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.RoleConstraint}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/role-constraints [post]
+func (a *RoleConstraint) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.RoleConstraintForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.RoleConstraintBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// @Tags RoleConstraintAPI
+// @Security ApiKeyAuth
+// @Summary Update role constraint record by ID
+// @Param id path string true "unique id"
+// @Param body body schema.RoleConstraintForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/role-constraints/{id} [put]
+func (a *RoleConstraint) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.RoleConstraintForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.RoleConstraintBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags RoleConstraintAPI
+// @Security ApiKeyAuth
+// @Summary Delete role constraint record by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/role-constraints/{id} [delete]
+func (a *RoleConstraint) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.RoleConstraintBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Just-in-time elevation of the roles
+type RoleElevation struct {
+	RoleElevationBIZ *biz.RoleElevation
+}
+
+// @Tags RoleElevationAPI
+// @Security ApiKeyAuth
+// @Summary Request a role for a bounded window
+// @Param body body schema.RoleElevationForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.RoleElevation}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/elevations [post]
+func (a *RoleElevation) Request(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.RoleElevationForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.RoleElevationBIZ.Request(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// @Tags RoleElevationAPI
+// @Security ApiKeyAuth
+// @Summary Query the role elevations requested by the current user
+// @Param current query int true "pagination index" default(1)
+// @Param pageSize query int true "pagination size" default(10)
+// @Param status query string false "Status of request (pending, approved, rejected, cancelled)"
+// @Success 200 {object} util.ResponseResult{data=[]schema.RoleElevation}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/elevations [get]
+func (a *RoleElevation) QueryMine(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.RoleElevationQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.RoleElevationBIZ.QueryMine(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+// @Tags RoleElevationAPI
+// @Security ApiKeyAuth
+// @Summary Cancel the pending role elevation of the current user
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/elevations/{id} [delete]
+func (a *RoleElevation) Cancel(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.RoleElevationBIZ.Cancel(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags RoleElevationAPI
+// @Security ApiKeyAuth
+// @Summary Query the role elevations of all users for review (root or Security.Elevation.ApproverRoleCodes)
+// @Param current query int true "pagination index" default(1)
+// @Param pageSize query int true "pagination size" default(10)
+// @Param status query string false "Status of request (pending, approved, rejected, cancelled)"
+// @Success 200 {object} util.ResponseResult{data=[]schema.RoleElevation}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/elevations/reviews [get]
+func (a *RoleElevation) QueryReviews(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.RoleElevationQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.RoleElevationBIZ.QueryReviews(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+// @Tags RoleElevationAPI
+// @Security ApiKeyAuth
+// @Summary Approve the role elevation, the role is granted for the requested window from now
+// @Param id path string true "unique id"
+// @Param body body schema.RoleElevationReviewForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/elevations/{id}/approve [post]
+func (a *RoleElevation) Approve(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.RoleElevationReviewForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.RoleElevationBIZ.Approve(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags RoleElevationAPI
+// @Security ApiKeyAuth
+// @Summary Reject the role elevation
+// @Param id path string true "unique id"
+// @Param body body schema.RoleElevationReviewForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/current/elevations/{id}/reject [post]
+func (a *RoleElevation) Reject(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.RoleElevationReviewForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.RoleElevationBIZ.Reject(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Registry of the API routes
+type Route struct {
+	RouteBIZ *biz.Route
+}
+
+// @Tags ResourceAPI
+// @Security ApiKeyAuth
+// @Summary Query the registered API routes for picking the menu resources
+// @Param method query string false "HTTP method"
+// @Param path query string false "Path of the route"
+// @Param protected query bool false "Only the routes checked by casbin"
+// @Param uncovered query bool false "Only the routes not covered by any menu resource"
+// @Success 200 {object} util.ResponseResult{data=[]schema.Route}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/resources/routes [get]
+func (a *Route) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.RouteQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	data, err := a.RouteBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
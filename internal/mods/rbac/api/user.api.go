@@ -9,7 +9,9 @@ import (
 
 // User management for RBAC
 type User struct {
-	UserBIZ *biz.User
+	UserBIZ      *biz.User
+	UserTOTPBIZ  *biz.UserTOTP
+	LoginLockBIZ *biz.LoginLock
 }
 
 // @Tags UserAPI
@@ -20,6 +22,7 @@ type User struct {
 // @Param username query string false "Username for login"
 // @Param name query string false "Name of user"
 // @Param status query string false "Status of user (activated, freezed)"
+// @Param locked query bool false "Locked by failed logins"
 // @Success 200 {object} util.ResponseResult{data=[]schema.User}
 // @Failure 401 {object} util.ResponseResult
 // @Failure 500 {object} util.ResponseResult
@@ -40,6 +43,33 @@ func (a *User) Query(c *gin.Context) {
 	util.ResPage(c, result.Data, result.PageResult)
 }
 
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Query the role assignments which end soon
+// @Param current query int true "pagination index" default(1)
+// @Param pageSize query int true "pagination size" default(10)
+// @Param days query int false "The roles which end in the days" default(7)
+// @Success 200 {object} util.ResponseResult{data=[]schema.UserRole}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/users/expiring-roles [get]
+func (a *User) QueryExpiringRoles(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.ExpiringUserRoleQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.UserBIZ.QueryExpiringRoles(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
 // @Tags UserAPI
 // @Security ApiKeyAuth
 // @Summary Get user record by ID
@@ -150,3 +180,79 @@ func (a *User) ResetPassword(c *gin.Context) {
 	}
 	util.ResOK(c)
 }
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Query active sessions of the user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult{data=[]schema.UserSession}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/users/{id}/sessions [get]
+func (a *User) QuerySessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	data, err := a.UserBIZ.QuerySessions(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, data)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Revoke all sessions of the user by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/users/{id}/sessions [delete]
+func (a *User) RevokeSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.UserBIZ.RevokeSessions(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Reset the 2FA of the user by ID (the user has to enroll again)
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/users/{id}/2fa [delete]
+func (a *User) ResetMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.UserTOTPBIZ.Reset(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags UserAPI
+// @Security ApiKeyAuth
+// @Summary Unlock the user locked by failed logins
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 404 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/users/{id}/unlock [patch]
+func (a *User) Unlock(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.LoginLockBIZ.Unlock(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
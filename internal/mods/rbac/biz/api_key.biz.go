@@ -0,0 +1,141 @@
+package biz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/rand"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// The last use of the key is recorded at most once per interval (unless the client IP changes)
+const apiKeyLastUsedInterval = time.Minute
+
+// Personal API keys for machine clients
+type APIKey struct {
+	APIKeyDAL *dal.APIKey
+}
+
+// Check whether the token looks like an API key (instead of a JWT).
+func (a *APIKey) IsAPIKey(token string) bool {
+	prefix := config.C.Security.APIKey.Prefix
+	return prefix != "" && strings.HasPrefix(token, prefix)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify the API key, returns nil if the key is unknown or expired. The key is looked up in all tenants, the tenant
+// of the request is the tenant of the key.
+func (a *APIKey) Verify(ctx context.Context, key, clientIP string) (*schema.APIKey, error) {
+	ctx = util.NewTenantUnscoped(ctx)
+	item, err := a.APIKeyDAL.GetByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	} else if item == nil || item.IsExpired() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	if item.LastUsedAt == nil || now.Sub(*item.LastUsedAt) >= apiKeyLastUsedInterval || item.LastUsedIP != clientIP {
+		if err := a.APIKeyDAL.UpdateLastUsed(ctx, item.ID, now, clientIP); err != nil {
+			logging.Context(ctx).Error("Failed to update the last use of API key", zap.String("id", item.ID), zap.Error(err))
+		}
+	}
+	return item, nil
+}
+
+// Query the API keys of the current user.
+func (a *APIKey) Query(ctx context.Context) (schema.APIKeys, error) {
+	return a.APIKeyDAL.QueryByUserID(ctx, util.FromUserID(ctx))
+}
+
+func (a *APIKey) checkManage(ctx context.Context) error {
+	if util.FromIsRootUser(ctx) {
+		return errors.BadRequest("", "Root user cannot manage API keys")
+	}
+	if _, ok := util.FromAPIKeyScopes(ctx); ok {
+		return errors.Forbidden("", "API keys cannot be managed with an API key")
+	}
+	return nil
+}
+
+// Create a new API key for the current user, the plain key is only returned here.
+func (a *APIKey) Create(ctx context.Context, formItem *schema.APIKeyForm) (*schema.APIKeyCreated, error) {
+	if err := a.checkManage(ctx); err != nil {
+		return nil, err
+	}
+
+	cfg := config.C.Security.APIKey
+	userID := util.FromUserID(ctx)
+	if cfg.MaxPerUser > 0 {
+		count, err := a.APIKeyDAL.CountByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		} else if count >= int64(cfg.MaxPerUser) {
+			return nil, errors.BadRequest("", "Too many API keys, at most %d keys are allowed", cfg.MaxPerUser)
+		}
+	}
+
+	expiresIn := formItem.ExpiresIn
+	if cfg.MaxExpiresIn > 0 {
+		if expiresIn > cfg.MaxExpiresIn {
+			return nil, errors.BadRequest("", "The API key expires in at most %d days", cfg.MaxExpiresIn)
+		} else if expiresIn == 0 {
+			expiresIn = cfg.MaxExpiresIn
+		}
+	}
+
+	secret, err := rand.Random(40, rand.LdigitAndLetter)
+	if err != nil {
+		return nil, err
+	}
+	key := cfg.Prefix + secret
+
+	item := schema.APIKey{
+		ID:        util.NewXID(),
+		UserID:    userID,
+		Name:      formItem.Name,
+		Prefix:    key[:len(cfg.Prefix)+4],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    formItem.Scopes,
+		CreatedAt: time.Now(),
+	}
+	if expiresIn > 0 {
+		expiresAt := item.CreatedAt.AddDate(0, 0, expiresIn)
+		item.ExpiresAt = &expiresAt
+	}
+
+	if err := a.APIKeyDAL.Create(ctx, &item); err != nil {
+		return nil, err
+	}
+	logging.Context(ctx).Info("Create API key", zap.String("id", item.ID), zap.String("name", item.Name), zap.Strings("scopes", item.Scopes))
+	return &schema.APIKeyCreated{APIKey: item, Key: key}, nil
+}
+
+// Revoke the specified API key of the current user.
+func (a *APIKey) Delete(ctx context.Context, id string) error {
+	if err := a.checkManage(ctx); err != nil {
+		return err
+	}
+
+	ok, err := a.APIKeyDAL.DeleteByID(ctx, util.FromUserID(ctx), id)
+	if err != nil {
+		return err
+	} else if !ok {
+		return errors.NotFound("", "API key not found")
+	}
+	logging.Context(ctx).Info("Revoke API key", zap.String("id", id))
+	return nil
+}
@@ -0,0 +1,71 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAPIKey(t *testing.T) *APIKey {
+	apiKey := config.C.Security.APIKey
+	t.Cleanup(func() { config.C.Security.APIKey = apiKey })
+	config.C.Security.APIKey.Prefix = "ga_"
+	config.C.Security.APIKey.MaxPerUser = 2
+	config.C.Security.APIKey.MaxExpiresIn = 30
+
+	db := newTestDB(t, new(schema.APIKey))
+	return &APIKey{APIKeyDAL: &dal.APIKey{DB: db}}
+}
+
+func TestAPIKey(t *testing.T) {
+	apiKeyBIZ := newTestAPIKey(t)
+	ctx := util.NewUserID(context.Background(), "u1")
+
+	created, err := apiKeyBIZ.Create(ctx, &schema.APIKeyForm{Name: "ci", Scopes: []string{"GET /api/v1/users"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, apiKeyBIZ.IsAPIKey(created.Key))
+	assert.NotEqual(t, created.Key, created.KeyHash, "the plain key is not stored")
+	if assert.NotNil(t, created.ExpiresAt) {
+		assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), *created.ExpiresAt, time.Minute, "the maximum lifetime by default")
+	}
+
+	// The key is verified with its owner and scopes
+	item, err := apiKeyBIZ.Verify(context.Background(), created.Key, "10.0.0.1")
+	if assert.NoError(t, err) && assert.NotNil(t, item) {
+		assert.Equal(t, "u1", item.UserID)
+		assert.Equal(t, []string{"GET /api/v1/users"}, item.Scopes)
+	}
+	item, err = apiKeyBIZ.Verify(context.Background(), created.Key+"x", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	// The expired key is rejected
+	expiresAt := time.Now().Add(-time.Second)
+	assert.NoError(t, apiKeyBIZ.APIKeyDAL.DB.Model(new(schema.APIKey)).Where("id=?", created.ID).Update("expires_at", expiresAt).Error)
+	item, err = apiKeyBIZ.Verify(context.Background(), created.Key, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	_, err = apiKeyBIZ.Create(ctx, &schema.APIKeyForm{Name: "long", Scopes: []string{"*"}, ExpiresIn: 31})
+	assert.Equal(t, int32(400), errors.FromError(err).Code)
+	_, err = apiKeyBIZ.Create(ctx, &schema.APIKeyForm{Name: "second", Scopes: []string{"*"}})
+	assert.NoError(t, err)
+	_, err = apiKeyBIZ.Create(ctx, &schema.APIKeyForm{Name: "third", Scopes: []string{"*"}})
+	assert.Equal(t, int32(400), errors.FromError(err).Code, "too many keys")
+
+	// The keys cannot be managed with an API key, and only by their owner
+	_, err = apiKeyBIZ.Create(util.NewAPIKeyScopes(ctx, []string{"*"}), &schema.APIKeyForm{Name: "nested", Scopes: []string{"*"}})
+	assert.Equal(t, int32(403), errors.FromError(err).Code)
+	err = apiKeyBIZ.Delete(util.NewUserID(context.Background(), "u2"), created.ID)
+	assert.Equal(t, int32(404), errors.FromError(err).Code)
+	assert.NoError(t, apiKeyBIZ.Delete(ctx, created.ID))
+}
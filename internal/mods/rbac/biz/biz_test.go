@@ -0,0 +1,38 @@
+package biz
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open a sqlite database in the temporary directory of the test and migrate the models.
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// Create the records in the database.
+func mustCreate(t *testing.T, db *gorm.DB, items ...interface{}) {
+	for _, item := range items {
+		if err := db.Create(item).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Synchronizer of the casbin policies which applies the policy changes of the roles incrementally
+type PolicySyncer interface {
+	SyncRoles(ctx context.Context, roleIDs ...string) error
+}
+
+// Apply the policy changes of the roles, all instances fall back to reload all policies if it fails.
+func syncToCasbin(ctx context.Context, cache cachex.Cacher, syncer PolicySyncer, roleIDs ...string) error {
+	err := syncer.SyncRoles(ctx, roleIDs...)
+	if err == nil {
+		return nil
+	}
+
+	logging.Context(ctx).Error("Failed to sync casbin policies, fall back to reload all policies",
+		zap.Error(err), zap.Strings("roles", roleIDs))
+	// The fallback is shared by the tenants
+	ctx = util.NewTenantUnscoped(ctx)
+	return cache.Set(ctx, config.CacheNSForRole, config.CacheKeyForSyncToCasbin, fmt.Sprintf("%d", time.Now().Unix()))
+}
@@ -0,0 +1,158 @@
+package biz
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/rand"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Email address that waits for the confirmation code
+type pendingEmail struct {
+	Email    string `json:"email"`
+	Code     string `json:"code"`
+	Attempts int    `json:"attempts"`
+	SentAt   int64  `json:"sent_at"`
+}
+
+// Verification of the email address with a confirmation code
+type EmailVerification struct {
+	Cache   cachex.Cacher
+	UserDAL *dal.User
+}
+
+func (a *EmailVerification) getPending(ctx context.Context, userID string) (*pendingEmail, error) {
+	val, ok, err := a.Cache.Get(ctx, config.CacheNSForEmailVerification, userID)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, nil
+	}
+
+	item := new(pendingEmail)
+	if err := json.Unmarshal([]byte(val), item); err != nil {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Save the pending email until the code expires, the failed attempts do not extend the lifetime of the code.
+func (a *EmailVerification) setPending(ctx context.Context, userID string, item *pendingEmail) error {
+	expiresAt := time.Unix(item.SentAt, 0).Add(time.Duration(config.C.Security.EmailVerification.Expired) * time.Second)
+	expiration := time.Until(expiresAt)
+	if expiration <= 0 {
+		return a.Cache.Delete(ctx, config.CacheNSForEmailVerification, userID)
+	}
+	return a.Cache.Set(ctx, config.CacheNSForEmailVerification, userID, json.MarshalToString(item), expiration)
+}
+
+// Send the confirmation code to the email, the email of the user is updated after it is confirmed.
+func (a *EmailVerification) Start(ctx context.Context, user *schema.User, email string) error {
+	cfg := config.C.Security.EmailVerification
+	pending, err := a.getPending(ctx, user.ID)
+	if err != nil {
+		return err
+	} else if pending != nil {
+		if wait := pending.SentAt + int64(cfg.ResendInterval) - time.Now().Unix(); wait > 0 {
+			return errors.TooManyRequests("", "Please wait %d seconds before requesting a new code", wait)
+		}
+	}
+
+	code, err := rand.Random(6, rand.Ldigit)
+	if err != nil {
+		return err
+	}
+	pending = &pendingEmail{
+		Email:  email,
+		Code:   code,
+		SentAt: time.Now().Unix(),
+	}
+	if err := a.setPending(ctx, user.ID, pending); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("<p>Hi %s,</p><p>Your confirmation code is <b>%s</b>, it expires in %d minutes.</p>"+
+		"<p>If you did not request it, please ignore this email.</p>",
+		html.EscapeString(user.Name), code, cfg.Expired/60)
+	if err := sendMail(ctx, email, "Confirm your email address", body); err != nil {
+		logging.Context(ctx).Error("Failed to send email confirmation code", zap.String("user_id", user.ID), zap.Error(err))
+		_ = a.Cache.Delete(ctx, config.CacheNSForEmailVerification, user.ID)
+		return errors.BadRequest("", "Failed to send the confirmation code, please try again later")
+	}
+
+	logging.Context(ctx).Info("Send email confirmation code", zap.String("user_id", user.ID))
+	return nil
+}
+
+// Send the confirmation code to the current (unverified) email of the current user.
+func (a *EmailVerification) SendCurrent(ctx context.Context) error {
+	if util.FromIsRootUser(ctx) {
+		return errors.BadRequest("", "Root user has no email")
+	}
+
+	user, err := a.UserDAL.Get(ctx, util.FromUserID(ctx), schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "name", "email", "email_verified"}},
+	})
+	if err != nil {
+		return err
+	} else if user == nil {
+		return errors.NotFound("", "User not found")
+	} else if user.Email == "" {
+		return errors.BadRequest("", "No email address")
+	} else if user.EmailVerified {
+		return errors.BadRequest("", "The email address is already verified")
+	}
+	return a.Start(ctx, user, user.Email)
+}
+
+// Confirm the email with the code, the pending email becomes the verified email of the user.
+func (a *EmailVerification) Confirm(ctx context.Context, formItem *schema.EmailVerificationForm) error {
+	userID := util.FromUserID(ctx)
+	invalidCode := errors.BadRequest(config.ErrInvalidEmailCodeID, "Invalid or expired confirmation code")
+	pending, err := a.getPending(ctx, userID)
+	if err != nil {
+		return err
+	} else if pending == nil {
+		return invalidCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pending.Code), []byte(formItem.Code)) != 1 {
+		pending.Attempts++
+		if pending.Attempts >= config.C.Security.EmailVerification.MaxAttempts {
+			err = a.Cache.Delete(ctx, config.CacheNSForEmailVerification, userID)
+		} else {
+			err = a.setPending(ctx, userID, pending)
+		}
+		if err != nil {
+			return err
+		}
+		return invalidCode
+	}
+
+	user := &schema.User{
+		ID:            userID,
+		Email:         pending.Email,
+		EmailVerified: true,
+		UpdatedAt:     time.Now(),
+	}
+	if err := a.UserDAL.Update(ctx, user, "email", "email_verified", "updated_at"); err != nil {
+		return err
+	} else if err := a.Cache.Delete(ctx, config.CacheNSForEmailVerification, userID); err != nil {
+		return err
+	}
+
+	logging.Context(ctx).Info("Confirm email address", zap.String("user_id", userID))
+	return nil
+}
@@ -0,0 +1,85 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEmailVerification(t *testing.T) *EmailVerification {
+	emailVerification, mail := config.C.Security.EmailVerification, config.C.Util.Mail
+	t.Cleanup(func() { config.C.Security.EmailVerification, config.C.Util.Mail = emailVerification, mail })
+	config.C.Security.EmailVerification.Expired = 900
+	config.C.Security.EmailVerification.MaxAttempts = 3
+	config.C.Security.EmailVerification.ResendInterval = 60
+	config.C.Util.Mail.Host = ""
+
+	db := newTestDB(t, new(schema.User))
+	mustCreate(t, db, &schema.User{ID: "u1", Username: "u1", Email: "old@example.com", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated})
+	return &EmailVerification{
+		Cache:   cachex.NewMemoryCache(cachex.MemoryConfig{}),
+		UserDAL: &dal.User{DB: db},
+	}
+}
+
+// The code sent the seconds ago.
+func newTestPendingEmail(ago int) *pendingEmail {
+	return &pendingEmail{Email: "new@example.com", Code: "123456", SentAt: time.Now().Unix() - int64(ago)}
+}
+
+func TestEmailVerificationConfirm(t *testing.T) {
+	emailVerificationBIZ := newTestEmailVerification(t)
+	ctx := util.NewUserID(context.Background(), "u1")
+
+	// The code is invalidated after the failed attempts
+	assert.NoError(t, emailVerificationBIZ.setPending(ctx, "u1", newTestPendingEmail(0)))
+	for i := 0; i < 3; i++ {
+		err := emailVerificationBIZ.Confirm(ctx, &schema.EmailVerificationForm{Code: "000000"})
+		assert.Equal(t, config.ErrInvalidEmailCodeID, errorID(err))
+	}
+	err := emailVerificationBIZ.Confirm(ctx, &schema.EmailVerificationForm{Code: "123456"})
+	assert.Equal(t, config.ErrInvalidEmailCodeID, errorID(err))
+
+	// A new code cannot be requested in the resend interval
+	assert.NoError(t, emailVerificationBIZ.setPending(ctx, "u1", newTestPendingEmail(0)))
+	err = emailVerificationBIZ.Start(ctx, &schema.User{ID: "u1"}, "new@example.com")
+	assert.True(t, isTooManyRequests(err), err)
+
+	assert.NoError(t, emailVerificationBIZ.Confirm(ctx, &schema.EmailVerificationForm{Code: "123456"}))
+	var user schema.User
+	assert.NoError(t, emailVerificationBIZ.UserDAL.DB.First(&user, "id=?", "u1").Error)
+	assert.Equal(t, "new@example.com", user.Email)
+	assert.True(t, user.EmailVerified)
+	err = emailVerificationBIZ.Confirm(ctx, &schema.EmailVerificationForm{Code: "123456"})
+	assert.Equal(t, config.ErrInvalidEmailCodeID, errorID(err), "the code can be used only once")
+}
+
+func TestEmailVerificationExpiry(t *testing.T) {
+	emailVerificationBIZ := newTestEmailVerification(t)
+	ctx := util.NewUserID(context.Background(), "u1")
+
+	// The expired code is not saved
+	assert.NoError(t, emailVerificationBIZ.setPending(ctx, "u1", newTestPendingEmail(900)))
+	err := emailVerificationBIZ.Confirm(ctx, &schema.EmailVerificationForm{Code: "123456"})
+	assert.Equal(t, config.ErrInvalidEmailCodeID, errorID(err))
+
+	// The failed attempt does not extend the lifetime of the code
+	assert.NoError(t, emailVerificationBIZ.setPending(ctx, "u1", newTestPendingEmail(899)))
+	err = emailVerificationBIZ.Confirm(ctx, &schema.EmailVerificationForm{Code: "000000"})
+	assert.Equal(t, config.ErrInvalidEmailCodeID, errorID(err))
+	time.Sleep(1100 * time.Millisecond)
+	err = emailVerificationBIZ.Confirm(ctx, &schema.EmailVerificationForm{Code: "123456"})
+	assert.Equal(t, config.ErrInvalidEmailCodeID, errorID(err))
+
+	var user schema.User
+	assert.NoError(t, emailVerificationBIZ.UserDAL.DB.First(&user, "id=?", "u1").Error)
+	assert.Equal(t, "old@example.com", user.Email)
+	assert.False(t, user.EmailVerified)
+}
@@ -0,0 +1,305 @@
+package biz
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/middleware"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Impersonation of the users by root or the privileged roles (support staff)
+type Impersonation struct {
+	Auth          jwtx.Auther
+	Enforcer      Enforcer
+	UserDAL       *dal.User
+	RoleDAL       *dal.Role
+	RoleParentDAL *dal.RoleParent
+	UserBIZ       *User
+}
+
+// The operations of the current user are always blocked during impersonation, logout ends the impersonation
+const impersonationCurrentPathPrefix = "/api/v1/current/"
+
+var impersonationAllowedPaths = []string{"/api/v1/current/logout"}
+
+// Check whether the request is allowed with an impersonation token.
+func checkImpersonation(c *gin.Context) error {
+	cfg := config.C.Security.Impersonation
+	if !cfg.Enable {
+		return errors.Unauthorized(config.ErrInvalidTokenID, "Invalid access token")
+	}
+
+	method, path := c.Request.Method, c.Request.URL.Path
+	for _, p := range impersonationAllowedPaths {
+		if path == p {
+			return nil
+		}
+	}
+
+	blocked := errors.Forbidden(config.ErrImpersonationBlockedID, "The operation is not allowed during impersonation")
+	if method != http.MethodGet && (cfg.ReadOnly || strings.HasPrefix(path, impersonationCurrentPathPrefix)) {
+		return blocked
+	}
+
+	for _, item := range cfg.BlockedPathPrefixes {
+		prefix := item
+		if i := strings.IndexByte(item, ' '); i > 0 {
+			if !strings.EqualFold(item[:i], method) {
+				continue
+			}
+			prefix = strings.TrimSpace(item[i+1:])
+		}
+		if strings.HasPrefix(path, prefix) {
+			return blocked
+		}
+	}
+	return nil
+}
+
+// Get the IDs of the roles allowed to impersonate which are assigned to (or inherited by) the user.
+func (a *Impersonation) privilegedRoleIDs(ctx context.Context, userID string) ([]string, error) {
+	codes := config.C.Security.Impersonation.RoleCodes
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	roleIDs, err := a.RoleDAL.GetIDsByCodes(ctx, codes)
+	if err != nil || len(roleIDs) == 0 {
+		return nil, err
+	}
+	userRoleIDs, err := a.UserBIZ.GetRoleIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return nil, err
+	}
+	userRoleIDs = append(userRoleIDs, roleParentResult.Data.AncestorIDs(userRoleIDs...)...)
+
+	privileged := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		privileged[roleID] = true
+	}
+	var ids []string
+	for _, roleID := range userRoleIDs {
+		if privileged[roleID] {
+			ids = append(ids, roleID)
+		}
+	}
+	return ids, nil
+}
+
+// Check the permissions of the target user are a subset of the permissions of the actor, so that the actor gains no
+// permission by the impersonation. The conditions of the policies are assumed to be met.
+func (a *Impersonation) checkPermissions(ctx context.Context, actorID, targetID string) error {
+	if config.C.Middleware.Casbin.Disable {
+		return nil
+	}
+
+	forbidden := errors.Forbidden("", "The user has permissions which are not granted to the current user")
+	enforcer := a.Enforcer.GetEnforcer()
+	if enforcer == nil {
+		return forbidden
+	}
+
+	actorRoleIDs, err := a.UserBIZ.GetRoleIDs(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	targetRoleIDs, err := a.UserBIZ.GetRoleIDs(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	tenantID, _ := util.FromTenantID(ctx)
+	domain := schema.CasbinDomain(tenantID)
+	subjects := toRoleSet(targetRoleIDs)
+	for _, roleID := range targetRoleIDs {
+		implicitRoles, err := enforcer.GetImplicitRolesForUser(roleID, domain)
+		if err != nil {
+			return err
+		}
+		for _, implicitRole := range implicitRoles {
+			subjects[implicitRole] = true
+		}
+	}
+
+	checked := make(map[string]bool)
+	for _, policy := range enforcer.GetFilteredPolicy(1, domain) {
+		item := schema.NewPermissionPolicy(policy)
+		if item == nil || !subjects[item.Subject] || item.Effect == schema.MenuResourceEffectDeny {
+			continue
+		}
+		key := item.Action + " " + item.Object
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		if allowed, _, err := middleware.CasbinEnforce(enforcer, targetRoleIDs, domain, item.Object, item.Action, nil); err != nil {
+			return err
+		} else if !allowed {
+			continue
+		}
+		if allowed, _, err := middleware.CasbinEnforce(enforcer, actorRoleIDs, domain, item.Object, item.Action, nil); err != nil {
+			return err
+		} else if !allowed {
+			return forbidden
+		}
+	}
+	return nil
+}
+
+// Check the data scope of the target user is a subset of the data scope of the actor, so that the actor sees no
+// record by the impersonation which is not visible to them. The records of the target user itself are visible to
+// the actor since the target user is in their data scope.
+func (a *Impersonation) checkDataScope(ctx context.Context, targetID string) error {
+	actorScope, actorScoped, err := a.UserBIZ.DataScopeBIZ.Get(ctx)
+	if err != nil {
+		return err
+	} else if !actorScoped {
+		return nil
+	}
+
+	targetRoleIDs, err := a.UserBIZ.GetRoleIDs(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	targetCtx := util.NewUserCache(util.NewUserID(ctx, targetID), util.UserCache{RoleIDs: targetRoleIDs})
+	targetScope, targetScoped, err := a.UserBIZ.DataScopeBIZ.Get(targetCtx)
+	if err != nil {
+		return err
+	}
+
+	forbidden := errors.Forbidden("", "The user has a wider data scope than the current user")
+	if !targetScoped {
+		return forbidden
+	}
+	visible := make(map[string]bool, len(actorScope.DepartmentIDs))
+	for _, departmentID := range actorScope.DepartmentIDs {
+		visible[departmentID] = true
+	}
+	for _, departmentID := range targetScope.DepartmentIDs {
+		if !visible[departmentID] {
+			return forbidden
+		}
+	}
+	return nil
+}
+
+// Issue a time-limited token of the user for the current user (root or the privileged roles), which cannot be
+// refreshed. The privileged users cannot be impersonated by each other, and the other users can only impersonate
+// the users in their data scope whose permissions and data scope are granted to them as well.
+func (a *Impersonation) Start(ctx context.Context, formItem *schema.ImpersonateForm) (*schema.LoginToken, error) {
+	cfg := config.C.Security.Impersonation
+	if !cfg.Enable {
+		return nil, errors.BadRequest("", "Impersonation is disabled")
+	} else if _, ok := util.FromAPIKeyScopes(ctx); ok {
+		return nil, errors.Forbidden("", "The operation is not allowed with an API key")
+	} else if _, ok := util.FromImpersonation(ctx); ok {
+		return nil, errors.Forbidden(config.ErrImpersonationBlockedID, "The operation is not allowed during impersonation")
+	}
+
+	actorID := util.FromUserID(ctx)
+	isRoot := util.FromIsRootUser(ctx) || util.FromIsTenantAdmin(ctx)
+	if actorID != config.C.General.Root.ID {
+		actor, err := a.UserDAL.Get(ctx, actorID, schema.UserQueryOptions{
+			QueryOptions: util.QueryOptions{SelectFields: []string{"id", "status"}},
+		})
+		if err != nil {
+			return nil, err
+		} else if actor == nil || actor.Status != schema.UserStatusActivated {
+			return nil, errors.Forbidden("", "Impersonation is not allowed")
+		}
+	}
+	if !isRoot {
+		roleIDs, err := a.privilegedRoleIDs(ctx, actorID)
+		if err != nil {
+			return nil, err
+		} else if len(roleIDs) == 0 {
+			return nil, errors.Forbidden("", "Impersonation is not allowed")
+		}
+	}
+
+	if formItem.UserID == actorID || formItem.UserID == config.C.General.Root.ID ||
+		formItem.UserID == util.FromTenantAdminID(ctx) {
+		return nil, errors.BadRequest("", "The user cannot be impersonated")
+	}
+	user, err := a.UserBIZ.getInDataScope(ctx, formItem.UserID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "username", "status"}},
+	})
+	if err != nil {
+		return nil, err
+	} else if user.Status != schema.UserStatusActivated {
+		return nil, errors.BadRequest("", "User status is not activated")
+	}
+
+	if !isRoot {
+		roleIDs, err := a.privilegedRoleIDs(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		} else if len(roleIDs) > 0 {
+			return nil, errors.Forbidden("", "The privileged user cannot be impersonated")
+		} else if err := a.checkPermissions(ctx, actorID, user.ID); err != nil {
+			return nil, err
+		} else if err := a.checkDataScope(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := a.Auth.GenerateImpersonationToken(newSessionMeta(ctx), user.ID, actorID, cfg.Expired)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	logging.Context(ctx).Warn("Impersonation started",
+		zap.String("target_user_id", user.ID),
+		zap.String("username", user.Username),
+		zap.String("reason", formItem.Reason),
+		zap.Int64("expires_at", token.GetExpiresAt()))
+
+	return &schema.LoginToken{
+		AccessToken: token.GetAccessToken(),
+		TokenType:   token.GetTokenType(),
+		ExpiresAt:   token.GetExpiresAt(),
+	}, nil
+}
+
+// Get the impersonation of the current user shown by the UI, nil if the user is not impersonated.
+func (a *Impersonation) Get(ctx context.Context) (*schema.UserImpersonation, error) {
+	impersonation, ok := util.FromImpersonation(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	item := &schema.UserImpersonation{
+		ImpersonatorID: impersonation.ActorID,
+		ExpiresAt:      impersonation.ExpiresAt,
+	}
+	if impersonation.ActorID == config.C.General.Root.ID {
+		item.ImpersonatorName = config.C.General.Root.Name
+		return item, nil
+	}
+
+	actor, err := a.UserDAL.Get(ctx, impersonation.ActorID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"name"}},
+	})
+	if err != nil {
+		return nil, err
+	} else if actor != nil {
+		item.ImpersonatorName = actor.Name
+	}
+	return item, nil
+}
@@ -0,0 +1,193 @@
+package biz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	orgbiz "github.com/LyricTian/gin-admin/v10/internal/mods/org/biz"
+	orgdal "github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
+	orgschema "github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/LyricTian/gin-admin/v10/pkg/middleware"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type testEnforcer struct {
+	enforcer *casbin.SyncedEnforcer
+}
+
+func (a *testEnforcer) GetEnforcer() *casbin.SyncedEnforcer {
+	return a.enforcer
+}
+
+// Create an enforcer of the model in the configuration directory with the policies of the default domain.
+func newTestEnforcer(t *testing.T, policies ...[]string) *testEnforcer {
+	enforcer, err := casbin.NewSyncedEnforcer("../../../../configs/rbac_model.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction(middleware.CasbinConditionFunction, middleware.CasbinConditionMatch)
+	for _, policy := range policies {
+		rule := append([]string{policy[0], schema.CasbinDefaultDomain}, policy[1:]...)
+		rule = append(rule, schema.MenuResourceEffectAllow, "")
+		if _, err := enforcer.AddPolicy(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &testEnforcer{enforcer: enforcer}
+}
+
+// The actor has the support role (the data scope of their department d1), the other users are the targets.
+func newTestImpersonation(t *testing.T) *Impersonation {
+	impersonation, casbinCfg := config.C.Security.Impersonation, config.C.Middleware.Casbin
+	t.Cleanup(func() { config.C.Security.Impersonation, config.C.Middleware.Casbin = impersonation, casbinCfg })
+	config.C.Security.Impersonation.Enable = true
+	config.C.Security.Impersonation.RoleCodes = []string{"support"}
+	config.C.Security.Impersonation.Expired = 1800
+	config.C.Middleware.Casbin.Disable = false
+
+	db := newTestDB(t, new(schema.User), new(schema.UserRole), new(schema.Role), new(schema.RoleParent),
+		new(schema.RoleElevation), new(orgschema.Department), new(orgschema.DepartmentUser))
+	user := func(id string) *schema.User {
+		return &schema.User{ID: id, Username: id, Source: schema.UserSourceLocal, Status: schema.UserStatusActivated}
+	}
+	role := func(id, dataScope string) *schema.Role {
+		return &schema.Role{ID: id, Code: id, Name: id, Status: schema.RoleStatusEnabled, DataScope: dataScope}
+	}
+	mustCreate(t, db,
+		&orgschema.Department{ID: "d1", Code: "d1", Name: "D1", Status: orgschema.DepartmentStatusEnabled},
+		&orgschema.Department{ID: "d11", Code: "d11", Name: "D11", Status: orgschema.DepartmentStatusEnabled, ParentID: "d1", ParentPath: "d1."},
+		&orgschema.Department{ID: "d2", Code: "d2", Name: "D2", Status: orgschema.DepartmentStatusEnabled},
+		role("support", schema.RoleDataScopeDepartment),
+		role("viewer", schema.RoleDataScopeDepartment),
+		role("editor", schema.RoleDataScopeDepartment),
+		role("wide", schema.RoleDataScopeAll),
+		role("tree", schema.RoleDataScopeDepartmentAndChildren),
+		user("actor"), user("viewer"), user("other"), user("editor"), user("wide"), user("tree"), user("norole"), user("support"),
+		&schema.UserRole{ID: "ur1", UserID: "actor", RoleID: "support"},
+		&schema.UserRole{ID: "ur2", UserID: "viewer", RoleID: "viewer"},
+		&schema.UserRole{ID: "ur3", UserID: "other", RoleID: "viewer"},
+		&schema.UserRole{ID: "ur4", UserID: "editor", RoleID: "editor"},
+		&schema.UserRole{ID: "ur5", UserID: "wide", RoleID: "wide"},
+		&schema.UserRole{ID: "ur6", UserID: "tree", RoleID: "tree"},
+		&schema.UserRole{ID: "ur7", UserID: "support", RoleID: "support"},
+		&orgschema.DepartmentUser{ID: "du1", DepartmentID: "d1", UserID: "actor"},
+		&orgschema.DepartmentUser{ID: "du2", DepartmentID: "d1", UserID: "viewer"},
+		&orgschema.DepartmentUser{ID: "du3", DepartmentID: "d2", UserID: "other"},
+		&orgschema.DepartmentUser{ID: "du4", DepartmentID: "d1", UserID: "editor"},
+		&orgschema.DepartmentUser{ID: "du5", DepartmentID: "d1", UserID: "wide"},
+		&orgschema.DepartmentUser{ID: "du6", DepartmentID: "d1", UserID: "tree"},
+		&orgschema.DepartmentUser{ID: "du7", DepartmentID: "d1", UserID: "norole"},
+		&orgschema.DepartmentUser{ID: "du8", DepartmentID: "d1", UserID: "support"},
+	)
+
+	userDAL := &dal.User{DB: db}
+	roleDAL := &dal.Role{DB: db}
+	return &Impersonation{
+		Auth: jwtx.New(jwtx.NewStoreWithCache(jwtx.NewMemoryCache(jwtx.MemoryConfig{})), jwtx.SetSigningKey("test", "")),
+		Enforcer: newTestEnforcer(t,
+			[]string{"support", "/api/v1/users", "GET"},
+			[]string{"support", "/api/v1/departments", "GET"},
+			[]string{"viewer", "/api/v1/users", "GET"},
+			[]string{"editor", "/api/v1/users", "GET"},
+			[]string{"editor", "/api/v1/users/:id", "DELETE"},
+			[]string{"wide", "/api/v1/users", "GET"},
+			[]string{"tree", "/api/v1/departments", "GET"},
+		),
+		UserDAL:       userDAL,
+		RoleDAL:       roleDAL,
+		RoleParentDAL: &dal.RoleParent{DB: db},
+		UserBIZ: &User{
+			Cache:            cachex.NewMemoryCache(cachex.MemoryConfig{}),
+			UserDAL:          userDAL,
+			UserRoleDAL:      &dal.UserRole{DB: db},
+			RoleElevationDAL: &dal.RoleElevation{DB: db},
+			DataScopeBIZ: &orgbiz.DataScope{
+				RoleDAL:           roleDAL,
+				DepartmentDAL:     &orgdal.Department{DB: db},
+				DepartmentUserDAL: &orgdal.DepartmentUser{DB: db},
+			},
+		},
+	}
+}
+
+func TestImpersonationStart(t *testing.T) {
+	impersonation := newTestImpersonation(t)
+	ctx := util.NewUserCache(util.NewUserID(context.Background(), "actor"), util.UserCache{RoleIDs: []string{"support"}})
+	start := func(userID string) error {
+		token, err := impersonation.Start(ctx, &schema.ImpersonateForm{UserID: userID, Reason: "test"})
+		if err == nil {
+			assert.NotEmpty(t, token.AccessToken)
+		}
+		return err
+	}
+	code := func(err error) int32 {
+		if err == nil {
+			return 0
+		}
+		return errors.FromError(err).Code
+	}
+
+	// The permissions and the data scope of the target are granted to the actor
+	assert.NoError(t, start("viewer"))
+	assert.NoError(t, start("norole"), "the records of the user itself are in the data scope of the actor")
+
+	// The users out of the data scope of the actor are not found
+	assert.Equal(t, int32(404), code(start("other")))
+
+	// The permissions of the target are not a subset of the permissions of the actor
+	assert.Equal(t, int32(403), code(start("editor")))
+
+	// The data scope of the target is wider than the data scope of the actor
+	assert.Equal(t, int32(403), code(start("wide")))
+	assert.Equal(t, int32(403), code(start("tree")), "the child departments are not visible to the actor")
+
+	// The privileged users cannot be impersonated by each other
+	assert.Equal(t, int32(403), code(start("support")))
+	assert.Equal(t, int32(400), code(start("actor")))
+}
+
+func TestCheckImpersonation(t *testing.T) {
+	impersonation := config.C.Security.Impersonation
+	t.Cleanup(func() { config.C.Security.Impersonation = impersonation })
+	config.C.Security.Impersonation.Enable = true
+	config.C.Security.Impersonation.BlockedPathPrefixes = []string{"/api/v1/roles", "DELETE /api/v1/users"}
+
+	check := func(method, path string) error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(method, path, nil)
+		return checkImpersonation(c)
+	}
+	isBlocked := func(err error) bool {
+		return err != nil && errors.FromError(err).ID == config.ErrImpersonationBlockedID
+	}
+
+	assert.NoError(t, check(http.MethodGet, "/api/v1/users"))
+	assert.NoError(t, check(http.MethodPut, "/api/v1/users/u1"))
+	assert.True(t, isBlocked(check(http.MethodDelete, "/api/v1/users/u1")))
+	assert.True(t, isBlocked(check(http.MethodGet, "/api/v1/roles")))
+	assert.True(t, isBlocked(check(http.MethodPost, "/api/v1/roles/r1")))
+
+	// The current user can be read and logged out, but not changed
+	assert.NoError(t, check(http.MethodGet, "/api/v1/current/user"))
+	assert.NoError(t, check(http.MethodPost, "/api/v1/current/logout"))
+	assert.True(t, isBlocked(check(http.MethodPut, "/api/v1/current/password")))
+
+	config.C.Security.Impersonation.ReadOnly = true
+	assert.NoError(t, check(http.MethodGet, "/api/v1/users"))
+	assert.True(t, isBlocked(check(http.MethodPut, "/api/v1/users/u1")))
+
+	config.C.Security.Impersonation.Enable = false
+	err := check(http.MethodGet, "/api/v1/users")
+	assert.Equal(t, int32(401), errors.FromError(err).Code)
+}
@@ -1,17 +1,21 @@
 package biz
 
 import (
+	"bytes"
 	"context"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
 
-	"github.com/LyricTian/captcha"
 	"github.com/LyricTian/gin-admin/v10/internal/config"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/captchax"
 	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/rand"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
 	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
 	"github.com/LyricTian/gin-admin/v10/pkg/logging"
@@ -22,12 +26,39 @@ import (
 
 // Login management for RBAC
 type Login struct {
-	Cache       cachex.Cacher
-	Auth        jwtx.Auther
-	UserDAL     *dal.User
-	UserRoleDAL *dal.UserRole
-	MenuDAL     *dal.Menu
-	UserBIZ     *User
+	Cache                cachex.Cacher
+	Auth                 jwtx.Auther
+	Captcha              captchax.Captcha
+	UserDAL              *dal.User
+	UserRoleDAL          *dal.UserRole
+	RoleParentDAL        *dal.RoleParent
+	MenuDAL              *dal.Menu
+	UserBIZ              *User
+	UserTOTPBIZ          *UserTOTP
+	LoginLockBIZ         *LoginLock
+	LoginLDAPBIZ         *LoginLDAP
+	APIKeyBIZ            *APIKey
+	EmailVerificationBIZ *EmailVerification
+	RootBIZ              *Root
+	ImpersonationBIZ     *Impersonation
+	TenantProvider       TenantProvider
+	now                  func() time.Time `wire:"-"` // The clock of the login challenges (default time.Now)
+}
+
+func (a *Login) timeNow() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
+}
+
+// Pending login that waits for the 2FA code
+type loginChallenge struct {
+	UserID    string `json:"uid"`
+	Username  string `json:"username"`
+	Enroll    bool   `json:"enroll"` // 2FA is required by the roles but not enrolled yet
+	Attempts  int    `json:"attempts"`
+	ExpiresAt int64  `json:"exp"`
 }
 
 func (a *Login) ParseUserID(c *gin.Context) (string, error) {
@@ -45,13 +76,62 @@ func (a *Login) ParseUserID(c *gin.Context) (string, error) {
 	ctx := c.Request.Context()
 	ctx = util.NewUserToken(ctx, token)
 
-	userID, err := a.Auth.ParseSubject(ctx, token)
-	if err != nil {
-		if err == jwtx.ErrInvalidToken {
+	var userID, tenantID string
+	if a.APIKeyBIZ.IsAPIKey(token) {
+		apiKey, err := a.APIKeyBIZ.Verify(ctx, token, c.ClientIP())
+		if err != nil {
+			return "", err
+		} else if apiKey == nil {
 			return "", invalidToken
 		}
+
+		// The endpoints of the current user are read-only with API keys
+		if strings.HasPrefix(c.Request.URL.Path, "/api/v1/current/") && c.Request.Method != http.MethodGet {
+			return "", errors.Forbidden("", "The operation is not allowed with an API key")
+		}
+		userID = apiKey.UserID
+		tenantID = apiKey.TenantID
+		ctx = util.NewAPIKeyScopes(ctx, apiKey.Scopes)
+	} else {
+		claims, err := a.Auth.ParseClaims(newSessionMeta(ctx), token)
+		if err != nil {
+			if err == jwtx.ErrInvalidToken {
+				return "", invalidToken
+			}
+			return "", err
+		}
+		userID = claims.Subject
+		tenantID = claims.Tenant
+		ctx = util.NewSessionID(ctx, claims.FamilyID)
+
+		if claims.Actor != "" {
+			if err := checkImpersonation(c); err != nil {
+				return "", err
+			}
+			ctx = util.NewImpersonation(ctx, util.Impersonation{ActorID: claims.Actor, ExpiresAt: claims.ExpiresAt})
+			ctx = logging.NewImpersonatorID(ctx, claims.Actor)
+		}
+	}
+
+	// The request is scoped to the tenant of the user
+	ctx = util.NewTenantID(ctx, tenantID)
+	ctx = logging.NewTenantID(ctx, tenantID)
+	adminID, err := a.TenantProvider.CheckTenant(ctx, tenantID)
+	if err != nil {
 		return "", err
-	} else if userID == rootID {
+	} else if adminID != "" {
+		ctx = util.NewTenantAdminID(ctx, adminID)
+	}
+	if impersonation, ok := util.FromImpersonation(ctx); ok {
+		if err := a.checkImpersonator(ctx, impersonation.ActorID); err != nil {
+			return "", err
+		}
+	}
+
+	if userID == rootID {
+		if tenantID != "" || a.RootBIZ.LoginDisabled() {
+			return "", invalidToken
+		}
 		c.Request = c.Request.WithContext(util.NewIsRootUser(ctx))
 		return userID, nil
 	}
@@ -61,13 +141,16 @@ func (a *Login) ParseUserID(c *gin.Context) (string, error) {
 		return "", err
 	} else if ok {
 		userCache := util.ParseUserCache(userCacheVal)
+		if err := checkPasswordChange(ctx, c, userCache); err != nil {
+			return "", err
+		}
 		c.Request = c.Request.WithContext(util.NewUserCache(ctx, userCache))
 		return userID, nil
 	}
 
 	// Check user status, if not activated, force to logout
 	user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
-		QueryOptions: util.QueryOptions{SelectFields: []string{"status"}},
+		QueryOptions: util.QueryOptions{SelectFields: []string{"status", "source", "created_at", "password_reset", "password_updated_at"}},
 	})
 	if err != nil {
 		return "", err
@@ -79,36 +162,98 @@ func (a *Login) ParseUserID(c *gin.Context) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	elevatedRoleIDs, err := a.UserBIZ.GetElevatedRoleIDs(ctx, userID, roleIDs)
+	if err != nil {
+		return "", err
+	}
 
 	userCache := util.UserCache{
-		RoleIDs: roleIDs,
+		RoleIDs:            roleIDs,
+		ElevatedRoleIDs:    elevatedRoleIDs,
+		MustChangePassword: user.IsPasswordChangeRequired(),
 	}
 	err = a.Cache.Set(ctx, config.CacheNSForUser, userID, userCache.String())
 	if err != nil {
 		return "", err
 	}
 
+	if err := checkPasswordChange(ctx, c, userCache); err != nil {
+		return "", err
+	}
 	c.Request = c.Request.WithContext(util.NewUserCache(ctx, userCache))
 	return userID, nil
 }
 
+// Check the actor of the impersonation on every request, the impersonation ends once the actor is frozen or deleted.
+func (a *Login) checkImpersonator(ctx context.Context, actorID string) error {
+	invalidToken := errors.Unauthorized(config.ErrInvalidTokenID, "Invalid access token")
+	if actorID == config.C.General.Root.ID {
+		if a.RootBIZ.LoginDisabled() {
+			return invalidToken
+		}
+		return nil
+	}
+
+	actor, err := a.UserDAL.Get(ctx, actorID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"status"}},
+	})
+	if err != nil {
+		return err
+	} else if actor == nil || actor.Status != schema.UserStatusActivated {
+		return invalidToken
+	}
+	return nil
+}
+
+// The paths allowed until the user changes the reset or expired password
+var passwordChangeAllowedPathPrefixes = []string{"/api/v1/current/"}
+
+func checkPasswordChange(ctx context.Context, c *gin.Context, userCache util.UserCache) error {
+	if !userCache.MustChangePassword {
+		return nil
+	} else if _, ok := util.FromImpersonation(ctx); ok {
+		// The password cannot be changed by the impersonator
+		return nil
+	}
+
+	for _, prefix := range passwordChangeAllowedPathPrefixes {
+		if strings.HasPrefix(c.Request.URL.Path, prefix) {
+			return nil
+		}
+	}
+	return errors.Forbidden(config.ErrMustChangePasswordID, "Password is reset or expired, please change your password")
+}
+
 // This function generates a new captcha ID and returns it as a `schema.Captcha` struct. The length of
 // the captcha is determined by the `config.C.Util.Captcha.Length` configuration value.
 func (a *Login) GetCaptcha(ctx context.Context) (*schema.Captcha, error) {
+	challenge, err := a.Captcha.New(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return &schema.Captcha{
-		CaptchaID: captcha.NewLen(config.C.Util.Captcha.Length),
+		CaptchaID:   challenge.ID,
+		CaptchaType: challenge.Type,
+		PieceSize:   challenge.PieceSize,
+		PieceY:      challenge.PieceY,
 	}, nil
 }
 
 // Response captcha image
-func (a *Login) ResponseCaptcha(ctx context.Context, w http.ResponseWriter, id string, reload bool) error {
-	if reload && !captcha.Reload(id) {
-		return errors.NotFound("", "Captcha id not found")
+func (a *Login) ResponseCaptcha(ctx context.Context, w http.ResponseWriter, id, part string, reload bool) error {
+	if reload {
+		if err := a.Captcha.Reload(ctx, id); err != nil {
+			if err == captchax.ErrNotFound {
+				return errors.NotFound("", "Captcha id not found")
+			}
+			return err
+		}
 	}
 
-	err := captcha.WriteImage(w, id, config.C.Util.Captcha.Width, config.C.Util.Captcha.Height)
+	var buf bytes.Buffer
+	contentType, err := a.Captcha.Write(ctx, &buf, id, part)
 	if err != nil {
-		if err == captcha.ErrNotFound {
+		if err == captchax.ErrNotFound {
 			return errors.NotFound("", "Captcha id not found")
 		}
 		return err
@@ -117,41 +262,103 @@ func (a *Login) ResponseCaptcha(ctx context.Context, w http.ResponseWriter, id s
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
-	w.Header().Set("Content-Type", "image/png")
-	return nil
+	w.Header().Set("Content-Type", contentType)
+	_, err = buf.WriteTo(w)
+	return err
 }
 
-func (a *Login) genUserToken(ctx context.Context, userID string) (*schema.LoginToken, error) {
-	token, err := a.Auth.GenerateToken(ctx, userID)
+// Verify the captcha, which is only required after n failed logins of the username or client IP if configured.
+func (a *Login) verifyCaptcha(ctx context.Context, formItem *schema.LoginForm) error {
+	if n := config.C.Util.Captcha.RequireAfterFailures; n > 0 {
+		failures, err := a.LoginLockBIZ.Failures(ctx, formItem.Username, util.FromClientIP(ctx))
+		if err != nil {
+			return err
+		} else if failures < n {
+			return nil
+		}
+	}
+
+	if formItem.CaptchaID == "" {
+		return errors.BadRequest(config.ErrCaptchaRequiredID, "Captcha is required")
+	}
+	ok, err := a.Captcha.Verify(ctx, formItem.CaptchaID, formItem.CaptchaCode)
 	if err != nil {
-		return nil, err
+		return err
+	} else if !ok {
+		return errors.BadRequest(config.ErrInvalidCaptchaID, "Incorrect captcha")
 	}
+	return nil
+}
+
+// Client info of the request, recorded with the login session
+func newSessionMeta(ctx context.Context) context.Context {
+	userAgent := util.FromUserAgent(ctx)
+	tenantID, _ := util.FromTenantID(ctx)
+	return jwtx.NewSessionMeta(ctx, jwtx.SessionMeta{
+		Device:    util.ParseDevice(userAgent),
+		IP:        util.FromClientIP(ctx),
+		UserAgent: userAgent,
+		Tenant:    tenantID,
+	})
+}
 
-	tokenBuf, err := token.EncodeToJSON()
+func (a *Login) genUserToken(ctx context.Context, userID string) (*schema.LoginToken, error) {
+	token, err := a.Auth.GenerateToken(newSessionMeta(ctx), userID)
 	if err != nil {
 		return nil, err
 	}
-	logging.Context(ctx).Info("Generate user token", zap.Any("token", string(tokenBuf)))
+	return a.toLoginToken(ctx, token), nil
+}
+
+func (a *Login) toLoginToken(ctx context.Context, token jwtx.TokenInfo) *schema.LoginToken {
+	// Refresh token is a long-lived credential, keep it out of the logs
+	logging.Context(ctx).Info("Generate user token",
+		zap.String("access_token", token.GetAccessToken()),
+		zap.Int64("expires_at", token.GetExpiresAt()),
+		zap.Int64("refresh_expires_at", token.GetRefreshExpiresAt()),
+	)
 
 	return &schema.LoginToken{
-		AccessToken: token.GetAccessToken(),
-		TokenType:   token.GetTokenType(),
-		ExpiresAt:   token.GetExpiresAt(),
-	}, nil
+		AccessToken:      token.GetAccessToken(),
+		TokenType:        token.GetTokenType(),
+		ExpiresAt:        token.GetExpiresAt(),
+		RefreshToken:     token.GetRefreshToken(),
+		RefreshExpiresAt: token.GetRefreshExpiresAt(),
+	}
 }
 
 func (a *Login) Login(ctx context.Context, formItem *schema.LoginForm) (*schema.LoginToken, error) {
 	// verify captcha
-	if !captcha.VerifyString(formItem.CaptchaID, formItem.CaptchaCode) {
-		return nil, errors.BadRequest(config.ErrInvalidCaptchaID, "Incorrect captcha")
+	if err := a.verifyCaptcha(ctx, formItem); err != nil {
+		return nil, err
 	}
 
 	ctx = logging.NewTag(ctx, logging.TagKeyLogin)
 
-	// login by root
-	if formItem.Username == config.C.General.Root.Username {
-		if formItem.Password != config.C.General.Root.Password {
-			return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
+	// check the failed logins of the username and client IP
+	clientIP := util.FromClientIP(ctx)
+	if err := a.LoginLockBIZ.Check(ctx, formItem.Username, clientIP); err != nil {
+		return nil, err
+	}
+	loginFailed := func(user *schema.User) error {
+		if err := a.LoginLockBIZ.Failure(ctx, formItem.Username, clientIP, user); err != nil {
+			logging.Context(ctx).Error("Failed to record login failure", zap.Error(err))
+		}
+		return errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
+	}
+
+	// login by root (only in the default tenant)
+	if tenantID, _ := util.FromTenantID(ctx); tenantID == "" && formItem.Username == config.C.General.Root.Username {
+		if ok, err := a.RootBIZ.Verify(ctx, formItem.Password); err != nil {
+			return nil, err
+		} else if !ok {
+			if a.RootBIZ.LoginDisabled() {
+				logging.Context(ctx).Warn("Login by root is disabled")
+			}
+			return nil, loginFailed(nil)
+		}
+		if err := a.LoginLockBIZ.Success(ctx, formItem.Username, nil); err != nil {
+			return nil, err
 		}
 
 		userID := config.C.General.Root.ID
@@ -163,60 +370,271 @@ func (a *Login) Login(ctx context.Context, formItem *schema.LoginForm) (*schema.
 	// get user info
 	user, err := a.UserDAL.GetByUsername(ctx, formItem.Username, schema.UserQueryOptions{
 		QueryOptions: util.QueryOptions{
-			SelectFields: []string{"id", "password", "status"},
+			SelectFields: []string{"id", "password", "status", "locked_until", "source"},
 		},
 	})
 	if err != nil {
 		return nil, err
-	} else if user == nil {
-		return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
-	} else if user.Status != schema.UserStatusActivated {
+	} else if user != nil && user.Status != schema.UserStatusActivated {
 		return nil, errors.BadRequest("", "User status is not activated, please contact the administrator")
 	}
 
-	// check password
-	if err := hash.CompareHashAndPassword(user.Password, formItem.Password); err != nil {
-		return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
+	// The local users are checked first, the others are authenticated by the directory
+	if a.LoginLDAPBIZ.Enabled() && (user == nil || user.Source == schema.UserSourceLDAP) {
+		ldapUser, err := a.LoginLDAPBIZ.Authenticate(ctx, formItem.Username, formItem.Password, user)
+		if err != nil {
+			return nil, err
+		} else if ldapUser == nil {
+			return nil, loginFailed(user)
+		}
+		user = ldapUser
+	} else if user == nil {
+		return nil, loginFailed(nil)
+	} else if err := hash.CompareHashAndPassword(user.Password, formItem.Password); err != nil {
+		return nil, loginFailed(user)
+	}
+	ctx = logging.NewUserID(ctx, user.ID)
+	token, err := a.continueLogin(ctx, user.ID, formItem.Username)
+	if err != nil {
+		return nil, err
+	} else if token.MFARequired {
+		// The failures are reset after the 2FA code is verified, so that a new challenge does not renew the attempts
+		return token, nil
 	}
 
-	userID := user.ID
-	ctx = logging.NewUserID(ctx, userID)
+	if err := a.LoginLockBIZ.Success(ctx, formItem.Username, user); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// The user is authenticated by the password or an identity provider, the 2FA code is required as the second step
+// when it is enabled or required by the roles.
+func (a *Login) continueLogin(ctx context.Context, userID, username string) (*schema.LoginToken, error) {
+	enabled, required, err := a.UserTOTPBIZ.Check(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if enabled || required {
+		return a.newLoginChallenge(ctx, &loginChallenge{
+			UserID:   userID,
+			Username: username,
+			Enroll:   !enabled,
+		})
+	}
+
+	return a.completeLogin(ctx, userID, username)
+}
+
+func (a *Login) completeLogin(ctx context.Context, userID, username string) (*schema.LoginToken, error) {
+	user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"source", "created_at", "password_reset", "password_updated_at"}},
+	})
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, errors.BadRequest(config.ErrInvalidUsernameOrPassword, "Incorrect username or password")
+	}
 
 	// set user cache with role ids
 	roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	elevatedRoleIDs, err := a.UserBIZ.GetElevatedRoleIDs(ctx, userID, roleIDs)
+	if err != nil {
+		return nil, err
+	}
 
-	userCache := util.UserCache{RoleIDs: roleIDs}
+	userCache := util.UserCache{
+		RoleIDs:            roleIDs,
+		ElevatedRoleIDs:    elevatedRoleIDs,
+		MustChangePassword: user.IsPasswordChangeRequired(),
+	}
 	err = a.Cache.Set(ctx, config.CacheNSForUser, userID, userCache.String(),
 		time.Duration(config.C.Dictionary.UserCacheExp)*time.Hour)
 	if err != nil {
 		logging.Context(ctx).Error("Failed to set cache", zap.Error(err))
 	}
-	logging.Context(ctx).Info("Login success", zap.String("username", formItem.Username))
+	logging.Context(ctx).Info("Login success", zap.String("username", username))
 
 	// generate token
-	return a.genUserToken(ctx, userID)
+	token, err := a.genUserToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	token.MustChangePassword = userCache.MustChangePassword
+	return token, nil
 }
 
-func (a *Login) RefreshToken(ctx context.Context) (*schema.LoginToken, error) {
-	userID := util.FromUserID(ctx)
+func (a *Login) newLoginChallenge(ctx context.Context, challenge *loginChallenge) (*schema.LoginToken, error) {
+	token, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return nil, err
+	}
 
-	user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
-		QueryOptions: util.QueryOptions{
-			SelectFields: []string{"status"},
-		},
+	expiration := time.Duration(config.C.Security.MFA.ChallengeExpired) * time.Second
+	challenge.ExpiresAt = a.timeNow().Add(expiration).Unix()
+	if err := a.Cache.Set(ctx, config.CacheNSForMFA, token, json.MarshalToString(challenge), expiration); err != nil {
+		return nil, err
+	}
+
+	logging.Context(ctx).Info("Login password verified, waiting for 2FA", zap.Bool("enroll", challenge.Enroll))
+	return &schema.LoginToken{
+		MFARequired: true,
+		MFAEnroll:   challenge.Enroll,
+		MFAToken:    token,
+	}, nil
+}
+
+// Take the login challenge out of the cache, so that a challenge can be verified only once at a time.
+func (a *Login) takeLoginChallenge(ctx context.Context, token string) (*loginChallenge, error) {
+	val, ok, err := a.Cache.GetAndDelete(ctx, config.CacheNSForMFA, token)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.BadRequest(config.ErrInvalidMFATokenID, "Invalid or expired 2FA token")
+	}
+
+	challenge := new(loginChallenge)
+	if err := json.Unmarshal([]byte(val), challenge); err != nil || challenge.ExpiresAt <= a.timeNow().Unix() {
+		return nil, errors.BadRequest(config.ErrInvalidMFATokenID, "Invalid or expired 2FA token")
+	}
+	return challenge, nil
+}
+
+// Put back the challenge after a failed attempt, until the attempts are exhausted.
+func (a *Login) retryLoginChallenge(ctx context.Context, token string, challenge *loginChallenge) error {
+	challenge.Attempts++
+	remaining := time.Unix(challenge.ExpiresAt, 0).Sub(a.timeNow())
+	if challenge.Attempts >= config.C.Security.MFA.MaxAttempts || remaining <= 0 {
+		logging.Context(ctx).Warn("Too many 2FA attempts, login challenge revoked", zap.Int("attempts", challenge.Attempts))
+		return nil
+	}
+	return a.Cache.Set(ctx, config.CacheNSForMFA, token, json.MarshalToString(challenge), remaining)
+}
+
+// Generate the 2FA secret during login, for the users whose roles require 2FA but have not enrolled.
+func (a *Login) LoginEnrollMFA(ctx context.Context, formItem *schema.LoginMFAEnrollForm) (*schema.UserTOTPEnrollment, error) {
+	challenge, err := a.takeLoginChallenge(ctx, formItem.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := time.Unix(challenge.ExpiresAt, 0).Sub(a.timeNow())
+	if !challenge.Enroll || remaining <= 0 {
+		return nil, errors.BadRequest(config.ErrInvalidMFATokenID, "Invalid or expired 2FA token")
+	}
+	if err := a.Cache.Set(ctx, config.CacheNSForMFA, formItem.MFAToken, json.MarshalToString(challenge), remaining); err != nil {
+		return nil, err
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeyLogin)
+	ctx = logging.NewUserID(ctx, challenge.UserID)
+	return a.UserTOTPBIZ.Enroll(ctx, challenge.UserID)
+}
+
+// Complete the login with the 2FA code (the recovery codes are returned if the enrollment is verified)
+func (a *Login) LoginMFA(ctx context.Context, formItem *schema.LoginMFAForm) (*schema.LoginToken, error) {
+	challenge, err := a.takeLoginChallenge(ctx, formItem.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeyLogin)
+	ctx = logging.NewUserID(ctx, challenge.UserID)
+
+	// The 2FA failures are counted with the password failures of the username and the client IP
+	clientIP := util.FromClientIP(ctx)
+	if err := a.LoginLockBIZ.Check(ctx, challenge.Username, clientIP); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if challenge.Enroll {
+		result, verr := a.UserTOTPBIZ.Enable(ctx, challenge.UserID, formItem.Code)
+		if verr == nil {
+			recoveryCodes = result.RecoveryCodes
+		}
+		err = verr
+	} else {
+		err = a.UserTOTPBIZ.Verify(ctx, challenge.UserID, formItem.Code)
+	}
+	if err != nil {
+		if e, ok := errors.As(err); ok && e.ID == config.ErrInvalidMFACodeID {
+			logging.Context(ctx).Warn("Incorrect 2FA code")
+			if ferr := a.LoginLockBIZ.Failure(ctx, challenge.Username, clientIP, &schema.User{ID: challenge.UserID}); ferr != nil {
+				logging.Context(ctx).Error("Failed to record login failure", zap.Error(ferr))
+			}
+			if rerr := a.retryLoginChallenge(ctx, formItem.MFAToken, challenge); rerr != nil {
+				return nil, rerr
+			}
+		}
+		return nil, err
+	}
+
+	// The user may be freezed while waiting for the code
+	user, err := a.UserDAL.Get(ctx, challenge.UserID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "status", "locked_until"}},
 	})
 	if err != nil {
 		return nil, err
-	} else if user == nil {
-		return nil, errors.BadRequest("", "Incorrect user")
-	} else if user.Status != schema.UserStatusActivated {
+	} else if user == nil || user.Status != schema.UserStatusActivated {
 		return nil, errors.BadRequest("", "User status is not activated, please contact the administrator")
 	}
+	if err := a.LoginLockBIZ.Success(ctx, challenge.Username, user); err != nil {
+		return nil, err
+	}
 
-	return a.genUserToken(ctx, userID)
+	token, err := a.completeLogin(ctx, challenge.UserID, challenge.Username)
+	if err != nil {
+		return nil, err
+	}
+	token.RecoveryCodes = recoveryCodes
+	return token, nil
+}
+
+// Exchange the refresh token for a new token pair (the refresh token is rotated)
+func (a *Login) RefreshToken(ctx context.Context, formItem *schema.RefreshTokenForm) (*schema.LoginToken, error) {
+	token, err := a.Auth.RefreshToken(newSessionMeta(ctx), formItem.RefreshToken, func(ctx context.Context, userID string) error {
+		// The user is checked in the tenant of the refresh token
+		tenantID := jwtx.FromSessionMeta(ctx).Tenant
+		ctx = util.NewTenantID(ctx, tenantID)
+		if _, err := a.TenantProvider.CheckTenant(ctx, tenantID); err != nil {
+			return err
+		}
+
+		if userID == config.C.General.Root.ID {
+			if tenantID != "" || a.RootBIZ.LoginDisabled() {
+				return errors.Unauthorized(config.ErrInvalidTokenID, "Invalid refresh token")
+			}
+			return nil
+		}
+
+		user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
+			QueryOptions: util.QueryOptions{
+				SelectFields: []string{"status"},
+			},
+		})
+		if err != nil {
+			return err
+		} else if user == nil {
+			return errors.BadRequest("", "Incorrect user")
+		} else if user.Status != schema.UserStatusActivated {
+			return errors.BadRequest("", "User status is not activated, please contact the administrator")
+		}
+		return nil
+	})
+	if err != nil {
+		if err == jwtx.ErrRefreshTokenReused {
+			logging.Context(logging.NewTag(ctx, logging.TagKeyLogin)).Warn("Refresh token reused, token family revoked")
+		}
+		if err == jwtx.ErrInvalidToken || err == jwtx.ErrRefreshTokenReused {
+			return nil, errors.Unauthorized(config.ErrInvalidTokenID, "Invalid refresh token")
+		}
+		return nil, err
+	}
+
+	return a.toLoginToken(ctx, token), nil
 }
 
 func (a *Login) Logout(ctx context.Context) error {
@@ -235,11 +653,77 @@ func (a *Login) Logout(ctx context.Context) error {
 	if err != nil {
 		logging.Context(ctx).Error("Failed to delete user cache", zap.Error(err))
 	}
-	logging.Context(ctx).Info("Logout success")
+	if _, ok := util.FromImpersonation(ctx); ok {
+		logging.Context(logging.NewTag(ctx, logging.TagKeySecurity)).Info("Impersonation ended", zap.String("target_user_id", userID))
+	} else {
+		logging.Context(ctx).Info("Logout success")
+	}
+
+	return nil
+}
+
+// Query the active sessions of the current user
+func (a *Login) QuerySessions(ctx context.Context) (schema.UserSessions, error) {
+	sessions, err := a.Auth.QuerySessions(ctx, util.FromUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return schema.NewUserSessions(sessions, util.FromSessionID(ctx)), nil
+}
 
+// Revoke a session of the current user (e.g. sign out a lost device)
+func (a *Login) RevokeSession(ctx context.Context, id string) error {
+	ctx = logging.NewTag(ctx, logging.TagKeyLogout)
+	if err := a.Auth.RevokeSession(ctx, util.FromUserID(ctx), id); err != nil {
+		if err == jwtx.ErrSessionNotFound {
+			return errors.NotFound("", "Session not found")
+		}
+		return err
+	}
+	logging.Context(ctx).Info("Revoke session", zap.String("session_id", id))
 	return nil
 }
 
+// Get the 2FA status of the current user
+func (a *Login) GetMFAStatus(ctx context.Context) (*schema.UserTOTPStatus, error) {
+	if util.FromIsRootUser(ctx) {
+		return &schema.UserTOTPStatus{}, nil
+	}
+	return a.UserTOTPBIZ.GetStatus(ctx, util.FromUserID(ctx))
+}
+
+// Start the 2FA enrollment of the current user
+func (a *Login) EnrollMFA(ctx context.Context) (*schema.UserTOTPEnrollment, error) {
+	if util.FromIsRootUser(ctx) {
+		return nil, errors.BadRequest("", "Root user cannot enable 2FA")
+	}
+	return a.UserTOTPBIZ.Enroll(ctx, util.FromUserID(ctx))
+}
+
+// Verify the enrollment and enable the 2FA of the current user
+func (a *Login) EnableMFA(ctx context.Context, formItem *schema.UserTOTPCodeForm) (*schema.UserTOTPRecoveryCodes, error) {
+	if util.FromIsRootUser(ctx) {
+		return nil, errors.BadRequest("", "Root user cannot enable 2FA")
+	}
+	return a.UserTOTPBIZ.Enable(logging.NewTag(ctx, logging.TagKeyLogin), util.FromUserID(ctx), formItem.Code)
+}
+
+// Disable the 2FA of the current user
+func (a *Login) DisableMFA(ctx context.Context, formItem *schema.UserTOTPCodeForm) error {
+	if util.FromIsRootUser(ctx) {
+		return errors.BadRequest("", "2FA is not enabled")
+	}
+	return a.UserTOTPBIZ.Disable(logging.NewTag(ctx, logging.TagKeyLogin), util.FromUserID(ctx), formItem.Code)
+}
+
+// Regenerate the recovery codes of the current user
+func (a *Login) RegenerateMFARecoveryCodes(ctx context.Context, formItem *schema.UserTOTPCodeForm) (*schema.UserTOTPRecoveryCodes, error) {
+	if util.FromIsRootUser(ctx) {
+		return nil, errors.BadRequest("", "2FA is not enabled")
+	}
+	return a.UserTOTPBIZ.RegenerateRecoveryCodes(ctx, util.FromUserID(ctx), formItem.Code)
+}
+
 // Get user info
 func (a *Login) GetUserInfo(ctx context.Context) (*schema.User, error) {
 	if util.FromIsRootUser(ctx) {
@@ -262,6 +746,7 @@ func (a *Login) GetUserInfo(ctx context.Context) (*schema.User, error) {
 	} else if user == nil {
 		return nil, errors.NotFound("", "User not found")
 	}
+	user.MustChangePassword = user.IsPasswordChangeRequired()
 
 	userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
 		UserID: userID,
@@ -273,19 +758,28 @@ func (a *Login) GetUserInfo(ctx context.Context) (*schema.User, error) {
 	}
 	user.Roles = userRoleResult.Data
 
+	user.Impersonation, err = a.ImpersonationBIZ.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
 // Change login password
 func (a *Login) UpdatePassword(ctx context.Context, updateItem *schema.UpdateLoginPassword) error {
 	if util.FromIsRootUser(ctx) {
-		return errors.BadRequest("", "Root user cannot change password")
+		if ok, err := a.RootBIZ.Verify(ctx, updateItem.OldPassword); err != nil {
+			return err
+		} else if !ok {
+			return errors.BadRequest("", "Incorrect old password")
+		}
+		return a.RootBIZ.SetPassword(ctx, updateItem.NewPassword)
 	}
 
 	userID := util.FromUserID(ctx)
 	user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
 		QueryOptions: util.QueryOptions{
-			SelectFields: []string{"password"},
+			SelectFields: append([]string{"id", "source", "created_at"}, userPasswordFields...),
 		},
 	})
 	if err != nil {
@@ -294,17 +788,25 @@ func (a *Login) UpdatePassword(ctx context.Context, updateItem *schema.UpdateLog
 		return errors.NotFound("", "User not found")
 	}
 
+	if !user.IsLocal() {
+		return errors.BadRequest("", "The password is managed by %s", user.Source)
+	}
+
 	// check old password
 	if err := hash.CompareHashAndPassword(user.Password, updateItem.OldPassword); err != nil {
 		return errors.BadRequest("", "Incorrect old password")
 	}
 
 	// update password
-	newPassword, err := hash.GeneratePassword(updateItem.NewPassword)
-	if err != nil {
+	if err := setUserPassword(user, updateItem.NewPassword, false); err != nil {
 		return err
 	}
-	return a.UserDAL.UpdatePasswordByID(ctx, userID, newPassword)
+	if err := a.UserDAL.Update(ctx, user, userPasswordFields...); err != nil {
+		return err
+	}
+
+	// the password change is no longer required
+	return a.Cache.Delete(ctx, config.CacheNSForUser, userID)
 }
 
 // Query menus based on user permissions
@@ -313,9 +815,21 @@ func (a *Login) QueryMenus(ctx context.Context) (schema.Menus, error) {
 		Status: schema.MenuStatusEnabled,
 	}
 
-	isRoot := util.FromIsRootUser(ctx)
+	isRoot := util.FromIsRootUser(ctx) || util.FromIsTenantAdmin(ctx)
 	if !isRoot {
-		menuQueryParams.UserID = util.FromUserID(ctx)
+		roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, util.FromUserID(ctx))
+		if err != nil {
+			return nil, err
+		} else if len(roleIDs) == 0 {
+			return nil, nil
+		}
+
+		// The menus of the ancestor roles are inherited
+		roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+		if err != nil {
+			return nil, err
+		}
+		menuQueryParams.InRoleIDs = append(roleIDs, roleParentResult.Data.AncestorIDs(roleIDs...)...)
 	}
 	menuResult, err := a.MenuDAL.Query(ctx, menuQueryParams, schema.MenuQueryOptions{
 		QueryOptions: util.QueryOptions{
@@ -353,22 +867,44 @@ func (a *Login) QueryMenus(ctx context.Context) (schema.Menus, error) {
 }
 
 // Update current user info
-func (a *Login) UpdateUser(ctx context.Context, updateItem *schema.UpdateCurrentUser) error {
+func (a *Login) UpdateUser(ctx context.Context, updateItem *schema.UpdateCurrentUser) (*schema.UpdateCurrentUserResult, error) {
 	if util.FromIsRootUser(ctx) {
-		return errors.BadRequest("", "Root user cannot update")
+		return nil, errors.BadRequest("", "Root user cannot update")
 	}
 
 	userID := util.FromUserID(ctx)
 	user, err := a.UserDAL.Get(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
 	} else if user == nil {
-		return errors.NotFound("", "User not found")
+		return nil, errors.NotFound("", "User not found")
+	}
+
+	result := new(schema.UpdateCurrentUserResult)
+	fields := []string{"name", "phone", "remark"}
+	if user.Email != updateItem.Email {
+		if !user.IsLocal() {
+			return nil, errors.BadRequest("", "The email is managed by %s", user.Source)
+		}
+
+		if updateItem.Email == "" {
+			user.Email = ""
+			user.EmailVerified = false
+			fields = append(fields, "email", "email_verified")
+		} else {
+			// The new email is committed after it is confirmed
+			if err := a.EmailVerificationBIZ.Start(ctx, user, updateItem.Email); err != nil {
+				return nil, err
+			}
+			result.PendingEmail = updateItem.Email
+		}
 	}
 
 	user.Name = updateItem.Name
 	user.Phone = updateItem.Phone
-	user.Email = updateItem.Email
 	user.Remark = updateItem.Remark
-	return a.UserDAL.Update(ctx, user, "name", "phone", "email", "remark")
+	if err := a.UserDAL.Update(ctx, user, fields...); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
@@ -0,0 +1,208 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/ldapx"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Login with LDAP / Active Directory
+type LoginLDAP struct {
+	Trans             *util.Trans
+	UserDAL           *dal.User
+	UserRoleDAL       *dal.UserRole
+	RoleDAL           *dal.Role
+	RoleConstraintBIZ *RoleConstraint
+}
+
+func (a *LoginLDAP) Enabled() bool {
+	return config.C.Security.LDAP.Enable
+}
+
+func (a *LoginLDAP) newClient() *ldapx.Client {
+	cfg := config.C.Security.LDAP
+	return ldapx.New(ldapx.Config{
+		URL:                cfg.URL,
+		StartTLS:           cfg.StartTLS,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		Timeout:            time.Duration(cfg.Timeout) * time.Second,
+		BindDN:             cfg.BindDN,
+		BindPassword:       cfg.BindPassword,
+		BaseDN:             cfg.BaseDN,
+		UserFilter:         cfg.UserFilter,
+		UsernameAttr:       cfg.UsernameAttr,
+		NameAttr:           cfg.NameAttr,
+		EmailAttr:          cfg.EmailAttr,
+		PhoneAttr:          cfg.PhoneAttr,
+		MemberOfAttr:       cfg.MemberOfAttr,
+		GroupBaseDN:        cfg.GroupBaseDN,
+		GroupFilter:        cfg.GroupFilter,
+		GroupNameAttr:      cfg.GroupNameAttr,
+	})
+}
+
+// Authenticate the user against the directory, the user (nil if not exists yet) is created or updated with the
+// directory attributes and the roles mapped from the groups. The returned user is nil if the credentials are invalid.
+func (a *LoginLDAP) Authenticate(ctx context.Context, username, password string, user *schema.User) (*schema.User, error) {
+	entry, err := a.newClient().Authenticate(ctx, username, password)
+	if err != nil {
+		if errors.Is(err, ldapx.ErrInvalidCredentials) || errors.Is(err, ldapx.ErrUserNotFound) {
+			return nil, nil
+		}
+		logging.Context(ctx).Error("Failed to authenticate with LDAP", zap.String("username", username), zap.Error(err))
+		return nil, err
+	}
+
+	roleIDs, managedRoleIDs, err := a.mapRoles(ctx, entry.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	var userRoles schema.UserRoles
+	if user != nil && len(managedRoleIDs) > 0 {
+		userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+			UserID: user.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		userRoles = userRoleResult.Data
+	}
+
+	name := entry.Name
+	if name == "" {
+		name = username
+	}
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if user == nil {
+			user = &schema.User{
+				ID:            util.NewXID(),
+				Username:      username,
+				Name:          truncate(name, 64),
+				Email:         truncate(entry.Email, 128),
+				EmailVerified: entry.Email != "", // Trust the directory
+				Phone:         truncate(entry.Phone, 32),
+				Status:        schema.UserStatusActivated,
+				Source:        schema.UserSourceLDAP,
+				CreatedAt:     time.Now(),
+			}
+			logging.Context(ctx).Info("Provision user from LDAP", zap.String("username", username), zap.String("dn", entry.DN))
+			if err := a.UserDAL.Create(ctx, user); err != nil {
+				return err
+			}
+		} else {
+			// The directory attributes are refreshed on each login
+			user.Name = truncate(name, 64)
+			user.Email = truncate(entry.Email, 128)
+			user.EmailVerified = entry.Email != ""
+			user.Phone = truncate(entry.Phone, 32)
+			user.UpdatedAt = time.Now()
+			if err := a.UserDAL.Update(ctx, user, "name", "email", "email_verified", "phone", "updated_at"); err != nil {
+				return err
+			}
+		}
+		return a.syncRoles(ctx, user.ID, userRoles, roleIDs, managedRoleIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Get the role IDs mapped from the groups, and the IDs of all roles managed by the mapping rules.
+func (a *LoginLDAP) mapRoles(ctx context.Context, groups []ldapx.Group) ([]string, []string, error) {
+	cfg := config.C.Security.LDAP
+	codes := append([]string{}, cfg.DefaultRoleCodes...)
+	managedCodes := append([]string{}, cfg.DefaultRoleCodes...)
+	for _, rule := range cfg.GroupRoles {
+		managedCodes = append(managedCodes, rule.RoleCodes...)
+		for _, group := range groups {
+			if group.Match(rule.Group) {
+				codes = append(codes, rule.RoleCodes...)
+				break
+			}
+		}
+	}
+
+	if len(managedCodes) == 0 {
+		return nil, nil, nil
+	}
+
+	managedRoleIDs, err := a.RoleDAL.GetIDsByCodes(ctx, managedCodes)
+	if err != nil {
+		return nil, nil, err
+	}
+	var roleIDs []string
+	if len(codes) > 0 {
+		roleIDs, err = a.RoleDAL.GetIDsByCodes(ctx, codes)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return roleIDs, managedRoleIDs, nil
+}
+
+// Sync the managed roles of the user, the roles assigned by the administrator are kept.
+func (a *LoginLDAP) syncRoles(ctx context.Context, userID string, userRoles schema.UserRoles, roleIDs, managedRoleIDs []string) error {
+	if len(managedRoleIDs) == 0 {
+		return nil
+	}
+
+	managed := make(map[string]bool, len(managedRoleIDs))
+	for _, roleID := range managedRoleIDs {
+		managed[roleID] = true
+	}
+	mapped := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		mapped[roleID] = true
+	}
+
+	var removed, added, synced schema.UserRoles
+	assigned := make(map[string]bool)
+	for _, userRole := range userRoles {
+		if managed[userRole.RoleID] && !mapped[userRole.RoleID] {
+			removed = append(removed, userRole)
+			continue
+		}
+		assigned[userRole.RoleID] = true
+		synced = append(synced, userRole)
+	}
+	for _, roleID := range roleIDs {
+		if assigned[roleID] {
+			continue
+		}
+		assigned[roleID] = true
+		added = append(added, &schema.UserRole{
+			ID:        util.NewXID(),
+			UserID:    userID,
+			RoleID:    roleID,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	// The mapped roles are checked against the separation of duties constraints
+	if err := a.RoleConstraintBIZ.Check(ctx, userID, append(synced, added...)); err != nil {
+		return err
+	}
+
+	for _, userRole := range removed {
+		if err := a.UserRoleDAL.Delete(ctx, userRole.ID); err != nil {
+			return err
+		}
+	}
+	for _, userRole := range added {
+		if err := a.UserRoleDAL.Create(ctx, userRole); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package biz
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Lock state of a username or a client IP, the failures are counted in a separate key by atomic increments.
+type loginLockState struct {
+	Locks       int   `json:"locks"`
+	LockedUntil int64 `json:"locked_until"`
+}
+
+// Progressive delay and lockout after failed logins
+type LoginLock struct {
+	Cache   cachex.Cacher
+	UserDAL *dal.User
+	UserBIZ *User
+}
+
+// The failures are also counted for the captcha threshold when the lockout is disabled.
+func (a *LoginLock) enabled() bool {
+	return config.C.Security.Lockout.Enable || config.C.Util.Captcha.RequireAfterFailures > 0
+}
+
+func (a *LoginLock) usernameKey(username string) string {
+	return "u:" + username
+}
+
+func (a *LoginLock) ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (a *LoginLock) failuresKey(key string) string {
+	return key + ":failures"
+}
+
+func (a *LoginLock) delayKey(key string) string {
+	return key + ":delay"
+}
+
+func (a *LoginLock) getState(ctx context.Context, key string) (*loginLockState, error) {
+	item := new(loginLockState)
+	val, ok, err := a.Cache.Get(ctx, config.CacheNSForLoginFailure, key)
+	if err != nil {
+		return nil, err
+	} else if ok {
+		_ = json.Unmarshal([]byte(val), item)
+	}
+	return item, nil
+}
+
+func (a *LoginLock) setState(ctx context.Context, key string, item *loginLockState) error {
+	expiration := time.Duration(config.C.Security.Lockout.FailureWindow) * time.Second
+	if d := time.Until(time.Unix(item.LockedUntil, 0)); d > 0 {
+		// Keep the lock count after the lock ends, so the next lock lasts longer
+		expiration += d
+	}
+	return a.Cache.Set(ctx, config.CacheNSForLoginFailure, key, json.MarshalToString(item), expiration)
+}
+
+func (a *LoginLock) getInt(ctx context.Context, key string) (int64, error) {
+	val, ok, err := a.Cache.Get(ctx, config.CacheNSForLoginFailure, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	n, _ := strconv.ParseInt(val, 10, 64)
+	return n, nil
+}
+
+func (a *LoginLock) reset(ctx context.Context, key string) error {
+	for _, k := range []string{key, a.failuresKey(key), a.delayKey(key)} {
+		if err := a.Cache.Delete(ctx, config.CacheNSForLoginFailure, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check whether the username and the client IP are allowed to login now.
+func (a *LoginLock) Check(ctx context.Context, username, ip string) error {
+	if !config.C.Security.Lockout.Enable {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	for _, key := range []string{a.usernameKey(username), a.ipKey(ip)} {
+		item, err := a.getState(ctx, key)
+		if err != nil {
+			return err
+		}
+		nextAttemptAt, err := a.getInt(ctx, a.delayKey(key))
+		if err != nil {
+			return err
+		}
+
+		if item.LockedUntil > now {
+			return errors.TooManyRequests(config.ErrAccountLockedID,
+				"Too many failed logins, please try again after %s", time.Unix(item.LockedUntil, 0).Format(time.RFC3339))
+		} else if nextAttemptAt > now {
+			return errors.TooManyRequests(config.ErrTooManyLoginAttemptsID,
+				"Too many failed logins, please try again in %d seconds", nextAttemptAt-now)
+		}
+	}
+	return nil
+}
+
+// Get the recent failed logins of the username or the client IP (the larger one), a previous lock counts as the
+// threshold being reached.
+func (a *LoginLock) Failures(ctx context.Context, username, ip string) (int, error) {
+	var failures int
+	keys := []string{a.usernameKey(username)}
+	if ip != "" {
+		keys = append(keys, a.ipKey(ip))
+	}
+	for _, key := range keys {
+		item, err := a.getState(ctx, key)
+		if err != nil {
+			return 0, err
+		} else if item.Locks > 0 {
+			return math.MaxInt32, nil
+		}
+
+		n, err := a.getInt(ctx, a.failuresKey(key))
+		if err != nil {
+			return 0, err
+		} else if int(n) > failures {
+			failures = int(n)
+		}
+	}
+	return failures, nil
+}
+
+// Record a failed login of the username from the client IP, the user is the one of the username (nil if not found).
+func (a *LoginLock) Failure(ctx context.Context, username, ip string, user *schema.User) error {
+	if !a.enabled() {
+		return nil
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	lockedUntil, err := a.failure(ctx, a.usernameKey(username), config.C.Security.Lockout.MaxFailures)
+	if err != nil {
+		return err
+	} else if lockedUntil != nil {
+		var userID string
+		if user != nil {
+			userID = user.ID
+		}
+		logging.Context(ctx).Warn("Account locked by failed logins",
+			zap.String("target_user_id", userID),
+			zap.String("username", username),
+			zap.String("client_ip", ip),
+			zap.Time("locked_until", *lockedUntil))
+		// Unknown usernames are locked as well, so that the response does not reveal whether the account exists
+		if user != nil {
+			if err := a.UserDAL.UpdateLockedUntilByID(ctx, user.ID, lockedUntil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	lockedUntil, err = a.failure(ctx, a.ipKey(ip), config.C.Security.Lockout.MaxIPFailures)
+	if err != nil {
+		return err
+	} else if lockedUntil != nil {
+		logging.Context(ctx).Warn("Client IP locked by failed logins",
+			zap.String("client_ip", ip),
+			zap.Time("locked_until", *lockedUntil))
+	}
+	return nil
+}
+
+// Count the failure by an atomic increment and decide on the returned count, so that concurrent failures can not
+// overwrite each other's counts. The count is not reset by a lock, every n-th failure in the window locks again, so
+// that exactly one of the concurrent failures reaching the threshold locks.
+func (a *LoginLock) failure(ctx context.Context, key string, maxFailures int) (*time.Time, error) {
+	cfg := config.C.Security.Lockout
+	window := time.Duration(cfg.FailureWindow) * time.Second
+	failures, err := a.Cache.Incr(ctx, config.CacheNSForLoginFailure, a.failuresKey(key), window)
+	if err != nil {
+		return nil, err
+	} else if !cfg.Enable {
+		// Only counted for the captcha threshold
+		return nil, nil
+	}
+
+	now := time.Now()
+	if maxFailures > 0 {
+		if failures%int64(maxFailures) == 0 {
+			return a.lock(ctx, key, now)
+		}
+		// Failures since the last lock
+		failures %= int64(maxFailures)
+	}
+
+	if cfg.DelayBase > 0 && failures >= int64(cfg.DelayAfterFailures) {
+		delay := float64(cfg.DelayBase) * math.Pow(2, float64(failures-int64(cfg.DelayAfterFailures)))
+		delay = math.Min(delay, float64(cfg.MaxDelay))
+		d := time.Duration(delay) * time.Second
+		return nil, a.Cache.Set(ctx, config.CacheNSForLoginFailure, a.delayKey(key),
+			strconv.FormatInt(now.Add(d).Unix(), 10), d)
+	}
+	return nil, nil
+}
+
+// Lock and double the duration on each subsequent lock.
+func (a *LoginLock) lock(ctx context.Context, key string, now time.Time) (*time.Time, error) {
+	cfg := config.C.Security.Lockout
+	item, err := a.getState(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Locks++
+	duration := float64(cfg.LockDuration) * math.Pow(2, float64(item.Locks-1))
+	duration = math.Min(duration, float64(cfg.MaxLockDuration))
+	t := now.Add(time.Duration(duration) * time.Second)
+	item.LockedUntil = t.Unix()
+	if err := a.setState(ctx, key, item); err != nil {
+		return nil, err
+	}
+	return &t, a.Cache.Delete(ctx, config.CacheNSForLoginFailure, a.delayKey(key))
+}
+
+// Reset the failures of the username after a successful login, the failures of the client IP expire by themselves.
+func (a *LoginLock) Success(ctx context.Context, username string, user *schema.User) error {
+	if !a.enabled() {
+		return nil
+	}
+
+	if err := a.reset(ctx, a.usernameKey(username)); err != nil {
+		return err
+	}
+	if user != nil && user.LockedUntil != nil {
+		return a.UserDAL.UpdateLockedUntilByID(ctx, user.ID, nil)
+	}
+	return nil
+}
+
+// Unlock the user locked by failed logins, the user out of the data scope of the current user is not found.
+func (a *LoginLock) Unlock(ctx context.Context, id string) error {
+	user, err := a.UserBIZ.getInDataScope(ctx, id, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "username", "locked_until"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := a.reset(ctx, a.usernameKey(user.Username)); err != nil {
+		return err
+	}
+	if err := a.UserDAL.UpdateLockedUntilByID(ctx, id, nil); err != nil {
+		return err
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	logging.Context(ctx).Info("Account unlocked",
+		zap.String("target_user_id", id),
+		zap.String("username", user.Username))
+	return nil
+}
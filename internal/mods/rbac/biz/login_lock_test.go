@@ -0,0 +1,241 @@
+package biz
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	orgbiz "github.com/LyricTian/gin-admin/v10/internal/mods/org/biz"
+	orgdal "github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
+	orgschema "github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// Lock after 3 failures of the username (10 of the client IP) without delays, restored after the test.
+func setTestLockout(t *testing.T) {
+	lockout := config.C.Security.Lockout
+	t.Cleanup(func() { config.C.Security.Lockout = lockout })
+	config.C.Security.Lockout.Enable = true
+	config.C.Security.Lockout.FailureWindow = 900
+	config.C.Security.Lockout.DelayAfterFailures = 100
+	config.C.Security.Lockout.DelayBase = 1
+	config.C.Security.Lockout.MaxDelay = 60
+	config.C.Security.Lockout.MaxFailures = 3
+	config.C.Security.Lockout.MaxIPFailures = 10
+	config.C.Security.Lockout.LockDuration = 900
+	config.C.Security.Lockout.MaxLockDuration = 86400
+}
+
+func newTestLoginLock(t *testing.T) (*LoginLock, *gorm.DB) {
+	setTestLockout(t)
+	db := newTestDB(t, new(schema.User), new(schema.Role), new(orgschema.Department), new(orgschema.DepartmentUser))
+	mustCreate(t, db,
+		&schema.User{ID: "u1", Username: "u1", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+		&schema.Role{ID: "dept", Code: "dept", Name: "Dept", Status: schema.RoleStatusEnabled, DataScope: schema.RoleDataScopeDepartment},
+		&orgschema.Department{ID: "d1", Code: "d1", Name: "D1", Status: orgschema.DepartmentStatusEnabled},
+		&orgschema.Department{ID: "d2", Code: "d2", Name: "D2", Status: orgschema.DepartmentStatusEnabled},
+		&orgschema.DepartmentUser{ID: "du1", DepartmentID: "d1", UserID: "u1"},
+		&orgschema.DepartmentUser{ID: "du2", DepartmentID: "d2", UserID: "actor"},
+	)
+	userDAL := &dal.User{DB: db}
+	return &LoginLock{
+		Cache:   cachex.NewMemoryCache(cachex.MemoryConfig{}),
+		UserDAL: userDAL,
+		UserBIZ: &User{
+			UserDAL: userDAL,
+			DataScopeBIZ: &orgbiz.DataScope{
+				RoleDAL:           &dal.Role{DB: db},
+				DepartmentDAL:     &orgdal.Department{DB: db},
+				DepartmentUserDAL: &orgdal.DepartmentUser{DB: db},
+			},
+		},
+	}, db
+}
+
+func isTooManyRequests(err error) bool {
+	return err != nil && errors.FromError(err).Code == 429
+}
+
+func TestLoginLockThreshold(t *testing.T) {
+	lock, db := newTestLoginLock(t)
+	ctx := context.Background()
+	user := &schema.User{ID: "u1", Username: "u1"}
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, lock.Check(ctx, "u1", "10.0.0.1"))
+		assert.NoError(t, lock.Failure(ctx, "u1", "10.0.0.1", user))
+	}
+	failures, err := lock.Failures(ctx, "u1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, failures)
+	assert.NoError(t, lock.Check(ctx, "u1", "10.0.0.1"))
+
+	// The third failure reaches the threshold
+	assert.NoError(t, lock.Failure(ctx, "u1", "10.0.0.1", user))
+	assert.True(t, isTooManyRequests(lock.Check(ctx, "u1", "10.0.0.1")))
+	assert.True(t, isTooManyRequests(lock.Check(ctx, "u1", "10.0.0.2")), "the username is locked from any IP")
+	assert.NoError(t, lock.Check(ctx, "u2", "10.0.0.2"))
+
+	var stored schema.User
+	assert.NoError(t, db.First(&stored, "id=?", "u1").Error)
+	if assert.NotNil(t, stored.LockedUntil) {
+		assert.WithinDuration(t, time.Now().Add(900*time.Second), *stored.LockedUntil, 5*time.Second)
+	}
+
+	failures, err = lock.Failures(ctx, "u1", "")
+	assert.NoError(t, err)
+	assert.Greater(t, failures, 3, "a previous lock counts as the threshold being reached")
+}
+
+func TestLoginLockConcurrentFailures(t *testing.T) {
+	lock, _ := newTestLoginLock(t)
+	config.C.Security.Lockout.MaxFailures = 100
+	config.C.Security.Lockout.MaxIPFailures = 1000
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, lock.Failure(ctx, "u1", "10.0.0.1", nil))
+		}()
+	}
+	wg.Wait()
+
+	// No failure is lost by concurrent updates
+	failures, err := lock.Failures(ctx, "u1", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 50, failures)
+
+	// Concurrent failures reaching the threshold lock only once
+	config.C.Security.Lockout.MaxFailures = 60
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, lock.Failure(ctx, "u1", "10.0.0.1", nil))
+		}()
+	}
+	wg.Wait()
+
+	state, err := lock.getState(ctx, lock.usernameKey("u1"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, state.Locks)
+	assert.True(t, isTooManyRequests(lock.Check(ctx, "u1", "10.0.0.1")))
+}
+
+func TestLoginLockExpiry(t *testing.T) {
+	lock, _ := newTestLoginLock(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, lock.Failure(ctx, "u1", "", nil))
+	}
+	assert.True(t, isTooManyRequests(lock.Check(ctx, "u1", "")))
+
+	// The lock ends, but the lock count is kept
+	key := lock.usernameKey("u1")
+	state, err := lock.getState(ctx, key)
+	assert.NoError(t, err)
+	state.LockedUntil = time.Now().Add(-time.Second).Unix()
+	assert.NoError(t, lock.setState(ctx, key, state))
+	assert.NoError(t, lock.Check(ctx, "u1", ""))
+
+	// The next lock lasts twice as long
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, lock.Failure(ctx, "u1", "", nil))
+	}
+	state, err = lock.getState(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, state.Locks)
+	assert.InDelta(t, time.Now().Add(1800*time.Second).Unix(), state.LockedUntil, 5)
+	assert.True(t, isTooManyRequests(lock.Check(ctx, "u1", "")))
+}
+
+func TestLoginLockDelay(t *testing.T) {
+	lock, _ := newTestLoginLock(t)
+	config.C.Security.Lockout.DelayAfterFailures = 2
+	config.C.Security.Lockout.DelayBase = 30
+	ctx := context.Background()
+
+	assert.NoError(t, lock.Failure(ctx, "u1", "", nil))
+	assert.NoError(t, lock.Check(ctx, "u1", ""))
+	assert.NoError(t, lock.Failure(ctx, "u1", "", nil))
+	err := lock.Check(ctx, "u1", "")
+	if assert.True(t, isTooManyRequests(err)) {
+		assert.Equal(t, config.ErrTooManyLoginAttemptsID, errors.FromError(err).ID)
+	}
+}
+
+func TestLoginLockSuccess(t *testing.T) {
+	lock, db := newTestLoginLock(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, lock.Failure(ctx, "u1", "10.0.0.1", nil))
+	}
+	assert.NoError(t, lock.Success(ctx, "u1", nil))
+
+	// The failures of the username start over, the ones of the client IP are kept
+	failures, err := lock.Failures(ctx, "u1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, failures)
+	failures, err = lock.Failures(ctx, "u2", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, failures)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, lock.Failure(ctx, "u1", "", nil))
+	}
+	assert.NoError(t, lock.Check(ctx, "u1", ""))
+
+	// A successful login clears the locked time of the user
+	lockedUntil := time.Now().Add(time.Hour)
+	assert.NoError(t, db.Model(new(schema.User)).Where("id=?", "u1").Update("locked_until", lockedUntil).Error)
+	assert.NoError(t, lock.Success(ctx, "u1", &schema.User{ID: "u1", LockedUntil: &lockedUntil}))
+	var stored schema.User
+	assert.NoError(t, db.First(&stored, "id=?", "u1").Error)
+	assert.Nil(t, stored.LockedUntil)
+}
+
+func TestLoginLockUnlock(t *testing.T) {
+	lock, db := newTestLoginLock(t)
+	ctx := context.Background()
+	user := &schema.User{ID: "u1", Username: "u1"}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, lock.Failure(ctx, "u1", "", user))
+	}
+	assert.True(t, isTooManyRequests(lock.Check(ctx, "u1", "")))
+
+	assert.NoError(t, lock.Unlock(ctx, "u1"))
+	assert.NoError(t, lock.Check(ctx, "u1", ""))
+	failures, err := lock.Failures(ctx, "u1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, failures)
+
+	var stored schema.User
+	assert.NoError(t, db.First(&stored, "id=?", "u1").Error)
+	assert.Nil(t, stored.LockedUntil)
+
+	err = lock.Unlock(ctx, "missing")
+	assert.Equal(t, int32(404), errors.FromError(err).Code)
+
+	// The users out of the data scope of the current user are not found
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, lock.Failure(ctx, "u1", "", user))
+	}
+	scopeCtx := util.NewUserCache(util.NewUserID(ctx, "actor"), util.UserCache{RoleIDs: []string{"dept"}})
+	err = lock.Unlock(scopeCtx, "u1")
+	assert.Equal(t, int32(404), errors.FromError(err).Code)
+	assert.True(t, isTooManyRequests(lock.Check(ctx, "u1", "")))
+}
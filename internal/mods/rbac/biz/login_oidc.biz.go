@@ -0,0 +1,313 @@
+package biz
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/rand"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/oidc"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Login with OpenID Connect identity providers
+type LoginOIDC struct {
+	Cache             cachex.Cacher
+	Trans             *util.Trans
+	UserDAL           *dal.User
+	UserRoleDAL       *dal.UserRole
+	RoleDAL           *dal.Role
+	IdentityDAL       *dal.UserIdentity
+	LoginBIZ          *Login
+	RoleConstraintBIZ *RoleConstraint
+
+	mu        sync.Mutex                `wire:"-"`
+	providers map[string]*oidc.Provider `wire:"-"` // Discovered providers by name
+}
+
+// Pending authorization request that waits for the callback
+type oidcAuthRequest struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// Query the configured identity providers.
+func (a *LoginOIDC) QueryProviders(ctx context.Context) []*schema.LoginOIDCProvider {
+	list := make([]*schema.LoginOIDCProvider, 0, len(config.C.Security.OIDC.Providers))
+	for _, item := range config.C.Security.OIDC.Providers {
+		displayName := item.DisplayName
+		if displayName == "" {
+			displayName = item.Name
+		}
+		list = append(list, &schema.LoginOIDCProvider{
+			Name:        item.Name,
+			DisplayName: displayName,
+		})
+	}
+	return list
+}
+
+// Get the provider by name, the discovery document is fetched on the first use.
+func (a *LoginOIDC) getProvider(ctx context.Context, name string) (*oidc.Provider, config.OIDCProvider, error) {
+	cfg, ok := config.C.Security.GetOIDCProvider(name)
+	if !ok {
+		return nil, cfg, errors.NotFound("", "Identity provider not found")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if provider, ok := a.providers[name]; ok {
+		return provider, cfg, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, oidc.Config{
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+	})
+	if err != nil {
+		return nil, cfg, errors.WithStack(err)
+	}
+
+	if a.providers == nil {
+		a.providers = make(map[string]*oidc.Provider)
+	}
+	a.providers[name] = provider
+	return provider, cfg, nil
+}
+
+// Start the authorization code flow with PKCE, the user agent should be redirected to the returned URL.
+func (a *LoginOIDC) Authorize(ctx context.Context, name string) (*schema.LoginOIDCAuthorization, error) {
+	provider, _, err := a.getProvider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := rand.Random(32, rand.LdigitAndLetter)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	authReq := &oidcAuthRequest{
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	}
+	expiration := time.Duration(config.C.Security.OIDC.StateExpired) * time.Second
+	if err := a.Cache.Set(ctx, config.CacheNSForOIDC, state, json.MarshalToString(authReq), expiration); err != nil {
+		return nil, err
+	}
+
+	return &schema.LoginOIDCAuthorization{
+		AuthURL: provider.AuthCodeURL(state, nonce, oidc.CodeChallengeS256(verifier)),
+		State:   state,
+	}, nil
+}
+
+// Complete the login with the authorization code, the identity is mapped to the user (provisioned if allowed).
+func (a *LoginOIDC) Callback(ctx context.Context, name string, formItem *schema.LoginOIDCCallbackForm) (*schema.LoginToken, error) {
+	ctx = logging.NewTag(ctx, logging.TagKeyLogin)
+
+	// The state can only be used once
+	val, ok, err := a.Cache.GetAndDelete(ctx, config.CacheNSForOIDC, formItem.State)
+	if err != nil {
+		return nil, err
+	}
+	authReq := new(oidcAuthRequest)
+	if !ok || json.Unmarshal([]byte(val), authReq) != nil || authReq.Provider != name {
+		return nil, errors.BadRequest(config.ErrInvalidOIDCStateID, "Invalid or expired login state")
+	}
+
+	provider, cfg, err := a.getProvider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	loginFailed := errors.BadRequest(config.ErrOIDCLoginFailedID, "Failed to login with the identity provider")
+	token, err := provider.Exchange(ctx, formItem.Code, authReq.CodeVerifier)
+	if err != nil {
+		logging.Context(ctx).Warn("Failed to exchange the authorization code", zap.String("provider", name), zap.Error(err))
+		return nil, loginFailed
+	}
+	idToken, err := provider.VerifyIDToken(ctx, token.IDToken, authReq.Nonce)
+	if err != nil {
+		logging.Context(ctx).Warn("Failed to verify the ID token", zap.String("provider", name), zap.Error(err))
+		return nil, loginFailed
+	}
+
+	user, err := a.getIdentityUser(ctx, cfg, idToken)
+	if err != nil {
+		return nil, err
+	} else if user.Status != schema.UserStatusActivated {
+		return nil, errors.BadRequest("", "User status is not activated, please contact the administrator")
+	}
+
+	ctx = logging.NewUserID(ctx, user.ID)
+	logging.Context(ctx).Info("Login with identity provider", zap.String("provider", name), zap.String("subject", idToken.Subject))
+	return a.LoginBIZ.continueLogin(ctx, user.ID, user.Username)
+}
+
+// Get the user linked to the identity, or provision the user on the first login.
+func (a *LoginOIDC) getIdentityUser(ctx context.Context, cfg config.OIDCProvider, idToken *oidc.IDToken) (*schema.User, error) {
+	identity, err := a.IdentityDAL.GetBySubject(ctx, cfg.Name, idToken.Subject)
+	if err != nil {
+		return nil, err
+	} else if identity == nil {
+		if !cfg.AutoProvision {
+			return nil, errors.BadRequest(config.ErrOIDCLoginFailedID, "The external account is not linked to any user")
+		}
+		return a.provisionUser(ctx, cfg, idToken)
+	}
+
+	user, err := a.UserDAL.Get(ctx, identity.UserID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "username", "status"}},
+	})
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, errors.BadRequest(config.ErrOIDCLoginFailedID, "The external account is not linked to any user")
+	}
+
+	// Keep the claims of the last login
+	if identity.Email != idToken.Email || identity.Name != idToken.Name {
+		identity.Email = idToken.Email
+		identity.Name = idToken.Name
+		identity.UpdatedAt = time.Now()
+		if err := a.IdentityDAL.Update(ctx, identity); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (a *LoginOIDC) provisionUser(ctx context.Context, cfg config.OIDCProvider, idToken *oidc.IDToken) (*schema.User, error) {
+	username, err := a.newUsername(ctx, cfg, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	name := idToken.Name
+	if name == "" {
+		name = username
+	}
+	user := &schema.User{
+		ID:            util.NewXID(),
+		Username:      username,
+		Name:          truncate(name, 64),
+		Email:         truncate(idToken.Email, 128),
+		EmailVerified: idToken.Email != "" && idToken.EmailVerified,
+		Status:        schema.UserStatusActivated,
+		Source:        schema.UserSourceOIDC + ":" + cfg.Name,
+		CreatedAt:     time.Now(),
+	}
+
+	var roleIDs []string
+	if len(cfg.DefaultRoleCodes) > 0 {
+		roleIDs, err = a.RoleDAL.GetIDsByCodes(ctx, cfg.DefaultRoleCodes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var userRoles schema.UserRoles
+	for _, roleID := range roleIDs {
+		userRoles = append(userRoles, &schema.UserRole{
+			ID:        util.NewXID(),
+			UserID:    user.ID,
+			RoleID:    roleID,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.RoleConstraintBIZ.Check(ctx, "", userRoles); err != nil {
+			return err
+		}
+		if err := a.UserDAL.Create(ctx, user); err != nil {
+			return err
+		}
+
+		for _, userRole := range userRoles {
+			if err := a.UserRoleDAL.Create(ctx, userRole); err != nil {
+				return err
+			}
+		}
+
+		return a.IdentityDAL.Create(ctx, &schema.UserIdentity{
+			ID:        util.NewXID(),
+			UserID:    user.ID,
+			Provider:  cfg.Name,
+			Subject:   idToken.Subject,
+			Email:     idToken.Email,
+			Name:      idToken.Name,
+			CreatedAt: time.Now(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Context(ctx).Info("Provision user from identity provider",
+		zap.String("provider", cfg.Name),
+		zap.String("username", username),
+		zap.Strings("role_ids", roleIDs))
+	return user, nil
+}
+
+// Take the username from the claim, the provider name is appended when the username is taken.
+func (a *LoginOIDC) newUsername(ctx context.Context, cfg config.OIDCProvider, idToken *oidc.IDToken) (string, error) {
+	claim := cfg.UsernameClaim
+	if claim == "" {
+		claim = "preferred_username"
+	}
+
+	username := strings.TrimSpace(idToken.GetString(claim))
+	if username == "" {
+		username = idToken.Email
+	}
+	if username == "" {
+		username = idToken.Subject
+	}
+
+	for _, candidate := range []string{username, username + "@" + cfg.Name} {
+		candidate = truncate(candidate, 64)
+		if candidate == config.C.General.Root.Username {
+			continue
+		}
+
+		exists, err := a.UserDAL.ExistsUsername(ctx, candidate)
+		if err != nil {
+			return "", err
+		} else if !exists {
+			return candidate, nil
+		}
+	}
+	return "", errors.BadRequest(config.ErrOIDCLoginFailedID, "Username %s already exists, please contact the administrator", username)
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
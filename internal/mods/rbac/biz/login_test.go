@@ -0,0 +1,295 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/LyricTian/gin-admin/v10/pkg/totp"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// Login with the 2FA enabled user u1 (password: secret), the challenges and the codes follow the fake clock.
+type testLogin struct {
+	*Login
+	now    time.Time
+	secret string
+	codes  []string // Recovery codes
+}
+
+func newTestLogin(t *testing.T) *testLogin {
+	security, captcha := config.C.Security, config.C.Util.Captcha
+	t.Cleanup(func() { config.C.Security, config.C.Util.Captcha = security, captcha })
+	setTestLockout(t)
+	config.C.Security.LDAP.Enable = false
+	config.C.Security.MFA.Issuer = "test"
+	config.C.Security.MFA.Skew = 1
+	config.C.Security.MFA.ChallengeExpired = 300
+	config.C.Security.MFA.MaxAttempts = 5
+	config.C.Security.MFA.RecoveryCodes = 2
+	config.C.Util.Captcha.RequireAfterFailures = 100
+
+	password, err := hash.GeneratePassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t, new(schema.User), new(schema.UserRole), new(schema.Role), new(schema.RoleElevation), new(schema.UserTOTP))
+	mustCreate(t, db, &schema.User{ID: "u1", Username: "u1", Password: password, Source: schema.UserSourceLocal, Status: schema.UserStatusActivated})
+
+	tl := &testLogin{now: time.Unix(1700000000, 0)}
+	clock := func() time.Time { return tl.now }
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{})
+	userDAL := &dal.User{DB: db}
+	tl.Login = &Login{
+		Cache:   cache,
+		Auth:    jwtx.New(jwtx.NewStoreWithCache(jwtx.NewMemoryCache(jwtx.MemoryConfig{})), jwtx.SetSigningKey("test", "")),
+		UserDAL: userDAL,
+		UserBIZ: &User{
+			UserDAL:          userDAL,
+			UserRoleDAL:      &dal.UserRole{DB: db},
+			RoleElevationDAL: &dal.RoleElevation{DB: db},
+		},
+		UserTOTPBIZ:  &UserTOTP{UserDAL: userDAL, UserTOTPDAL: &dal.UserTOTP{DB: db}, now: clock},
+		LoginLockBIZ: &LoginLock{Cache: cache, UserDAL: userDAL},
+		LoginLDAPBIZ: &LoginLDAP{},
+		now:          clock,
+	}
+
+	ctx := context.Background()
+	enrollment, err := tl.UserTOTPBIZ.Enroll(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl.secret = enrollment.Secret
+	result, err := tl.UserTOTPBIZ.Enable(ctx, "u1", tl.code(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl.codes = result.RecoveryCodes
+	// The code of the enrollment is used
+	tl.advance(30 * time.Second)
+	return tl
+}
+
+func (a *testLogin) advance(d time.Duration) {
+	a.now = a.now.Add(d)
+}
+
+func (a *testLogin) code(t *testing.T) string {
+	code, err := totp.New().GenerateAt(a.secret, a.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func (a *testLogin) challenge(t *testing.T, ctx context.Context) string {
+	token, err := a.Login.Login(ctx, &schema.LoginForm{Username: "u1", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, token.MFARequired)
+	assert.Empty(t, token.AccessToken)
+	return token.MFAToken
+}
+
+func errorID(err error) string {
+	if err == nil {
+		return ""
+	}
+	return errors.FromError(err).ID
+}
+
+func TestLoginMFAChallenge(t *testing.T) {
+	tl := newTestLogin(t)
+	config.C.Security.Lockout.MaxFailures = 100
+	ctx := context.Background()
+
+	_, err := tl.Login.Login(ctx, &schema.LoginForm{Username: "u1", Password: "wrong"})
+	assert.Equal(t, config.ErrInvalidUsernameOrPassword, errorID(err))
+
+	mfaToken := tl.challenge(t, ctx)
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: "000000"})
+	assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+
+	// The challenge is kept after an incorrect code, and can be completed only once
+	token, err := tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: tl.code(t)})
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, token.AccessToken)
+		assert.NotEmpty(t, token.RefreshToken)
+		assert.False(t, token.MFARequired)
+	}
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: tl.code(t)})
+	assert.Equal(t, config.ErrInvalidMFATokenID, errorID(err))
+
+	// Revoked after the attempts are exhausted
+	mfaToken = tl.challenge(t, ctx)
+	for i := 0; i < config.C.Security.MFA.MaxAttempts; i++ {
+		_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: "000000"})
+		assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+	}
+	tl.advance(30 * time.Second)
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: tl.code(t)})
+	assert.Equal(t, config.ErrInvalidMFATokenID, errorID(err))
+}
+
+func TestLoginMFAReplay(t *testing.T) {
+	tl := newTestLogin(t)
+	ctx := context.Background()
+
+	code := tl.code(t)
+	_, err := tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: tl.challenge(t, ctx), Code: code})
+	assert.NoError(t, err)
+
+	// The same code is rejected in its time step and the skew window after it
+	mfaToken := tl.challenge(t, ctx)
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: code})
+	assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+	tl.advance(30 * time.Second)
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: code})
+	assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+
+	// A code of an earlier time step is rejected as well
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: tl.code(t)})
+	assert.NoError(t, err)
+	earlier, err := totp.New().GenerateAt(tl.secret, tl.now.Add(-30*time.Second))
+	assert.NoError(t, err)
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: tl.challenge(t, ctx), Code: earlier})
+	assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+}
+
+func TestLoginMFARecoveryCode(t *testing.T) {
+	tl := newTestLogin(t)
+	ctx := context.Background()
+
+	_, err := tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: tl.challenge(t, ctx), Code: tl.codes[0]})
+	assert.NoError(t, err)
+
+	// A recovery code can be used only once, the others are kept
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: tl.challenge(t, ctx), Code: tl.codes[0]})
+	assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+	status, err := tl.UserTOTPBIZ.GetStatus(ctx, "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, status.RecoveryCodesRemaining)
+
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: tl.challenge(t, ctx), Code: tl.codes[1]})
+	assert.NoError(t, err)
+}
+
+func TestLoginMFAExpiry(t *testing.T) {
+	tl := newTestLogin(t)
+	ctx := context.Background()
+
+	mfaToken := tl.challenge(t, ctx)
+	tl.advance(time.Duration(config.C.Security.MFA.ChallengeExpired-1) * time.Second)
+	_, err := tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: "000000"})
+	assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+
+	tl.advance(time.Second)
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: tl.code(t)})
+	assert.Equal(t, config.ErrInvalidMFATokenID, errorID(err))
+}
+
+func TestLoginMFALockout(t *testing.T) {
+	tl := newTestLogin(t)
+	ctx := util.NewClientIP(context.Background(), "10.0.0.1")
+
+	// The incorrect codes lock the account before the attempts of the challenge are exhausted
+	mfaToken := tl.challenge(t, ctx)
+	for i := 0; i < config.C.Security.Lockout.MaxFailures; i++ {
+		_, err := tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: "000000"})
+		assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+	}
+	_, err := tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: mfaToken, Code: tl.code(t)})
+	assert.Equal(t, config.ErrAccountLockedID, errorID(err))
+	assert.Equal(t, config.ErrAccountLockedID, errorID(tl.LoginLockBIZ.Check(ctx, "u1", "10.0.0.2")))
+
+	var user schema.User
+	assert.NoError(t, tl.UserDAL.DB.First(&user, "id=?", "u1").Error)
+	assert.NotNil(t, user.LockedUntil)
+
+	// Counted for the client IP as well
+	failures, err := tl.LoginLockBIZ.Failures(ctx, "u2", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, config.C.Security.Lockout.MaxFailures, failures)
+}
+
+func TestLoginMFAFailuresNotResetByPassword(t *testing.T) {
+	tl := newTestLogin(t)
+	ctx := context.Background()
+
+	// A new challenge with the correct password does not renew the attempts
+	for i := 0; i < config.C.Security.Lockout.MaxFailures-1; i++ {
+		_, err := tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: tl.challenge(t, ctx), Code: "000000"})
+		assert.Equal(t, config.ErrInvalidMFACodeID, errorID(err))
+	}
+	failures, err := tl.LoginLockBIZ.Failures(ctx, "u1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, config.C.Security.Lockout.MaxFailures-1, failures)
+
+	// Reset after the login is completed
+	_, err = tl.LoginMFA(ctx, &schema.LoginMFAForm{MFAToken: tl.challenge(t, ctx), Code: tl.code(t)})
+	assert.NoError(t, err)
+	failures, err = tl.LoginLockBIZ.Failures(ctx, "u1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, failures)
+}
+
+func TestLoginQueryMenus(t *testing.T) {
+	db := newTestDB(t, new(schema.User), new(schema.UserRole), new(schema.RoleParent), new(schema.RoleMenu),
+		new(schema.Menu), new(schema.RoleElevation))
+	expired := time.Now().Add(-time.Minute)
+	mustCreate(t, db,
+		&schema.Menu{ID: "root", Code: "root", Name: "Root", Status: schema.MenuStatusEnabled},
+		&schema.Menu{ID: "ma", Code: "ma", Name: "MA", Status: schema.MenuStatusEnabled, ParentID: "root", ParentPath: "root."},
+		&schema.Menu{ID: "mb", Code: "mb", Name: "MB", Status: schema.MenuStatusEnabled},
+		&schema.Menu{ID: "mc", Code: "mc", Name: "MC", Status: schema.MenuStatusEnabled},
+		&schema.Menu{ID: "mx", Code: "mx", Name: "MX", Status: schema.MenuStatusEnabled},
+		&schema.RoleMenu{ID: "rma", RoleID: "a", MenuID: "ma"},
+		&schema.RoleMenu{ID: "rmb", RoleID: "b", MenuID: "mb"},
+		&schema.RoleMenu{ID: "rmc", RoleID: "c", MenuID: "mc"},
+		&schema.RoleMenu{ID: "rmx", RoleID: "x", MenuID: "mx"},
+		&schema.RoleParent{ID: "rpb", RoleID: "b", ParentID: "a"},
+		&schema.RoleParent{ID: "rpc", RoleID: "c", ParentID: "b"},
+		&schema.RoleParent{ID: "rpy", RoleID: "y", ParentID: "x"},
+		&schema.UserRole{ID: "ur1", UserID: "u1", RoleID: "c"},
+		&schema.UserRole{ID: "ur2", UserID: "u1", RoleID: "y", ExpiresAt: &expired},
+	)
+	loginBIZ := &Login{
+		RoleParentDAL: &dal.RoleParent{DB: db},
+		MenuDAL:       &dal.Menu{DB: db},
+		UserBIZ:       &User{UserRoleDAL: &dal.UserRole{DB: db}},
+	}
+
+	var menuIDs func(menus schema.Menus) []string
+	menuIDs = func(menus schema.Menus) []string {
+		var ids []string
+		for _, menu := range menus {
+			ids = append(ids, menu.ID)
+			if menu.Children != nil {
+				ids = append(ids, menuIDs(*menu.Children)...)
+			}
+		}
+		return ids
+	}
+
+	// The direct role is expanded to all of its ancestors, the ancestors of the expired role are not included
+	roleIDs, err := loginBIZ.UserBIZ.GetRoleIDs(context.Background(), "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, roleIDs)
+	menus, err := loginBIZ.QueryMenus(util.NewUserID(context.Background(), "u1"))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"root", "ma", "mb", "mc"}, menuIDs(menus))
+
+	menus, err = loginBIZ.QueryMenus(util.NewUserID(context.Background(), "u2"))
+	assert.NoError(t, err)
+	assert.Len(t, menus, 0)
+}
@@ -0,0 +1,29 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/mail"
+)
+
+// Send the email with the SMTP server in the configuration.
+func sendMail(ctx context.Context, to, subject, body string) error {
+	cfg := config.C.Util.Mail
+	if cfg.Host == "" {
+		return errors.Errorf("mail: SMTP server is not configured")
+	}
+
+	sender := &mail.SmtpSender{
+		SmtpHost: cfg.Host,
+		Port:     cfg.Port,
+		FromName: cfg.FromName,
+		FromMail: cfg.FromMail,
+		UserName: cfg.Username,
+		AuthCode: cfg.Password,
+	}
+	subject = fmt.Sprintf("[%s] %s", config.C.General.AppName, subject)
+	return sender.SendTo(ctx, []string{to}, subject, body)
+}
@@ -2,7 +2,6 @@ package biz
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
@@ -17,6 +16,7 @@ import (
 	"github.com/LyricTian/gin-admin/v10/pkg/encoding/yaml"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
 	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/middleware"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 	"go.uber.org/zap"
 )
@@ -28,6 +28,7 @@ type Menu struct {
 	MenuDAL         *dal.Menu
 	MenuResourceDAL *dal.MenuResource
 	RoleMenuDAL     *dal.RoleMenu
+	PolicySyncer    PolicySyncer
 }
 
 func (a *Menu) InitFromFile(ctx context.Context, menuFile string) error {
@@ -336,7 +337,9 @@ func (a *Menu) Create(ctx context.Context, formItem *schema.MenuForm) (*schema.M
 		return nil, errors.BadRequest("", "Menu code already exists at the same level")
 	}
 
-	if err := formItem.FillTo(menu); err != nil {
+	if err := a.checkResources(formItem.Resources); err != nil {
+		return nil, err
+	} else if err := formItem.FillTo(menu); err != nil {
 		return nil, err
 	}
 
@@ -362,6 +365,16 @@ func (a *Menu) Create(ctx context.Context, formItem *schema.MenuForm) (*schema.M
 	return menu, nil
 }
 
+// Check the conditions of the resources can be evaluated against the attributes of the requests.
+func (a *Menu) checkResources(resources schema.MenuResources) error {
+	for _, res := range resources {
+		if err := middleware.ValidateCasbinCondition(res.Condition); err != nil {
+			return errors.BadRequest("", "Invalid condition of resource '%s %s': %s", res.Method, res.Path, err.Error())
+		}
+	}
+	return nil
+}
+
 // Update the specified menu in the data access object.
 func (a *Menu) Update(ctx context.Context, id string, formItem *schema.MenuForm) error {
 	if config.C.General.DenyOperateMenu {
@@ -412,11 +425,18 @@ func (a *Menu) Update(ctx context.Context, id string, formItem *schema.MenuForm)
 		}
 	}
 
-	if err := formItem.FillTo(menu); err != nil {
+	if err := a.checkResources(formItem.Resources); err != nil {
+		return err
+	} else if err := formItem.FillTo(menu); err != nil {
 		return err
 	}
 
-	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+	roleIDs, err := a.queryRoleIDs(ctx, id, oldParentPath)
+	if err != nil {
+		return err
+	}
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
 		if oldStatus != formItem.Status {
 			oldPath := oldParentPath + menu.ID + util.TreePathDelimiter
 			if err := a.MenuDAL.UpdateStatusByParentPath(ctx, oldPath, formItem.Status); err != nil {
@@ -453,9 +473,12 @@ func (a *Menu) Update(ctx context.Context, id string, formItem *schema.MenuForm)
 				return err
 			}
 		}
-
-		return a.syncToCasbin(ctx)
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	return syncToCasbin(ctx, a.Cache, a.PolicySyncer, roleIDs...)
 }
 
 // Delete the specified menu from the data access object.
@@ -482,7 +505,12 @@ func (a *Menu) Delete(ctx context.Context, id string) error {
 		return err
 	}
 
-	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+	roleIDs, err := a.queryRoleIDs(ctx, id, menu.ParentPath)
+	if err != nil {
+		return err
+	}
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
 		if err := a.delete(ctx, id); err != nil {
 			return err
 		}
@@ -492,9 +520,12 @@ func (a *Menu) Delete(ctx context.Context, id string) error {
 				return err
 			}
 		}
-
-		return a.syncToCasbin(ctx)
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	return syncToCasbin(ctx, a.Cache, a.PolicySyncer, roleIDs...)
 }
 
 func (a *Menu) delete(ctx context.Context, id string) error {
@@ -510,6 +541,32 @@ func (a *Menu) delete(ctx context.Context, id string) error {
 	return nil
 }
 
-func (a *Menu) syncToCasbin(ctx context.Context) error {
-	return a.Cache.Set(ctx, config.CacheNSForRole, config.CacheKeyForSyncToCasbin, fmt.Sprintf("%d", time.Now().Unix()))
+// Get the roles which have the menu or its children, the resources of the menu are granted to these roles.
+func (a *Menu) queryRoleIDs(ctx context.Context, id, parentPath string) ([]string, error) {
+	childResult, err := a.MenuDAL.Query(ctx, schema.MenuQueryParam{
+		ParentPathPrefix: parentPath + id + util.TreePathDelimiter,
+	}, schema.MenuQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	menuIDs := []string{id}
+	for _, child := range childResult.Data {
+		menuIDs = append(menuIDs, child.ID)
+	}
+	roleMenuResult, err := a.RoleMenuDAL.Query(ctx, schema.RoleMenuQueryParam{
+		InMenuIDs: menuIDs,
+	}, schema.RoleMenuQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"role_id"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roleMenuResult.Data.ToRoleIDs(), nil
 }
@@ -0,0 +1,65 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMenuResourceCondition(t *testing.T) {
+	db := newTestDB(t, new(schema.Menu), new(schema.MenuResource), new(schema.RoleMenu))
+	menuBIZ := &Menu{
+		Trans:           &util.Trans{DB: db},
+		MenuDAL:         &dal.Menu{DB: db},
+		MenuResourceDAL: &dal.MenuResource{DB: db},
+		RoleMenuDAL:     &dal.RoleMenu{DB: db},
+	}
+	ctx := context.Background()
+
+	newForm := func(code, cond string) *schema.MenuForm {
+		return &schema.MenuForm{
+			Code:   code,
+			Name:   code,
+			Type:   "page",
+			Status: schema.MenuStatusEnabled,
+			Resources: schema.MenuResources{
+				{Method: "GET", Path: "/api/v1/users/:id", Condition: cond},
+			},
+		}
+	}
+
+	for _, cond := range []string{"hour >=", "unknown == 1", "hour + 1", `in_cidr(ip, "10.0.0.0")`} {
+		_, err := menuBIZ.Create(ctx, newForm("invalid", cond))
+		if assert.NotNil(t, err, cond) {
+			assert.Equal(t, int32(400), errors.FromError(err).Code, cond)
+		}
+	}
+	exists, err := menuBIZ.MenuDAL.ExistsCodeByParentID(ctx, "invalid", "")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	menu, err := menuBIZ.Create(ctx, newForm("valid", "param_id == user_id"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	resResult, err := menuBIZ.MenuResourceDAL.Query(ctx, schema.MenuResourceQueryParam{MenuID: menu.ID})
+	assert.Nil(t, err)
+	if assert.Len(t, resResult.Data, 1) {
+		assert.Equal(t, "param_id == user_id", resResult.Data[0].Condition)
+	}
+
+	err = menuBIZ.Update(ctx, menu.ID, newForm("valid", "weekday >="))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, int32(400), errors.FromError(err).Code)
+	}
+	resResult, err = menuBIZ.MenuResourceDAL.Query(ctx, schema.MenuResourceQueryParam{MenuID: menu.ID})
+	assert.Nil(t, err)
+	if assert.Len(t, resResult.Data, 1) {
+		assert.Equal(t, "param_id == user_id", resResult.Data[0].Condition)
+	}
+}
@@ -0,0 +1,213 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/rand"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Reset requests of an account or a client IP in the window
+type passwordResetRequests struct {
+	Count   int   `json:"count"`
+	ResetAt int64 `json:"reset_at"`
+}
+
+// Self-service password reset via email
+type PasswordReset struct {
+	Cache        cachex.Cacher
+	UserDAL      *dal.User
+	UserBIZ      *User
+	LoginLockBIZ *LoginLock
+}
+
+func (a *PasswordReset) tokenKey(token string) string {
+	return "t:" + token
+}
+
+// Only the latest token of the user is valid
+func (a *PasswordReset) userKey(userID string) string {
+	return "u:" + userID
+}
+
+// Count the request of the key, returns an error if there are too many requests in the window.
+func (a *PasswordReset) limit(ctx context.Context, key string) error {
+	cfg := config.C.Security.PasswordReset
+	if cfg.MaxRequests <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	item := new(passwordResetRequests)
+	val, ok, err := a.Cache.Get(ctx, config.CacheNSForPasswordReset, key)
+	if err != nil {
+		return err
+	} else if ok {
+		_ = json.Unmarshal([]byte(val), item)
+	}
+	if item.ResetAt <= now.Unix() {
+		item.Count = 0
+		item.ResetAt = now.Add(time.Duration(cfg.RequestWindow) * time.Second).Unix()
+	}
+
+	if item.Count >= cfg.MaxRequests {
+		return errors.TooManyRequests(config.ErrTooManyResetRequestsID,
+			"Too many password reset requests, please try again in %d seconds", item.ResetAt-now.Unix())
+	}
+	item.Count++
+	return a.Cache.Set(ctx, config.CacheNSForPasswordReset, key, json.MarshalToString(item), time.Until(time.Unix(item.ResetAt, 0)))
+}
+
+// Find the user by username, or by email if the email belongs to exactly one user.
+func (a *PasswordReset) findUser(ctx context.Context, account string) (*schema.User, error) {
+	opts := schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "username", "name", "email", "status", "source"}},
+	}
+	user, err := a.UserDAL.GetByUsername(ctx, account, opts)
+	if err != nil || user != nil || !strings.Contains(account, "@") {
+		return user, err
+	}
+
+	users, err := a.UserDAL.QueryByEmail(ctx, account, opts)
+	if err != nil {
+		return nil, err
+	} else if len(users) != 1 {
+		return nil, nil
+	}
+	return users[0], nil
+}
+
+// Send the reset email if the account exists. The result is the same whether or not the account
+// exists, so the accounts cannot be enumerated.
+func (a *PasswordReset) Forgot(ctx context.Context, formItem *schema.ForgotPasswordForm) error {
+	cfg := config.C.Security.PasswordReset
+	if !cfg.Enable {
+		return errors.BadRequest("", "Password reset is not enabled")
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	account := strings.TrimSpace(formItem.Account)
+	if err := a.limit(ctx, "ip:"+util.FromClientIP(ctx)); err != nil {
+		return err
+	} else if err := a.limit(ctx, "a:"+strings.ToLower(account)); err != nil {
+		return err
+	}
+
+	user, err := a.findUser(ctx, account)
+	if err != nil {
+		return err
+	} else if user == nil || user.Status != schema.UserStatusActivated || !user.IsLocal() || user.Email == "" {
+		logging.Context(ctx).Info("Ignore password reset request", zap.String("account", account))
+		return nil
+	}
+
+	token, err := rand.Random(40, rand.LdigitAndLetter)
+	if err != nil {
+		return err
+	}
+
+	expiration := time.Duration(cfg.Expired) * time.Second
+	if err := a.Cache.Set(ctx, config.CacheNSForPasswordReset, a.tokenKey(token), user.ID, expiration); err != nil {
+		return err
+	} else if err := a.Cache.Set(ctx, config.CacheNSForPasswordReset, a.userKey(user.ID), token, expiration); err != nil {
+		return err
+	}
+
+	// Sending the email takes a while, which should not reveal the existence of the account
+	mailCtx := logging.NewTag(logging.NewTraceID(context.Background(), logging.FromTraceID(ctx)), logging.TagKeySecurity)
+	go a.sendMail(mailCtx, user, token, expiration)
+	return nil
+}
+
+func (a *PasswordReset) sendMail(ctx context.Context, user *schema.User, token string, expiration time.Duration) {
+	var action string
+	if link := config.C.Security.PasswordReset.URL; link != "" {
+		link = strings.ReplaceAll(link, "{token}", token)
+		action = fmt.Sprintf(`<p><a href="%s">Reset password</a></p>`, html.EscapeString(link))
+	} else {
+		action = fmt.Sprintf("<p>Reset code: <b>%s</b></p>", token)
+	}
+	body := fmt.Sprintf("<p>Hi %s,</p><p>A password reset was requested for the account <b>%s</b>.</p>%s"+
+		"<p>The request expires in %d minutes and can only be used once. If you did not request it, please ignore this email.</p>",
+		html.EscapeString(user.Name), html.EscapeString(user.Username), action, int(expiration.Minutes()))
+
+	if err := sendMail(ctx, user.Email, "Reset your password", body); err != nil {
+		logging.Context(ctx).Error("Failed to send password reset email", zap.String("user_id", user.ID), zap.Error(err))
+		return
+	}
+	logging.Context(ctx).Info("Send password reset email", zap.String("user_id", user.ID))
+}
+
+// Set the new password with the token from the reset email, the token can only be used once.
+func (a *PasswordReset) Reset(ctx context.Context, formItem *schema.ResetPasswordForm) error {
+	if !config.C.Security.PasswordReset.Enable {
+		return errors.BadRequest("", "Password reset is not enabled")
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	invalidToken := errors.BadRequest(config.ErrInvalidResetTokenID, "Invalid or expired reset token")
+	userID, ok, err := a.Cache.Get(ctx, config.CacheNSForPasswordReset, a.tokenKey(formItem.Token))
+	if err != nil {
+		return err
+	} else if !ok {
+		return invalidToken
+	}
+
+	latest, ok, err := a.Cache.Get(ctx, config.CacheNSForPasswordReset, a.userKey(userID))
+	if err != nil {
+		return err
+	} else if !ok || latest != formItem.Token {
+		return invalidToken
+	}
+
+	user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: append([]string{"id", "username", "status", "source", "created_at", "locked_until"}, userPasswordFields...),
+		},
+	})
+	if err != nil {
+		return err
+	} else if user == nil || user.Status != schema.UserStatusActivated || !user.IsLocal() {
+		return invalidToken
+	}
+
+	// The token is kept if the password is rejected by the policy
+	if err := setUserPassword(user, formItem.NewPassword, false); err != nil {
+		return err
+	}
+
+	if _, ok, err := a.Cache.GetAndDelete(ctx, config.CacheNSForPasswordReset, a.tokenKey(formItem.Token)); err != nil {
+		return err
+	} else if !ok {
+		return invalidToken
+	}
+	if err := a.Cache.Delete(ctx, config.CacheNSForPasswordReset, a.userKey(userID)); err != nil {
+		return err
+	}
+
+	if err := a.UserDAL.Update(ctx, user, userPasswordFields...); err != nil {
+		return err
+	} else if err := a.Cache.Delete(ctx, config.CacheNSForUser, userID); err != nil {
+		return err
+	}
+
+	// The owner of the mailbox is trusted, so the failed logins are cleared
+	if err := a.LoginLockBIZ.Success(ctx, user.Username, user); err != nil {
+		return err
+	}
+
+	logging.Context(ctx).Info("Reset password with email", zap.String("user_id", userID))
+	return a.UserBIZ.revokeSessions(ctx, userID)
+}
@@ -0,0 +1,102 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
+	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPasswordReset(t *testing.T) *PasswordReset {
+	passwordReset, mail := config.C.Security.PasswordReset, config.C.Util.Mail
+	t.Cleanup(func() { config.C.Security.PasswordReset, config.C.Util.Mail = passwordReset, mail })
+	setTestLockout(t)
+	config.C.Security.PasswordReset.Enable = true
+	config.C.Security.PasswordReset.Expired = 1800
+	config.C.Security.PasswordReset.MaxRequests = 3
+	config.C.Security.PasswordReset.RequestWindow = 3600
+	config.C.Util.Mail.Host = ""
+
+	db := newTestDB(t, new(schema.User))
+	mustCreate(t, db,
+		&schema.User{ID: "u1", Username: "u1", Email: "u1@example.com", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+		&schema.User{ID: "u2", Username: "u2", Email: "u2@example.com", Source: schema.UserSourceLDAP, Status: schema.UserStatusActivated},
+		&schema.User{ID: "u3", Username: "u3", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+	)
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{})
+	userDAL := &dal.User{DB: db}
+	return &PasswordReset{
+		Cache:   cache,
+		UserDAL: userDAL,
+		UserBIZ: &User{
+			Cache:   cache,
+			Auth:    jwtx.New(jwtx.NewStoreWithCache(jwtx.NewMemoryCache(jwtx.MemoryConfig{})), jwtx.SetSigningKey("test", "")),
+			UserDAL: userDAL,
+		},
+		LoginLockBIZ: &LoginLock{Cache: cache, UserDAL: userDAL},
+	}
+}
+
+// Request a reset of the account and get the token which is sent by email.
+func forgotToken(t *testing.T, a *PasswordReset, ctx context.Context, account, userID string) string {
+	assert.NoError(t, a.Forgot(ctx, &schema.ForgotPasswordForm{Account: account}))
+	token, ok, err := a.Cache.Get(ctx, config.CacheNSForPasswordReset, a.userKey(userID))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	return token
+}
+
+func TestPasswordReset(t *testing.T) {
+	passwordResetBIZ := newTestPasswordReset(t)
+	ctx := util.NewClientIP(context.Background(), "10.0.0.1")
+
+	// Only the latest token is valid
+	older := forgotToken(t, passwordResetBIZ, ctx, "u1", "u1")
+	latest := forgotToken(t, passwordResetBIZ, ctx, "u1@example.com", "u1")
+	assert.NotEqual(t, older, latest)
+	err := passwordResetBIZ.Reset(ctx, &schema.ResetPasswordForm{Token: older, NewPassword: "Reset-pass-1234"})
+	assert.Equal(t, config.ErrInvalidResetTokenID, errorID(err))
+
+	// The token can be used only once
+	assert.NoError(t, passwordResetBIZ.Reset(ctx, &schema.ResetPasswordForm{Token: latest, NewPassword: "Reset-pass-1234"}))
+	err = passwordResetBIZ.Reset(ctx, &schema.ResetPasswordForm{Token: latest, NewPassword: "Reset-pass-5678"})
+	assert.Equal(t, config.ErrInvalidResetTokenID, errorID(err))
+
+	var user schema.User
+	assert.NoError(t, passwordResetBIZ.UserDAL.DB.First(&user, "id=?", "u1").Error)
+	assert.NoError(t, hash.CompareHashAndPassword(user.Password, "Reset-pass-1234"))
+}
+
+func TestPasswordResetUnknownAccount(t *testing.T) {
+	passwordResetBIZ := newTestPasswordReset(t)
+	config.C.Security.PasswordReset.MaxRequests = 100
+
+	// The response is the same for the unknown accounts and the accounts which cannot be reset, no token is issued
+	for _, account := range []string{"unknown", "unknown@example.com", "u2", "u3"} {
+		ctx := util.NewClientIP(context.Background(), "10.0.0.1")
+		assert.NoError(t, passwordResetBIZ.Forgot(ctx, &schema.ForgotPasswordForm{Account: account}), account)
+	}
+	for _, userID := range []string{"u2", "u3"} {
+		exists, err := passwordResetBIZ.Cache.Exists(context.Background(), config.CacheNSForPasswordReset, passwordResetBIZ.userKey(userID))
+		assert.NoError(t, err)
+		assert.False(t, exists, userID)
+	}
+
+	// The requests of the unknown accounts are limited in the same way
+	config.C.Security.PasswordReset.MaxRequests = 2
+	for _, account := range []string{"u1", "nobody"} {
+		ctx := util.NewClientIP(context.Background(), "10.0.0."+account)
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, passwordResetBIZ.Forgot(ctx, &schema.ForgotPasswordForm{Account: account}), account)
+		}
+		err := passwordResetBIZ.Forgot(ctx, &schema.ForgotPasswordForm{Account: account})
+		assert.Equal(t, config.ErrTooManyResetRequestsID, errorID(err), account)
+	}
+}
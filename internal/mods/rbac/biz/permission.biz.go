@@ -0,0 +1,530 @@
+package biz
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/middleware"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/casbin/casbin/v2"
+	casbinutil "github.com/casbin/casbin/v2/util"
+)
+
+// Provider of the casbin enforcer which checks the permissions
+type Enforcer interface {
+	GetEnforcer() *casbin.SyncedEnforcer
+}
+
+// Explain and report the permissions of the casbin policies
+type Permission struct {
+	Enforcer      Enforcer
+	UserDAL       *dal.User
+	UserRoleDAL   *dal.UserRole
+	RoleDAL       *dal.Role
+	RoleMenuDAL   *dal.RoleMenu
+	RoleParentDAL *dal.RoleParent
+	MenuDAL       *dal.Menu
+	UserBIZ       *User
+}
+
+// Explain the permission check of the request of the user (ID or username), the decision is made in the same way as
+// the casbin middleware.
+func (a *Permission) Explain(ctx context.Context, params schema.PermissionExplainParam) (*schema.PermissionExplain, error) {
+	result := &schema.PermissionExplain{
+		Method:   strings.ToUpper(params.Method),
+		Path:     params.Path,
+		Roles:    []*schema.PermissionRole{},
+		Policies: []*schema.PermissionPolicy{},
+	}
+
+	user, unrestricted, err := a.getUser(ctx, params.User)
+	if err != nil {
+		return nil, err
+	}
+	result.UserID = user.ID
+	result.Username = user.Username
+	if unrestricted {
+		result.Decision = schema.PermissionDecisionRoot
+		result.Allowed = true
+		return result, nil
+	}
+
+	roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.Roles, err = a.queryRoles(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	casbinCfg := config.C.Middleware.Casbin
+	if casbinCfg.Disable {
+		result.Decision = schema.PermissionDecisionSkipped
+		result.Allowed = true
+		return result, nil
+	}
+	for _, prefix := range casbinCfg.SkippedPathPrefixes {
+		if strings.HasPrefix(result.Path, prefix) {
+			result.Decision = schema.PermissionDecisionSkipped
+			result.Allowed = true
+			return result, nil
+		}
+	}
+
+	enforcer := a.Enforcer.GetEnforcer()
+	if enforcer == nil {
+		result.Decision = schema.PermissionDecisionNoMatch
+		return result, nil
+	}
+
+	tenantID, _ := util.FromTenantID(ctx)
+	domain := schema.CasbinDomain(tenantID)
+	subjects := make(map[string]bool)
+	for _, roleID := range roleIDs {
+		subjects[roleID] = true
+		implicitRoles, err := enforcer.GetImplicitRolesForUser(roleID, domain)
+		if err != nil {
+			return nil, err
+		}
+		for _, implicitRole := range implicitRoles {
+			subjects[implicitRole] = true
+		}
+	}
+	for _, policy := range enforcer.GetFilteredPolicy(1, domain) {
+		if len(policy) < 4 || !subjects[policy[0]] || policy[3] != result.Method {
+			continue
+		}
+		if casbinutil.KeyMatch2(result.Path, policy[2]) || casbinutil.KeyMatch3(result.Path, policy[2]) {
+			result.Policies = append(result.Policies, schema.NewPermissionPolicy(policy))
+		}
+	}
+
+	allowed, explain, err := middleware.CasbinEnforce(enforcer, roleIDs, domain, result.Path, result.Method, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Allowed = allowed
+	result.Explain = schema.NewPermissionPolicy(explain)
+	if allowed {
+		result.Decision = schema.PermissionDecisionAllow
+	} else if len(explain) > 0 {
+		result.Decision = schema.PermissionDecisionDeny
+	} else {
+		result.Decision = schema.PermissionDecisionNoMatch
+	}
+	return result, nil
+}
+
+// Get the user by ID or username, the root user (and the administrator of the tenant) is unrestricted.
+func (a *Permission) getUser(ctx context.Context, idOrUsername string) (*schema.User, bool, error) {
+	rootCfg := config.C.General.Root
+	tenantID, _ := util.FromTenantID(ctx)
+	if tenantID == "" && (idOrUsername == rootCfg.ID || idOrUsername == rootCfg.Username) {
+		return &schema.User{ID: rootCfg.ID, Username: rootCfg.Username}, true, nil
+	}
+
+	opt := schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "username"}},
+	}
+	user, err := a.UserDAL.Get(ctx, idOrUsername, opt)
+	if err != nil {
+		return nil, false, err
+	} else if user == nil {
+		user, err = a.UserDAL.GetByUsername(ctx, idOrUsername, opt)
+		if err != nil {
+			return nil, false, err
+		} else if user == nil {
+			return nil, false, errors.NotFound("", "User not found")
+		}
+	}
+	return user, user.ID == util.FromTenantAdminID(ctx), nil
+}
+
+// Query the assigned roles and the roles inherited from them.
+func (a *Permission) queryRoles(ctx context.Context, roleIDs []string) ([]*schema.PermissionRole, error) {
+	roles := []*schema.PermissionRole{}
+	if len(roleIDs) == 0 {
+		return roles, nil
+	}
+
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return nil, err
+	}
+	inheritedIDs := roleParentResult.Data.AncestorIDs(roleIDs...)
+
+	roleResult, err := a.RoleDAL.Query(ctx, schema.RoleQueryParam{
+		InIDs: append(append([]string{}, roleIDs...), inheritedIDs...),
+	}, schema.RoleQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "code", "name", "status"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inherited := make(map[string]bool, len(inheritedIDs))
+	for _, id := range inheritedIDs {
+		inherited[id] = true
+	}
+	for _, role := range roleResult.Data {
+		roles = append(roles, &schema.PermissionRole{
+			ID:        role.ID,
+			Code:      role.Code,
+			Name:      role.Name,
+			Status:    role.Status,
+			Inherited: inherited[role.ID],
+		})
+	}
+	return roles, nil
+}
+
+// Report the effective menus and API resources of the user (ID or username) with the roles which grant them.
+func (a *Permission) ReportUser(ctx context.Context, params schema.PermissionUserReportParam) (*schema.PermissionUserReport, error) {
+	user, unrestricted, err := a.getUser(ctx, params.User)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &schema.PermissionUserReport{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Unrestricted: unrestricted,
+		Roles:        []*schema.PermissionRole{},
+		Menus:        []*schema.PermissionMenu{},
+		Resources:    []*schema.PermissionResource{},
+	}
+	if unrestricted {
+		return report, nil
+	}
+
+	roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	report.Roles, err = a.queryRoles(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the enabled roles grant the permissions
+	enabledRoles := make(map[string]*schema.PermissionRole)
+	var enabledRoleIDs []string
+	for _, role := range report.Roles {
+		if role.Status == schema.RoleStatusEnabled {
+			enabledRoles[role.ID] = role
+			enabledRoleIDs = append(enabledRoleIDs, role.ID)
+		}
+	}
+	if len(enabledRoleIDs) == 0 {
+		return report, nil
+	}
+
+	if err := a.fillUserMenus(ctx, report, enabledRoles, enabledRoleIDs); err != nil {
+		return nil, err
+	}
+
+	enforcer := a.Enforcer.GetEnforcer()
+	if enforcer == nil {
+		return report, nil
+	}
+
+	tenantID, _ := util.FromTenantID(ctx)
+	domain := schema.CasbinDomain(tenantID)
+	effective := make(map[string]bool)
+	for _, roleID := range enabledRoleIDs {
+		for _, policy := range enforcer.GetFilteredPolicy(0, roleID, domain) {
+			item := schema.NewPermissionPolicy(policy)
+			if item == nil {
+				continue
+			}
+
+			key := item.Action + " " + item.Object
+			allowed, ok := effective[key]
+			if !ok {
+				allowed, _, err = middleware.CasbinEnforce(enforcer, roleIDs, domain, item.Object, item.Action, nil)
+				if err != nil {
+					return nil, err
+				}
+				effective[key] = allowed
+			}
+
+			report.Resources = append(report.Resources, &schema.PermissionResource{
+				Method:    item.Action,
+				Path:      item.Object,
+				Effect:    item.Effect,
+				Effective: allowed && item.Effect != schema.MenuResourceEffectDeny,
+				RoleID:    roleID,
+				RoleName:  enabledRoles[roleID].Name,
+			})
+		}
+	}
+	return report, nil
+}
+
+func (a *Permission) fillUserMenus(ctx context.Context, report *schema.PermissionUserReport, roles map[string]*schema.PermissionRole, roleIDs []string) error {
+	roleMenuResult, err := a.RoleMenuDAL.Query(ctx, schema.RoleMenuQueryParam{
+		InRoleIDs: roleIDs,
+	})
+	if err != nil {
+		return err
+	} else if len(roleMenuResult.Data) == 0 {
+		return nil
+	}
+
+	menuResult, err := a.MenuDAL.Query(ctx, schema.MenuQueryParam{
+		InIDs:  roleMenuResult.Data.ToMenuIDs(),
+		Status: schema.MenuStatusEnabled,
+	}, schema.MenuQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: schema.MenusOrderParams,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	menuRoleIDs := make(map[string][]string)
+	for _, item := range roleMenuResult.Data {
+		menuRoleIDs[item.MenuID] = append(menuRoleIDs[item.MenuID], item.RoleID)
+	}
+	for _, menu := range menuResult.Data {
+		for _, roleID := range menuRoleIDs[menu.ID] {
+			report.Menus = append(report.Menus, &schema.PermissionMenu{
+				MenuID:   menu.ID,
+				Code:     menu.Code,
+				Name:     menu.Name,
+				Type:     menu.Type,
+				Path:     menu.Path,
+				RoleID:   roleID,
+				RoleName: roles[roleID].Name,
+			})
+		}
+	}
+	return nil
+}
+
+// Report the enabled roles and their users which can access the API resource or the menu.
+func (a *Permission) ReportAccess(ctx context.Context, params schema.PermissionAccessReportParam) (*schema.PermissionAccessReport, error) {
+	report := &schema.PermissionAccessReport{
+		MenuID: params.MenuID,
+		Roles:  []*schema.PermissionRole{},
+		Users:  []*schema.PermissionUser{},
+	}
+
+	roleResult, err := a.RoleDAL.Query(ctx, schema.RoleQueryParam{
+		Status: schema.RoleStatusEnabled,
+	}, schema.RoleQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "code", "name", "status"},
+			OrderFields:  []util.OrderByParam{{Field: "sequence", Direction: util.DESC}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		grants func(roleID string) (granted, direct bool, err error)
+		check  func(roleIDs []string) (bool, error)
+	)
+	if params.MenuID != "" {
+		grants, err = a.menuGrants(ctx, params.MenuID)
+	} else {
+		report.Method = strings.ToUpper(params.Method)
+		report.Path = params.Path
+		grants = a.resourceGrants(ctx, report.Method, report.Path)
+		check = a.resourceCheck(ctx, report.Method, report.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]*schema.PermissionRole)
+	for _, role := range roleResult.Data {
+		granted, direct, err := grants(role.ID)
+		if err != nil {
+			return nil, err
+		} else if !granted {
+			continue
+		}
+
+		item := &schema.PermissionRole{
+			ID:        role.ID,
+			Code:      role.Code,
+			Name:      role.Name,
+			Status:    role.Status,
+			Inherited: !direct,
+		}
+		roles[role.ID] = item
+		report.Roles = append(report.Roles, item)
+	}
+	if len(roles) == 0 {
+		return report, nil
+	}
+
+	if err := a.fillAccessUsers(ctx, report, roles, check); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// The roles which have the menu, or inherit it from the parent roles, can access it.
+func (a *Permission) menuGrants(ctx context.Context, menuID string) (func(string) (bool, bool, error), error) {
+	exists, err := a.MenuDAL.Exists(ctx, menuID)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.NotFound("", "Menu not found")
+	}
+
+	roleMenuResult, err := a.RoleMenuDAL.Query(ctx, schema.RoleMenuQueryParam{
+		InMenuIDs: []string{menuID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	holders := make(map[string]bool)
+	for _, item := range roleMenuResult.Data {
+		holders[item.RoleID] = true
+	}
+
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(roleID string) (bool, bool, error) {
+		if holders[roleID] {
+			return true, true, nil
+		}
+		for _, ancestorID := range roleParentResult.Data.AncestorIDs(roleID) {
+			if holders[ancestorID] {
+				return true, false, nil
+			}
+		}
+		return false, false, nil
+	}, nil
+}
+
+// The roles which are allowed by casbin (including the inherited policies) can access the resource.
+func (a *Permission) resourceGrants(ctx context.Context, method, path string) func(string) (bool, bool, error) {
+	enforcer := a.Enforcer.GetEnforcer()
+	tenantID, _ := util.FromTenantID(ctx)
+	domain := schema.CasbinDomain(tenantID)
+
+	return func(roleID string) (bool, bool, error) {
+		if enforcer == nil {
+			return false, false, nil
+		}
+
+		allowed, _, err := middleware.CasbinEnforce(enforcer, []string{roleID}, domain, path, method, nil)
+		if err != nil || !allowed {
+			return false, false, err
+		}
+
+		for _, policy := range enforcer.GetFilteredPolicy(0, roleID, domain, "", method) {
+			item := schema.NewPermissionPolicy(policy)
+			if item != nil && item.Effect == schema.MenuResourceEffectAllow &&
+				(casbinutil.KeyMatch2(path, item.Object) || casbinutil.KeyMatch3(path, item.Object)) {
+				return true, true, nil
+			}
+		}
+		return true, false, nil
+	}
+}
+
+// Check the request with all roles of a user in the same way as the casbin middleware, the explicit deny of any role
+// overrides the allow of the other roles.
+func (a *Permission) resourceCheck(ctx context.Context, method, path string) func([]string) (bool, error) {
+	enforcer := a.Enforcer.GetEnforcer()
+	tenantID, _ := util.FromTenantID(ctx)
+	domain := schema.CasbinDomain(tenantID)
+
+	return func(roleIDs []string) (bool, error) {
+		if enforcer == nil {
+			return false, nil
+		}
+		allowed, _, err := middleware.CasbinEnforce(enforcer, roleIDs, domain, path, method, nil)
+		return allowed, err
+	}
+}
+
+// Fill the users holding the roles, the users are excluded if the check with all their roles fails.
+func (a *Permission) fillAccessUsers(ctx context.Context, report *schema.PermissionAccessReport, roles map[string]*schema.PermissionRole,
+	check func(roleIDs []string) (bool, error)) error {
+	roleIDs := make([]string, 0, len(roles))
+	for roleID := range roles {
+		roleIDs = append(roleIDs, roleID)
+	}
+
+	now := time.Now()
+	userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+		InRoleIDs: roleIDs,
+		ActiveAt:  &now,
+	})
+	if err != nil {
+		return err
+	} else if len(userRoleResult.Data) == 0 {
+		return nil
+	}
+
+	var userIDs []string
+	for userID := range userRoleResult.Data.ToUserIDMap() {
+		userIDs = append(userIDs, userID)
+	}
+	if check != nil {
+		allUserRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+			InUserIDs: userIDs,
+			ActiveAt:  &now,
+		})
+		if err != nil {
+			return err
+		}
+
+		userIDs = userIDs[:0]
+		for userID, userRoles := range allUserRoleResult.Data.ToUserIDMap() {
+			if allowed, err := check(userRoles.ToRoleIDs()); err != nil {
+				return err
+			} else if allowed {
+				userIDs = append(userIDs, userID)
+			}
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+	}
+	userResult, err := a.UserDAL.Query(ctx, schema.UserQueryParam{
+		InIDs: userIDs,
+	}, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "username", "name", "status"},
+			OrderFields:  []util.OrderByParam{{Field: "username", Direction: util.ASC}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	userRoles := userRoleResult.Data.ToUserIDMap()
+	for _, user := range userResult.Data {
+		for _, userRole := range userRoles[user.ID] {
+			report.Users = append(report.Users, &schema.PermissionUser{
+				UserID:   user.ID,
+				Username: user.Username,
+				Name:     user.Name,
+				Status:   user.Status,
+				RoleID:   userRole.RoleID,
+				RoleName: roles[userRole.RoleID].Name,
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// The admin role has the menu and may delete the users, the child role inherits both from the admin role. The blocked
+// role denies the deletion, and the disabled role is ignored even though it has the same grants as the admin role.
+func newTestPermission(t *testing.T) *Permission {
+	db := newTestDB(t, new(schema.User), new(schema.UserRole), new(schema.Role), new(schema.RoleParent),
+		new(schema.RoleMenu), new(schema.Menu))
+	role := func(id, status string, sequence int) *schema.Role {
+		return &schema.Role{ID: id, Code: id, Name: id, Status: status, Sequence: sequence}
+	}
+	user := func(id string) *schema.User {
+		return &schema.User{ID: id, Username: id, Name: id, Status: schema.UserStatusActivated}
+	}
+	expiredAt := time.Now().Add(-time.Hour)
+	mustCreate(t, db,
+		&schema.Menu{ID: "m1", Code: "m1", Name: "M1", Status: schema.MenuStatusEnabled},
+		role("admin", schema.RoleStatusEnabled, 4),
+		role("child", schema.RoleStatusEnabled, 3),
+		role("blocked", schema.RoleStatusEnabled, 2),
+		role("disabled", schema.RoleStatusDisabled, 1),
+		&schema.RoleParent{ID: "rp1", RoleID: "child", ParentID: "admin"},
+		&schema.RoleMenu{ID: "rm1", RoleID: "admin", MenuID: "m1"},
+		&schema.RoleMenu{ID: "rm2", RoleID: "disabled", MenuID: "m1"},
+		user("u1"), user("u2"), user("u3"), user("u4"), user("u5"),
+		&schema.UserRole{ID: "ur1", UserID: "u1", RoleID: "admin"},
+		&schema.UserRole{ID: "ur2", UserID: "u2", RoleID: "child"},
+		&schema.UserRole{ID: "ur3", UserID: "u3", RoleID: "admin", ExpiresAt: &expiredAt},
+		&schema.UserRole{ID: "ur4", UserID: "u4", RoleID: "admin"},
+		&schema.UserRole{ID: "ur5", UserID: "u4", RoleID: "blocked"},
+		&schema.UserRole{ID: "ur6", UserID: "u5", RoleID: "disabled"},
+	)
+
+	enforcer := newTestEnforcer(t,
+		[]string{"admin", "/api/v1/users/:id", "DELETE"},
+		[]string{"disabled", "/api/v1/users/:id", "DELETE"},
+	)
+	for _, rule := range [][]string{
+		{"blocked", schema.CasbinDefaultDomain, "/api/v1/users/:id", "DELETE", schema.MenuResourceEffectDeny, ""},
+	} {
+		if _, err := enforcer.enforcer.AddPolicy(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := enforcer.enforcer.AddGroupingPolicy("child", "admin", schema.CasbinDefaultDomain); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Permission{
+		Enforcer:      enforcer,
+		UserDAL:       &dal.User{DB: db},
+		UserRoleDAL:   &dal.UserRole{DB: db},
+		RoleDAL:       &dal.Role{DB: db},
+		RoleMenuDAL:   &dal.RoleMenu{DB: db},
+		RoleParentDAL: &dal.RoleParent{DB: db},
+		MenuDAL:       &dal.Menu{DB: db},
+	}
+}
+
+func TestPermissionReportAccess(t *testing.T) {
+	permission := newTestPermission(t)
+	ctx := context.Background()
+	roles := func(report *schema.PermissionAccessReport) map[string]bool {
+		items := make(map[string]bool)
+		for _, item := range report.Roles {
+			items[item.ID] = item.Inherited
+		}
+		return items
+	}
+	users := func(report *schema.PermissionAccessReport) [][2]string {
+		var items [][2]string
+		for _, item := range report.Users {
+			items = append(items, [2]string{item.UserID, item.RoleID})
+		}
+		return items
+	}
+
+	// The user with the blocked role is denied by the explicit deny, even though the admin role allows the request
+	report, err := permission.ReportAccess(ctx, schema.PermissionAccessReportParam{Method: "delete", Path: "/api/v1/users/u9"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "DELETE", report.Method)
+		assert.Equal(t, map[string]bool{"admin": false, "child": true}, roles(report))
+		assert.Equal(t, [][2]string{{"u1", "admin"}, {"u2", "child"}}, users(report))
+	}
+
+	// The menu is not checked by casbin, so the deny of the resource does not exclude the user
+	report, err = permission.ReportAccess(ctx, schema.PermissionAccessReportParam{MenuID: "m1"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]bool{"admin": false, "child": true}, roles(report))
+		assert.Equal(t, [][2]string{{"u1", "admin"}, {"u2", "child"}, {"u4", "admin"}}, users(report))
+	}
+
+	report, err = permission.ReportAccess(ctx, schema.PermissionAccessReportParam{Method: "GET", Path: "/api/v1/users"})
+	if assert.NoError(t, err) {
+		assert.Len(t, report.Roles, 0)
+		assert.Len(t, report.Users, 0)
+	}
+
+	_, err = permission.ReportAccess(ctx, schema.PermissionAccessReportParam{MenuID: "missing"})
+	assert.Equal(t, int32(404), errors.FromError(err).Code)
+}
@@ -2,10 +2,9 @@ package biz
 
 import (
 	"context"
-	"fmt"
 	"time"
 
-	"github.com/LyricTian/gin-admin/v10/internal/config"
+	orgdal "github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
@@ -15,11 +14,16 @@ import (
 
 // Role management for RBAC
 type Role struct {
-	Cache       cachex.Cacher
-	Trans       *util.Trans
-	RoleDAL     *dal.Role
-	RoleMenuDAL *dal.RoleMenu
-	UserRoleDAL *dal.UserRole
+	Cache             cachex.Cacher
+	Trans             *util.Trans
+	RoleDAL           *dal.Role
+	RoleMenuDAL       *dal.RoleMenu
+	UserRoleDAL       *dal.UserRole
+	RoleParentDAL     *dal.RoleParent
+	RoleConstraintDAL *dal.RoleConstraint
+	RoleConstraintBIZ *RoleConstraint
+	DepartmentDAL     *orgdal.Department
+	PolicySyncer      PolicySyncer
 }
 
 // Query roles from the data access object based on the provided parameters and options.
@@ -64,6 +68,32 @@ func (a *Role) Get(ctx context.Context, id string) (*schema.Role, error) {
 	}
 	role.Menus = roleMenuResult.Data
 
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{
+		RoleID: id,
+	}, schema.RoleParentQueryOptions{
+		JoinParent: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	role.Parents = roleParentResult.Data
+
+	role.InheritedMenus = schema.RoleMenus{}
+	if len(role.Parents) > 0 {
+		allParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+		if err != nil {
+			return nil, err
+		}
+		inheritedResult, err := a.RoleMenuDAL.Query(ctx, schema.RoleMenuQueryParam{
+			InRoleIDs: allParentResult.Data.AncestorIDs(id),
+		})
+		if err != nil {
+			return nil, err
+		}
+		role.InheritedMenus = inheritedResult.Data
+	}
+	role.EffectiveMenus = role.Menus.Merge(role.InheritedMenus)
+
 	return role, nil
 }
 
@@ -75,10 +105,17 @@ func (a *Role) Create(ctx context.Context, formItem *schema.RoleForm) (*schema.R
 		return nil, errors.BadRequest("", "Role code already exists")
 	}
 
+	if err := a.checkDataScope(ctx, formItem); err != nil {
+		return nil, err
+	}
+
 	role := &schema.Role{
 		ID:        util.NewXID(),
 		CreatedAt: time.Now(),
 	}
+	if err := a.checkParents(ctx, role.ID, formItem); err != nil {
+		return nil, err
+	}
 	if err := formItem.FillTo(role); err != nil {
 		return nil, err
 	}
@@ -96,12 +133,15 @@ func (a *Role) Create(ctx context.Context, formItem *schema.RoleForm) (*schema.R
 				return err
 			}
 		}
-		return a.syncToCasbin(ctx)
+		return a.createParents(ctx, role.ID, formItem.Parents)
 	})
 	if err != nil {
 		return nil, err
+	} else if err := syncToCasbin(ctx, a.Cache, a.PolicySyncer, role.ID); err != nil {
+		return nil, err
 	}
 	role.Menus = formItem.Menus
+	role.Parents = formItem.Parents
 
 	return role, nil
 }
@@ -121,12 +161,19 @@ func (a *Role) Update(ctx context.Context, id string, formItem *schema.RoleForm)
 		}
 	}
 
+	if err := a.checkDataScope(ctx, formItem); err != nil {
+		return err
+	}
+	if err := a.checkParents(ctx, id, formItem); err != nil {
+		return err
+	}
+
 	if err := formItem.FillTo(role); err != nil {
 		return err
 	}
 	role.UpdatedAt = time.Now()
 
-	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
 		if err := a.RoleDAL.Update(ctx, role); err != nil {
 			return err
 		}
@@ -146,8 +193,89 @@ func (a *Role) Update(ctx context.Context, id string, formItem *schema.RoleForm)
 				return err
 			}
 		}
-		return a.syncToCasbin(ctx)
+		if err := a.RoleParentDAL.DeleteByRoleID(ctx, id); err != nil {
+			return err
+		}
+		return a.createParents(ctx, id, formItem.Parents)
 	})
+	if err != nil {
+		return err
+	}
+	return syncToCasbin(ctx, a.Cache, a.PolicySyncer, id)
+}
+
+// Checks if the parent roles exist, the inheritance of the role has no cycle and breaks no exclusive constraint.
+func (a *Role) checkParents(ctx context.Context, id string, formItem *schema.RoleForm) error {
+	if len(formItem.Parents) == 0 {
+		return nil
+	}
+
+	parents := make(schema.RoleParents, 0, len(formItem.Parents))
+	seen := make(map[string]bool)
+	for _, parent := range formItem.Parents {
+		if parent.ParentID == id {
+			return errors.BadRequest("", "Role cannot inherit from itself")
+		} else if seen[parent.ParentID] {
+			continue
+		}
+		seen[parent.ParentID] = true
+
+		if exists, err := a.RoleDAL.Exists(ctx, parent.ParentID); err != nil {
+			return err
+		} else if !exists {
+			return errors.NotFound("", "Parent role not found")
+		}
+		parents = append(parents, parent)
+	}
+	formItem.Parents = parents
+
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return err
+	}
+	for _, ancestorID := range roleParentResult.Data.AncestorIDs(parents.ToParentIDs()...) {
+		if ancestorID == id {
+			return errors.BadRequest("", "Role inheritance cannot contain a cycle")
+		}
+	}
+	return a.RoleConstraintBIZ.CheckParents(ctx, id, parents.ToParentIDs())
+}
+
+func (a *Role) createParents(ctx context.Context, id string, parents schema.RoleParents) error {
+	for _, roleParent := range parents {
+		roleParent.ID = util.NewXID()
+		roleParent.RoleID = id
+		roleParent.CreatedAt = time.Now()
+		if err := a.RoleParentDAL.Create(ctx, roleParent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks if the departments of the custom data scope exist.
+func (a *Role) checkDataScope(ctx context.Context, formItem *schema.RoleForm) error {
+	if formItem.DataScope != schema.RoleDataScopeCustom {
+		return nil
+	}
+
+	departmentIDs := make([]string, 0, len(formItem.DataScopeDepartmentIDs))
+	seen := make(map[string]bool)
+	for _, id := range formItem.DataScopeDepartmentIDs {
+		if !seen[id] {
+			seen[id] = true
+			departmentIDs = append(departmentIDs, id)
+		}
+	}
+	formItem.DataScopeDepartmentIDs = departmentIDs
+
+	count, err := a.DepartmentDAL.CountByIDs(ctx, departmentIDs)
+	if err != nil {
+		return err
+	} else if count != int64(len(departmentIDs)) {
+		return errors.NotFound("", "Department not found")
+	}
+	return nil
 }
 
 // Delete the specified role from the data access object.
@@ -159,7 +287,7 @@ func (a *Role) Delete(ctx context.Context, id string) error {
 		return errors.NotFound("", "Role not found")
 	}
 
-	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
 		if err := a.RoleDAL.Delete(ctx, id); err != nil {
 			return err
 		}
@@ -169,11 +297,16 @@ func (a *Role) Delete(ctx context.Context, id string) error {
 		if err := a.UserRoleDAL.DeleteByRoleID(ctx, id); err != nil {
 			return err
 		}
-
-		return a.syncToCasbin(ctx)
+		if err := a.RoleParentDAL.DeleteByRoleID(ctx, id); err != nil {
+			return err
+		}
+		if err := a.RoleConstraintDAL.DeleteByRoleID(ctx, id); err != nil {
+			return err
+		}
+		return a.RoleParentDAL.DeleteByParentID(ctx, id)
 	})
-}
-
-func (a *Role) syncToCasbin(ctx context.Context) error {
-	return a.Cache.Set(ctx, config.CacheNSForRole, config.CacheKeyForSyncToCasbin, fmt.Sprintf("%d", time.Now().Unix()))
+	if err != nil {
+		return err
+	}
+	return syncToCasbin(ctx, a.Cache, a.PolicySyncer, id)
 }
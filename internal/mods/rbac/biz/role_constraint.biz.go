@@ -0,0 +1,356 @@
+package biz
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+// Separation of duties constraints between roles
+type RoleConstraint struct {
+	RoleConstraintDAL *dal.RoleConstraint
+	RoleDAL           *dal.Role
+	RoleParentDAL     *dal.RoleParent
+	UserRoleDAL       *dal.UserRole
+}
+
+// Query role constraints from the data access object based on the provided parameters and options.
+func (a *RoleConstraint) Query(ctx context.Context, params schema.RoleConstraintQueryParam) (*schema.RoleConstraintQueryResult, error) {
+	params.Pagination = true
+
+	result, err := a.RoleConstraintDAL.Query(ctx, params, schema.RoleConstraintQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "a.created_at", Direction: util.DESC},
+			},
+		},
+		JoinRole: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Get the specified role constraint from the data access object.
+func (a *RoleConstraint) Get(ctx context.Context, id string) (*schema.RoleConstraint, error) {
+	roleConstraint, err := a.RoleConstraintDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if roleConstraint == nil {
+		return nil, errors.NotFound("", "Role constraint not found")
+	}
+	return roleConstraint, nil
+}
+
+// Create a new role constraint in the data access object, the existing violations are not removed.
+func (a *RoleConstraint) Create(ctx context.Context, formItem *schema.RoleConstraintForm) (*schema.RoleConstraint, error) {
+	roleConstraint := &schema.RoleConstraint{
+		ID:        util.NewXID(),
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(roleConstraint); err != nil {
+		return nil, err
+	} else if err := a.checkRoles(ctx, roleConstraint); err != nil {
+		return nil, err
+	}
+
+	if err := a.RoleConstraintDAL.Create(ctx, roleConstraint); err != nil {
+		return nil, err
+	}
+	return roleConstraint, nil
+}
+
+// Update the specified role constraint in the data access object.
+func (a *RoleConstraint) Update(ctx context.Context, id string, formItem *schema.RoleConstraintForm) error {
+	roleConstraint, err := a.RoleConstraintDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if roleConstraint == nil {
+		return errors.NotFound("", "Role constraint not found")
+	}
+
+	if err := formItem.FillTo(roleConstraint); err != nil {
+		return err
+	} else if err := a.checkRoles(ctx, roleConstraint); err != nil {
+		return err
+	}
+	roleConstraint.UpdatedAt = time.Now()
+
+	return a.RoleConstraintDAL.Update(ctx, roleConstraint)
+}
+
+// Checks if the roles of the constraint exist and the same constraint is not defined twice.
+func (a *RoleConstraint) checkRoles(ctx context.Context, roleConstraint *schema.RoleConstraint) error {
+	for _, roleID := range []string{roleConstraint.RoleID, roleConstraint.OtherRoleID} {
+		if roleID == "" {
+			continue
+		}
+		if exists, err := a.RoleDAL.Exists(ctx, roleID); err != nil {
+			return err
+		} else if !exists {
+			return errors.NotFound("", "Role not found")
+		}
+	}
+
+	if exists, err := a.RoleConstraintDAL.ExistsRoles(ctx, roleConstraint); err != nil {
+		return err
+	} else if exists {
+		return errors.BadRequest("", "Role constraint already exists")
+	}
+	return nil
+}
+
+// Delete the specified role constraint from the data access object.
+func (a *RoleConstraint) Delete(ctx context.Context, id string) error {
+	exists, err := a.RoleConstraintDAL.Exists(ctx, id)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.NotFound("", "Role constraint not found")
+	}
+	return a.RoleConstraintDAL.Delete(ctx, id)
+}
+
+// Check the roles of the user after the assignment (the user ID is empty for a new user) against the constraints.
+// The exclusive roles are checked with the roles inherited from them. Only the violations caused by the assignment
+// are rejected, the existing ones (e.g. made before the constraint is added) are reported by QueryViolations.
+// It must be called in the transaction of the assignment, the roles with the max holders are locked until the
+// assignment is committed so that the concurrent assignments cannot exceed the limit.
+func (a *RoleConstraint) Check(ctx context.Context, userID string, userRoles schema.UserRoles) error {
+	constraintResult, err := a.RoleConstraintDAL.Query(ctx, schema.RoleConstraintQueryParam{}, schema.RoleConstraintQueryOptions{
+		JoinRole: true,
+	})
+	if err != nil {
+		return err
+	} else if len(constraintResult.Data) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var roleIDs []string
+	for _, userRole := range userRoles {
+		if userRole.ExpiresAt == nil || userRole.ExpiresAt.After(now) {
+			roleIDs = append(roleIDs, userRole.RoleID)
+		}
+	}
+
+	var currentRoleIDs []string
+	if userID != "" {
+		userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+			UserID:      userID,
+			UnexpiredAt: &now,
+		})
+		if err != nil {
+			return err
+		}
+		currentRoleIDs = userRoleResult.Data.ToRoleIDs()
+	}
+
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return err
+	}
+	assigned := toRoleSet(roleIDs)
+	current := toRoleSet(currentRoleIDs)
+	effective := toRoleSet(append(roleIDs, roleParentResult.Data.AncestorIDs(roleIDs...)...))
+	currentEffective := toRoleSet(append(currentRoleIDs, roleParentResult.Data.AncestorIDs(currentRoleIDs...)...))
+
+	for _, item := range constraintResult.Data {
+		switch item.Type {
+		case schema.RoleConstraintTypeExclusive:
+			if effective[item.RoleID] && effective[item.OtherRoleID] &&
+				!(currentEffective[item.RoleID] && currentEffective[item.OtherRoleID]) {
+				return errors.BadRequest("", "The roles %s and %s are mutually exclusive and cannot be held by the same user",
+					item.RoleName, item.OtherRoleName)
+			}
+		case schema.RoleConstraintTypeMaxHolders:
+			if !assigned[item.RoleID] || current[item.RoleID] {
+				continue
+			}
+
+			if _, err := a.RoleDAL.Get(util.NewRowLock(ctx), item.RoleID, schema.RoleQueryOptions{
+				QueryOptions: util.QueryOptions{SelectFields: []string{"id"}},
+			}); err != nil {
+				return err
+			}
+			holderResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+				RoleID:      item.RoleID,
+				UnexpiredAt: &now,
+			}, schema.UserRoleQueryOptions{
+				QueryOptions: util.QueryOptions{SelectFields: []string{"user_id"}},
+			})
+			if err != nil {
+				return err
+			} else if len(holderResult.Data.ToUserIDMap()) >= item.MaxHolders {
+				return errors.BadRequest("", "The holders of the role %s cannot exceed %d", item.RoleName, item.MaxHolders)
+			}
+		}
+	}
+	return nil
+}
+
+// Check the role inheriting from the parents against the exclusive constraints. The role self and the users holding
+// it (or the roles inheriting from it) must not hold the exclusive roles by the new parents.
+func (a *RoleConstraint) CheckParents(ctx context.Context, roleID string, parentIDs []string) error {
+	constraintResult, err := a.RoleConstraintDAL.Query(ctx, schema.RoleConstraintQueryParam{
+		Type: schema.RoleConstraintTypeExclusive,
+	}, schema.RoleConstraintQueryOptions{
+		JoinRole: true,
+	})
+	if err != nil {
+		return err
+	} else if len(constraintResult.Data) == 0 {
+		return nil
+	}
+
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return err
+	}
+	currentParents := roleParentResult.Data
+	var (
+		parents  schema.RoleParents
+		affected = []string{roleID}
+	)
+	for _, item := range currentParents {
+		if item.RoleID != roleID {
+			parents = append(parents, item)
+		}
+	}
+	for _, parentID := range parentIDs {
+		parents = append(parents, &schema.RoleParent{RoleID: roleID, ParentID: parentID})
+	}
+	for _, item := range currentParents {
+		if item.RoleID != roleID && toRoleSet(currentParents.AncestorIDs(item.RoleID))[roleID] {
+			affected = append(affected, item.RoleID)
+		}
+	}
+
+	now := time.Now()
+	userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+		InRoleIDs:   affected,
+		UnexpiredAt: &now,
+	}, schema.UserRoleQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"user_id"}},
+	})
+	if err != nil {
+		return err
+	}
+	var userIDs []string
+	for userID := range userRoleResult.Data.ToUserIDMap() {
+		userIDs = append(userIDs, userID)
+	}
+
+	roleSets := [][]string{{roleID}}
+	if len(userIDs) > 0 {
+		userRoleResult, err = a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+			InUserIDs:   userIDs,
+			UnexpiredAt: &now,
+		})
+		if err != nil {
+			return err
+		}
+		for _, userRoles := range userRoleResult.Data.ToUserIDMap() {
+			roleSets = append(roleSets, userRoles.ToRoleIDs())
+		}
+	}
+
+	for _, roleIDs := range roleSets {
+		current := toRoleSet(append(roleIDs, currentParents.AncestorIDs(roleIDs...)...))
+		effective := toRoleSet(append(roleIDs, parents.AncestorIDs(roleIDs...)...))
+		for _, item := range constraintResult.Data {
+			if effective[item.RoleID] && effective[item.OtherRoleID] && !(current[item.RoleID] && current[item.OtherRoleID]) {
+				return errors.BadRequest("", "The roles %s and %s are mutually exclusive and cannot be inherited together",
+					item.RoleName, item.OtherRoleName)
+			}
+		}
+	}
+	return nil
+}
+
+// Query the constraints which are broken by the current role assignments.
+func (a *RoleConstraint) QueryViolations(ctx context.Context) ([]*schema.RoleConstraintViolation, error) {
+	violations := []*schema.RoleConstraintViolation{}
+	constraintResult, err := a.RoleConstraintDAL.Query(ctx, schema.RoleConstraintQueryParam{}, schema.RoleConstraintQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{{Field: "a.created_at", Direction: util.DESC}},
+		},
+		JoinRole: true,
+	})
+	if err != nil {
+		return nil, err
+	} else if len(constraintResult.Data) == 0 {
+		return violations, nil
+	}
+
+	now := time.Now()
+	userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+		UnexpiredAt: &now,
+	}, schema.UserRoleQueryOptions{
+		JoinUser: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*schema.RoleConstraintViolationUser
+	assigned := make(map[string]map[string]bool)
+	effective := make(map[string]map[string]bool)
+	for userID, userRoles := range userRoleResult.Data.ToUserIDMap() {
+		roleIDs := userRoles.ToRoleIDs()
+		users = append(users, &schema.RoleConstraintViolationUser{UserID: userID, Username: userRoles[0].Username})
+		assigned[userID] = toRoleSet(roleIDs)
+		effective[userID] = toRoleSet(append(roleIDs, roleParentResult.Data.AncestorIDs(roleIDs...)...))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	for _, item := range constraintResult.Data {
+		violation := &schema.RoleConstraintViolation{
+			Constraint: item,
+			Users:      []*schema.RoleConstraintViolationUser{},
+		}
+		for _, user := range users {
+			switch item.Type {
+			case schema.RoleConstraintTypeExclusive:
+				if effective[user.UserID][item.RoleID] && effective[user.UserID][item.OtherRoleID] {
+					violation.Users = append(violation.Users, user)
+				}
+			case schema.RoleConstraintTypeMaxHolders:
+				if assigned[user.UserID][item.RoleID] {
+					violation.Users = append(violation.Users, user)
+				}
+			}
+		}
+
+		if item.Type == schema.RoleConstraintTypeMaxHolders {
+			violation.Holders = len(violation.Users)
+			if violation.Holders <= item.MaxHolders {
+				continue
+			}
+		} else if len(violation.Users) == 0 {
+			continue
+		}
+		violations = append(violations, violation)
+	}
+	return violations, nil
+}
+
+func toRoleSet(roleIDs []string) map[string]bool {
+	m := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		m[roleID] = true
+	}
+	return m
+}
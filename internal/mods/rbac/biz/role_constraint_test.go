@@ -0,0 +1,204 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRoleConstraint(t *testing.T) (*RoleConstraint, *util.Trans) {
+	db := newTestDB(t, new(schema.User), new(schema.Role), new(schema.RoleParent), new(schema.UserRole),
+		new(schema.RoleConstraint))
+
+	expired := time.Now().Add(-time.Hour)
+	mustCreate(t, db,
+		&schema.Role{ID: "a", Code: "a", Name: "Approver", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "r", Code: "r", Name: "Requester", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "m", Code: "m", Name: "Master", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "x", Code: "x", Name: "Extended requester", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "b", Code: "b", Name: "Base", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "z", Code: "z", Name: "Parent", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "w", Code: "w", Name: "Child", Status: schema.RoleStatusEnabled},
+		&schema.RoleParent{ID: "rp1", RoleID: "x", ParentID: "r"},
+		&schema.RoleParent{ID: "rp2", RoleID: "w", ParentID: "z"},
+		&schema.RoleConstraint{ID: "c1", Type: schema.RoleConstraintTypeExclusive, RoleID: "a", OtherRoleID: "r"},
+		&schema.RoleConstraint{ID: "c2", Type: schema.RoleConstraintTypeMaxHolders, RoleID: "m", MaxHolders: 1},
+	)
+	for _, item := range []struct {
+		userID  string
+		roleIDs []string
+	}{
+		{"alice", []string{"a", "b"}},
+		{"bob", []string{"m"}},
+		{"carol", []string{"a", "r"}},
+		{"dave", []string{"z"}},
+		{"erin", []string{"a", "w"}},
+	} {
+		mustCreate(t, db, &schema.User{ID: item.userID, Username: item.userID, Status: schema.UserStatusActivated})
+		for _, roleID := range item.roleIDs {
+			mustCreate(t, db, &schema.UserRole{ID: item.userID + roleID, UserID: item.userID, RoleID: roleID})
+		}
+	}
+	mustCreate(t, db, &schema.UserRole{ID: "daver", UserID: "dave", RoleID: "r", ExpiresAt: &expired})
+
+	return &RoleConstraint{
+		RoleConstraintDAL: &dal.RoleConstraint{DB: db},
+		RoleDAL:           &dal.Role{DB: db},
+		RoleParentDAL:     &dal.RoleParent{DB: db},
+		UserRoleDAL:       &dal.UserRole{DB: db},
+	}, &util.Trans{DB: db}
+}
+
+func toUserRoles(userID string, roleIDs ...string) schema.UserRoles {
+	var userRoles schema.UserRoles
+	for _, roleID := range roleIDs {
+		userRoles = append(userRoles, &schema.UserRole{UserID: userID, RoleID: roleID})
+	}
+	return userRoles
+}
+
+func TestRoleConstraint(t *testing.T) {
+	roleConstraintBIZ, trans := newTestRoleConstraint(t)
+	ctx := context.Background()
+
+	formItem := &schema.RoleConstraintForm{
+		Type:        schema.RoleConstraintTypeExclusive,
+		RoleID:      "b",
+		OtherRoleID: "z",
+		Description: "Base and parent are held by different users",
+	}
+	roleConstraint, err := roleConstraintBIZ.Create(ctx, formItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, roleConstraint.ID)
+	assert.Equal(t, formItem.Type, roleConstraint.Type)
+	assert.Equal(t, formItem.RoleID, roleConstraint.RoleID)
+	assert.Equal(t, formItem.OtherRoleID, roleConstraint.OtherRoleID)
+
+	_, err = roleConstraintBIZ.Create(ctx, formItem)
+	assert.Equal(t, int32(400), errors.FromError(err).Code, "the same constraint is defined twice")
+	_, err = roleConstraintBIZ.Create(ctx, &schema.RoleConstraintForm{Type: schema.RoleConstraintTypeExclusive, RoleID: "b", OtherRoleID: "missing"})
+	assert.Equal(t, int32(404), errors.FromError(err).Code)
+
+	// A new user cannot hold both roles, no violation is reported for the existing users
+	err = trans.Exec(ctx, func(ctx context.Context) error {
+		return roleConstraintBIZ.Check(ctx, "", toUserRoles("", "b", "z"))
+	})
+	assert.Equal(t, int32(400), errors.FromError(err).Code)
+	violations, err := roleConstraintBIZ.QueryViolations(ctx)
+	assert.Nil(t, err)
+	for _, violation := range violations {
+		assert.NotEqual(t, roleConstraint.ID, violation.Constraint.ID)
+	}
+
+	formItem.Description = "Updated"
+	assert.Nil(t, roleConstraintBIZ.Update(ctx, roleConstraint.ID, formItem))
+	roleConstraint, err = roleConstraintBIZ.Get(ctx, roleConstraint.ID)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "Updated", roleConstraint.Description)
+	}
+
+	assert.Nil(t, roleConstraintBIZ.Delete(ctx, roleConstraint.ID))
+	_, err = roleConstraintBIZ.Get(ctx, roleConstraint.ID)
+	assert.Equal(t, int32(404), errors.FromError(err).Code)
+	assert.Equal(t, int32(404), errors.FromError(roleConstraintBIZ.Delete(ctx, roleConstraint.ID)).Code)
+}
+
+func TestRoleConstraintCheck(t *testing.T) {
+	roleConstraintBIZ, trans := newTestRoleConstraint(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		userID  string
+		roleIDs []string
+		valid   bool
+	}{
+		{"exclusive roles", "", []string{"a", "r"}, false},
+		{"exclusive role inherited", "", []string{"a", "x"}, false},
+		{"exclusive role added", "alice", []string{"a", "b", "r"}, false},
+		{"max holders exceeded", "", []string{"m"}, false},
+		{"max holders kept", "bob", []string{"m", "b"}, true},
+		{"existing violation kept", "carol", []string{"a", "r"}, true},
+		{"expired exclusive role", "dave", []string{"a", "z"}, true},
+		{"unconstrained roles", "", []string{"b", "z"}, true},
+	}
+	for _, tt := range tests {
+		err := trans.Exec(ctx, func(ctx context.Context) error {
+			return roleConstraintBIZ.Check(ctx, tt.userID, toUserRoles(tt.userID, tt.roleIDs...))
+		})
+		assert.Equal(t, tt.valid, err == nil, tt.name, err)
+	}
+
+	// The expired roles are not counted in the assignment
+	expired := time.Now().Add(-time.Minute)
+	userRoles := toUserRoles("", "a", "r")
+	userRoles[1].ExpiresAt = &expired
+	assert.Nil(t, roleConstraintBIZ.Check(ctx, "", userRoles))
+}
+
+func TestRoleConstraintCheckParents(t *testing.T) {
+	roleConstraintBIZ, _ := newTestRoleConstraint(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		roleID    string
+		parentIDs []string
+		valid     bool
+	}{
+		{"role inherits exclusive roles", "y", []string{"a", "r"}, false},
+		{"holder inherits exclusive role", "b", []string{"r"}, false},
+		{"holder of child inherits exclusive role", "z", []string{"x"}, false},
+		{"holder without exclusive role", "m", []string{"r"}, true},
+		{"no exclusive role inherited", "b", []string{"z"}, true},
+		{"existing violation kept", "r", []string{"b"}, true},
+	}
+	for _, tt := range tests {
+		err := roleConstraintBIZ.CheckParents(ctx, tt.roleID, tt.parentIDs)
+		assert.Equal(t, tt.valid, err == nil, tt.name, err)
+	}
+}
+
+func TestRoleConstraintQueryViolations(t *testing.T) {
+	roleConstraintBIZ, _ := newTestRoleConstraint(t)
+	ctx := context.Background()
+
+	violations, err := roleConstraintBIZ.QueryViolations(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, violations, 1) {
+		assert.Equal(t, "c1", violations[0].Constraint.ID)
+		assert.Equal(t, "Approver", violations[0].Constraint.RoleName)
+		if assert.Len(t, violations[0].Users, 1) {
+			assert.Equal(t, "carol", violations[0].Users[0].Username)
+		}
+	}
+
+	// The violations by the inherited roles and the exceeded holders are reported
+	db := roleConstraintBIZ.UserRoleDAL.DB
+	mustCreate(t, db,
+		&schema.User{ID: "frank", Username: "frank", Status: schema.UserStatusActivated},
+		&schema.UserRole{ID: "frankm", UserID: "frank", RoleID: "m"},
+		&schema.UserRole{ID: "frankx", UserID: "frank", RoleID: "x"},
+		&schema.UserRole{ID: "franka", UserID: "frank", RoleID: "a"},
+	)
+	violations, err = roleConstraintBIZ.QueryViolations(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, violations, 2) {
+		for _, violation := range violations {
+			switch violation.Constraint.ID {
+			case "c1":
+				assert.Len(t, violation.Users, 2)
+			case "c2":
+				assert.Equal(t, 2, violation.Holders)
+			}
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package biz
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Just-in-time elevation, the users request a role for a bounded window which is granted once approved
+type RoleElevation struct {
+	Cache             cachex.Cacher
+	Trans             *util.Trans
+	RoleElevationDAL  *dal.RoleElevation
+	RoleDAL           *dal.Role
+	RoleParentDAL     *dal.RoleParent
+	UserRoleDAL       *dal.UserRole
+	UserBIZ           *User
+	RoleConstraintBIZ *RoleConstraint
+}
+
+func (a *RoleElevation) checkEnabled(ctx context.Context) error {
+	if !config.C.Security.Elevation.Enable {
+		return errors.BadRequest("", "Role elevation is disabled")
+	} else if _, ok := util.FromAPIKeyScopes(ctx); ok {
+		return errors.Forbidden("", "The operation is not allowed with an API key")
+	} else if _, ok := util.FromImpersonation(ctx); ok {
+		return errors.Forbidden(config.ErrImpersonationBlockedID, "The operation is not allowed during impersonation")
+	}
+	return nil
+}
+
+// Checks if the current user (root, the administrator of the tenant, or the holders of the approver roles) can
+// approve or reject the requests.
+func (a *RoleElevation) checkApprover(ctx context.Context) error {
+	if util.FromIsRootUser(ctx) || util.FromIsTenantAdmin(ctx) {
+		return nil
+	}
+
+	forbidden := errors.Forbidden("", "Only the approvers can review the role elevations")
+	codes := config.C.Security.Elevation.ApproverRoleCodes
+	if len(codes) == 0 {
+		return forbidden
+	}
+	approverRoleIDs, err := a.RoleDAL.GetIDsByCodes(ctx, codes)
+	if err != nil {
+		return err
+	}
+
+	userRoleIDs, err := a.UserBIZ.GetRoleIDs(ctx, util.FromUserID(ctx))
+	if err != nil {
+		return err
+	}
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return err
+	}
+	held := toRoleSet(append(userRoleIDs, roleParentResult.Data.AncestorIDs(userRoleIDs...)...))
+	for _, roleID := range approverRoleIDs {
+		if held[roleID] {
+			return nil
+		}
+	}
+	return forbidden
+}
+
+// Get the enabled role which can be requested.
+func (a *RoleElevation) getRole(ctx context.Context, roleID string) (*schema.Role, error) {
+	role, err := a.RoleDAL.Get(ctx, roleID, schema.RoleQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "code", "name", "status"}},
+	})
+	if err != nil {
+		return nil, err
+	} else if role == nil {
+		return nil, errors.NotFound("", "Role not found")
+	} else if role.Status != schema.RoleStatusEnabled {
+		return nil, errors.BadRequest("", "Role is disabled")
+	}
+
+	if codes := config.C.Security.Elevation.RoleCodes; len(codes) > 0 {
+		for _, code := range codes {
+			if code == role.Code {
+				return role, nil
+			}
+		}
+		return nil, errors.Forbidden("", "The role %s cannot be requested", role.Name)
+	}
+	return role, nil
+}
+
+// Checks the roles of the user with the requested role against the separation of duties constraints.
+func (a *RoleElevation) checkConstraints(ctx context.Context, userID, roleID string) error {
+	now := time.Now()
+	userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+		UserID:      userID,
+		UnexpiredAt: &now,
+	})
+	if err != nil {
+		return err
+	}
+	userRoles := append(userRoleResult.Data, &schema.UserRole{UserID: userID, RoleID: roleID})
+	return a.RoleConstraintBIZ.Check(ctx, userID, userRoles)
+}
+
+// Request the role for a bounded window by the current user.
+func (a *RoleElevation) Request(ctx context.Context, formItem *schema.RoleElevationForm) (*schema.RoleElevation, error) {
+	if err := a.checkEnabled(ctx); err != nil {
+		return nil, err
+	} else if util.FromIsRootUser(ctx) || util.FromIsTenantAdmin(ctx) {
+		return nil, errors.BadRequest("", "The user is unrestricted")
+	}
+
+	userID := util.FromUserID(ctx)
+	role, err := a.getRole(ctx, formItem.RoleID)
+	if err != nil {
+		return nil, err
+	}
+
+	roleIDs, err := a.UserBIZ.GetRoleIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, roleID := range roleIDs {
+		if roleID == role.ID {
+			return nil, errors.BadRequest("", "The role %s is already held", role.Name)
+		}
+	}
+	if exists, err := a.RoleElevationDAL.ExistsPending(ctx, userID, role.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errors.BadRequest("", "The request of the role %s is waiting for the approval", role.Name)
+	}
+	if err := a.checkConstraints(ctx, userID, role.ID); err != nil {
+		return nil, err
+	}
+
+	roleElevation := &schema.RoleElevation{
+		ID:        util.NewXID(),
+		UserID:    userID,
+		Status:    schema.RoleElevationStatusPending,
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(roleElevation); err != nil {
+		return nil, err
+	} else if err := a.RoleElevationDAL.Create(ctx, roleElevation); err != nil {
+		return nil, err
+	}
+	roleElevation.RoleName = role.Name
+
+	ctx = logging.NewTag(ctx, logging.TagKeyElevation)
+	logging.Context(ctx).Info("Role elevation requested",
+		zap.String("elevation_id", roleElevation.ID),
+		zap.String("role_id", role.ID),
+		zap.String("role_name", role.Name),
+		zap.Int("duration", roleElevation.Duration),
+		zap.String("justification", roleElevation.Justification))
+	return roleElevation, nil
+}
+
+// Query the requests of the current user, the latest first.
+func (a *RoleElevation) QueryMine(ctx context.Context, params schema.RoleElevationQueryParam) (*schema.RoleElevationQueryResult, error) {
+	params.Pagination = true
+	params.UserID = util.FromUserID(ctx)
+	return a.RoleElevationDAL.Query(ctx, params, schema.RoleElevationQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{{Field: "a.created_at", Direction: util.DESC}},
+		},
+		JoinRole: true,
+	})
+}
+
+// Cancel the pending request of the current user.
+func (a *RoleElevation) Cancel(ctx context.Context, id string) error {
+	if err := a.checkEnabled(ctx); err != nil {
+		return err
+	}
+
+	roleElevation, err := a.RoleElevationDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if roleElevation == nil || roleElevation.UserID != util.FromUserID(ctx) {
+		return errors.NotFound("", "Role elevation not found")
+	}
+
+	roleElevation.Status = schema.RoleElevationStatusCancelled
+	roleElevation.UpdatedAt = time.Now()
+	if ok, err := a.RoleElevationDAL.UpdatePending(ctx, roleElevation); err != nil {
+		return err
+	} else if !ok {
+		return errors.BadRequest("", "Only the pending request can be cancelled")
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeyElevation)
+	logging.Context(ctx).Info("Role elevation cancelled",
+		zap.String("elevation_id", roleElevation.ID),
+		zap.String("role_id", roleElevation.RoleID))
+	return nil
+}
+
+// Query the requests of all users for the approvers, the latest first.
+func (a *RoleElevation) QueryReviews(ctx context.Context, params schema.RoleElevationQueryParam) (*schema.RoleElevationQueryResult, error) {
+	if err := a.checkEnabled(ctx); err != nil {
+		return nil, err
+	} else if err := a.checkApprover(ctx); err != nil {
+		return nil, err
+	}
+
+	params.Pagination = true
+	return a.RoleElevationDAL.Query(ctx, params, schema.RoleElevationQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{{Field: "a.created_at", Direction: util.DESC}},
+		},
+		JoinUser: true,
+		JoinRole: true,
+	})
+}
+
+// Get the pending request for the review, the approvers cannot review their own requests.
+func (a *RoleElevation) getReview(ctx context.Context, id string) (*schema.RoleElevation, error) {
+	if err := a.checkEnabled(ctx); err != nil {
+		return nil, err
+	} else if err := a.checkApprover(ctx); err != nil {
+		return nil, err
+	}
+
+	roleElevation, err := a.RoleElevationDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if roleElevation == nil {
+		return nil, errors.NotFound("", "Role elevation not found")
+	} else if roleElevation.UserID == util.FromUserID(ctx) {
+		return nil, errors.Forbidden("", "The own request cannot be reviewed")
+	} else if roleElevation.Status != schema.RoleElevationStatusPending {
+		return nil, errors.BadRequest("", "The request has been reviewed or cancelled")
+	}
+	return roleElevation, nil
+}
+
+// Approve the request, the role is assigned to the user from now until the end of the requested window.
+func (a *RoleElevation) Approve(ctx context.Context, id string, formItem *schema.RoleElevationReviewForm) error {
+	roleElevation, err := a.getReview(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	role, err := a.getRole(ctx, roleElevation.RoleID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(roleElevation.Duration) * time.Minute)
+	userRole := &schema.UserRole{
+		ID:        util.NewXID(),
+		UserID:    roleElevation.UserID,
+		RoleID:    role.ID,
+		StartsAt:  &now,
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
+	}
+	roleElevation.Status = schema.RoleElevationStatusApproved
+	roleElevation.ReviewerID = util.FromUserID(ctx)
+	roleElevation.ReviewComment = formItem.Comment
+	roleElevation.ReviewedAt = &now
+	roleElevation.UserRoleID = userRole.ID
+	roleElevation.StartsAt = &now
+	roleElevation.ExpiresAt = &expiresAt
+	roleElevation.UpdatedAt = now
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.checkConstraints(ctx, roleElevation.UserID, role.ID); err != nil {
+			return err
+		}
+		if ok, err := a.RoleElevationDAL.UpdatePending(ctx, roleElevation); err != nil {
+			return err
+		} else if !ok {
+			return errors.BadRequest("", "The request has been reviewed or cancelled")
+		}
+		if err := a.UserRoleDAL.Create(ctx, userRole); err != nil {
+			return err
+		}
+		// The role takes effect on the next request and is removed by the sweeper of the time-bounded roles
+		return a.Cache.Delete(ctx, config.CacheNSForUser, roleElevation.UserID)
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeyElevation)
+	logging.Context(ctx).Warn("Role elevation approved",
+		zap.String("elevation_id", roleElevation.ID),
+		zap.String("target_user_id", roleElevation.UserID),
+		zap.String("role_id", role.ID),
+		zap.String("role_name", role.Name),
+		zap.String("comment", formItem.Comment),
+		zap.Time("expires_at", expiresAt))
+	return nil
+}
+
+// Reject the request.
+func (a *RoleElevation) Reject(ctx context.Context, id string, formItem *schema.RoleElevationReviewForm) error {
+	roleElevation, err := a.getReview(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	roleElevation.Status = schema.RoleElevationStatusRejected
+	roleElevation.ReviewerID = util.FromUserID(ctx)
+	roleElevation.ReviewComment = formItem.Comment
+	roleElevation.ReviewedAt = &now
+	roleElevation.UpdatedAt = now
+	if ok, err := a.RoleElevationDAL.UpdatePending(ctx, roleElevation); err != nil {
+		return err
+	} else if !ok {
+		return errors.BadRequest("", "The request has been reviewed or cancelled")
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeyElevation)
+	logging.Context(ctx).Info("Role elevation rejected",
+		zap.String("elevation_id", roleElevation.ID),
+		zap.String("target_user_id", roleElevation.UserID),
+		zap.String("role_id", roleElevation.RoleID),
+		zap.String("comment", formItem.Comment))
+	return nil
+}
@@ -0,0 +1,156 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// The requester holds the staff role and can request the admin role, the approver holds the approver role.
+func newTestRoleElevation(t *testing.T) *RoleElevation {
+	elevation := config.C.Security.Elevation
+	t.Cleanup(func() { config.C.Security.Elevation = elevation })
+	config.C.Security.Elevation.Enable = true
+	config.C.Security.Elevation.RoleCodes = []string{"admin", "auditor"}
+	config.C.Security.Elevation.ApproverRoleCodes = []string{"approver"}
+
+	db := newTestDB(t, new(schema.User), new(schema.Role), new(schema.RoleParent), new(schema.UserRole),
+		new(schema.RoleElevation), new(schema.RoleConstraint))
+	mustCreate(t, db,
+		&schema.Role{ID: "admin", Code: "admin", Name: "Admin", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "auditor", Code: "auditor", Name: "Auditor", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "approver", Code: "approver", Name: "Approver", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "staff", Code: "staff", Name: "Staff", Status: schema.RoleStatusEnabled},
+		&schema.User{ID: "requester", Username: "requester", Status: schema.UserStatusActivated},
+		&schema.User{ID: "approver", Username: "approver", Status: schema.UserStatusActivated},
+		&schema.UserRole{ID: "ur1", UserID: "requester", RoleID: "staff"},
+		&schema.UserRole{ID: "ur2", UserID: "approver", RoleID: "approver"},
+	)
+
+	roleDAL := &dal.Role{DB: db}
+	roleParentDAL := &dal.RoleParent{DB: db}
+	userRoleDAL := &dal.UserRole{DB: db}
+	roleElevationDAL := &dal.RoleElevation{DB: db}
+	return &RoleElevation{
+		Cache:            cachex.NewMemoryCache(cachex.MemoryConfig{}),
+		Trans:            &util.Trans{DB: db},
+		RoleElevationDAL: roleElevationDAL,
+		RoleDAL:          roleDAL,
+		RoleParentDAL:    roleParentDAL,
+		UserRoleDAL:      userRoleDAL,
+		UserBIZ:          &User{UserRoleDAL: userRoleDAL, RoleElevationDAL: roleElevationDAL},
+		RoleConstraintBIZ: &RoleConstraint{
+			RoleConstraintDAL: &dal.RoleConstraint{DB: db},
+			RoleDAL:           roleDAL,
+			RoleParentDAL:     roleParentDAL,
+			UserRoleDAL:       userRoleDAL,
+		},
+	}
+}
+
+func newTestElevationCtx(ctx context.Context, userID string, roleIDs ...string) context.Context {
+	return util.NewUserCache(util.NewUserID(ctx, userID), util.UserCache{RoleIDs: roleIDs})
+}
+
+func TestRoleElevation(t *testing.T) {
+	roleElevationBIZ := newTestRoleElevation(t)
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logging.NewLogger(context.Background(), zap.New(core))
+	requesterCtx := newTestElevationCtx(ctx, "requester", "staff")
+	approverCtx := newTestElevationCtx(ctx, "approver", "approver")
+	code := func(err error) int32 {
+		if err == nil {
+			return 0
+		}
+		return errors.FromError(err).Code
+	}
+
+	roleElevation, err := roleElevationBIZ.Request(requesterCtx, &schema.RoleElevationForm{RoleID: "admin", Duration: 30, Justification: "incident"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = roleElevationBIZ.Request(requesterCtx, &schema.RoleElevationForm{RoleID: "admin", Duration: 30, Justification: "incident"})
+	assert.Equal(t, int32(400), code(err), "the request is waiting for the approval")
+
+	// The roles out of the elevation grant cannot be requested
+	_, err = roleElevationBIZ.Request(requesterCtx, &schema.RoleElevationForm{RoleID: "approver", Duration: 30, Justification: "approve myself"})
+	assert.Equal(t, int32(403), code(err))
+
+	// The requester cannot approve their own request, even holding the approver role
+	selfApproverCtx := newTestElevationCtx(ctx, "requester", "staff", "approver")
+	mustCreate(t, roleElevationBIZ.UserRoleDAL.DB, &schema.UserRole{ID: "ur3", UserID: "requester", RoleID: "approver"})
+	assert.Equal(t, int32(403), code(roleElevationBIZ.Approve(selfApproverCtx, roleElevation.ID, &schema.RoleElevationReviewForm{})))
+	assert.NoError(t, roleElevationBIZ.UserRoleDAL.Delete(ctx, "ur3"))
+
+	// The users without the approver role cannot approve
+	assert.Equal(t, int32(403), code(roleElevationBIZ.Approve(newTestElevationCtx(ctx, "other", "staff"), roleElevation.ID, &schema.RoleElevationReviewForm{})))
+
+	// The role removed from the elevation grant after the request cannot be approved
+	config.C.Security.Elevation.RoleCodes = []string{"auditor"}
+	assert.Equal(t, int32(403), code(roleElevationBIZ.Approve(approverCtx, roleElevation.ID, &schema.RoleElevationReviewForm{})))
+	roleIDs, err := roleElevationBIZ.UserBIZ.GetRoleIDs(ctx, "requester")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"staff"}, roleIDs)
+	config.C.Security.Elevation.RoleCodes = []string{"admin", "auditor"}
+
+	// The approved role is in effect for the requested window only
+	assert.NoError(t, roleElevationBIZ.Cache.Set(ctx, config.CacheNSForUser, "requester", "cached"))
+	assert.NoError(t, roleElevationBIZ.Approve(approverCtx, roleElevation.ID, &schema.RoleElevationReviewForm{Comment: "ok"}))
+	exists, err := roleElevationBIZ.Cache.Exists(ctx, config.CacheNSForUser, "requester")
+	assert.NoError(t, err)
+	assert.False(t, exists, "the user cache is reloaded with the role")
+	assert.Equal(t, int32(400), code(roleElevationBIZ.Approve(approverCtx, roleElevation.ID, &schema.RoleElevationReviewForm{})))
+
+	userBIZ := roleElevationBIZ.UserBIZ
+	for _, tt := range []struct {
+		at       time.Duration
+		roleIDs  []string
+		elevated []string
+	}{
+		{time.Minute, []string{"staff", "admin"}, []string{"admin"}},
+		{31 * time.Minute, []string{"staff"}, nil},
+	} {
+		now := time.Now().Add(tt.at)
+		userBIZ.now = func() time.Time { return now }
+		roleIDs, err := userBIZ.GetRoleIDs(ctx, "requester")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, tt.roleIDs, roleIDs, tt.at)
+		elevated, err := userBIZ.GetElevatedRoleIDs(ctx, "requester", roleIDs)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.elevated, elevated, tt.at)
+	}
+
+	// Each step is recorded under the dedicated tag
+	rejected, err := roleElevationBIZ.Request(requesterCtx, &schema.RoleElevationForm{RoleID: "auditor", Duration: 10, Justification: "audit"})
+	assert.NoError(t, err)
+	assert.NoError(t, roleElevationBIZ.Reject(approverCtx, rejected.ID, &schema.RoleElevationReviewForm{Comment: "no"}))
+	cancelled, err := roleElevationBIZ.Request(requesterCtx, &schema.RoleElevationForm{RoleID: "auditor", Duration: 10, Justification: "audit"})
+	assert.NoError(t, err)
+	assert.NoError(t, roleElevationBIZ.Cancel(requesterCtx, cancelled.ID))
+
+	var messages []string
+	for _, entry := range logs.All() {
+		if entry.ContextMap()["tag"] == logging.TagKeyElevation {
+			messages = append(messages, entry.Message)
+		}
+	}
+	assert.Equal(t, []string{
+		"Role elevation requested",
+		"Role elevation approved",
+		"Role elevation requested",
+		"Role elevation rejected",
+		"Role elevation requested",
+		"Role elevation cancelled",
+	}, messages)
+}
@@ -0,0 +1,129 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPolicySyncer struct {
+	roleIDs []string
+}
+
+func (a *testPolicySyncer) SyncRoles(ctx context.Context, roleIDs ...string) error {
+	a.roleIDs = append(a.roleIDs, roleIDs...)
+	return nil
+}
+
+func newTestRole(t *testing.T) *Role {
+	db := newTestDB(t, new(schema.Role), new(schema.RoleMenu), new(schema.RoleParent), new(schema.UserRole),
+		new(schema.RoleConstraint), new(schema.Menu))
+	mustCreate(t, db,
+		&schema.Menu{ID: "ma", Code: "ma", Name: "MA", Status: schema.MenuStatusEnabled},
+		&schema.Menu{ID: "mb", Code: "mb", Name: "MB", Status: schema.MenuStatusEnabled},
+		&schema.Menu{ID: "mc", Code: "mc", Name: "MC", Status: schema.MenuStatusEnabled},
+	)
+
+	roleDAL := &dal.Role{DB: db}
+	roleParentDAL := &dal.RoleParent{DB: db}
+	userRoleDAL := &dal.UserRole{DB: db}
+	roleConstraintDAL := &dal.RoleConstraint{DB: db}
+	return &Role{
+		Cache:             cachex.NewMemoryCache(cachex.MemoryConfig{}),
+		Trans:             &util.Trans{DB: db},
+		RoleDAL:           roleDAL,
+		RoleMenuDAL:       &dal.RoleMenu{DB: db},
+		UserRoleDAL:       userRoleDAL,
+		RoleParentDAL:     roleParentDAL,
+		RoleConstraintDAL: roleConstraintDAL,
+		RoleConstraintBIZ: &RoleConstraint{
+			RoleConstraintDAL: roleConstraintDAL,
+			RoleDAL:           roleDAL,
+			RoleParentDAL:     roleParentDAL,
+			UserRoleDAL:       userRoleDAL,
+		},
+		PolicySyncer: new(testPolicySyncer),
+	}
+}
+
+func newTestRoleForm(code, menuID string, parentIDs ...string) *schema.RoleForm {
+	formItem := &schema.RoleForm{
+		Code:   code,
+		Name:   code,
+		Status: schema.RoleStatusEnabled,
+		Menus:  schema.RoleMenus{{MenuID: menuID}},
+	}
+	for _, parentID := range parentIDs {
+		formItem.Parents = append(formItem.Parents, &schema.RoleParent{ParentID: parentID})
+	}
+	return formItem
+}
+
+func TestRoleParents(t *testing.T) {
+	roleBIZ := newTestRole(t)
+	ctx := context.Background()
+	isBadRequest := func(err error) bool {
+		return err != nil && errors.FromError(err).Code == 400
+	}
+
+	// c inherits from b, which inherits from a
+	a, err := roleBIZ.Create(ctx, newTestRoleForm("a", "ma"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := roleBIZ.Create(ctx, newTestRoleForm("b", "mb", a.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := roleBIZ.Create(ctx, newTestRoleForm("c", "mc", b.ID, b.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, c.Parents, 1, "the duplicated parents are skipped")
+
+	_, err = roleBIZ.Create(ctx, newTestRoleForm("d", "ma", "missing"))
+	assert.Equal(t, int32(404), errors.FromError(err).Code)
+
+	// The role cannot inherit from itself or its descendants
+	assert.True(t, isBadRequest(roleBIZ.Update(ctx, a.ID, newTestRoleForm("a", "ma", a.ID))), "self parent")
+	assert.True(t, isBadRequest(roleBIZ.Update(ctx, a.ID, newTestRoleForm("a", "ma", b.ID))), "A->B->A")
+	assert.True(t, isBadRequest(roleBIZ.Update(ctx, a.ID, newTestRoleForm("a", "ma", c.ID))), "A->B->C->A")
+	assert.True(t, isBadRequest(roleBIZ.Update(ctx, b.ID, newTestRoleForm("b", "mb", c.ID))), "B->C->B")
+	role, err := roleBIZ.Get(ctx, a.ID)
+	if assert.Nil(t, err) {
+		assert.Len(t, role.Parents, 0, "the rejected parents are not saved")
+	}
+
+	// The menus of all ancestors are inherited
+	role, err = roleBIZ.Get(ctx, c.ID)
+	if assert.Nil(t, err) {
+		if assert.Len(t, role.Parents, 1) {
+			assert.Equal(t, "b", role.Parents[0].ParentName)
+		}
+		assert.ElementsMatch(t, []string{"ma", "mb"}, menuIDs(role.InheritedMenus))
+		assert.ElementsMatch(t, []string{"ma", "mb", "mc"}, menuIDs(role.EffectiveMenus))
+	}
+
+	// The relations of the deleted parent are removed
+	assert.Nil(t, roleBIZ.Delete(ctx, b.ID))
+	role, err = roleBIZ.Get(ctx, c.ID)
+	if assert.Nil(t, err) {
+		assert.Len(t, role.Parents, 0)
+		assert.Len(t, role.InheritedMenus, 0)
+	}
+	assert.Nil(t, roleBIZ.Update(ctx, a.ID, newTestRoleForm("a", "ma", c.ID)))
+}
+
+func menuIDs(roleMenus schema.RoleMenus) []string {
+	var ids []string
+	for _, item := range roleMenus {
+		ids = append(ids, item.MenuID)
+	}
+	return ids
+}
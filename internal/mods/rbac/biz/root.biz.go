@@ -0,0 +1,111 @@
+package biz
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Credential of the root user, the password in the database overrides the one in the configuration
+type Root struct {
+	RootCredentialDAL *dal.RootCredential
+	UserBIZ           *User
+}
+
+// Check whether the root user is not allowed to login.
+func (a *Root) LoginDisabled() bool {
+	return config.C.General.Root.DisableLogin
+}
+
+// Warn about the configuration, the root user cannot login with a password which is not hashed.
+func (a *Root) Check(ctx context.Context) error {
+	if a.LoginDisabled() {
+		return nil
+	}
+
+	item, err := a.RootCredentialDAL.Get(ctx, config.C.General.Root.ID)
+	if err != nil {
+		return err
+	} else if item != nil {
+		return nil
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	if password := config.C.General.Root.Password; password == "" {
+		logging.Context(ctx).Warn("Root password is not set, run `root password` command to set it")
+	} else if !hash.IsPasswordHash(password) {
+		logging.Context(ctx).Warn("Root password in the configuration is not a bcrypt hash and is ignored, run `root password` command to set it")
+	}
+	return nil
+}
+
+func (a *Root) getPasswordHash(ctx context.Context) (string, error) {
+	item, err := a.RootCredentialDAL.Get(ctx, config.C.General.Root.ID)
+	if err != nil {
+		return "", err
+	} else if item != nil {
+		return item.Password, nil
+	}
+
+	if password := config.C.General.Root.Password; hash.IsPasswordHash(password) {
+		return password, nil
+	}
+	return "", nil
+}
+
+// Verify the password of the root user, it is always incorrect if the login is disabled or the password is not set.
+func (a *Root) Verify(ctx context.Context, password string) (bool, error) {
+	if a.LoginDisabled() {
+		return false, nil
+	}
+
+	hashPass, err := a.getPasswordHash(ctx)
+	if err != nil {
+		return false, err
+	} else if hashPass == "" {
+		return false, nil
+	}
+	return hash.CompareHashAndPassword(hashPass, password) == nil, nil
+}
+
+// Set or rotate the password of the root user (md5 hash, or plain text with Security.Password.ClientHashed=false),
+// the sessions of the root user are revoked.
+func (a *Root) SetPassword(ctx context.Context, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	rootID := config.C.General.Root.ID
+	item, err := a.RootCredentialDAL.Get(ctx, rootID)
+	if err != nil {
+		return err
+	} else if item == nil {
+		item = &schema.RootCredential{
+			ID:        rootID,
+			CreatedAt: time.Now(),
+		}
+	} else if hash.CompareHashAndPassword(item.Password, password) == nil {
+		return errors.BadRequest(config.ErrPasswordPolicyID, "Password must not be the current password")
+	}
+
+	hashPass, err := hash.GeneratePassword(password)
+	if err != nil {
+		return errors.BadRequest("", "Failed to generate hash password: %s", err.Error())
+	}
+	item.Password = hashPass
+	item.UpdatedAt = time.Now()
+	if err := a.RootCredentialDAL.Save(ctx, item); err != nil {
+		return err
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	logging.Context(ctx).Info("Root password changed", zap.String("target_user_id", rootID))
+	return a.UserBIZ.revokeSessions(ctx, rootID)
+}
@@ -0,0 +1,193 @@
+package biz
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/swaggo/swag"
+	"go.uber.org/zap"
+)
+
+// Registry of the API routes registered on the HTTP server, which helps to pick the menu resources
+type Route struct {
+	lock            sync.RWMutex  `wire:"-"`
+	routes          schema.Routes `wire:"-"`
+	MenuResourceDAL *dal.MenuResource
+}
+
+// Register the routes of the HTTP server, the routes with the prefixes are protected unless skipped by casbin. The
+// menu resources are checked against the routes and the mismatches are reported.
+func (a *Route) Register(ctx context.Context, routesInfo gin.RoutesInfo, prefixes []string) error {
+	summaries := readSwaggerSummaries(ctx)
+
+	routes := make(schema.Routes, 0, len(routesInfo))
+	for _, info := range routesInfo {
+		if !hasPathPrefix(info.Path, prefixes) {
+			continue
+		}
+
+		path := schema.FormatRoutePath(info.Path)
+		routes = append(routes, &schema.Route{
+			Method:    info.Method,
+			Path:      path,
+			Handler:   formatHandlerName(info.Handler),
+			Summary:   summaries[strings.ToLower(info.Method)+" "+path],
+			Protected: !hasPathPrefix(info.Path, config.C.Middleware.Casbin.SkippedPathPrefixes),
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+
+	a.lock.Lock()
+	a.routes = routes
+	a.lock.Unlock()
+
+	return a.report(ctx, routes)
+}
+
+// Query the registered routes, the coverage is checked by the menu resources of the current tenant.
+func (a *Route) Query(ctx context.Context, params schema.RouteQueryParam) (schema.Routes, error) {
+	menuResourceResult, err := a.MenuResourceDAL.Query(ctx, schema.MenuResourceQueryParam{}, schema.MenuResourceQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"method", "path"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	var list schema.Routes
+	for _, item := range a.routes {
+		if params.Method != "" && !strings.EqualFold(item.Method, params.Method) {
+			continue
+		} else if params.LikePath != "" && !strings.Contains(item.Path, params.LikePath) {
+			continue
+		} else if params.Protected && !item.Protected {
+			continue
+		}
+
+		route := *item
+		route.Covered = isRouteCovered(&route, menuResourceResult.Data)
+		if params.Uncovered && route.Covered {
+			continue
+		}
+		list = append(list, &route)
+	}
+	return list, nil
+}
+
+// Report the menu resources which point to non-existent routes, and the protected routes not covered by any menu
+// resource (only the super administrators can access them). The resources of all tenants are checked.
+func (a *Route) report(ctx context.Context, routes schema.Routes) error {
+	menuResourceResult, err := a.MenuResourceDAL.Query(util.NewTenantUnscoped(ctx), schema.MenuResourceQueryParam{},
+		schema.MenuResourceQueryOptions{
+			QueryOptions: util.QueryOptions{SelectFields: []string{"method", "path"}},
+		})
+	if err != nil {
+		return err
+	}
+
+	resources := make(schema.MenuResources, 0, len(menuResourceResult.Data))
+	seen := make(map[string]struct{})
+	for _, item := range menuResourceResult.Data {
+		key := item.Method + " " + item.Path
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		resources = append(resources, item)
+	}
+
+	var unknownResources, uncoveredRoutes []string
+	for _, item := range resources {
+		if !routes.Match(item.Method, item.Path) {
+			unknownResources = append(unknownResources, item.Method+" "+item.Path)
+		}
+	}
+	for _, item := range routes {
+		if item.Protected && !isRouteCovered(item, resources) {
+			uncoveredRoutes = append(uncoveredRoutes, item.Method+" "+item.Path)
+		}
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySystem)
+	if len(unknownResources) > 0 {
+		logging.Context(ctx).Warn("Menu resources point to non-existent routes", zap.Strings("resources", unknownResources))
+	}
+	if len(uncoveredRoutes) > 0 {
+		logging.Context(ctx).Warn("Protected routes are not covered by any menu resource", zap.Strings("routes", uncoveredRoutes))
+	}
+	logging.Context(ctx).Info("Registered API routes", zap.Int("routes", len(routes)),
+		zap.Int("unknown_resources", len(unknownResources)), zap.Int("uncovered_routes", len(uncoveredRoutes)))
+	return nil
+}
+
+func isRouteCovered(route *schema.Route, resources schema.MenuResources) bool {
+	for _, item := range resources {
+		if route.Match(item.Method, item.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Trim the package path of the handler (e.g. github.com/xxx/rbac/api.(*User).Query-fm to rbac/api.(*User).Query)
+func formatHandlerName(name string) string {
+	name = strings.TrimSuffix(name, "-fm")
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		if j := strings.LastIndex(name[:i], "/"); j >= 0 {
+			return name[j+1:]
+		}
+	}
+	return name
+}
+
+// Read the summaries of the APIs from the swagger document, the keys are the lower case methods and the paths.
+func readSwaggerSummaries(ctx context.Context) map[string]string {
+	summaries := make(map[string]string)
+	doc, err := swag.ReadDoc()
+	if err != nil {
+		logging.Context(ctx).Warn("Failed to read swagger document", zap.Error(err))
+		return summaries
+	}
+
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Summary string `json:"summary"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(doc), &spec); err != nil {
+		logging.Context(ctx).Warn("Failed to parse swagger document", zap.Error(err))
+		return summaries
+	}
+
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			summaries[method+" "+path] = operation.Summary
+		}
+	}
+	return summaries
+}
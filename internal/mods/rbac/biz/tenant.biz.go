@@ -0,0 +1,13 @@
+package biz
+
+import (
+	"context"
+)
+
+// Provider of the tenants which the users, roles and menus belong to
+type TenantProvider interface {
+	// Check the tenant is activated and get the user ID of its administrator (empty for the default tenant)
+	CheckTenant(ctx context.Context, tenantID string) (adminID string, err error)
+	// Query the IDs of the tenants except the default tenant
+	QueryTenantIDs(ctx context.Context) ([]string, error)
+}
@@ -5,26 +5,51 @@ import (
 	"time"
 
 	"github.com/LyricTian/gin-admin/v10/internal/config"
+	orgbiz "github.com/LyricTian/gin-admin/v10/internal/mods/org/biz"
+	orgdal "github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
-	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
 )
 
 // User management for RBAC
 type User struct {
-	Cache       cachex.Cacher
-	Trans       *util.Trans
-	UserDAL     *dal.User
-	UserRoleDAL *dal.UserRole
+	Cache             cachex.Cacher
+	Trans             *util.Trans
+	Auth              jwtx.Auther
+	UserDAL           *dal.User
+	UserRoleDAL       *dal.UserRole
+	UserTOTPDAL       *dal.UserTOTP
+	IdentityDAL       *dal.UserIdentity
+	APIKeyDAL         *dal.APIKey
+	DataScopeBIZ      *orgbiz.DataScope
+	DepartmentUserDAL *orgdal.DepartmentUser
+	RoleConstraintBIZ *RoleConstraint
+	RoleElevationDAL  *dal.RoleElevation
+	now               func() time.Time `wire:"-"` // The clock of the time-bounded roles (default time.Now)
+}
+
+func (a *User) timeNow() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
 }
 
 // Query users from the data access object based on the provided parameters and options.
 func (a *User) Query(ctx context.Context, params schema.UserQueryParam) (*schema.UserQueryResult, error) {
 	params.Pagination = true
 
+	ctx, err := a.DataScopeBIZ.NewContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	result, err := a.UserDAL.Query(ctx, params, schema.UserQueryOptions{
 		QueryOptions: util.QueryOptions{
 			OrderFields: []util.OrderByParam{
@@ -57,15 +82,13 @@ func (a *User) Query(ctx context.Context, params schema.UserQueryParam) (*schema
 
 // Get the specified user from the data access object.
 func (a *User) Get(ctx context.Context, id string) (*schema.User, error) {
-	user, err := a.UserDAL.Get(ctx, id, schema.UserQueryOptions{
+	user, err := a.getInDataScope(ctx, id, schema.UserQueryOptions{
 		QueryOptions: util.QueryOptions{
 			OmitFields: []string{"password"},
 		},
 	})
 	if err != nil {
 		return nil, err
-	} else if user == nil {
-		return nil, errors.NotFound("", "User not found")
 	}
 
 	userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
@@ -90,18 +113,28 @@ func (a *User) Create(ctx context.Context, formItem *schema.UserForm) (*schema.U
 
 	user := &schema.User{
 		ID:        util.NewXID(),
+		Source:    schema.UserSourceLocal,
 		CreatedAt: time.Now(),
 	}
 
-	if formItem.Password == "" {
-		formItem.Password = config.C.General.DefaultLoginPwd
+	if err := formItem.FillTo(user); err != nil {
+		return nil, err
 	}
 
-	if err := formItem.FillTo(user); err != nil {
+	// The initial password is set by the administrator, the user must change it on the first login
+	if formItem.Password == "" {
+		err = resetUserPassword(user)
+	} else {
+		err = setUserPassword(user, formItem.Password, true)
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.RoleConstraintBIZ.Check(ctx, "", formItem.Roles); err != nil {
+			return err
+		}
 		if err := a.UserDAL.Create(ctx, user); err != nil {
 			return err
 		}
@@ -126,11 +159,9 @@ func (a *User) Create(ctx context.Context, formItem *schema.UserForm) (*schema.U
 
 // Update the specified user in the data access object.
 func (a *User) Update(ctx context.Context, id string, formItem *schema.UserForm) error {
-	user, err := a.UserDAL.Get(ctx, id)
+	user, err := a.getInDataScope(ctx, id)
 	if err != nil {
 		return err
-	} else if user == nil {
-		return errors.NotFound("", "User not found")
 	} else if user.Username != formItem.Username {
 		existsUsername, err := a.UserDAL.ExistsUsername(ctx, formItem.Username)
 		if err != nil {
@@ -140,12 +171,27 @@ func (a *User) Update(ctx context.Context, id string, formItem *schema.UserForm)
 		}
 	}
 
+	freezed := user.Status != schema.UserStatusFreezed && formItem.Status == schema.UserStatusFreezed
+	if freezed && id == util.FromTenantAdminID(ctx) {
+		return errors.BadRequest("", "The administrator of the tenant cannot be freezed")
+	}
 	if err := formItem.FillTo(user); err != nil {
 		return err
 	}
+	if formItem.Password != "" {
+		if !user.IsLocal() {
+			return errors.BadRequest("", "The password is managed by %s", user.Source)
+		}
+		if err := setUserPassword(user, formItem.Password, true); err != nil {
+			return err
+		}
+	}
 	user.UpdatedAt = time.Now()
 
-	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.RoleConstraintBIZ.Check(ctx, id, formItem.Roles); err != nil {
+			return err
+		}
 		if err := a.UserDAL.Update(ctx, user); err != nil {
 			return err
 		}
@@ -169,52 +215,105 @@ func (a *User) Update(ctx context.Context, id string, formItem *schema.UserForm)
 
 		return a.Cache.Delete(ctx, config.CacheNSForUser, id)
 	})
+	if err != nil {
+		return err
+	}
+
+	// A freezed user is kicked out of all devices immediately
+	if freezed {
+		return a.revokeSessions(ctx, id)
+	}
+	return nil
 }
 
 // Delete the specified user from the data access object.
 func (a *User) Delete(ctx context.Context, id string) error {
-	exists, err := a.UserDAL.Exists(ctx, id)
-	if err != nil {
+	if id == util.FromTenantAdminID(ctx) {
+		return errors.BadRequest("", "The administrator of the tenant cannot be deleted")
+	}
+
+	if _, err := a.getInDataScope(ctx, id, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id"},
+		},
+	}); err != nil {
 		return err
-	} else if !exists {
-		return errors.NotFound("", "User not found")
 	}
 
-	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+	err := a.Trans.Exec(ctx, func(ctx context.Context) error {
 		if err := a.UserDAL.Delete(ctx, id); err != nil {
 			return err
 		}
 		if err := a.UserRoleDAL.DeleteByUserID(ctx, id); err != nil {
 			return err
 		}
+		if err := a.UserTOTPDAL.DeleteByUserID(ctx, id); err != nil {
+			return err
+		}
+		if err := a.IdentityDAL.DeleteByUserID(ctx, id); err != nil {
+			return err
+		}
+		if err := a.APIKeyDAL.DeleteByUserID(ctx, id); err != nil {
+			return err
+		}
+		if err := a.DepartmentUserDAL.DeleteByUserID(ctx, id); err != nil {
+			return err
+		}
 		return a.Cache.Delete(ctx, config.CacheNSForUser, id)
 	})
+	if err != nil {
+		return err
+	}
+	return a.revokeSessions(ctx, id)
 }
 
+// Reset the password to the default one, the user must change it on the next login.
 func (a *User) ResetPassword(ctx context.Context, id string) error {
-	exists, err := a.UserDAL.Exists(ctx, id)
+	user, err := a.getInDataScope(ctx, id, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id", "source", "password_history"},
+		},
+	})
 	if err != nil {
 		return err
-	} else if !exists {
-		return errors.NotFound("", "User not found")
+	} else if !user.IsLocal() {
+		return errors.BadRequest("", "The password is managed by %s", user.Source)
 	}
 
-	hashPass, err := hash.GeneratePassword(config.C.General.DefaultLoginPwd)
-	if err != nil {
-		return errors.BadRequest("", "Failed to generate hash password: %s", err.Error())
+	if err := resetUserPassword(user); err != nil {
+		return err
 	}
 
 	return a.Trans.Exec(ctx, func(ctx context.Context) error {
-		if err := a.UserDAL.UpdatePasswordByID(ctx, id, hashPass); err != nil {
+		if err := a.UserDAL.Update(ctx, user, userPasswordFields...); err != nil {
 			return err
 		}
-		return nil
+		return a.Cache.Delete(ctx, config.CacheNSForUser, id)
 	})
 }
 
+// Get the specified user in the data scope of the current user, the user out of the scope is not found.
+func (a *User) getInDataScope(ctx context.Context, id string, opts ...schema.UserQueryOptions) (*schema.User, error) {
+	scopeCtx, err := a.DataScopeBIZ.NewContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.UserDAL.Get(scopeCtx, id, opts...)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, errors.NotFound("", "User not found")
+	}
+	return user, nil
+}
+
+// Get the IDs of the roles in effect of the specified user, the roles which have not started or have ended are excluded.
 func (a *User) GetRoleIDs(ctx context.Context, id string) ([]string, error) {
+	now := a.timeNow()
 	userRoleResult, err := a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
-		UserID: id,
+		UserID:   id,
+		ActiveAt: &now,
 	}, schema.UserRoleQueryOptions{
 		QueryOptions: util.QueryOptions{
 			SelectFields: []string{"role_id"},
@@ -225,3 +324,129 @@ func (a *User) GetRoleIDs(ctx context.Context, id string) ([]string, error) {
 	}
 	return userRoleResult.Data.ToRoleIDs(), nil
 }
+
+// Get the roles among the role IDs of the user which are granted temporarily by the approved elevations.
+func (a *User) GetElevatedRoleIDs(ctx context.Context, id string, roleIDs []string) ([]string, error) {
+	if len(roleIDs) == 0 {
+		return nil, nil
+	}
+
+	now := a.timeNow()
+	roleElevationResult, err := a.RoleElevationDAL.Query(ctx, schema.RoleElevationQueryParam{
+		UserID:   id,
+		ActiveAt: &now,
+	}, schema.RoleElevationQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"role_id"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The elevated roles which have been removed by the administrator are excluded
+	held := toRoleSet(roleIDs)
+	var ids []string
+	for _, roleID := range roleElevationResult.Data.ToRoleIDs() {
+		if held[roleID] {
+			ids = append(ids, roleID)
+		}
+	}
+	return ids, nil
+}
+
+// Query the role assignments which end in the specified days, the soonest first.
+func (a *User) QueryExpiringRoles(ctx context.Context, params schema.ExpiringUserRoleQueryParam) (*schema.UserRoleQueryResult, error) {
+	if params.Days == 0 {
+		params.Days = 7
+	}
+
+	now := a.timeNow()
+	expiresTo := now.AddDate(0, 0, params.Days)
+	params.Pagination = true
+	return a.UserRoleDAL.Query(ctx, schema.UserRoleQueryParam{
+		PaginationParam: params.PaginationParam,
+		ExpiresFrom:     &now,
+		ExpiresTo:       &expiresTo,
+	}, schema.UserRoleQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "a.expires_at", Direction: util.ASC},
+			},
+		},
+		JoinRole: true,
+		JoinUser: true,
+	})
+}
+
+// Clear the caches of the users whose role assignments start or end in the time range (from, to], so that the roles
+// in effect are reloaded on the next request. It covers all tenants.
+func (a *User) ClearRoleChangedCaches(ctx context.Context, from, to time.Time) error {
+	userRoleResult, err := a.UserRoleDAL.Query(util.NewTenantUnscoped(ctx), schema.UserRoleQueryParam{
+		ChangedFrom: &from,
+		ChangedTo:   &to,
+	}, schema.UserRoleQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"tenant_id", "user_id"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	cleared := make(map[string]struct{})
+	for _, item := range userRoleResult.Data {
+		key := item.TenantID + ":" + item.UserID
+		if _, ok := cleared[key]; ok {
+			continue
+		}
+		cleared[key] = struct{}{}
+
+		if err := a.Cache.Delete(util.NewTenantID(ctx, item.TenantID), config.CacheNSForUser, item.UserID); err != nil {
+			return err
+		}
+	}
+
+	if len(cleared) > 0 {
+		logging.Context(ctx).Info("Cleared the caches of the users whose roles started or ended", zap.Int("count", len(cleared)))
+	}
+	return nil
+}
+
+// Query the active sessions of the specified user.
+func (a *User) QuerySessions(ctx context.Context, id string) (schema.UserSessions, error) {
+	if _, err := a.getInDataScope(ctx, id, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id"},
+		},
+	}); err != nil {
+		return nil, err
+	}
+
+	sessions, err := a.Auth.QuerySessions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return schema.NewUserSessions(sessions, util.FromSessionID(ctx)), nil
+}
+
+// Revoke all sessions of the specified user, the user has to login again on every device.
+func (a *User) RevokeSessions(ctx context.Context, id string) error {
+	if _, err := a.getInDataScope(ctx, id, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{
+			SelectFields: []string{"id"},
+		},
+	}); err != nil {
+		return err
+	}
+	return a.revokeSessions(ctx, id)
+}
+
+func (a *User) revokeSessions(ctx context.Context, id string) error {
+	ctx = logging.NewTag(ctx, logging.TagKeyLogout)
+	if err := a.Auth.RevokeSessions(ctx, id); err != nil {
+		return err
+	}
+	logging.Context(ctx).Info("Revoke all sessions of user", zap.String("target_user_id", id))
+	return nil
+}
@@ -0,0 +1,110 @@
+package biz
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+)
+
+// Check the length and character classes of a plain password.
+func validatePassword(password string) error {
+	cfg := config.C.Security.Password
+	if cfg.ClientHashed {
+		return nil
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if len([]rune(password)) < cfg.MinLength {
+		return errors.BadRequest(config.ErrPasswordPolicyID, "Password must be at least %d characters", cfg.MinLength)
+	} else if cfg.RequireUppercase && !hasUpper {
+		return errors.BadRequest(config.ErrPasswordPolicyID, "Password must contain an uppercase letter")
+	} else if cfg.RequireLowercase && !hasLower {
+		return errors.BadRequest(config.ErrPasswordPolicyID, "Password must contain a lowercase letter")
+	} else if cfg.RequireDigit && !hasDigit {
+		return errors.BadRequest(config.ErrPasswordPolicyID, "Password must contain a digit")
+	} else if cfg.RequireSymbol && !hasSymbol {
+		return errors.BadRequest(config.ErrPasswordPolicyID, "Password must contain a symbol")
+	}
+	return nil
+}
+
+// Check that the password is not one of the recent passwords of the user.
+func checkPasswordReuse(user *schema.User, password string) error {
+	size := config.C.Security.Password.HistorySize
+	if size <= 0 {
+		return nil
+	}
+
+	hashes := user.GetPasswordHistory()
+	if len(hashes) > size {
+		hashes = hashes[:size]
+	}
+	if user.Password != "" {
+		hashes = append(hashes, user.Password)
+	}
+
+	for _, h := range hashes {
+		if hash.CompareHashAndPassword(h, password) == nil {
+			return errors.BadRequest(config.ErrPasswordPolicyID, "Password must not be one of the last %d passwords", size)
+		}
+	}
+	return nil
+}
+
+// Apply the password policy and set the new password of the user. The password set by the
+// administrator (reset) must be changed by the user on the next login.
+func setUserPassword(user *schema.User, password string, reset bool) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	} else if err := checkPasswordReuse(user, password); err != nil {
+		return err
+	}
+
+	hashPass, err := hash.GeneratePassword(password)
+	if err != nil {
+		return errors.BadRequest("", "Failed to generate hash password: %s", err.Error())
+	}
+
+	now := time.Now()
+	user.Password = hashPass
+	user.PasswordReset = reset
+	user.PasswordUpdatedAt = &now
+	if size := config.C.Security.Password.HistorySize; size > 0 {
+		user.AddPasswordHistory(hashPass, size)
+	}
+	return nil
+}
+
+// Reset the password to the default one (not checked by the policy), the user must change it on the next login.
+func resetUserPassword(user *schema.User) error {
+	hashPass, err := hash.GeneratePassword(config.C.General.DefaultLoginPwd)
+	if err != nil {
+		return errors.BadRequest("", "Failed to generate hash password: %s", err.Error())
+	}
+
+	now := time.Now()
+	user.Password = hashPass
+	user.PasswordReset = true
+	user.PasswordUpdatedAt = &now
+	return nil
+}
+
+// The fields updated by `setUserPassword` and `resetUserPassword`
+var userPasswordFields = []string{"password", "password_reset", "password_updated_at", "password_history"}
@@ -0,0 +1,174 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	orgbiz "github.com/LyricTian/gin-admin/v10/internal/mods/org/biz"
+	orgdal "github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
+	orgschema "github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserDataScope(t *testing.T) {
+	db := newTestDB(t, new(schema.User), new(schema.UserRole), new(schema.Role),
+		new(orgschema.Department), new(orgschema.DepartmentUser))
+	mustCreate(t, db,
+		&orgschema.Department{ID: "d1", Code: "d1", Name: "D1", Status: orgschema.DepartmentStatusEnabled},
+		&orgschema.Department{ID: "d2", Code: "d2", Name: "D2", Status: orgschema.DepartmentStatusEnabled},
+		&orgschema.Department{ID: "d11", Code: "d11", Name: "D11", Status: orgschema.DepartmentStatusEnabled, ParentID: "d1", ParentPath: "d1."},
+		&orgschema.Department{ID: "d111", Code: "d111", Name: "D111", Status: orgschema.DepartmentStatusEnabled, ParentID: "d11", ParentPath: "d1.d11."},
+		&orgschema.Department{ID: "d21", Code: "d21", Name: "D21", Status: orgschema.DepartmentStatusEnabled, ParentID: "d2", ParentPath: "d2."},
+		&schema.User{ID: "actor", Username: "actor", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+		&schema.User{ID: "u1", Username: "u1", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+		&schema.User{ID: "u2", Username: "u2", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+		&schema.User{ID: "u3", Username: "u3", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+		&schema.User{ID: "u4", Username: "u4", Source: schema.UserSourceLocal, Status: schema.UserStatusActivated},
+		&orgschema.DepartmentUser{ID: "du1", DepartmentID: "d1", UserID: "actor"},
+		&orgschema.DepartmentUser{ID: "du2", DepartmentID: "d1", UserID: "u1"},
+		&orgschema.DepartmentUser{ID: "du3", DepartmentID: "d2", UserID: "u2"},
+		&orgschema.DepartmentUser{ID: "du4", DepartmentID: "d111", UserID: "u3"},
+		&orgschema.DepartmentUser{ID: "du5", DepartmentID: "d21", UserID: "u4"},
+		&schema.Role{ID: "dept", Code: "dept", Name: "Dept", Status: schema.RoleStatusEnabled, DataScope: schema.RoleDataScopeDepartment},
+		&schema.Role{ID: "tree", Code: "tree", Name: "Tree", Status: schema.RoleStatusEnabled, DataScope: schema.RoleDataScopeDepartmentAndChildren},
+		&schema.Role{ID: "all", Code: "all", Name: "All", Status: schema.RoleStatusEnabled, DataScope: schema.RoleDataScopeAll},
+	)
+
+	userBIZ := &User{
+		Cache:       cachex.NewMemoryCache(cachex.MemoryConfig{}),
+		Trans:       &util.Trans{DB: db},
+		UserDAL:     &dal.User{DB: db},
+		UserRoleDAL: &dal.UserRole{DB: db},
+		DataScopeBIZ: &orgbiz.DataScope{
+			RoleDAL:           &dal.Role{DB: db},
+			DepartmentDAL:     &orgdal.Department{DB: db},
+			DepartmentUserDAL: &orgdal.DepartmentUser{DB: db},
+		},
+		RoleConstraintBIZ: &RoleConstraint{},
+	}
+
+	newCtx := func(roleIDs ...string) context.Context {
+		ctx := util.NewUserID(context.Background(), "actor")
+		return util.NewUserCache(ctx, util.UserCache{RoleIDs: roleIDs})
+	}
+	isNotFound := func(err error) bool {
+		return err != nil && errors.FromError(err).Code == 404
+	}
+
+	// The users of the other departments are not found in the data scope of the department
+	ctx := newCtx("dept")
+	user, err := userBIZ.Get(ctx, "u1")
+	assert.Nil(t, err)
+	assert.Equal(t, "u1", user.Username)
+	_, err = userBIZ.Get(ctx, "u2")
+	assert.True(t, isNotFound(err), err)
+	assert.True(t, isNotFound(userBIZ.Update(ctx, "u2", &schema.UserForm{Username: "u2", Status: schema.UserStatusFreezed})))
+	assert.True(t, isNotFound(userBIZ.Delete(ctx, "u2")))
+	assert.True(t, isNotFound(userBIZ.ResetPassword(ctx, "u2")))
+	assert.True(t, isNotFound(userBIZ.RevokeSessions(ctx, "u2")))
+	assert.Nil(t, userBIZ.ResetPassword(ctx, "u1"))
+
+	// The users of the child departments are visible in the data scope of the department and its children
+	_, err = userBIZ.Get(ctx, "u3")
+	assert.True(t, isNotFound(err), err)
+	ctx = newCtx("tree")
+	for _, id := range []string{"u1", "u3"} {
+		_, err = userBIZ.Get(ctx, id)
+		assert.Nil(t, err, id)
+	}
+	for _, id := range []string{"u2", "u4"} {
+		_, err = userBIZ.Get(ctx, id)
+		assert.True(t, isNotFound(err), id)
+	}
+
+	// The user without role can see itself only
+	_, err = userBIZ.Get(newCtx(), "actor")
+	assert.Nil(t, err)
+	_, err = userBIZ.Get(newCtx(), "u1")
+	assert.True(t, isNotFound(err), err)
+
+	// All users are visible to the role with the scope of all data and to the root user
+	for _, ctx := range []context.Context{newCtx("dept", "all"), util.NewIsRootUser(newCtx())} {
+		_, err = userBIZ.Get(ctx, "u2")
+		assert.Nil(t, err)
+		assert.Nil(t, userBIZ.ResetPassword(ctx, "u2"))
+	}
+	_, err = userBIZ.Get(ctx, "unknown")
+	assert.True(t, isNotFound(err), err)
+}
+
+func TestUserTimeBoundedRoles(t *testing.T) {
+	db := newTestDB(t, new(schema.UserRole))
+	now := time.Unix(1700000000, 0)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+	mustCreate(t, db,
+		&schema.UserRole{ID: "ur1", UserID: "u1", RoleID: "permanent"},
+		&schema.UserRole{ID: "ur2", UserID: "u1", RoleID: "not_started", StartsAt: at(time.Second)},
+		&schema.UserRole{ID: "ur3", UserID: "u1", RoleID: "expired", ExpiresAt: at(-time.Second)},
+		&schema.UserRole{ID: "ur4", UserID: "u1", RoleID: "starts_now", StartsAt: at(0)},
+		&schema.UserRole{ID: "ur5", UserID: "u1", RoleID: "expires_now", ExpiresAt: at(0)},
+		&schema.UserRole{ID: "ur6", UserID: "u1", RoleID: "bounded", StartsAt: at(-time.Hour), ExpiresAt: at(time.Hour)},
+	)
+	userBIZ := &User{
+		UserRoleDAL: &dal.UserRole{DB: db},
+		now:         func() time.Time { return now },
+	}
+
+	// The role starting at the moment is in effect, the role ending at the moment is not
+	roleIDs, err := userBIZ.GetRoleIDs(context.Background(), "u1")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"permanent", "starts_now", "bounded"}, roleIDs)
+
+	now = now.Add(time.Hour)
+	roleIDs, err = userBIZ.GetRoleIDs(context.Background(), "u1")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"permanent", "not_started", "starts_now"}, roleIDs)
+}
+
+func TestUserClearRoleChangedCaches(t *testing.T) {
+	db := newTestDB(t, new(schema.UserRole))
+	now := time.Unix(1700000000, 0)
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+	mustCreate(t, db,
+		&schema.UserRole{ID: "ur1", UserID: "permanent", RoleID: "r"},
+		&schema.UserRole{ID: "ur2", UserID: "starts", RoleID: "r", StartsAt: at(30 * time.Second)},
+		&schema.UserRole{ID: "ur3", UserID: "ends", RoleID: "r", ExpiresAt: at(time.Minute)},
+		&schema.UserRole{ID: "ur4", UserID: "started", RoleID: "r", StartsAt: at(0)},
+		&schema.UserRole{ID: "ur5", UserID: "later", RoleID: "r", StartsAt: at(time.Hour), ExpiresAt: at(2 * time.Hour)},
+	)
+	userBIZ := &User{
+		Cache:       cachex.NewMemoryCache(cachex.MemoryConfig{}),
+		UserRoleDAL: &dal.UserRole{DB: db},
+	}
+	ctx := context.Background()
+	for _, userID := range []string{"permanent", "starts", "ends", "started", "later"} {
+		assert.Nil(t, userBIZ.Cache.Set(ctx, config.CacheNSForUser, userID, "cached"))
+	}
+
+	// The caches of the users whose roles start or end in (from, to] are cleared
+	assert.Nil(t, userBIZ.ClearRoleChangedCaches(ctx, now, now.Add(time.Minute)))
+	for userID, cleared := range map[string]bool{
+		"permanent": false,
+		"starts":    true,
+		"ends":      true,
+		"started":   false,
+		"later":     false,
+	} {
+		exists, err := userBIZ.Cache.Exists(ctx, config.CacheNSForUser, userID)
+		assert.Nil(t, err)
+		assert.Equal(t, cleared, !exists, userID)
+	}
+}
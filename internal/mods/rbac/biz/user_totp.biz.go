@@ -0,0 +1,251 @@
+package biz
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/rand"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/totp"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+// TOTP two-factor authentication of the user
+type UserTOTP struct {
+	UserDAL     *dal.User
+	UserTOTPDAL *dal.UserTOTP
+	now         func() time.Time `wire:"-"` // The clock of the codes (default time.Now)
+}
+
+func (a *UserTOTP) newTOTP() *totp.TOTP {
+	opts := []totp.Option{totp.SetSkew(int64(config.C.Security.MFA.Skew))}
+	if a.now != nil {
+		opts = append(opts, totp.SetClock(a.now))
+	}
+	return totp.New(opts...)
+}
+
+// Generate the one-time recovery codes (format: XXXXX-XXXXX)
+func (a *UserTOTP) newRecoveryCodes() ([]string, error) {
+	codes := make([]string, config.C.Security.MFA.RecoveryCodes)
+	for i := range codes {
+		code, err := rand.Random(10, rand.LdigitAndUpperCase)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// Check whether the user enabled the 2FA, and whether it is required by the roles of the user.
+func (a *UserTOTP) Check(ctx context.Context, userID string) (enabled bool, required bool, err error) {
+	item, err := a.UserTOTPDAL.GetByUserID(ctx, userID)
+	if err != nil {
+		return false, false, err
+	}
+
+	required, err = a.UserTOTPDAL.RequiredByRoles(ctx, userID)
+	if err != nil {
+		return false, false, err
+	}
+	return item != nil && item.Enabled, required, nil
+}
+
+// Get the 2FA status of the user
+func (a *UserTOTP) GetStatus(ctx context.Context, userID string) (*schema.UserTOTPStatus, error) {
+	item, err := a.UserTOTPDAL.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	required, err := a.UserTOTPDAL.RequiredByRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &schema.UserTOTPStatus{Required: required}
+	if item != nil && item.Enabled {
+		status.Enabled = true
+		status.EnabledAt = item.EnabledAt
+		status.RecoveryCodesRemaining = len(item.RecoveryCodeHashes())
+	}
+	return status, nil
+}
+
+// Generate a new secret for the user, the enrollment takes effect after being verified by `Enable`.
+func (a *UserTOTP) Enroll(ctx context.Context, userID string) (*schema.UserTOTPEnrollment, error) {
+	user, err := a.UserDAL.Get(ctx, userID, schema.UserQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "username"}},
+	})
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, errors.NotFound("", "User not found")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := a.UserTOTPDAL.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		item = &schema.UserTOTP{
+			ID:        util.NewXID(),
+			UserID:    userID,
+			Secret:    secret,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := a.UserTOTPDAL.Create(ctx, item); err != nil {
+			return nil, err
+		}
+	} else if item.Enabled {
+		return nil, errors.BadRequest("", "2FA is already enabled")
+	} else {
+		item.Secret = secret
+		item.UpdatedAt = time.Now()
+		if err := a.UserTOTPDAL.Update(ctx, item, "secret", "updated_at"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &schema.UserTOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: a.newTOTP().ProvisioningURI(config.C.Security.MFA.Issuer, user.Username, secret),
+	}, nil
+}
+
+// Verify the enrollment with a code from the authenticator app and enable the 2FA,
+// the recovery codes are returned only once.
+func (a *UserTOTP) Enable(ctx context.Context, userID, code string) (*schema.UserTOTPRecoveryCodes, error) {
+	item, err := a.UserTOTPDAL.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, errors.BadRequest("", "2FA is not enrolled")
+	} else if item.Enabled {
+		return nil, errors.BadRequest("", "2FA is already enabled")
+	}
+
+	counter, ok := a.newTOTP().Validate(item.Secret, code)
+	if !ok {
+		return nil, errors.BadRequest(config.ErrInvalidMFACodeID, "Incorrect 2FA code")
+	}
+
+	codes, err := a.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	item.Enabled = true
+	item.EnabledAt = &now
+	item.LastCounter = counter
+	item.SetRecoveryCodes(codes)
+	item.UpdatedAt = now
+	if err := a.UserTOTPDAL.Update(ctx, item); err != nil {
+		return nil, err
+	}
+
+	logging.Context(ctx).Info("Enable 2FA", zap.String("target_user_id", userID))
+	return &schema.UserTOTPRecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// Verify a TOTP code (or consume a recovery code) of the user with the 2FA enabled.
+func (a *UserTOTP) Verify(ctx context.Context, userID, code string) error {
+	invalidCode := errors.BadRequest(config.ErrInvalidMFACodeID, "Incorrect 2FA code")
+	item, err := a.UserTOTPDAL.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	} else if item == nil || !item.Enabled {
+		return invalidCode
+	}
+
+	// A code can be used only once, the time step of the accepted code must move forward
+	if counter, ok := a.newTOTP().Validate(item.Secret, code); ok {
+		if counter <= item.LastCounter {
+			return invalidCode
+		}
+		item.LastCounter = counter
+		item.UpdatedAt = time.Now()
+		return a.UserTOTPDAL.Update(ctx, item, "last_counter", "updated_at")
+	}
+
+	if item.UseRecoveryCode(code) {
+		logging.Context(ctx).Warn("2FA recovery code used",
+			zap.String("target_user_id", userID),
+			zap.Int("recovery_codes_remaining", len(item.RecoveryCodeHashes())))
+		item.UpdatedAt = time.Now()
+		return a.UserTOTPDAL.Update(ctx, item, "recovery_codes", "updated_at")
+	}
+
+	return invalidCode
+}
+
+// Generate new recovery codes (the old ones are invalidated), a valid code is required.
+func (a *UserTOTP) RegenerateRecoveryCodes(ctx context.Context, userID, code string) (*schema.UserTOTPRecoveryCodes, error) {
+	if err := a.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	item, err := a.UserTOTPDAL.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := a.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	item.SetRecoveryCodes(codes)
+	item.UpdatedAt = time.Now()
+	if err := a.UserTOTPDAL.Update(ctx, item, "recovery_codes", "updated_at"); err != nil {
+		return nil, err
+	}
+	return &schema.UserTOTPRecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// Disable the 2FA of the user, a valid code is required.
+func (a *UserTOTP) Disable(ctx context.Context, userID, code string) error {
+	required, err := a.UserTOTPDAL.RequiredByRoles(ctx, userID)
+	if err != nil {
+		return err
+	} else if required {
+		return errors.BadRequest("", "2FA is required by your roles and cannot be disabled")
+	}
+
+	if err := a.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := a.UserTOTPDAL.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	logging.Context(ctx).Info("Disable 2FA", zap.String("target_user_id", userID))
+	return nil
+}
+
+// Reset the 2FA of the user (e.g. the device is lost), the user has to enroll again.
+func (a *UserTOTP) Reset(ctx context.Context, userID string) error {
+	exists, err := a.UserDAL.Exists(ctx, userID)
+	if err != nil {
+		return err
+	} else if !exists {
+		return errors.NotFound("", "User not found")
+	}
+
+	if err := a.UserTOTPDAL.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+	logging.Context(ctx).Info("Reset 2FA", zap.String("target_user_id", userID))
+	return nil
+}
@@ -1,10 +1,7 @@
 package rbac
 
 import (
-	"bytes"
 	"context"
-	"fmt"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -17,39 +14,57 @@ import (
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
 	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/middleware"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 	"github.com/casbin/casbin/v2"
 	"go.uber.org/zap"
 )
 
-// Load rbac permissions to casbin
+// The count of the changes pulled from the change log at a time
+const casbinChangePageSize = 1000
+
+// Load rbac permissions to casbin, the policies are stored in the database by the adapter and updated incrementally.
+// The instances pull the changes of the other instances from the change log, the full reload is only a fallback.
+// The IDs of the changes are allocated before their transactions commit, so a change may become visible after the
+// changes with greater IDs. The gaps of the IDs are pulled again until they are filled or out of the lookback window.
 type Casbinx struct {
-	enforcer        *atomic.Value `wire:"-"`
-	ticker          *time.Ticker  `wire:"-"`
+	enforcer        *atomic.Value        `wire:"-"`
+	ticker          *time.Ticker         `wire:"-"`
+	lock            sync.Mutex           `wire:"-"`
+	lastChangeID    uint64               `wire:"-"` // The changes up to the ID are all applied (or rolled back)
+	appliedChanges  map[uint64]time.Time `wire:"-"` // Create time of the applied changes after lastChangeID
 	Cache           cachex.Cacher
+	Adapter         *CasbinAdapter
 	MenuDAL         *dal.Menu
 	MenuResourceDAL *dal.MenuResource
 	RoleDAL         *dal.Role
+	RoleParentDAL   *dal.RoleParent
+	CasbinRuleDAL   *dal.CasbinRule
+	CasbinChangeDAL *dal.CasbinChange
 }
 
-func (a *Casbinx) GetEnforcer() *casbin.Enforcer {
+func (a *Casbinx) GetEnforcer() *casbin.SyncedEnforcer {
+	if a.enforcer == nil {
+		return nil
+	}
 	if v := a.enforcer.Load(); v != nil {
-		return v.(*casbin.Enforcer)
+		return v.(*casbin.SyncedEnforcer)
 	}
 	return nil
 }
 
-type policyQueueItem struct {
-	RoleID    string
-	Resources schema.MenuResources
-}
-
 func (a *Casbinx) Load(ctx context.Context) error {
 	if config.C.Middleware.Casbin.Disable {
 		return nil
 	}
 
+	// The policies of all tenants are loaded
+	ctx = util.NewTenantUnscoped(ctx)
 	a.enforcer = new(atomic.Value)
+	retention := time.Duration(config.C.Middleware.Casbin.ChangeRetention) * time.Second
+	if err := a.CasbinChangeDAL.DeleteBefore(ctx, time.Now().Add(-retention)); err != nil {
+		return err
+	}
 	if err := a.load(ctx); err != nil {
 		return err
 	}
@@ -58,85 +73,343 @@ func (a *Casbinx) Load(ctx context.Context) error {
 	return nil
 }
 
+// Reload all policies, the policies in the database are synchronized with the roles (of all tenants) before loading.
 func (a *Casbinx) load(ctx context.Context) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
 	start := time.Now()
-	roleResult, err := a.RoleDAL.Query(ctx, schema.RoleQueryParam{
-		Status: schema.RoleStatusEnabled,
-	}, schema.RoleQueryOptions{
-		QueryOptions: util.QueryOptions{SelectFields: []string{"id"}},
+	changes, err := a.diffPolicies(ctx, nil)
+	if err != nil {
+		return err
+	} else if err := a.Adapter.SaveChanges(ctx, changes); err != nil {
+		return err
+	}
+
+	// The visible changes are loaded with the policies, the changes within the lookback window which are not visible
+	// yet are pulled later
+	lastChangeID, err := a.CasbinChangeDAL.GetLatestIDBefore(ctx, time.Now().Add(-a.changeLookback()))
+	if err != nil {
+		return err
+	}
+	appliedChanges := make(map[uint64]time.Time)
+	err = a.queryChanges(ctx, lastChangeID, func(list schema.CasbinChanges) error {
+		for _, item := range list {
+			appliedChanges[item.ID] = item.CreatedAt
+		}
+		return nil
 	})
 	if err != nil {
 		return err
-	} else if len(roleResult.Data) == 0 {
+	}
+
+	modelFile := filepath.Join(config.C.General.WorkDir, config.C.Middleware.Casbin.ModelFile)
+	e, err := casbin.NewSyncedEnforcer(modelFile, a.Adapter)
+	if err != nil {
+		logging.Context(ctx).Error("Failed to create casbin enforcer", zap.Error(err))
+		return err
+	}
+	// The changes are saved by the adapter before they are applied to the enforcer
+	e.EnableAutoSave(false)
+	e.AddFunction(middleware.CasbinConditionFunction, middleware.CasbinConditionMatch)
+	e.EnableLog(config.C.IsDebug())
+	a.enforcer.Store(e)
+	a.lastChangeID = lastChangeID
+	a.appliedChanges = appliedChanges
+	a.advanceChanges()
+
+	logging.Context(ctx).Info("Casbin load policy",
+		zap.Duration("cost", time.Since(start)),
+		zap.Int("policies", len(e.GetPolicy())),
+		zap.Int("inherits", len(e.GetGroupingPolicy())),
+		zap.Int("changes", len(changes)),
+	)
+	return nil
+}
+
+// Apply the policy changes of the roles (e.g. the menus, parents or status of the roles are changed) to the database
+// and the enforcer, the other instances pull the changes from the change log.
+func (a *Casbinx) SyncRoles(ctx context.Context, roleIDs ...string) error {
+	if config.C.Middleware.Casbin.Disable || len(roleIDs) == 0 {
 		return nil
 	}
 
-	var resCount int32
-	queue := make(chan *policyQueueItem, len(roleResult.Data))
-	threadNum := config.C.Middleware.Casbin.LoadThread
-	lock := new(sync.Mutex)
-	buf := new(bytes.Buffer)
+	a.lock.Lock()
+	defer a.lock.Unlock()
 
-	wg := new(sync.WaitGroup)
-	wg.Add(threadNum)
-	for i := 0; i < threadNum; i++ {
-		go func() {
-			defer wg.Done()
-			ibuf := new(bytes.Buffer)
-			for item := range queue {
-				for _, res := range item.Resources {
-					_, _ = ibuf.WriteString(fmt.Sprintf("p, %s, %s, %s \n", item.RoleID, res.Path, res.Method))
-				}
+	changes, err := a.diffPolicies(ctx, roleIDs)
+	if err != nil {
+		return err
+	} else if len(changes) == 0 {
+		return nil
+	} else if err := a.Adapter.SaveChanges(ctx, changes); err != nil {
+		return err
+	}
+
+	if e := a.GetEnforcer(); e != nil {
+		if err := a.applyChanges(e, changes); err != nil {
+			return err
+		}
+	}
+	logging.Context(ctx).Info("Casbin sync role policies",
+		zap.Strings("roles", roleIDs),
+		zap.Int("changes", len(changes)),
+	)
+	return nil
+}
+
+// Pull the changes after the latest applied one from the change log and apply them to the enforcer.
+func (a *Casbinx) pullChanges(ctx context.Context) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	e := a.GetEnforcer()
+	if e == nil {
+		return nil
+	}
+
+	err := a.queryChanges(ctx, a.lastChangeID, func(list schema.CasbinChanges) error {
+		var changes schema.CasbinChanges
+		for _, item := range list {
+			if _, ok := a.appliedChanges[item.ID]; !ok {
+				changes = append(changes, item)
 			}
-			lock.Lock()
-			_, _ = buf.Write(ibuf.Bytes())
-			lock.Unlock()
-		}()
+		}
+
+		if err := a.applyChanges(e, changes); err != nil {
+			return err
+		}
+		for _, item := range changes {
+			a.appliedChanges[item.ID] = item.CreatedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	a.advanceChanges()
+	return nil
+}
 
-	for _, item := range roleResult.Data {
-		resources, err := a.queryRoleResources(ctx, item.ID)
+func (a *Casbinx) changeLookback() time.Duration {
+	return time.Duration(config.C.Middleware.Casbin.ChangeLookback) * time.Second
+}
+
+// Query the changes after the ID in pages.
+func (a *Casbinx) queryChanges(ctx context.Context, gtID uint64, fn func(list schema.CasbinChanges) error) error {
+	for {
+		changeResult, err := a.CasbinChangeDAL.Query(ctx, schema.CasbinChangeQueryParam{
+			PaginationParam: util.PaginationParam{PageSize: casbinChangePageSize},
+			GtID:            gtID,
+		})
 		if err != nil {
-			logging.Context(ctx).Error("Failed to query role resources", zap.Error(err))
+			return err
+		} else if len(changeResult.Data) == 0 {
+			return nil
+		}
+
+		if err := fn(changeResult.Data); err != nil {
+			return err
+		}
+		gtID = changeResult.Data[len(changeResult.Data)-1].ID
+		if len(changeResult.Data) < casbinChangePageSize {
+			return nil
+		}
+	}
+}
+
+// Advance lastChangeID over the applied changes. A missing ID is skipped once the change after it is older than the
+// lookback window, the transaction of the missing change has been rolled back.
+func (a *Casbinx) advanceChanges() {
+	cutoff := time.Now().Add(-a.changeLookback())
+	for len(a.appliedChanges) > 0 {
+		next := a.lastChangeID + 1
+		if _, ok := a.appliedChanges[next]; ok {
+			delete(a.appliedChanges, next)
+			a.lastChangeID = next
 			continue
 		}
-		atomic.AddInt32(&resCount, int32(len(resources)))
-		queue <- &policyQueueItem{
-			RoleID:    item.ID,
-			Resources: resources,
+
+		var (
+			minID uint64
+			minAt time.Time
+		)
+		for id, createdAt := range a.appliedChanges {
+			if minID == 0 || id < minID {
+				minID, minAt = id, createdAt
+			}
+		}
+		if minAt.After(cutoff) {
+			return
 		}
+		a.lastChangeID = minID - 1
 	}
-	close(queue)
-	wg.Wait()
+}
 
-	if buf.Len() > 0 {
-		policyFile := filepath.Join(config.C.General.WorkDir, config.C.Middleware.Casbin.GenPolicyFile)
-		_ = os.Rename(policyFile, policyFile+".bak")
-		_ = os.MkdirAll(filepath.Dir(policyFile), 0755)
-		if err := os.WriteFile(policyFile, buf.Bytes(), 0666); err != nil {
-			logging.Context(ctx).Error("Failed to write policy file", zap.Error(err))
-			return err
+// Apply the changes to the enforcer in order, the consecutive changes with the same action and policy type are
+// applied in batch.
+func (a *Casbinx) applyChanges(e *casbin.SyncedEnforcer, changes schema.CasbinChanges) error {
+	for i := 0; i < len(changes); {
+		action, ptype := changes[i].Action, changes[i].Ptype
+		var rules [][]string
+		for ; i < len(changes) && changes[i].Action == action && changes[i].Ptype == ptype; i++ {
+			rules = append(rules, changes[i].Rule)
 		}
-		// set readonly
-		_ = os.Chmod(policyFile, 0444)
 
-		modelFile := filepath.Join(config.C.General.WorkDir, config.C.Middleware.Casbin.ModelFile)
-		e, err := casbin.NewEnforcer(modelFile, policyFile)
+		var err error
+		switch {
+		case action == schema.CasbinChangeActionAdd && ptype == schema.CasbinPtypePolicy:
+			_, err = e.AddPoliciesEx(rules)
+		case action == schema.CasbinChangeActionAdd && ptype == schema.CasbinPtypeGrouping:
+			_, err = e.AddGroupingPoliciesEx(rules)
+		case action == schema.CasbinChangeActionRemove && ptype == schema.CasbinPtypePolicy:
+			_, err = e.RemovePolicies(rules)
+		case action == schema.CasbinChangeActionRemove && ptype == schema.CasbinPtypeGrouping:
+			_, err = e.RemoveGroupingPolicies(rules)
+		}
 		if err != nil {
-			logging.Context(ctx).Error("Failed to create casbin enforcer", zap.Error(err))
 			return err
 		}
-		e.EnableLog(config.C.IsDebug())
-		a.enforcer.Store(e)
 	}
+	return nil
+}
 
-	logging.Context(ctx).Info("Casbin load policy",
-		zap.Duration("cost", time.Since(start)),
-		zap.Int("roles", len(roleResult.Data)),
-		zap.Int32("resources", resCount),
-		zap.Int("bytes", buf.Len()),
+// Compare the policies generated from the roles with the policies in the database, and get the changes to make them
+// consistent. All policies are compared if the roles are not specified.
+func (a *Casbinx) diffPolicies(ctx context.Context, roleIDs []string) (schema.CasbinChanges, error) {
+	expected, err := a.queryPolicies(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var current schema.CasbinRules
+	if len(roleIDs) == 0 {
+		ruleResult, err := a.CasbinRuleDAL.Query(ctx, schema.CasbinRuleQueryParam{})
+		if err != nil {
+			return nil, err
+		}
+		current = ruleResult.Data
+	} else {
+		for _, params := range []schema.CasbinRuleQueryParam{
+			{InV0: roleIDs},
+			{Ptype: schema.CasbinPtypeGrouping, InV1: roleIDs},
+		} {
+			ruleResult, err := a.CasbinRuleDAL.Query(ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			current = append(current, ruleResult.Data...)
+		}
+	}
+
+	expectedKeys := make(map[string]bool, len(expected))
+	for _, item := range expected {
+		expectedKeys[item.Key()] = true
+	}
+	currentKeys := make(map[string]bool, len(current))
+
+	var changes schema.CasbinChanges
+	for _, item := range current {
+		key := item.Key()
+		if currentKeys[key] {
+			continue
+		}
+		currentKeys[key] = true
+		if !expectedKeys[key] {
+			changes = changes.Append(schema.CasbinChangeActionRemove, item.Ptype, item.ToRule())
+		}
+	}
+	for _, item := range expected {
+		key := item.Key()
+		if !currentKeys[key] {
+			currentKeys[key] = true
+			changes = changes.Append(schema.CasbinChangeActionAdd, item.Ptype, item.ToRule())
+		}
+	}
+	return changes, nil
+}
+
+// Generate the policies of the enabled roles and the grouping policies of the enabled roles and their enabled parent
+// roles. The policies of all roles are generated if the roles are not specified.
+func (a *Casbinx) queryPolicies(ctx context.Context, roleIDs []string) (schema.CasbinRules, error) {
+	roleResult, err := a.RoleDAL.Query(ctx, schema.RoleQueryParam{
+		Status: schema.RoleStatusEnabled,
+	}, schema.RoleQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id", "tenant_id"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The enabled roles with their domains
+	domains := make(map[string]string, len(roleResult.Data))
+	for _, item := range roleResult.Data {
+		domains[item.ID] = schema.CasbinDomain(item.TenantID)
+	}
+	targets := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		targets[roleID] = true
+	}
+	isTarget := func(roleID string) bool {
+		return len(roleIDs) == 0 || targets[roleID]
+	}
+
+	queue := make(chan string, len(roleResult.Data))
+	for _, item := range roleResult.Data {
+		if isTarget(item.ID) {
+			queue <- item.ID
+		}
+	}
+	close(queue)
+
+	var (
+		rules    schema.CasbinRules
+		queryErr error
 	)
-	return nil
+	threadNum := config.C.Middleware.Casbin.LoadThread
+	lock := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	wg.Add(threadNum)
+	for i := 0; i < threadNum; i++ {
+		go func() {
+			defer wg.Done()
+			for roleID := range queue {
+				resources, err := a.queryRoleResources(ctx, roleID)
+				lock.Lock()
+				if err != nil {
+					if queryErr == nil {
+						queryErr = err
+					}
+				} else {
+					for _, res := range resources {
+						rule := []string{roleID, domains[roleID], res.Path, res.Method, res.PolicyEffect(), res.Condition}
+						rules = append(rules, schema.NewCasbinRule(schema.CasbinPtypePolicy, rule))
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if queryErr != nil {
+		logging.Context(ctx).Error("Failed to query role resources", zap.Error(queryErr))
+		return nil, queryErr
+	}
+
+	roleParentResult, err := a.RoleParentDAL.Query(ctx, schema.RoleParentQueryParam{})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range roleParentResult.Data {
+		domain, ok := domains[item.RoleID]
+		if _, parentOK := domains[item.ParentID]; !ok || !parentOK ||
+			(!isTarget(item.RoleID) && !isTarget(item.ParentID)) {
+			continue
+		}
+		rule := []string{item.RoleID, item.ParentID, domain}
+		rules = append(rules, schema.NewCasbinRule(schema.CasbinPtypeGrouping, rule))
+	}
+	return rules, nil
 }
 
 func (a *Casbinx) queryRoleResources(ctx context.Context, roleID string) (schema.MenuResources, error) {
@@ -190,26 +463,26 @@ func (a *Casbinx) autoLoad(ctx context.Context) {
 	var lastUpdated int64
 	a.ticker = time.NewTicker(time.Duration(config.C.Middleware.Casbin.AutoLoadInterval) * time.Second)
 	for range a.ticker.C {
+		// Fall back to reload all policies if an instance failed to apply the changes
 		val, ok, err := a.Cache.Get(ctx, config.CacheNSForRole, config.CacheKeyForSyncToCasbin)
 		if err != nil {
 			logging.Context(ctx).Error("Failed to get cache", zap.Error(err), zap.String("key", config.CacheKeyForSyncToCasbin))
-			continue
-		} else if !ok {
-			continue
-		}
-
-		updated, err := strconv.ParseInt(val, 10, 64)
-		if err != nil {
-			logging.Context(ctx).Error("Failed to parse cache value", zap.Error(err), zap.String("val", val))
-			continue
+		} else if ok {
+			updated, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				logging.Context(ctx).Error("Failed to parse cache value", zap.Error(err), zap.String("val", val))
+			} else if lastUpdated < updated {
+				if err := a.load(ctx); err != nil {
+					logging.Context(ctx).Error("Failed to load casbin policy", zap.Error(err))
+				} else {
+					lastUpdated = updated
+				}
+				continue
+			}
 		}
 
-		if lastUpdated < updated {
-			if err := a.load(ctx); err != nil {
-				logging.Context(ctx).Error("Failed to load casbin policy", zap.Error(err))
-			} else {
-				lastUpdated = updated
-			}
+		if err := a.pullChanges(ctx); err != nil {
+			logging.Context(ctx).Error("Failed to pull casbin policy changes", zap.Error(err))
 		}
 	}
 }
@@ -0,0 +1,144 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+var _ persist.BatchAdapter = (*CasbinAdapter)(nil)
+
+// Casbin adapter which stores the policy rules in the database, each change of the rules is recorded to the change
+// log so that the other instances can apply it incrementally.
+type CasbinAdapter struct {
+	Trans           *util.Trans
+	CasbinRuleDAL   *dal.CasbinRule
+	CasbinChangeDAL *dal.CasbinChange
+}
+
+// Load all policy rules from the database.
+func (a *CasbinAdapter) LoadPolicy(m model.Model) error {
+	ruleResult, err := a.CasbinRuleDAL.Query(context.Background(), schema.CasbinRuleQueryParam{})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range ruleResult.Data {
+		if err := persist.LoadPolicyArray(append([]string{item.Ptype}, item.ToRule()...), m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replace all policy rules in the database with the rules of the model, the change log is not recorded.
+func (a *CasbinAdapter) SavePolicy(m model.Model) error {
+	ctx := context.Background()
+	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.CasbinRuleDAL.DeleteAll(ctx); err != nil {
+			return err
+		}
+
+		for _, sec := range []string{"p", "g"} {
+			for ptype, ast := range m[sec] {
+				for _, rule := range ast.Policy {
+					item := schema.NewCasbinRule(ptype, rule)
+					item.ID = util.NewXID()
+					item.CreatedAt = time.Now()
+					if err := a.CasbinRuleDAL.Create(ctx, item); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (a *CasbinAdapter) AddPolicy(sec string, ptype string, rule []string) error {
+	return a.AddPolicies(sec, ptype, [][]string{rule})
+}
+
+func (a *CasbinAdapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	changes := schema.CasbinChanges{}.Append(schema.CasbinChangeActionAdd, ptype, rules...)
+	return a.SaveChanges(context.Background(), changes)
+}
+
+func (a *CasbinAdapter) RemovePolicy(sec string, ptype string, rule []string) error {
+	return a.RemovePolicies(sec, ptype, [][]string{rule})
+}
+
+func (a *CasbinAdapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	changes := schema.CasbinChanges{}.Append(schema.CasbinChangeActionRemove, ptype, rules...)
+	return a.SaveChanges(context.Background(), changes)
+}
+
+func (a *CasbinAdapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	ctx := context.Background()
+	ruleResult, err := a.CasbinRuleDAL.Query(ctx, schema.CasbinRuleQueryParam{
+		Ptype: ptype,
+	})
+	if err != nil {
+		return err
+	}
+
+	var changes schema.CasbinChanges
+	for _, item := range ruleResult.Data {
+		rule := item.ToRule()
+		matched := true
+		for i, v := range fieldValues {
+			if v == "" {
+				continue
+			} else if fieldIndex+i >= len(rule) || rule[fieldIndex+i] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			changes = changes.Append(schema.CasbinChangeActionRemove, ptype, rule)
+		}
+	}
+	return a.SaveChanges(ctx, changes)
+}
+
+// Save the changes of the policy rules to the database and record them to the change log, the rules which already
+// exist are not added again.
+func (a *CasbinAdapter) SaveChanges(ctx context.Context, changes schema.CasbinChanges) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return a.Trans.Exec(ctx, func(ctx context.Context) error {
+		for _, change := range changes {
+			item := schema.NewCasbinRule(change.Ptype, change.Rule)
+			switch change.Action {
+			case schema.CasbinChangeActionAdd:
+				if exists, err := a.CasbinRuleDAL.Exists(ctx, item); err != nil {
+					return err
+				} else if !exists {
+					item.ID = util.NewXID()
+					item.CreatedAt = time.Now()
+					if err := a.CasbinRuleDAL.Create(ctx, item); err != nil {
+						return err
+					}
+				}
+			case schema.CasbinChangeActionRemove:
+				if err := a.CasbinRuleDAL.Delete(ctx, item); err != nil {
+					return err
+				}
+			}
+
+			change.ID = 0
+			change.CreatedAt = time.Now()
+			if err := a.CasbinChangeDAL.Create(ctx, change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,355 @@
+package rbac
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/middleware"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open a sqlite database with the roles r1 (menu m1: GET /api/v1/users) and r2 (no menus), the menu m2 (POST
+// /api/v1/users) is not assigned yet. The lookback window of the changes is 60 seconds.
+func newTestCasbinDB(t *testing.T) *gorm.DB {
+	general, casbinCfg := config.C.General, config.C.Middleware.Casbin
+	t.Cleanup(func() { config.C.General, config.C.Middleware.Casbin = general, casbinCfg })
+	config.C.General.WorkDir = filepath.Join("..", "..", "..", "configs")
+	config.C.Middleware.Casbin.Disable = false
+	config.C.Middleware.Casbin.ModelFile = "rbac_model.conf"
+	config.C.Middleware.Casbin.LoadThread = 2
+	config.C.Middleware.Casbin.ChangeLookback = 60
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	err = db.AutoMigrate(new(schema.Role), new(schema.RoleParent), new(schema.RoleMenu), new(schema.Menu),
+		new(schema.MenuResource), new(schema.CasbinRule), new(schema.CasbinChange))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range []interface{}{
+		&schema.Role{ID: "r1", Code: "r1", Name: "R1", Status: schema.RoleStatusEnabled},
+		&schema.Role{ID: "r2", Code: "r2", Name: "R2", Status: schema.RoleStatusEnabled},
+		&schema.Menu{ID: "m1", Code: "m1", Name: "M1", Status: schema.MenuStatusEnabled},
+		&schema.Menu{ID: "m2", Code: "m2", Name: "M2", Status: schema.MenuStatusEnabled},
+		&schema.MenuResource{ID: "mr1", MenuID: "m1", Method: "GET", Path: "/api/v1/users"},
+		&schema.MenuResource{ID: "mr2", MenuID: "m2", Method: "POST", Path: "/api/v1/users"},
+		&schema.RoleMenu{ID: "rm1", RoleID: "r1", MenuID: "m1"},
+	} {
+		if err := db.Create(item).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// Create an instance on the database and load the policies.
+func newTestCasbinx(t *testing.T, db *gorm.DB) *Casbinx {
+	casbinRuleDAL := &dal.CasbinRule{DB: db}
+	casbinChangeDAL := &dal.CasbinChange{DB: db}
+	a := &Casbinx{
+		enforcer: new(atomic.Value),
+		Cache:    cachex.NewMemoryCache(cachex.MemoryConfig{}),
+		Adapter: &CasbinAdapter{
+			Trans:           &util.Trans{DB: db},
+			CasbinRuleDAL:   casbinRuleDAL,
+			CasbinChangeDAL: casbinChangeDAL,
+		},
+		MenuDAL:         &dal.Menu{DB: db},
+		MenuResourceDAL: &dal.MenuResource{DB: db},
+		RoleDAL:         &dal.Role{DB: db},
+		RoleParentDAL:   &dal.RoleParent{DB: db},
+		CasbinRuleDAL:   casbinRuleDAL,
+		CasbinChangeDAL: casbinChangeDAL,
+	}
+	if err := a.load(testCasbinCtx()); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func testCasbinCtx() context.Context {
+	return util.NewTenantUnscoped(context.Background())
+}
+
+// The policies of the enforcer (p:... and g:...), sorted.
+func enforcerPolicies(a *Casbinx) []string {
+	e := a.GetEnforcer()
+	var list []string
+	for _, rule := range e.GetPolicy() {
+		list = append(list, schema.CasbinPtypePolicy+":"+strings.Join(rule, ","))
+	}
+	for _, rule := range e.GetGroupingPolicy() {
+		list = append(list, schema.CasbinPtypeGrouping+":"+strings.Join(rule, ","))
+	}
+	sort.Strings(list)
+	return list
+}
+
+// The policies in the database are generated from the roles, and the enforcer has the same policies.
+func assertCasbinConsistent(t *testing.T, a *Casbinx) {
+	ctx := testCasbinCtx()
+	changes, err := a.diffPolicies(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, changes, "the policies in the database are not generated from the roles")
+
+	ruleResult, err := a.CasbinRuleDAL.Query(ctx, schema.CasbinRuleQueryParam{})
+	assert.NoError(t, err)
+	var list []string
+	for _, item := range ruleResult.Data {
+		list = append(list, item.Ptype+":"+strings.Join(item.ToRule(), ","))
+	}
+	sort.Strings(list)
+	assert.Equal(t, list, enforcerPolicies(a))
+}
+
+func createTestCasbinChange(t *testing.T, db *gorm.DB, id uint64, createdAt time.Time, rule ...string) {
+	err := db.Create(&schema.CasbinChange{
+		ID:        id,
+		Action:    schema.CasbinChangeActionAdd,
+		Ptype:     schema.CasbinPtypePolicy,
+		Rule:      rule,
+		CreatedAt: createdAt,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCasbinSyncRoles(t *testing.T) {
+	db := newTestCasbinDB(t)
+	a := newTestCasbinx(t, db)
+	ctx := testCasbinCtx()
+	d := schema.CasbinDefaultDomain
+
+	assert.Equal(t, []string{"p:r1," + d + ",/api/v1/users,GET,allow,"}, enforcerPolicies(a))
+	assertCasbinConsistent(t, a)
+
+	countChanges := func() int64 {
+		var n int64
+		assert.NoError(t, db.Model(new(schema.CasbinChange)).Count(&n).Error)
+		return n
+	}
+	changes := countChanges()
+
+	// Update the menu resource: the old policy is removed and the new one is added
+	assert.NoError(t, db.Model(new(schema.MenuResource)).Where("id=?", "mr1").Update("path", "/api/v1/accounts").Error)
+	assert.NoError(t, a.SyncRoles(ctx, "r1"))
+	assert.Equal(t, []string{"p:r1," + d + ",/api/v1/accounts,GET,allow,"}, enforcerPolicies(a))
+	assertCasbinConsistent(t, a)
+	var list schema.CasbinChanges
+	assert.NoError(t, db.Where("id > ?", changes).Order("id").Find(&list).Error)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, schema.CasbinChangeActionRemove, list[0].Action)
+		assert.Equal(t, "/api/v1/users", list[0].Rule[2])
+		assert.Equal(t, schema.CasbinChangeActionAdd, list[1].Action)
+		assert.Equal(t, "/api/v1/accounts", list[1].Rule[2])
+	}
+
+	// Nothing is recorded without changes
+	changes = countChanges()
+	assert.NoError(t, a.SyncRoles(ctx, "r1", "r2"))
+	assert.Equal(t, changes, countChanges())
+
+	// Assign a menu with a deny resource to the role
+	assert.NoError(t, db.Create(&schema.RoleMenu{ID: "rm2", RoleID: "r1", MenuID: "m2"}).Error)
+	assert.NoError(t, db.Model(new(schema.MenuResource)).Where("id=?", "mr2").Updates(map[string]interface{}{
+		"effect": schema.MenuResourceEffectDeny, "condition": "hour < 9",
+	}).Error)
+	assert.NoError(t, a.SyncRoles(ctx, "r1"))
+	assert.Equal(t, []string{
+		"p:r1," + d + ",/api/v1/accounts,GET,allow,",
+		"p:r1," + d + ",/api/v1/users,POST,deny,hour < 9",
+	}, enforcerPolicies(a))
+	assertCasbinConsistent(t, a)
+
+	// Inherit the role
+	assert.NoError(t, db.Create(&schema.RoleParent{ID: "rp1", RoleID: "r2", ParentID: "r1"}).Error)
+	assert.NoError(t, a.SyncRoles(ctx, "r2"))
+	assert.Contains(t, enforcerPolicies(a), "g:r2,r1,"+d)
+	ok, err := a.GetEnforcer().Enforce("r2", d, "/api/v1/accounts", "GET", (*middleware.CasbinAttributes)(nil))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assertCasbinConsistent(t, a)
+
+	// Disable the parent role: its policies and the inheritance are removed
+	assert.NoError(t, db.Model(new(schema.Role)).Where("id=?", "r1").Update("status", schema.RoleStatusDisabled).Error)
+	assert.NoError(t, a.SyncRoles(ctx, "r1"))
+	assert.Empty(t, enforcerPolicies(a))
+	assertCasbinConsistent(t, a)
+}
+
+func TestCasbinPullChangesOutOfOrder(t *testing.T) {
+	db := newTestCasbinDB(t)
+	a := newTestCasbinx(t, db)
+	ctx := testCasbinCtx()
+	d := schema.CasbinDefaultDomain
+	base := a.lastChangeID
+
+	// The change with the greater ID is committed first
+	createTestCasbinChange(t, db, base+2, time.Now(), "r2", d, "/api/v1/b", "GET", "allow", "")
+	assert.NoError(t, a.pullChanges(ctx))
+	assert.Contains(t, enforcerPolicies(a), "p:r2,"+d+",/api/v1/b,GET,allow,")
+	assert.Equal(t, base, a.lastChangeID, "the gap is pulled again within the lookback window")
+	assert.Len(t, a.appliedChanges, 1)
+
+	createTestCasbinChange(t, db, base+1, time.Now(), "r2", d, "/api/v1/a", "GET", "allow", "")
+	assert.NoError(t, a.pullChanges(ctx))
+	assert.Contains(t, enforcerPolicies(a), "p:r2,"+d+",/api/v1/a,GET,allow,")
+	assert.Equal(t, base+2, a.lastChangeID)
+	assert.Empty(t, a.appliedChanges)
+
+	// The applied changes are not applied again
+	policies := enforcerPolicies(a)
+	assert.NoError(t, a.pullChanges(ctx))
+	assert.Equal(t, policies, enforcerPolicies(a))
+}
+
+func TestCasbinPullChangesGapExpired(t *testing.T) {
+	db := newTestCasbinDB(t)
+	a := newTestCasbinx(t, db)
+	ctx := testCasbinCtx()
+	d := schema.CasbinDefaultDomain
+	base := a.lastChangeID
+	lookback := time.Duration(config.C.Middleware.Casbin.ChangeLookback) * time.Second
+
+	// The change after the gap is older than the lookback window, the missing change has been rolled back
+	createTestCasbinChange(t, db, base+2, time.Now().Add(-lookback-time.Second), "r2", d, "/api/v1/b", "GET", "allow", "")
+	createTestCasbinChange(t, db, base+4, time.Now(), "r2", d, "/api/v1/d", "GET", "allow", "")
+	assert.NoError(t, a.pullChanges(ctx))
+	assert.Equal(t, base+2, a.lastChangeID, "the expired gap is skipped, the recent one is kept")
+	assert.Len(t, a.appliedChanges, 1)
+
+	// A change in the skipped gap is not pulled, the one in the open gap is
+	createTestCasbinChange(t, db, base+1, time.Now(), "r2", d, "/api/v1/a", "GET", "allow", "")
+	createTestCasbinChange(t, db, base+3, time.Now(), "r2", d, "/api/v1/c", "GET", "allow", "")
+	assert.NoError(t, a.pullChanges(ctx))
+	policies := enforcerPolicies(a)
+	assert.NotContains(t, policies, "p:r2,"+d+",/api/v1/a,GET,allow,")
+	assert.Contains(t, policies, "p:r2,"+d+",/api/v1/b,GET,allow,")
+	assert.Contains(t, policies, "p:r2,"+d+",/api/v1/c,GET,allow,")
+	assert.Contains(t, policies, "p:r2,"+d+",/api/v1/d,GET,allow,")
+	assert.Equal(t, base+4, a.lastChangeID)
+}
+
+func TestCasbinReload(t *testing.T) {
+	db := newTestCasbinDB(t)
+	a := newTestCasbinx(t, db)
+	b := newTestCasbinx(t, db)
+	ctx := testCasbinCtx()
+	d := schema.CasbinDefaultDomain
+
+	// The roles are changed without syncing (e.g. an instance failed to apply the changes), and a stale policy is
+	// left in the database
+	assert.NoError(t, db.Create(&schema.RoleMenu{ID: "rm2", RoleID: "r1", MenuID: "m2"}).Error)
+	stale := schema.NewCasbinRule(schema.CasbinPtypePolicy, []string{"r2", d, "/api/v1/stale", "GET", "allow", ""})
+	stale.ID = util.NewXID()
+	assert.NoError(t, db.Create(stale).Error)
+	assert.NotContains(t, enforcerPolicies(a), "p:r1,"+d+",/api/v1/users,POST,allow,")
+
+	// The full reload synchronizes the database with the roles
+	assert.NoError(t, a.load(ctx))
+	assert.Equal(t, []string{
+		"p:r1," + d + ",/api/v1/users,GET,allow,",
+		"p:r1," + d + ",/api/v1/users,POST,allow,",
+	}, enforcerPolicies(a))
+	assertCasbinConsistent(t, a)
+
+	// The changes of the reload are recorded, the other instance pulls them
+	assert.NoError(t, b.pullChanges(ctx))
+	assert.Equal(t, enforcerPolicies(a), enforcerPolicies(b))
+
+	// The changes loaded with the policies are not applied again after the reload
+	lastChangeID := a.lastChangeID
+	assert.NoError(t, a.pullChanges(ctx))
+	assert.Equal(t, lastChangeID, a.lastChangeID)
+	assertCasbinConsistent(t, a)
+}
+
+func TestCasbinConverge(t *testing.T) {
+	db := newTestCasbinDB(t)
+	a := newTestCasbinx(t, db)
+	b := newTestCasbinx(t, db)
+	ctx := testCasbinCtx()
+
+	steps := []struct {
+		instance *Casbinx
+		change   func() error
+		roleIDs  []string
+	}{
+		{a, func() error {
+			return db.Create(&schema.RoleMenu{ID: "rm2", RoleID: "r2", MenuID: "m2"}).Error
+		}, []string{"r2"}},
+		{b, func() error {
+			return db.Create(&schema.RoleParent{ID: "rp1", RoleID: "r2", ParentID: "r1"}).Error
+		}, []string{"r2"}},
+		{a, func() error {
+			return db.Model(new(schema.MenuResource)).Where("id=?", "mr1").Update("method", "PUT").Error
+		}, []string{"r1"}},
+		{b, func() error {
+			return db.Model(new(schema.Role)).Where("id=?", "r1").Update("status", schema.RoleStatusDisabled).Error
+		}, []string{"r1"}},
+		{a, func() error {
+			return db.Model(new(schema.Role)).Where("id=?", "r1").Update("status", schema.RoleStatusEnabled).Error
+		}, []string{"r1"}},
+	}
+	for i, step := range steps {
+		assert.NoError(t, step.change())
+		assert.NoError(t, step.instance.SyncRoles(ctx, step.roleIDs...))
+		assert.NoError(t, a.pullChanges(ctx))
+		assert.NoError(t, b.pullChanges(ctx))
+		assert.Equal(t, enforcerPolicies(a), enforcerPolicies(b), "step %d", i)
+		assertCasbinConsistent(t, a)
+	}
+	assert.Len(t, enforcerPolicies(a), 3)
+}
+
+func TestCasbinRoleParents(t *testing.T) {
+	db := newTestCasbinDB(t)
+	assert.NoError(t, db.Create(&schema.Role{ID: "r3", Code: "r3", Name: "R3", Status: schema.RoleStatusEnabled}).Error)
+	assert.NoError(t, db.Create(&schema.RoleParent{ID: "rp1", RoleID: "r2", ParentID: "r1"}).Error)
+	assert.NoError(t, db.Create(&schema.RoleParent{ID: "rp2", RoleID: "r3", ParentID: "r2"}).Error)
+	a := newTestCasbinx(t, db)
+	d := schema.CasbinDefaultDomain
+	assertCasbinConsistent(t, a)
+
+	// The direct roles of the user are expanded to all ancestors by the enforcer
+	for _, roleID := range []string{"r1", "r2", "r3"} {
+		ok, _, err := middleware.CasbinEnforce(a.GetEnforcer(), []string{roleID}, d, "/api/v1/users", "GET", nil)
+		assert.NoError(t, err)
+		assert.True(t, ok, roleID)
+	}
+	roles, err := a.GetEnforcer().GetImplicitRolesForUser("r3", d)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"r1", "r2"}, roles)
+
+	// The descendants lose the permissions when the inheritance in the middle is removed
+	assert.NoError(t, db.Where("id=?", "rp1").Delete(new(schema.RoleParent)).Error)
+	assert.NoError(t, a.SyncRoles(testCasbinCtx(), "r2"))
+	assertCasbinConsistent(t, a)
+	ok, _, err := middleware.CasbinEnforce(a.GetEnforcer(), []string{"r3"}, d, "/api/v1/users", "GET", nil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
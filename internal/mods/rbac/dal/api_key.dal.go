@@ -0,0 +1,72 @@
+package dal
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get api key storage instance
+func GetAPIKeyDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.APIKey))
+}
+
+// Personal API keys of the users
+type APIKey struct {
+	DB *gorm.DB
+}
+
+// Query the api keys of the specified user from the database.
+func (a *APIKey) QueryByUserID(ctx context.Context, userID string) (schema.APIKeys, error) {
+	var list schema.APIKeys
+	result := GetAPIKeyDB(ctx, a.DB).Where("user_id=?", userID).Order("created_at DESC").Find(&list)
+	return list, errors.WithStack(result.Error)
+}
+
+// Get the api key by the hash of the key from the database.
+func (a *APIKey) GetByHash(ctx context.Context, keyHash string) (*schema.APIKey, error) {
+	item := new(schema.APIKey)
+	ok, err := util.FindOne(ctx, GetAPIKeyDB(ctx, a.DB).Where("key_hash=?", keyHash), util.QueryOptions{}, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Count the api keys of the specified user.
+func (a *APIKey) CountByUserID(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	result := GetAPIKeyDB(ctx, a.DB).Where("user_id=?", userID).Count(&count)
+	return count, errors.WithStack(result.Error)
+}
+
+// Create a new api key.
+func (a *APIKey) Create(ctx context.Context, item *schema.APIKey) error {
+	result := GetAPIKeyDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Record the last use of the specified api key.
+func (a *APIKey) UpdateLastUsed(ctx context.Context, id string, lastUsedAt time.Time, lastUsedIP string) error {
+	result := GetAPIKeyDB(ctx, a.DB).Where("id=?", id).
+		UpdateColumns(map[string]interface{}{"last_used_at": lastUsedAt, "last_used_ip": lastUsedIP})
+	return errors.WithStack(result.Error)
+}
+
+// Delete the specified api key of the user from the database, returns false if not exists.
+func (a *APIKey) DeleteByID(ctx context.Context, userID, id string) (bool, error) {
+	result := GetAPIKeyDB(ctx, a.DB).Where("id=? AND user_id=?", id, userID).Delete(new(schema.APIKey))
+	return result.RowsAffected > 0, errors.WithStack(result.Error)
+}
+
+// Delete the api keys of the specified user from the database.
+func (a *APIKey) DeleteByUserID(ctx context.Context, userID string) error {
+	result := GetAPIKeyDB(ctx, a.DB).Where("user_id=?", userID).Delete(new(schema.APIKey))
+	return errors.WithStack(result.Error)
+}
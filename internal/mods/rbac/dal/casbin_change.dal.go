@@ -0,0 +1,68 @@
+package dal
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get casbin change storage instance
+func GetCasbinChangeDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.CasbinChange))
+}
+
+// Change log of the casbin policy rules
+type CasbinChange struct {
+	DB *gorm.DB
+}
+
+// Query casbin changes (ordered by ID) from the database based on the provided parameters and options.
+func (a *CasbinChange) Query(ctx context.Context, params schema.CasbinChangeQueryParam, opts ...schema.CasbinChangeQueryOptions) (*schema.CasbinChangeQueryResult, error) {
+	var opt schema.CasbinChangeQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetCasbinChangeDB(ctx, a.DB)
+	if v := params.GtID; v > 0 {
+		db = db.Where("id > ?", v)
+	}
+
+	var list schema.CasbinChanges
+	if len(opt.OrderFields) == 0 {
+		opt.OrderFields = util.OrderByParams{{Field: "id", Direction: util.ASC}}
+	}
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.CasbinChangeQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+// Get the ID of the latest change created before the time, it is 0 if there is no change.
+func (a *CasbinChange) GetLatestIDBefore(ctx context.Context, t time.Time) (uint64, error) {
+	var id uint64
+	result := GetCasbinChangeDB(ctx, a.DB).Where("created_at < ?", t).Select("COALESCE(MAX(id), 0)").Scan(&id)
+	return id, errors.WithStack(result.Error)
+}
+
+// Create a new casbin change.
+func (a *CasbinChange) Create(ctx context.Context, item *schema.CasbinChange) error {
+	result := GetCasbinChangeDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Delete the changes created before the specified time.
+func (a *CasbinChange) DeleteBefore(ctx context.Context, t time.Time) error {
+	result := GetCasbinChangeDB(ctx, a.DB).Where("created_at < ?", t).Delete(new(schema.CasbinChange))
+	return errors.WithStack(result.Error)
+}
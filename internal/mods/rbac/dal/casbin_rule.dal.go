@@ -0,0 +1,80 @@
+package dal
+
+import (
+	"context"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get casbin rule storage instance
+func GetCasbinRuleDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.CasbinRule))
+}
+
+// Policy rules of casbin
+type CasbinRule struct {
+	DB *gorm.DB
+}
+
+// Query casbin rules from the database based on the provided parameters and options.
+func (a *CasbinRule) Query(ctx context.Context, params schema.CasbinRuleQueryParam, opts ...schema.CasbinRuleQueryOptions) (*schema.CasbinRuleQueryResult, error) {
+	var opt schema.CasbinRuleQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetCasbinRuleDB(ctx, a.DB)
+	if v := params.Ptype; len(v) > 0 {
+		db = db.Where("ptype = ?", v)
+	}
+	if v := params.InV0; len(v) > 0 {
+		db = db.Where("v0 IN (?)", v)
+	}
+	if v := params.InV1; len(v) > 0 {
+		db = db.Where("v1 IN (?)", v)
+	}
+
+	var list schema.CasbinRules
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.CasbinRuleQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+func (a *CasbinRule) whereRule(db *gorm.DB, item *schema.CasbinRule) *gorm.DB {
+	return db.Where("ptype=? AND v0=? AND v1=? AND v2=? AND v3=? AND v4=? AND v5=?",
+		item.Ptype, item.V0, item.V1, item.V2, item.V3, item.V4, item.V5)
+}
+
+// Exists checks if the rule with the same fields exists in the database.
+func (a *CasbinRule) Exists(ctx context.Context, item *schema.CasbinRule) (bool, error) {
+	ok, err := util.Exists(ctx, a.whereRule(GetCasbinRuleDB(ctx, a.DB), item))
+	return ok, errors.WithStack(err)
+}
+
+// Create a new casbin rule.
+func (a *CasbinRule) Create(ctx context.Context, item *schema.CasbinRule) error {
+	result := GetCasbinRuleDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Delete the rules with the same fields from the database.
+func (a *CasbinRule) Delete(ctx context.Context, item *schema.CasbinRule) error {
+	result := a.whereRule(GetCasbinRuleDB(ctx, a.DB), item).Delete(new(schema.CasbinRule))
+	return errors.WithStack(result.Error)
+}
+
+// Delete all rules from the database.
+func (a *CasbinRule) DeleteAll(ctx context.Context) error {
+	result := GetCasbinRuleDB(ctx, a.DB).Where("1 = 1").Delete(new(schema.CasbinRule))
+	return errors.WithStack(result.Error)
+}
@@ -27,7 +27,7 @@ func (a *Logger) Query(ctx context.Context, params schema.LoggerQueryParam, opts
 		opt = opts[0]
 	}
 
-	db := a.DB.Table(fmt.Sprintf("%s AS a", new(schema.Logger).TableName()))
+	db := GetLoggerDB(ctx, a.DB).Table(fmt.Sprintf("%s AS a", new(schema.Logger).TableName()))
 	db = db.Joins(fmt.Sprintf("left join %s b on a.user_id=b.id", new(schema.User).TableName()))
 	db = db.Select("a.*,b.name as user_name,b.username as login_name")
 
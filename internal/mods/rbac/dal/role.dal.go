@@ -98,3 +98,10 @@ func (a *Role) Delete(ctx context.Context, id string) error {
 	result := GetRoleDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.Role))
 	return errors.WithStack(result.Error)
 }
+
+// Get the IDs of the enabled roles with the specified codes.
+func (a *Role) GetIDsByCodes(ctx context.Context, codes []string) ([]string, error) {
+	var ids []string
+	result := GetRoleDB(ctx, a.DB).Where("code IN (?) AND status=?", codes, schema.RoleStatusEnabled).Pluck("id", &ids)
+	return ids, errors.WithStack(result.Error)
+}
@@ -0,0 +1,115 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get role constraint storage instance
+func GetRoleConstraintDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.RoleConstraint))
+}
+
+// Separation of duties constraints between roles
+type RoleConstraint struct {
+	DB *gorm.DB
+}
+
+// Query role constraints from the database based on the provided parameters and options.
+func (a *RoleConstraint) Query(ctx context.Context, params schema.RoleConstraintQueryParam, opts ...schema.RoleConstraintQueryOptions) (*schema.RoleConstraintQueryResult, error) {
+	var opt schema.RoleConstraintQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetRoleConstraintDB(ctx, a.DB).Table(fmt.Sprintf("%s AS a", new(schema.RoleConstraint).TableName()))
+	if opt.JoinRole {
+		roleTable := new(schema.Role).TableName()
+		db = db.Joins(fmt.Sprintf("left join %s b on a.role_id=b.id", roleTable))
+		db = db.Joins(fmt.Sprintf("left join %s c on a.other_role_id=c.id", roleTable))
+		db = db.Select("a.*,b.name as role_name,c.name as other_role_name")
+	}
+
+	if v := params.Type; len(v) > 0 {
+		db = db.Where("a.type = ?", v)
+	}
+	if v := params.RoleID; len(v) > 0 {
+		db = db.Where("(a.role_id = ? OR a.other_role_id = ?)", v, v)
+	}
+
+	var list schema.RoleConstraints
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.RoleConstraintQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+// Get the specified role constraint from the database.
+func (a *RoleConstraint) Get(ctx context.Context, id string, opts ...schema.RoleConstraintQueryOptions) (*schema.RoleConstraint, error) {
+	var opt schema.RoleConstraintQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.RoleConstraint)
+	ok, err := util.FindOne(ctx, GetRoleConstraintDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Exist checks if the specified role constraint exists in the database.
+func (a *RoleConstraint) Exists(ctx context.Context, id string) (bool, error) {
+	ok, err := util.Exists(ctx, GetRoleConstraintDB(ctx, a.DB).Where("id=?", id))
+	return ok, errors.WithStack(err)
+}
+
+// Checks if the same constraint (in either order of the roles) exists, except the specified one.
+func (a *RoleConstraint) ExistsRoles(ctx context.Context, item *schema.RoleConstraint) (bool, error) {
+	db := GetRoleConstraintDB(ctx, a.DB).Where("type=? AND id<>?", item.Type, item.ID)
+	if item.Type == schema.RoleConstraintTypeExclusive {
+		db = db.Where("((role_id=? AND other_role_id=?) OR (role_id=? AND other_role_id=?))",
+			item.RoleID, item.OtherRoleID, item.OtherRoleID, item.RoleID)
+	} else {
+		db = db.Where("role_id=?", item.RoleID)
+	}
+	ok, err := util.Exists(ctx, db)
+	return ok, errors.WithStack(err)
+}
+
+// Create a new role constraint.
+func (a *RoleConstraint) Create(ctx context.Context, item *schema.RoleConstraint) error {
+	result := GetRoleConstraintDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Update the specified role constraint in the database.
+func (a *RoleConstraint) Update(ctx context.Context, item *schema.RoleConstraint) error {
+	result := GetRoleConstraintDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+// Delete the specified role constraint from the database.
+func (a *RoleConstraint) Delete(ctx context.Context, id string) error {
+	result := GetRoleConstraintDB(ctx, a.DB).Where("id=?", id).Delete(new(schema.RoleConstraint))
+	return errors.WithStack(result.Error)
+}
+
+func (a *RoleConstraint) DeleteByRoleID(ctx context.Context, roleID string) error {
+	result := GetRoleConstraintDB(ctx, a.DB).Where("role_id=? OR other_role_id=?", roleID, roleID).Delete(new(schema.RoleConstraint))
+	return errors.WithStack(result.Error)
+}
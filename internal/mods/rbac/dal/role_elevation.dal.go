@@ -0,0 +1,109 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get role elevation storage instance
+func GetRoleElevationDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.RoleElevation))
+}
+
+// Requests of the roles for bounded windows
+type RoleElevation struct {
+	DB *gorm.DB
+}
+
+// Query role elevations from the database based on the provided parameters and options.
+func (a *RoleElevation) Query(ctx context.Context, params schema.RoleElevationQueryParam, opts ...schema.RoleElevationQueryOptions) (*schema.RoleElevationQueryResult, error) {
+	var opt schema.RoleElevationQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetRoleElevationDB(ctx, a.DB).Table(fmt.Sprintf("%s AS a", new(schema.RoleElevation).TableName()))
+	selects := []string{"a.*"}
+	if opt.JoinUser {
+		db = db.Joins(fmt.Sprintf("left join %s b on a.user_id=b.id", new(schema.User).TableName()))
+		selects = append(selects, "b.username as username")
+	}
+	if opt.JoinRole {
+		db = db.Joins(fmt.Sprintf("left join %s c on a.role_id=c.id", new(schema.Role).TableName()))
+		selects = append(selects, "c.name as role_name")
+	}
+	if len(selects) > 1 {
+		db = db.Select(strings.Join(selects, ","))
+	}
+
+	if v := params.Status; len(v) > 0 {
+		db = db.Where("a.status = ?", v)
+	}
+	if v := params.UserID; len(v) > 0 {
+		db = db.Where("a.user_id = ?", v)
+	}
+	if v := params.RoleID; len(v) > 0 {
+		db = db.Where("a.role_id = ?", v)
+	}
+	if v := params.ActiveAt; v != nil {
+		db = db.Where("a.status = ? AND a.starts_at <= ? AND a.expires_at > ?", schema.RoleElevationStatusApproved, v, v)
+	}
+
+	var list schema.RoleElevations
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.RoleElevationQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+// Get the specified role elevation from the database.
+func (a *RoleElevation) Get(ctx context.Context, id string, opts ...schema.RoleElevationQueryOptions) (*schema.RoleElevation, error) {
+	var opt schema.RoleElevationQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	item := new(schema.RoleElevation)
+	ok, err := util.FindOne(ctx, GetRoleElevationDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Checks if a request of the user for the role is waiting for the approval.
+func (a *RoleElevation) ExistsPending(ctx context.Context, userID, roleID string) (bool, error) {
+	ok, err := util.Exists(ctx, GetRoleElevationDB(ctx, a.DB).Where("user_id=? AND role_id=? AND status=?",
+		userID, roleID, schema.RoleElevationStatusPending))
+	return ok, errors.WithStack(err)
+}
+
+// Create a new role elevation.
+func (a *RoleElevation) Create(ctx context.Context, item *schema.RoleElevation) error {
+	result := GetRoleElevationDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Update the pending role elevation (e.g. approve it), ok is false if it has been reviewed or cancelled.
+func (a *RoleElevation) UpdatePending(ctx context.Context, item *schema.RoleElevation) (bool, error) {
+	result := GetRoleElevationDB(ctx, a.DB).Where("id=? AND status=?", item.ID, schema.RoleElevationStatusPending).
+		Select("*").Omit("created_at").Updates(item)
+	if err := result.Error; err != nil {
+		return false, errors.WithStack(err)
+	}
+	return result.RowsAffected > 0, nil
+}
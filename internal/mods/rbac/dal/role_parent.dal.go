@@ -0,0 +1,70 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get role parent storage instance
+func GetRoleParentDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.RoleParent))
+}
+
+// Parent roles for RBAC
+type RoleParent struct {
+	DB *gorm.DB
+}
+
+// Query role parents from the database based on the provided parameters and options.
+func (a *RoleParent) Query(ctx context.Context, params schema.RoleParentQueryParam, opts ...schema.RoleParentQueryOptions) (*schema.RoleParentQueryResult, error) {
+	var opt schema.RoleParentQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	db := GetRoleParentDB(ctx, a.DB).Table(fmt.Sprintf("%s AS a", new(schema.RoleParent).TableName()))
+	if opt.JoinParent {
+		db = db.Joins(fmt.Sprintf("left join %s b on a.parent_id=b.id", new(schema.Role).TableName()))
+		db = db.Select("a.*,b.name as parent_name")
+	}
+
+	if v := params.RoleID; len(v) > 0 {
+		db = db.Where("a.role_id = ?", v)
+	}
+	if v := params.ParentID; len(v) > 0 {
+		db = db.Where("a.parent_id = ?", v)
+	}
+
+	var list schema.RoleParents
+	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	queryResult := &schema.RoleParentQueryResult{
+		PageResult: pageResult,
+		Data:       list,
+	}
+	return queryResult, nil
+}
+
+// Create a new role parent.
+func (a *RoleParent) Create(ctx context.Context, item *schema.RoleParent) error {
+	result := GetRoleParentDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+func (a *RoleParent) DeleteByRoleID(ctx context.Context, roleID string) error {
+	result := GetRoleParentDB(ctx, a.DB).Where("role_id=?", roleID).Delete(new(schema.RoleParent))
+	return errors.WithStack(result.Error)
+}
+
+func (a *RoleParent) DeleteByParentID(ctx context.Context, parentID string) error {
+	result := GetRoleParentDB(ctx, a.DB).Where("parent_id=?", parentID).Delete(new(schema.RoleParent))
+	return errors.WithStack(result.Error)
+}
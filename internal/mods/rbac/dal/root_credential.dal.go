@@ -0,0 +1,38 @@
+package dal
+
+import (
+	"context"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get root credential storage instance
+func GetRootCredentialDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.RootCredential))
+}
+
+// Password of the root user stored in the database
+type RootCredential struct {
+	DB *gorm.DB
+}
+
+// Get the credential of the root user from the database, nil if the password is not set yet.
+func (a *RootCredential) Get(ctx context.Context, id string) (*schema.RootCredential, error) {
+	item := new(schema.RootCredential)
+	ok, err := util.FindOne(ctx, GetRootCredentialDB(ctx, a.DB).Where("id=?", id), util.QueryOptions{}, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Create or update the credential of the root user.
+func (a *RootCredential) Save(ctx context.Context, item *schema.RootCredential) error {
+	result := util.GetDB(ctx, a.DB).Save(item)
+	return errors.WithStack(result.Error)
+}
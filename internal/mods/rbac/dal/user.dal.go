@@ -2,7 +2,9 @@ package dal
 
 import (
 	"context"
+	"time"
 
+	orgdal "github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
@@ -36,6 +38,18 @@ func (a *User) Query(ctx context.Context, params schema.UserQueryParam, opts ...
 	if v := params.Status; len(v) > 0 {
 		db = db.Where("status = ?", v)
 	}
+	if v := params.InIDs; len(v) > 0 {
+		db = db.Where("id IN (?)", v)
+	}
+	if params.Locked {
+		db = db.Where("locked_until > ?", time.Now())
+	}
+	if v := params.EmailVerified; v != nil {
+		db = db.Where("email <> '' AND email_verified = ?", *v)
+	}
+	db = orgdal.WithDataScope(ctx, a.DB, db, orgdal.DataScopeColumns{
+		UserColumn: "id",
+	})
 
 	var list schema.Users
 	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
@@ -50,15 +64,18 @@ func (a *User) Query(ctx context.Context, params schema.UserQueryParam, opts ...
 	return queryResult, nil
 }
 
-// Get the specified user from the database.
+// Get the specified user from the database, nil is returned if the user is out of the data scope of the context.
 func (a *User) Get(ctx context.Context, id string, opts ...schema.UserQueryOptions) (*schema.User, error) {
 	var opt schema.UserQueryOptions
 	if len(opts) > 0 {
 		opt = opts[0]
 	}
 
+	db := orgdal.WithDataScope(ctx, a.DB, GetUserDB(ctx, a.DB).Where("id=?", id), orgdal.DataScopeColumns{
+		UserColumn: "id",
+	})
 	item := new(schema.User)
-	ok, err := util.FindOne(ctx, GetUserDB(ctx, a.DB).Where("id=?", id), opt.QueryOptions, item)
+	ok, err := util.FindOne(ctx, db, opt.QueryOptions, item)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	} else if !ok {
@@ -83,9 +100,28 @@ func (a *User) GetByUsername(ctx context.Context, username string, opts ...schem
 	return item, nil
 }
 
-// Exist checks if the specified user exists in the database.
+// Query the users with the specified email from the database.
+func (a *User) QueryByEmail(ctx context.Context, email string, opts ...schema.UserQueryOptions) (schema.Users, error) {
+	var opt schema.UserQueryOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	var list schema.Users
+	db := GetUserDB(ctx, a.DB).Where("email=?", email)
+	if len(opt.SelectFields) > 0 {
+		db = db.Select(opt.SelectFields)
+	}
+	result := db.Find(&list)
+	return list, errors.WithStack(result.Error)
+}
+
+// Exist checks if the specified user exists in the database and in the data scope of the context.
 func (a *User) Exists(ctx context.Context, id string) (bool, error) {
-	ok, err := util.Exists(ctx, GetUserDB(ctx, a.DB).Where("id=?", id))
+	db := orgdal.WithDataScope(ctx, a.DB, GetUserDB(ctx, a.DB).Where("id=?", id), orgdal.DataScopeColumns{
+		UserColumn: "id",
+	})
+	ok, err := util.Exists(ctx, db)
 	return ok, errors.WithStack(err)
 }
 
@@ -122,3 +158,8 @@ func (a *User) UpdatePasswordByID(ctx context.Context, id string, password strin
 	result := GetUserDB(ctx, a.DB).Where("id=?", id).Select("password").Updates(schema.User{Password: password})
 	return errors.WithStack(result.Error)
 }
+
+func (a *User) UpdateLockedUntilByID(ctx context.Context, id string, lockedUntil *time.Time) error {
+	result := GetUserDB(ctx, a.DB).Where("id=?", id).Select("locked_until").Updates(schema.User{LockedUntil: lockedUntil})
+	return errors.WithStack(result.Error)
+}
@@ -0,0 +1,51 @@
+package dal
+
+import (
+	"context"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get user identity storage instance
+func GetUserIdentityDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.UserIdentity))
+}
+
+// External identities of the user
+type UserIdentity struct {
+	DB *gorm.DB
+}
+
+// Get the identity by the provider and subject from the database.
+func (a *UserIdentity) GetBySubject(ctx context.Context, provider, subject string) (*schema.UserIdentity, error) {
+	item := new(schema.UserIdentity)
+	db := GetUserIdentityDB(ctx, a.DB).Where("provider=? AND subject=?", provider, subject)
+	ok, err := util.FindOne(ctx, db, util.QueryOptions{}, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Create a new user identity.
+func (a *UserIdentity) Create(ctx context.Context, item *schema.UserIdentity) error {
+	result := GetUserIdentityDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Update the specified user identity in the database.
+func (a *UserIdentity) Update(ctx context.Context, item *schema.UserIdentity) error {
+	result := GetUserIdentityDB(ctx, a.DB).Where("id=?", item.ID).Select("*").Omit("created_at").Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+// Delete the identities of the specified user from the database.
+func (a *UserIdentity) DeleteByUserID(ctx context.Context, userID string) error {
+	result := GetUserIdentityDB(ctx, a.DB).Where("user_id=?", userID).Delete(new(schema.UserIdentity))
+	return errors.WithStack(result.Error)
+}
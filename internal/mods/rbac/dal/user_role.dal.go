@@ -3,6 +3,7 @@ package dal
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
@@ -27,10 +28,18 @@ func (a *UserRole) Query(ctx context.Context, params schema.UserRoleQueryParam,
 		opt = opts[0]
 	}
 
-	db := a.DB.Table(fmt.Sprintf("%s AS a", new(schema.UserRole).TableName()))
+	db := GetUserRoleDB(ctx, a.DB).Table(fmt.Sprintf("%s AS a", new(schema.UserRole).TableName()))
+	selects := []string{"a.*"}
 	if opt.JoinRole {
 		db = db.Joins(fmt.Sprintf("left join %s b on a.role_id=b.id", new(schema.Role).TableName()))
-		db = db.Select("a.*,b.name as role_name")
+		selects = append(selects, "b.name as role_name")
+	}
+	if opt.JoinUser {
+		db = db.Joins(fmt.Sprintf("left join %s c on a.user_id=c.id", new(schema.User).TableName()))
+		selects = append(selects, "c.username as username")
+	}
+	if len(selects) > 1 {
+		db = db.Select(strings.Join(selects, ","))
 	}
 
 	if v := params.InUserIDs; len(v) > 0 {
@@ -42,6 +51,24 @@ func (a *UserRole) Query(ctx context.Context, params schema.UserRoleQueryParam,
 	if v := params.RoleID; len(v) > 0 {
 		db = db.Where("a.role_id = ?", v)
 	}
+	if v := params.InRoleIDs; len(v) > 0 {
+		db = db.Where("a.role_id IN (?)", v)
+	}
+	if v := params.ActiveAt; v != nil {
+		db = db.Where("(a.starts_at IS NULL OR a.starts_at <= ?) AND (a.expires_at IS NULL OR a.expires_at > ?)", v, v)
+	}
+	if v := params.UnexpiredAt; v != nil {
+		db = db.Where("(a.expires_at IS NULL OR a.expires_at > ?)", v)
+	}
+	if v := params.ExpiresFrom; v != nil {
+		db = db.Where("a.expires_at > ?", v)
+	}
+	if v := params.ExpiresTo; v != nil {
+		db = db.Where("a.expires_at <= ?", v)
+	}
+	if from, to := params.ChangedFrom, params.ChangedTo; from != nil && to != nil {
+		db = db.Where("((a.starts_at > ? AND a.starts_at <= ?) OR (a.expires_at > ? AND a.expires_at <= ?))", from, to, from, to)
+	}
 
 	var list schema.UserRoles
 	pageResult, err := util.WrapPageQuery(ctx, db, params.PaginationParam, opt.QueryOptions, &list)
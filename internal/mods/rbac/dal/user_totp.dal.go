@@ -0,0 +1,69 @@
+package dal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"gorm.io/gorm"
+)
+
+// Get user totp storage instance
+func GetUserTOTPDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
+	return util.GetDB(ctx, defDB).Model(new(schema.UserTOTP))
+}
+
+// TOTP two-factor authentication of the user
+type UserTOTP struct {
+	DB *gorm.DB
+}
+
+// Get the totp of the specified user from the database.
+func (a *UserTOTP) GetByUserID(ctx context.Context, userID string) (*schema.UserTOTP, error) {
+	item := new(schema.UserTOTP)
+	ok, err := util.FindOne(ctx, GetUserTOTPDB(ctx, a.DB).Where("user_id=?", userID), util.QueryOptions{}, item)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	} else if !ok {
+		return nil, nil
+	}
+	return item, nil
+}
+
+// Create a new user totp.
+func (a *UserTOTP) Create(ctx context.Context, item *schema.UserTOTP) error {
+	result := GetUserTOTPDB(ctx, a.DB).Create(item)
+	return errors.WithStack(result.Error)
+}
+
+// Update the specified user totp in the database.
+func (a *UserTOTP) Update(ctx context.Context, item *schema.UserTOTP, selectFields ...string) error {
+	db := GetUserTOTPDB(ctx, a.DB).Where("id=?", item.ID)
+	if len(selectFields) > 0 {
+		db = db.Select(selectFields)
+	} else {
+		db = db.Select("*").Omit("created_at")
+	}
+	result := db.Updates(item)
+	return errors.WithStack(result.Error)
+}
+
+// Delete the totp of the specified user from the database.
+func (a *UserTOTP) DeleteByUserID(ctx context.Context, userID string) error {
+	result := GetUserTOTPDB(ctx, a.DB).Where("user_id=?", userID).Delete(new(schema.UserTOTP))
+	return errors.WithStack(result.Error)
+}
+
+// Check if any enabled role of the user requires the 2FA.
+func (a *UserTOTP) RequiredByRoles(ctx context.Context, userID string) (bool, error) {
+	db := GetUserRoleDB(ctx, a.DB).Table(fmt.Sprintf("%s AS a", new(schema.UserRole).TableName()))
+	db = db.Joins(fmt.Sprintf("inner join %s b on a.role_id=b.id", new(schema.Role).TableName()))
+	db = db.Where("a.user_id=? AND b.require_mfa=? AND b.status=?", userID, true, schema.RoleStatusEnabled)
+	now := time.Now()
+	db = db.Where("(a.starts_at IS NULL OR a.starts_at <= ?) AND (a.expires_at IS NULL OR a.expires_at > ?)", now, now)
+	ok, err := util.Exists(ctx, db)
+	return ok, errors.WithStack(err)
+}
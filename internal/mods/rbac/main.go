@@ -6,21 +6,29 @@ import (
 
 	"github.com/LyricTian/gin-admin/v10/internal/config"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/api"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
 	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 type RBAC struct {
-	DB        *gorm.DB
-	MenuAPI   *api.Menu
-	RoleAPI   *api.Role
-	UserAPI   *api.User
-	LoginAPI  *api.Login
-	LoggerAPI *api.Logger
-	Casbinx   *Casbinx
+	DB                *gorm.DB
+	MenuAPI           *api.Menu
+	RoleAPI           *api.Role
+	RoleConstraintAPI *api.RoleConstraint
+	RoleElevationAPI  *api.RoleElevation
+	UserAPI           *api.User
+	LoginAPI          *api.Login
+	LoggerAPI         *api.Logger
+	PermissionAPI     *api.Permission
+	RouteAPI          *api.Route
+	Casbinx           *Casbinx
+	RoleSweeper       *UserRoleSweeper
+	Tenant            biz.TenantProvider
 }
 
 func (a *RBAC) AutoMigrate(ctx context.Context) error {
@@ -29,8 +37,17 @@ func (a *RBAC) AutoMigrate(ctx context.Context) error {
 		new(schema.MenuResource),
 		new(schema.Role),
 		new(schema.RoleMenu),
+		new(schema.RoleParent),
+		new(schema.RoleConstraint),
+		new(schema.RoleElevation),
 		new(schema.User),
 		new(schema.UserRole),
+		new(schema.UserTOTP),
+		new(schema.UserIdentity),
+		new(schema.APIKey),
+		new(schema.RootCredential),
+		new(schema.CasbinRule),
+		new(schema.CasbinChange),
 	)
 }
 
@@ -41,17 +58,32 @@ func (a *RBAC) Init(ctx context.Context) error {
 		}
 	}
 
-	if err := a.Casbinx.Load(ctx); err != nil {
+	if err := a.LoginAPI.LoginBIZ.RootBIZ.Check(ctx); err != nil {
 		return err
 	}
 
 	if name := config.C.General.MenuFile; name != "" {
+		// Each tenant has its own menus
+		tenantIDs, err := a.Tenant.QueryTenantIDs(ctx)
+		if err != nil {
+			return err
+		}
+
 		fullPath := filepath.Join(config.C.General.WorkDir, name)
-		if err := a.MenuAPI.MenuBIZ.InitFromFile(ctx, fullPath); err != nil {
-			logging.Context(ctx).Error("failed to init menu data", zap.Error(err), zap.String("file", fullPath))
+		for _, tenantID := range append([]string{""}, tenantIDs...) {
+			if err := a.MenuAPI.MenuBIZ.InitFromFile(util.NewTenantID(ctx, tenantID), fullPath); err != nil {
+				logging.Context(ctx).Error("failed to init menu data", zap.Error(err), zap.String("file", fullPath),
+					zap.String("tenant_id", tenantID))
+			}
 		}
 	}
 
+	// The policies are synchronized with the initialized menus on loading
+	if err := a.Casbinx.Load(ctx); err != nil {
+		return err
+	}
+
+	a.RoleSweeper.Start(ctx)
 	return nil
 }
 
@@ -63,6 +95,13 @@ func (a *RBAC) RegisterV1Routers(ctx context.Context, v1 *gin.RouterGroup) error
 	}
 
 	v1.POST("login", a.LoginAPI.Login)
+	v1.POST("login/2fa", a.LoginAPI.LoginMFA)
+	v1.POST("login/2fa/enroll", a.LoginAPI.LoginEnrollMFA)
+	v1.GET("login/oidc", a.LoginAPI.QueryOIDCProviders)
+	v1.GET("login/oidc/:provider", a.LoginAPI.AuthorizeOIDC)
+	v1.POST("login/oidc/:provider/callback", a.LoginAPI.LoginOIDC)
+	v1.POST("password/forgot", a.LoginAPI.ForgotPassword)
+	v1.POST("password/reset", a.LoginAPI.ResetPassword)
 
 	current := v1.Group("current")
 	{
@@ -71,7 +110,26 @@ func (a *RBAC) RegisterV1Routers(ctx context.Context, v1 *gin.RouterGroup) error
 		current.GET("menus", a.LoginAPI.QueryMenus)
 		current.PUT("password", a.LoginAPI.UpdatePassword)
 		current.PUT("user", a.LoginAPI.UpdateUser)
+		current.POST("email/verification", a.LoginAPI.SendEmailVerification)
+		current.PUT("email/verification", a.LoginAPI.ConfirmEmail)
 		current.POST("logout", a.LoginAPI.Logout)
+		current.GET("sessions", a.LoginAPI.QuerySessions)
+		current.DELETE("sessions/:id", a.LoginAPI.RevokeSession)
+		current.GET("2fa", a.LoginAPI.GetMFAStatus)
+		current.POST("2fa", a.LoginAPI.EnrollMFA)
+		current.PUT("2fa", a.LoginAPI.EnableMFA)
+		current.DELETE("2fa", a.LoginAPI.DisableMFA)
+		current.POST("2fa/recovery-codes", a.LoginAPI.RegenerateMFARecoveryCodes)
+		current.GET("api-keys", a.LoginAPI.QueryAPIKeys)
+		current.POST("api-keys", a.LoginAPI.CreateAPIKey)
+		current.DELETE("api-keys/:id", a.LoginAPI.DeleteAPIKey)
+		current.POST("impersonation", a.LoginAPI.Impersonate)
+		current.GET("elevations", a.RoleElevationAPI.QueryMine)
+		current.POST("elevations", a.RoleElevationAPI.Request)
+		current.DELETE("elevations/:id", a.RoleElevationAPI.Cancel)
+		current.GET("elevations/reviews", a.RoleElevationAPI.QueryReviews)
+		current.POST("elevations/:id/approve", a.RoleElevationAPI.Approve)
+		current.POST("elevations/:id/reject", a.RoleElevationAPI.Reject)
 	}
 
 	menu := v1.Group("menus")
@@ -92,14 +150,41 @@ func (a *RBAC) RegisterV1Routers(ctx context.Context, v1 *gin.RouterGroup) error
 		role.DELETE(":id", a.RoleAPI.Delete)
 	}
 
+	roleConstraint := v1.Group("role-constraints")
+	{
+		roleConstraint.GET("", a.RoleConstraintAPI.Query)
+		roleConstraint.GET("violations", a.RoleConstraintAPI.QueryViolations)
+		roleConstraint.GET(":id", a.RoleConstraintAPI.Get)
+		roleConstraint.POST("", a.RoleConstraintAPI.Create)
+		roleConstraint.PUT(":id", a.RoleConstraintAPI.Update)
+		roleConstraint.DELETE(":id", a.RoleConstraintAPI.Delete)
+	}
+
 	user := v1.Group("users")
 	{
 		user.GET("", a.UserAPI.Query)
+		user.GET("expiring-roles", a.UserAPI.QueryExpiringRoles)
 		user.GET(":id", a.UserAPI.Get)
 		user.POST("", a.UserAPI.Create)
 		user.PUT(":id", a.UserAPI.Update)
 		user.DELETE(":id", a.UserAPI.Delete)
 		user.PATCH(":id/reset-pwd", a.UserAPI.ResetPassword)
+		user.GET(":id/sessions", a.UserAPI.QuerySessions)
+		user.DELETE(":id/sessions", a.UserAPI.RevokeSessions)
+		user.DELETE(":id/2fa", a.UserAPI.ResetMFA)
+		user.PATCH(":id/unlock", a.UserAPI.Unlock)
+	}
+
+	resource := v1.Group("resources")
+	{
+		resource.GET("routes", a.RouteAPI.Query)
+	}
+
+	permission := v1.Group("permissions")
+	{
+		permission.GET("explain", a.PermissionAPI.Explain)
+		permission.GET("reports/user", a.PermissionAPI.ReportUser)
+		permission.GET("reports/access", a.PermissionAPI.ReportAccess)
 	}
 
 	logger := v1.Group("loggers")
@@ -114,5 +199,8 @@ func (a *RBAC) Release(ctx context.Context) error {
 	if err := a.Casbinx.Release(ctx); err != nil {
 		return err
 	}
+	if err := a.RoleSweeper.Release(ctx); err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"strings"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+)
+
+// Personal API key of the user, only the hash of the key is stored
+type APIKey struct {
+	ID         string     `json:"id" gorm:"size:20;primarykey;"`             // Unique ID
+	TenantID   string     `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	UserID     string     `json:"user_id" gorm:"size:20;index;"`             // From User.ID
+	Name       string     `json:"name" gorm:"size:64;"`                      // Name of the key
+	Prefix     string     `json:"prefix" gorm:"size:16;"`                    // The first characters of the key (to identify it)
+	KeyHash    string     `json:"-" gorm:"size:64;uniqueIndex;"`             // SHA256 hash of the key
+	Scopes     []string   `json:"scopes" gorm:"size:2048;serializer:json;"`  // Scopes of the key ("*" or "{METHOD} {path}")
+	ExpiresAt  *time.Time `json:"expires_at" gorm:"index;"`                  // Expiration time (never expires if empty)
+	LastUsedAt *time.Time `json:"last_used_at"`                              // Last time the key is used
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:64;"`              // Client IP of the last use
+	CreatedAt  time.Time  `json:"created_at" gorm:"index;"`                  // Create time
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"index;"`                  // Update time
+}
+
+func (a *APIKey) TableName() string {
+	return config.C.FormatTableName("api_key")
+}
+
+func (a *APIKey) IsExpired() bool {
+	return a.ExpiresAt != nil && a.ExpiresAt.Before(time.Now())
+}
+
+// Defining the slice of `APIKey` struct.
+type APIKeys []*APIKey
+
+// Defining the data structure for creating a `APIKey` struct.
+type APIKeyForm struct {
+	Name      string   `json:"name" binding:"required,max=64"`         // Name of the key
+	Scopes    []string `json:"scopes" binding:"required,min=1,max=50"` // Scopes of the key ("*" or "{METHOD} {path}", e.g. "GET /api/v1/users/*")
+	ExpiresIn int      `json:"expires_in" binding:"min=0"`             // Lifetime of the key (days, 0 means the maximum lifetime)
+}
+
+// A validation function for the `APIKeyForm` struct.
+func (a *APIKeyForm) Validate() error {
+	for i, scope := range a.Scopes {
+		scope = strings.TrimSpace(scope)
+		a.Scopes[i] = scope
+		if scope == "*" {
+			continue
+		}
+
+		fields := strings.Fields(scope)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return errors.BadRequest("", "Invalid scope %s, the format is \"{METHOD} {path}\"", scope)
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "*", "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS":
+		default:
+			return errors.BadRequest("", "Invalid method %s of the scope", fields[0])
+		}
+	}
+	return nil
+}
+
+// The created key, the plain key is only returned once
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"` // The API key (Authorization: Bearer {key})
+}
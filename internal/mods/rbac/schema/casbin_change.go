@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+const (
+	CasbinChangeActionAdd    = "add"
+	CasbinChangeActionRemove = "remove"
+)
+
+// Change log of the casbin policy rules, the instances pull the changes in order to apply them incrementally
+type CasbinChange struct {
+	ID        uint64    `json:"id" gorm:"primarykey;autoIncrement;"`    // Increasing version of the change
+	Action    string    `json:"action" gorm:"size:8;"`                  // Action of the change (add, remove)
+	Ptype     string    `json:"ptype" gorm:"size:8;"`                   // Policy type (p, g)
+	Rule      []string  `json:"rule" gorm:"size:1024;serializer:json;"` // Fields of the rule (without the policy type)
+	CreatedAt time.Time `json:"created_at" gorm:"index;"`               // Create time
+}
+
+func (a *CasbinChange) TableName() string {
+	return config.C.FormatTableName("casbin_change")
+}
+
+// Defining the query parameters for the `CasbinChange` struct.
+type CasbinChangeQueryParam struct {
+	util.PaginationParam
+	GtID uint64 `form:"-"` // ID is greater than
+}
+
+// Defining the query options for the `CasbinChange` struct.
+type CasbinChangeQueryOptions struct {
+	util.QueryOptions
+}
+
+// Defining the query result for the `CasbinChange` struct.
+type CasbinChangeQueryResult struct {
+	Data       CasbinChanges
+	PageResult *util.PaginationResult
+}
+
+// Defining the slice of `CasbinChange` struct.
+type CasbinChanges []*CasbinChange
+
+// Append the changes of the rules with the same action and policy type.
+func (a CasbinChanges) Append(action, ptype string, rules ...[]string) CasbinChanges {
+	for _, rule := range rules {
+		a = append(a, &CasbinChange{
+			Action: action,
+			Ptype:  ptype,
+			Rule:   rule,
+		})
+	}
+	return a
+}
@@ -0,0 +1,99 @@
+package schema
+
+import (
+	"strings"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+const (
+	CasbinPtypePolicy   = "p" // Permission policy (role, domain, path, method, effect, condition)
+	CasbinPtypeGrouping = "g" // Grouping policy (role, parent role, domain)
+)
+
+// The casbin domain of the default tenant
+const CasbinDefaultDomain = "default"
+
+// Get the casbin domain of the tenant, the policies of the roles are only effective in the domain of their tenant.
+func CasbinDomain(tenantID string) string {
+	if tenantID == "" {
+		return CasbinDefaultDomain
+	}
+	return tenantID
+}
+
+// Policy rule of casbin, the rules of all roles are loaded by the casbin adapter. The rules are not scoped to the
+// tenants, the tenant of a rule is its domain.
+type CasbinRule struct {
+	ID        string    `json:"id" gorm:"size:20;primarykey;"` // Unique ID
+	Ptype     string    `json:"ptype" gorm:"size:8;"`          // Policy type (p, g)
+	V0        string    `json:"v0" gorm:"size:255;index;"`     // Subject (From Role.ID)
+	V1        string    `json:"v1" gorm:"size:255;index;"`     // Domain or parent role (From Role.ID)
+	V2        string    `json:"v2" gorm:"size:255;"`           // Path or domain
+	V3        string    `json:"v3" gorm:"size:255;"`           // Method
+	V4        string    `json:"v4" gorm:"size:255;"`           // Effect
+	V5        string    `json:"v5" gorm:"size:255;"`           // Condition
+	CreatedAt time.Time `json:"created_at" gorm:"index;"`      // Create time
+}
+
+func (a *CasbinRule) TableName() string {
+	return config.C.FormatTableName("casbin_rule")
+}
+
+// Create a policy rule from the fields of the rule (without the policy type).
+func NewCasbinRule(ptype string, rule []string) *CasbinRule {
+	item := &CasbinRule{Ptype: ptype}
+	for i, v := range []*string{&item.V0, &item.V1, &item.V2, &item.V3, &item.V4, &item.V5} {
+		if i < len(rule) {
+			*v = rule[i]
+		}
+	}
+	return item
+}
+
+// Get the fields of the rule (without the policy type). The permission policies have all fields (the condition may be
+// empty), the empty trailing fields of the other rules are removed.
+func (a *CasbinRule) ToRule() []string {
+	rule := []string{a.V0, a.V1, a.V2, a.V3, a.V4, a.V5}
+	if a.Ptype == CasbinPtypePolicy {
+		return rule
+	}
+	for len(rule) > 0 && rule[len(rule)-1] == "" {
+		rule = rule[:len(rule)-1]
+	}
+	return rule
+}
+
+// Get the unique key of the rule.
+func (a *CasbinRule) Key() string {
+	return CasbinRuleKey(a.Ptype, a.ToRule())
+}
+
+// Get the unique key of the rule with the policy type.
+func CasbinRuleKey(ptype string, rule []string) string {
+	return ptype + ", " + strings.Join(rule, ", ")
+}
+
+// Defining the query parameters for the `CasbinRule` struct.
+type CasbinRuleQueryParam struct {
+	util.PaginationParam
+	Ptype string   `form:"-"` // Policy type (p, g)
+	InV0  []string `form:"-"` // Subject list
+	InV1  []string `form:"-"` // Path or parent role list
+}
+
+// Defining the query options for the `CasbinRule` struct.
+type CasbinRuleQueryOptions struct {
+	util.QueryOptions
+}
+
+// Defining the query result for the `CasbinRule` struct.
+type CasbinRuleQueryResult struct {
+	Data       CasbinRules
+	PageResult *util.PaginationResult
+}
+
+// Defining the slice of `CasbinRule` struct.
+type CasbinRules []*CasbinRule
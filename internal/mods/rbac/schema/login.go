@@ -1,16 +1,24 @@
 package schema
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/go-playground/validator/v10"
+)
 
 type Captcha struct {
-	CaptchaID string `json:"captcha_id"` // Captcha ID
+	CaptchaID   string `json:"captcha_id"`           // Captcha ID
+	CaptchaType string `json:"captcha_type"`         // Captcha type (digits/math/audio/slider)
+	PieceSize   int    `json:"piece_size,omitempty"` // Size of the slider piece (pixels)
+	PieceY      int    `json:"piece_y,omitempty"`    // Y offset of the slider piece (pixels)
 }
 
 type LoginForm struct {
-	Username    string `json:"username" binding:"required"`     // Login name
-	Password    string `json:"password" binding:"required"`     // Login password (md5 hash)
-	CaptchaID   string `json:"captcha_id" binding:"required"`   // Captcha verify id
-	CaptchaCode string `json:"captcha_code" binding:"required"` // Captcha verify code
+	Username    string `json:"username" binding:"required"` // Login name
+	Password    string `json:"password" binding:"required"` // Login password (md5 hash)
+	CaptchaID   string `json:"captcha_id"`                  // Captcha verify id (optional before n failed logins with Util.Captcha.RequireAfterFailures)
+	CaptchaCode string `json:"captcha_code"`                // Captcha verify code (the X offset of the piece for the slider)
 }
 
 func (a *LoginForm) Trim() *LoginForm {
@@ -21,13 +29,46 @@ func (a *LoginForm) Trim() *LoginForm {
 
 type UpdateLoginPassword struct {
 	OldPassword string `json:"old_password" binding:"required"` // Old password (md5 hash)
-	NewPassword string `json:"new_password" binding:"required"` // New password (md5 hash)
+	NewPassword string `json:"new_password" binding:"required"` // New password (md5 hash, or plain text with Security.Password.ClientHashed=false)
+}
+
+type ForgotPasswordForm struct {
+	Account string `json:"account" binding:"required,max=128"` // Username or email of the account
+}
+
+type ResetPasswordForm struct {
+	Token       string `json:"token" binding:"required,max=64"` // Token from the reset email
+	NewPassword string `json:"new_password" binding:"required"` // New password (md5 hash, or plain text with Security.Password.ClientHashed=false)
 }
 
 type LoginToken struct {
-	AccessToken string `json:"access_token"` // Access token (JWT)
-	TokenType   string `json:"token_type"`   // Token type (Usage: Authorization=${token_type} ${access_token})
-	ExpiresAt   int64  `json:"expires_at"`   // Expired time (Unit: second)
+	AccessToken        string   `json:"access_token,omitempty"`         // Access token (JWT)
+	TokenType          string   `json:"token_type,omitempty"`           // Token type (Usage: Authorization=${token_type} ${access_token})
+	ExpiresAt          int64    `json:"expires_at,omitempty"`           // Expired time (Unit: second)
+	RefreshToken       string   `json:"refresh_token,omitempty"`        // Refresh token (opaque, rotated on each refresh)
+	RefreshExpiresAt   int64    `json:"refresh_expires_at,omitempty"`   // Refresh token expired time (Unit: second)
+	MFARequired        bool     `json:"mfa_required,omitempty"`         // The TOTP code is required to complete the login (POST /api/v1/login/2fa)
+	MFAEnroll          bool     `json:"mfa_enroll,omitempty"`           // 2FA is required by the roles but not enrolled (POST /api/v1/login/2fa/enroll first)
+	MFAToken           string   `json:"mfa_token,omitempty"`            // Challenge token for the second step
+	RecoveryCodes      []string `json:"recovery_codes,omitempty"`       // Recovery codes (only when 2FA is enabled during login)
+	MustChangePassword bool     `json:"must_change_password,omitempty"` // The password is reset or expired, only /api/v1/current/* is allowed until it is changed
+}
+
+// Impersonate a user with a time-limited token, which cannot be refreshed
+type ImpersonateForm struct {
+	UserID string `json:"user_id" binding:"required"`        // From User.ID
+	Reason string `json:"reason" binding:"required,max=256"` // Reason of the impersonation (recorded in the audit log)
+}
+
+// Impersonation of the current user, shown by the UI until it ends (logout) or expires
+type UserImpersonation struct {
+	ImpersonatorID   string `json:"impersonator_id"`   // The real user (From User.ID)
+	ImpersonatorName string `json:"impersonator_name"` // From User.Name
+	ExpiresAt        int64  `json:"expires_at"`        // Expired time (Unit: second)
+}
+
+type RefreshTokenForm struct {
+	RefreshToken string `json:"refresh_token" binding:"required"` // Refresh token
 }
 
 type UpdateCurrentUser struct {
@@ -36,3 +77,19 @@ type UpdateCurrentUser struct {
 	Email  string `json:"email" binding:"max=128"`        // Email of user
 	Remark string `json:"remark" binding:"max=1024"`      // Remark of user
 }
+
+// A validation function for the `UpdateCurrentUser` struct.
+func (a *UpdateCurrentUser) Validate() error {
+	if a.Email != "" && validator.New().Var(a.Email, "email") != nil {
+		return errors.BadRequest("", "Invalid email address")
+	}
+	return nil
+}
+
+type UpdateCurrentUserResult struct {
+	PendingEmail string `json:"pending_email,omitempty"` // The new email waits for the confirmation code
+}
+
+type EmailVerificationForm struct {
+	Code string `json:"code" binding:"required,max=16"` // Confirmation code from the email
+}
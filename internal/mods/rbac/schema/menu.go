@@ -24,21 +24,22 @@ var (
 
 // Menu management for RBAC
 type Menu struct {
-	ID          string        `json:"id" gorm:"size:20;primarykey;"`      // Unique ID
-	Code        string        `json:"code" gorm:"size:32;index;"`         // Code of menu (unique for each level)
-	Name        string        `json:"name" gorm:"size:128;index"`         // Display name of menu
-	Description string        `json:"description" gorm:"size:1024"`       // Details about menu
-	Sequence    int           `json:"sequence" gorm:"index;"`             // Sequence for sorting (Order by desc)
-	Type        string        `json:"type" gorm:"size:20;index"`          // Type of menu (page, button)
-	Path        string        `json:"path" gorm:"size:255;"`              // Access path of menu
-	Properties  string        `json:"properties" gorm:"type:text;"`       // Properties of menu (JSON)
-	Status      string        `json:"status" gorm:"size:20;index"`        // Status of menu (enabled, disabled)
-	ParentID    string        `json:"parent_id" gorm:"size:20;index;"`    // Parent ID (From Menu.ID)
-	ParentPath  string        `json:"parent_path" gorm:"size:255;index;"` // Parent path (split by .)
-	Children    *Menus        `json:"children" gorm:"-"`                  // Child menus
-	CreatedAt   time.Time     `json:"created_at" gorm:"index;"`           // Create time
-	UpdatedAt   time.Time     `json:"updated_at" gorm:"index;"`           // Update time
-	Resources   MenuResources `json:"resources" gorm:"-"`                 // Resources of menu
+	ID          string        `json:"id" gorm:"size:20;primarykey;"`             // Unique ID
+	TenantID    string        `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	Code        string        `json:"code" gorm:"size:32;index;"`                // Code of menu (unique for each level)
+	Name        string        `json:"name" gorm:"size:128;index"`                // Display name of menu
+	Description string        `json:"description" gorm:"size:1024"`              // Details about menu
+	Sequence    int           `json:"sequence" gorm:"index;"`                    // Sequence for sorting (Order by desc)
+	Type        string        `json:"type" gorm:"size:20;index"`                 // Type of menu (page, button)
+	Path        string        `json:"path" gorm:"size:255;"`                     // Access path of menu
+	Properties  string        `json:"properties" gorm:"type:text;"`              // Properties of menu (JSON)
+	Status      string        `json:"status" gorm:"size:20;index"`               // Status of menu (enabled, disabled)
+	ParentID    string        `json:"parent_id" gorm:"size:20;index;"`           // Parent ID (From Menu.ID)
+	ParentPath  string        `json:"parent_path" gorm:"size:255;index;"`        // Parent path (split by .)
+	Children    *Menus        `json:"children" gorm:"-"`                         // Child menus
+	CreatedAt   time.Time     `json:"created_at" gorm:"index;"`                  // Create time
+	UpdatedAt   time.Time     `json:"updated_at" gorm:"index;"`                  // Update time
+	Resources   MenuResources `json:"resources" gorm:"-"`                        // Resources of menu
 }
 
 func (a *Menu) TableName() string {
@@ -57,6 +58,7 @@ type MenuQueryParam struct {
 	ParentPathPrefix string   `form:"-"`                // Parent path (split by .)
 	UserID           string   `form:"-"`                // User ID
 	RoleID           string   `form:"-"`                // Role ID
+	InRoleIDs        []string `form:"-"`                // Role IDs
 }
 
 // Defining the query options for the `Menu` struct.
@@ -161,6 +163,13 @@ func (a *MenuForm) Validate() error {
 			return errors.BadRequest("", "invalid properties")
 		}
 	}
+	for _, res := range a.Resources {
+		if res.Effect != "" && res.Effect != MenuResourceEffectAllow && res.Effect != MenuResourceEffectDeny {
+			return errors.BadRequest("", "invalid effect of resource '%s %s'", res.Method, res.Path)
+		} else if len(res.Condition) > 255 {
+			return errors.BadRequest("", "condition of resource '%s %s' is too long", res.Method, res.Path)
+		}
+	}
 	return nil
 }
 
@@ -7,14 +7,30 @@ import (
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 )
 
+const (
+	MenuResourceEffectAllow = "allow" // Allow the request
+	MenuResourceEffectDeny  = "deny"  // Deny the request even if it is allowed by other resources
+)
+
 // Menu resource management for RBAC
 type MenuResource struct {
-	ID        string    `json:"id" gorm:"size:20;primarykey"` // Unique ID
-	MenuID    string    `json:"menu_id" gorm:"size:20;index"` // From Menu.ID
-	Method    string    `json:"method" gorm:"size:20;"`       // HTTP method
-	Path      string    `json:"path" gorm:"size:255;"`        // API request path (e.g. /api/v1/users/:id)
-	CreatedAt time.Time `json:"created_at" gorm:"index;"`     // Create time
-	UpdatedAt time.Time `json:"updated_at" gorm:"index;"`     // Update time
+	ID        string    `json:"id" gorm:"size:20;primarykey"`              // Unique ID
+	TenantID  string    `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	MenuID    string    `json:"menu_id" gorm:"size:20;index"`              // From Menu.ID
+	Method    string    `json:"method" gorm:"size:20;"`                    // HTTP method
+	Path      string    `json:"path" gorm:"size:255;"`                     // API request path (e.g. /api/v1/users/:id)
+	Effect    string    `json:"effect" gorm:"size:20;"`                    // Effect of the resource (allow, deny), empty means allow
+	Condition string    `json:"condition" gorm:"size:255;"`                // Condition on the request attributes (e.g. hour >= 9 && hour < 18), empty means always
+	CreatedAt time.Time `json:"created_at" gorm:"index;"`                  // Create time
+	UpdatedAt time.Time `json:"updated_at" gorm:"index;"`                  // Update time
+}
+
+// Get the effect of the resource for the casbin policy.
+func (a *MenuResource) PolicyEffect() string {
+	if a.Effect == MenuResourceEffectDeny {
+		return MenuResourceEffectDeny
+	}
+	return MenuResourceEffectAllow
 }
 
 func (a *MenuResource) TableName() string {
@@ -0,0 +1,186 @@
+package schema
+
+import (
+	"strconv"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+)
+
+const (
+	PermissionDecisionAllow   = "allow"    // Allowed by a policy
+	PermissionDecisionDeny    = "deny"     // Denied by a policy with the deny effect
+	PermissionDecisionNoMatch = "no_match" // Denied as no policy matches
+	PermissionDecisionRoot    = "root"     // Allowed as the root user (or the administrator of the tenant) is not restricted
+	PermissionDecisionSkipped = "skipped"  // Allowed as the permission is not checked
+)
+
+// Defining the query parameters to explain the permission check of a request.
+type PermissionExplainParam struct {
+	User   string `form:"user" binding:"required"`   // User ID or username
+	Method string `form:"method" binding:"required"` // HTTP method
+	Path   string `form:"path" binding:"required"`   // API request path (e.g. /api/v1/users/1)
+}
+
+// Explanation of the permission check of a request
+type PermissionExplain struct {
+	UserID   string              `json:"user_id"`           // From User.ID
+	Username string              `json:"username"`          // From User.Username
+	Method   string              `json:"method"`            // HTTP method
+	Path     string              `json:"path"`              // API request path
+	Roles    []*PermissionRole   `json:"roles"`             // Roles considered by the check
+	Policies []*PermissionPolicy `json:"policies"`          // Policies matching the request
+	Decision string              `json:"decision"`          // Decision (allow, deny, no_match, root, skipped)
+	Allowed  bool                `json:"allowed"`           // Whether the request is allowed
+	Explain  *PermissionPolicy   `json:"explain,omitempty"` // The policy which decides the result
+}
+
+// Role considered by the permission check
+type PermissionRole struct {
+	ID        string `json:"id"`        // From Role.ID
+	Code      string `json:"code"`      // From Role.Code
+	Name      string `json:"name"`      // From Role.Name
+	Status    string `json:"status"`    // From Role.Status
+	Inherited bool   `json:"inherited"` // Whether the role is inherited from the assigned roles
+}
+
+// Casbin policy of the permission check
+type PermissionPolicy struct {
+	Subject   string `json:"subject"`             // From Role.ID
+	Domain    string `json:"domain"`              // Domain of the tenant
+	Object    string `json:"object"`              // API request path
+	Action    string `json:"action"`              // HTTP method
+	Effect    string `json:"effect"`              // Effect of the policy (allow, deny)
+	Condition string `json:"condition,omitempty"` // Condition on the request attributes (assumed to be met by the checks without a request)
+}
+
+func NewPermissionPolicy(policy []string) *PermissionPolicy {
+	if len(policy) < 4 {
+		return nil
+	}
+
+	item := &PermissionPolicy{
+		Subject: policy[0],
+		Domain:  policy[1],
+		Object:  policy[2],
+		Action:  policy[3],
+		Effect:  MenuResourceEffectAllow,
+	}
+	if len(policy) > 4 {
+		item.Effect = policy[4]
+	}
+	if len(policy) > 5 {
+		item.Condition = policy[5]
+	}
+	return item
+}
+
+const (
+	PermissionReportFormatJSON = "json"
+	PermissionReportFormatCSV  = "csv"
+)
+
+// Defining the query parameters of the effective permissions of a user.
+type PermissionUserReportParam struct {
+	User   string `form:"user" binding:"required"`            // User ID or username
+	Format string `form:"format" binding:"oneof=json csv ''"` // Format of the report (json, csv)
+}
+
+// Effective permissions of a user with the roles which grant them
+type PermissionUserReport struct {
+	UserID       string                `json:"user_id"`      // From User.ID
+	Username     string                `json:"username"`     // From User.Username
+	Unrestricted bool                  `json:"unrestricted"` // The root user (or the administrator of the tenant) is not restricted
+	Roles        []*PermissionRole     `json:"roles"`        // Assigned roles and the roles inherited from them
+	Menus        []*PermissionMenu     `json:"menus"`        // Menus granted by the enabled roles
+	Resources    []*PermissionResource `json:"resources"`    // API resources granted (or denied) by the enabled roles
+}
+
+// Menu granted by a role
+type PermissionMenu struct {
+	MenuID   string `json:"menu_id"`   // From Menu.ID
+	Code     string `json:"code"`      // From Menu.Code
+	Name     string `json:"name"`      // From Menu.Name
+	Type     string `json:"type"`      // From Menu.Type
+	Path     string `json:"path"`      // From Menu.Path
+	RoleID   string `json:"role_id"`   // From Role.ID
+	RoleName string `json:"role_name"` // From Role.Name
+}
+
+// API resource granted (or denied) by a role
+type PermissionResource struct {
+	Method    string `json:"method"`    // HTTP method
+	Path      string `json:"path"`      // API request path
+	Effect    string `json:"effect"`    // Effect of the policy (allow, deny)
+	Effective bool   `json:"effective"` // Whether the request is allowed after all policies of the user are considered
+	RoleID    string `json:"role_id"`   // From Role.ID
+	RoleName  string `json:"role_name"` // From Role.Name
+}
+
+// Convert the report to the CSV rows, the menus and the resources are listed in one table.
+func (a *PermissionUserReport) ToCSV() [][]string {
+	rows := [][]string{{"type", "role_id", "role_name", "menu_code", "menu_name", "method", "path", "effect", "effective"}}
+	for _, item := range a.Menus {
+		rows = append(rows, []string{"menu", item.RoleID, item.RoleName, item.Code, item.Name, "", item.Path, "", ""})
+	}
+	for _, item := range a.Resources {
+		rows = append(rows, []string{"resource", item.RoleID, item.RoleName, "", "", item.Method, item.Path, item.Effect,
+			strconv.FormatBool(item.Effective)})
+	}
+	return rows
+}
+
+// Defining the query parameters of the users and roles which can access a resource or a menu.
+type PermissionAccessReportParam struct {
+	Method string `form:"method"`                             // HTTP method (required with path)
+	Path   string `form:"path"`                               // API request path (e.g. /api/v1/users/{id})
+	MenuID string `form:"menu_id"`                            // From Menu.ID (instead of the resource)
+	Format string `form:"format" binding:"oneof=json csv ''"` // Format of the report (json, csv)
+}
+
+// A validation function for the `PermissionAccessReportParam` struct.
+func (a *PermissionAccessReportParam) Validate() error {
+	if a.MenuID == "" && (a.Method == "" || a.Path == "") {
+		return errors.BadRequest("", "Either the menu or the method and path of the resource is required")
+	}
+	return nil
+}
+
+// Users and roles which can access a resource or a menu, the root user (and the administrator of the tenant) is not
+// listed as it can access everything.
+type PermissionAccessReport struct {
+	Method string            `json:"method,omitempty"`  // HTTP method
+	Path   string            `json:"path,omitempty"`    // API request path
+	MenuID string            `json:"menu_id,omitempty"` // From Menu.ID
+	Roles  []*PermissionRole `json:"roles"`             // Enabled roles which grant the access (inherited if granted by the parent roles)
+	Users  []*PermissionUser `json:"users"`             // Users of the roles
+}
+
+// User who can access by a role
+type PermissionUser struct {
+	UserID   string `json:"user_id"`   // From User.ID
+	Username string `json:"username"`  // From User.Username
+	Name     string `json:"name"`      // From User.Name
+	Status   string `json:"status"`    // From User.Status
+	RoleID   string `json:"role_id"`   // From Role.ID
+	RoleName string `json:"role_name"` // From Role.Name
+}
+
+// Convert the report to the CSV rows, the roles without users are listed with the empty user.
+func (a *PermissionAccessReport) ToCSV() [][]string {
+	rows := [][]string{{"role_id", "role_code", "role_name", "inherited", "user_id", "username", "name", "status"}}
+	users := make(map[string][]*PermissionUser)
+	for _, item := range a.Users {
+		users[item.RoleID] = append(users[item.RoleID], item)
+	}
+	for _, role := range a.Roles {
+		row := []string{role.ID, role.Code, role.Name, strconv.FormatBool(role.Inherited)}
+		if len(users[role.ID]) == 0 {
+			rows = append(rows, append(row, "", "", "", ""))
+			continue
+		}
+		for _, user := range users[role.ID] {
+			rows = append(rows, append(append([]string{}, row...), user.UserID, user.Username, user.Name, user.Status))
+		}
+	}
+	return rows
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 )
 
@@ -12,19 +13,32 @@ const (
 	RoleStatusDisabled = "disabled" // Disabled
 
 	RoleResultTypeSelect = "select" // Select
+
+	RoleDataScopeAll                   = "all"                     // All data
+	RoleDataScopeDepartment            = "department"              // Data of the own departments
+	RoleDataScopeDepartmentAndChildren = "department_and_children" // Data of the own departments and their children
+	RoleDataScopeSelf                  = "self"                    // Data of the user self only
+	RoleDataScopeCustom                = "custom"                  // Data of the specified departments
 )
 
 // Role management for RBAC
 type Role struct {
-	ID          string    `json:"id" gorm:"size:20;primarykey;"` // Unique ID
-	Code        string    `json:"code" gorm:"size:32;index;"`    // Code of role (unique)
-	Name        string    `json:"name" gorm:"size:128;index"`    // Display name of role
-	Description string    `json:"description" gorm:"size:1024"`  // Details about role
-	Sequence    int       `json:"sequence" gorm:"index"`         // Sequence for sorting
-	Status      string    `json:"status" gorm:"size:20;index"`   // Status of role (disabled, enabled)
-	CreatedAt   time.Time `json:"created_at" gorm:"index;"`      // Create time
-	UpdatedAt   time.Time `json:"updated_at" gorm:"index;"`      // Update time
-	Menus       RoleMenus `json:"menus" gorm:"-"`                // Role menu list
+	ID                     string      `json:"id" gorm:"size:20;primarykey;"`                               // Unique ID
+	TenantID               string      `json:"tenant_id" gorm:"size:20;index;default:''"`                   // From Tenant.ID (empty for the default tenant)
+	Code                   string      `json:"code" gorm:"size:32;index;"`                                  // Code of role (unique)
+	Name                   string      `json:"name" gorm:"size:128;index"`                                  // Display name of role
+	Description            string      `json:"description" gorm:"size:1024"`                                // Details about role
+	Sequence               int         `json:"sequence" gorm:"index"`                                       // Sequence for sorting
+	Status                 string      `json:"status" gorm:"size:20;index"`                                 // Status of role (disabled, enabled)
+	RequireMFA             bool        `json:"require_mfa"`                                                 // Whether the users of the role must login with 2FA
+	DataScope              string      `json:"data_scope" gorm:"size:32;"`                                  // Data scope of role (all, department, department_and_children, self, custom)
+	DataScopeDepartmentIDs []string    `json:"data_scope_department_ids" gorm:"type:text;serializer:json;"` // Departments of the custom data scope (From Department.ID)
+	CreatedAt              time.Time   `json:"created_at" gorm:"index;"`                                    // Create time
+	UpdatedAt              time.Time   `json:"updated_at" gorm:"index;"`                                    // Update time
+	Menus                  RoleMenus   `json:"menus" gorm:"-"`                                              // Role menu list
+	Parents                RoleParents `json:"parents" gorm:"-"`                                            // Parent roles, the permissions of the parents are inherited
+	InheritedMenus         RoleMenus   `json:"inherited_menus" gorm:"-"`                                    // Menus inherited from the ancestor roles
+	EffectiveMenus         RoleMenus   `json:"effective_menus" gorm:"-"`                                    // Menus of the role and its ancestor roles
 }
 
 func (a *Role) TableName() string {
@@ -57,16 +71,23 @@ type Roles []*Role
 
 // Defining the data structure for creating a `Role` struct.
 type RoleForm struct {
-	Code        string    `json:"code" binding:"required,max=32"`                   // Code of role (unique)
-	Name        string    `json:"name" binding:"required,max=128"`                  // Display name of role
-	Description string    `json:"description"`                                      // Details about role
-	Sequence    int       `json:"sequence"`                                         // Sequence for sorting
-	Status      string    `json:"status" binding:"required,oneof=disabled enabled"` // Status of role (enabled, disabled)
-	Menus       RoleMenus `json:"menus"`                                            // Role menu list
+	Code                   string      `json:"code" binding:"required,max=32"`                                                   // Code of role (unique)
+	Name                   string      `json:"name" binding:"required,max=128"`                                                  // Display name of role
+	Description            string      `json:"description"`                                                                      // Details about role
+	Sequence               int         `json:"sequence"`                                                                         // Sequence for sorting
+	Status                 string      `json:"status" binding:"required,oneof=disabled enabled"`                                 // Status of role (enabled, disabled)
+	RequireMFA             bool        `json:"require_mfa"`                                                                      // Whether the users of the role must login with 2FA
+	DataScope              string      `json:"data_scope" binding:"oneof=all department department_and_children self custom ''"` // Data scope of role (all, department, department_and_children, self, custom)
+	DataScopeDepartmentIDs []string    `json:"data_scope_department_ids"`                                                        // Departments of the custom data scope (From Department.ID)
+	Menus                  RoleMenus   `json:"menus"`                                                                            // Role menu list
+	Parents                RoleParents `json:"parents"`                                                                          // Parent roles, the permissions of the parents are inherited
 }
 
 // A validation function for the `RoleForm` struct.
 func (a *RoleForm) Validate() error {
+	if a.DataScope == RoleDataScopeCustom && len(a.DataScopeDepartmentIDs) == 0 {
+		return errors.BadRequest("", "Departments are required for the custom data scope")
+	}
 	return nil
 }
 
@@ -76,5 +97,14 @@ func (a *RoleForm) FillTo(role *Role) error {
 	role.Description = a.Description
 	role.Sequence = a.Sequence
 	role.Status = a.Status
+	role.RequireMFA = a.RequireMFA
+	role.DataScope = a.DataScope
+	if role.DataScope == "" {
+		role.DataScope = RoleDataScopeAll
+	}
+	role.DataScopeDepartmentIDs = nil
+	if role.DataScope == RoleDataScopeCustom {
+		role.DataScopeDepartmentIDs = a.DataScopeDepartmentIDs
+	}
 	return nil
 }
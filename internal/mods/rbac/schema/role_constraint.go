@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+const (
+	RoleConstraintTypeExclusive  = "exclusive"   // The roles cannot be held by the same user
+	RoleConstraintTypeMaxHolders = "max_holders" // The role can be held by limited users
+)
+
+// Separation of duties constraint between roles
+type RoleConstraint struct {
+	ID            string    `json:"id" gorm:"size:20;primarykey;"`                // Unique ID
+	TenantID      string    `json:"tenant_id" gorm:"size:20;index;default:''"`    // From Tenant.ID (empty for the default tenant)
+	Type          string    `json:"type" gorm:"size:20;index;"`                   // Type of constraint (exclusive, max_holders)
+	RoleID        string    `json:"role_id" gorm:"size:20;index;"`                // From Role.ID
+	OtherRoleID   string    `json:"other_role_id" gorm:"size:20;index;"`          // From Role.ID (exclusive only)
+	MaxHolders    int       `json:"max_holders"`                                  // Maximum number of the holders (max_holders only)
+	Description   string    `json:"description" gorm:"size:1024;"`                // Details about constraint
+	CreatedAt     time.Time `json:"created_at" gorm:"index;"`                     // Create time
+	UpdatedAt     time.Time `json:"updated_at" gorm:"index;"`                     // Update time
+	RoleName      string    `json:"role_name" gorm:"<-:false;-:migration;"`       // From Role.Name
+	OtherRoleName string    `json:"other_role_name" gorm:"<-:false;-:migration;"` // From Role.Name
+}
+
+func (a *RoleConstraint) TableName() string {
+	return config.C.FormatTableName("role_constraint")
+}
+
+// Defining the query parameters for the `RoleConstraint` struct.
+type RoleConstraintQueryParam struct {
+	util.PaginationParam
+	Type   string `form:"type" binding:"oneof=exclusive max_holders ''"` // Type of constraint (exclusive, max_holders)
+	RoleID string `form:"role_id"`                                       // Either of the roles of constraint (From Role.ID)
+}
+
+// Defining the query options for the `RoleConstraint` struct.
+type RoleConstraintQueryOptions struct {
+	util.QueryOptions
+	JoinRole bool // Join role table
+}
+
+// Defining the query result for the `RoleConstraint` struct.
+type RoleConstraintQueryResult struct {
+	Data       RoleConstraints
+	PageResult *util.PaginationResult
+}
+
+// Defining the slice of `RoleConstraint` struct.
+type RoleConstraints []*RoleConstraint
+
+// Defining the data structure for creating a `RoleConstraint` struct.
+type RoleConstraintForm struct {
+	Type        string `json:"type" binding:"required,oneof=exclusive max_holders"` // Type of constraint (exclusive, max_holders)
+	RoleID      string `json:"role_id" binding:"required"`                          // From Role.ID
+	OtherRoleID string `json:"other_role_id"`                                       // From Role.ID (required for exclusive)
+	MaxHolders  int    `json:"max_holders" binding:"min=0"`                         // Maximum number of the holders (required for max_holders)
+	Description string `json:"description" binding:"max=1024"`                      // Details about constraint
+}
+
+// A validation function for the `RoleConstraintForm` struct.
+func (a *RoleConstraintForm) Validate() error {
+	switch a.Type {
+	case RoleConstraintTypeExclusive:
+		if a.OtherRoleID == "" {
+			return errors.BadRequest("", "The other role is required for the exclusive constraint")
+		} else if a.OtherRoleID == a.RoleID {
+			return errors.BadRequest("", "The role cannot be exclusive with itself")
+		}
+		a.MaxHolders = 0
+	case RoleConstraintTypeMaxHolders:
+		if a.MaxHolders < 1 {
+			return errors.BadRequest("", "The maximum number of the holders must be at least 1")
+		}
+		a.OtherRoleID = ""
+	}
+	return nil
+}
+
+func (a *RoleConstraintForm) FillTo(roleConstraint *RoleConstraint) error {
+	roleConstraint.Type = a.Type
+	roleConstraint.RoleID = a.RoleID
+	roleConstraint.OtherRoleID = a.OtherRoleID
+	roleConstraint.MaxHolders = a.MaxHolders
+	roleConstraint.Description = a.Description
+	return nil
+}
+
+// The users which break a constraint, e.g. the assignments made before the constraint is added
+type RoleConstraintViolation struct {
+	Constraint *RoleConstraint                `json:"constraint"` // The broken constraint
+	Holders    int                            `json:"holders"`    // Number of the holders of the role (max_holders only)
+	Users      []*RoleConstraintViolationUser `json:"users"`      // The users which hold both roles (exclusive), or all holders of the role (max_holders)
+}
+
+// The user which breaks a constraint
+type RoleConstraintViolationUser struct {
+	UserID   string `json:"user_id"`  // From User.ID
+	Username string `json:"username"` // From User.Username
+}
@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+const (
+	RoleElevationStatusPending   = "pending"   // Waiting for the approval
+	RoleElevationStatusApproved  = "approved"  // The role is granted for the window
+	RoleElevationStatusRejected  = "rejected"  // Rejected by the approver
+	RoleElevationStatusCancelled = "cancelled" // Cancelled by the requester
+)
+
+// Request of a role for a bounded window, the role is granted by a time-bounded assignment once approved
+type RoleElevation struct {
+	ID            string     `json:"id" gorm:"size:20;primarykey;"`                    // Unique ID
+	TenantID      string     `json:"tenant_id" gorm:"size:20;index;default:''"`        // From Tenant.ID (empty for the default tenant)
+	UserID        string     `json:"user_id" gorm:"size:20;index;"`                    // The requester (From User.ID)
+	RoleID        string     `json:"role_id" gorm:"size:20;index;"`                    // From Role.ID
+	Duration      int        `json:"duration"`                                         // Requested window (minutes)
+	Justification string     `json:"justification" gorm:"size:1024;"`                  // Why the role is required
+	Status        string     `json:"status" gorm:"size:20;index;"`                     // Status of request (pending, approved, rejected, cancelled)
+	ReviewerID    string     `json:"reviewer_id" gorm:"size:20;"`                      // The approver (From User.ID)
+	ReviewComment string     `json:"review_comment" gorm:"size:1024;"`                 // Comment of the approver
+	ReviewedAt    *time.Time `json:"reviewed_at"`                                      // Review time
+	UserRoleID    string     `json:"user_role_id" gorm:"size:20;"`                     // The assignment made on approval (From UserRole.ID)
+	StartsAt      *time.Time `json:"starts_at" gorm:"index;"`                          // Time when the role takes effect (the approval time)
+	ExpiresAt     *time.Time `json:"expires_at" gorm:"index;"`                         // Time when the role ends
+	CreatedAt     time.Time  `json:"created_at" gorm:"index;"`                         // Create time
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"index;"`                         // Update time
+	Username      string     `json:"username,omitempty" gorm:"<-:false;-:migration;"`  // From User.Username
+	RoleName      string     `json:"role_name,omitempty" gorm:"<-:false;-:migration;"` // From Role.Name
+}
+
+func (a *RoleElevation) TableName() string {
+	return config.C.FormatTableName("role_elevation")
+}
+
+// Defining the query parameters for the `RoleElevation` struct.
+type RoleElevationQueryParam struct {
+	util.PaginationParam
+	Status   string     `form:"status" binding:"oneof=pending approved rejected cancelled ''"` // Status of request (pending, approved, rejected, cancelled)
+	UserID   string     `form:"-"`                                                             // From User.ID
+	RoleID   string     `form:"-"`                                                             // From Role.ID
+	ActiveAt *time.Time `form:"-"`                                                             // Only the approved requests in effect at the time
+}
+
+// Defining the query options for the `RoleElevation` struct.
+type RoleElevationQueryOptions struct {
+	util.QueryOptions
+	JoinUser bool // Join user table
+	JoinRole bool // Join role table
+}
+
+// Defining the query result for the `RoleElevation` struct.
+type RoleElevationQueryResult struct {
+	Data       RoleElevations
+	PageResult *util.PaginationResult
+}
+
+// Defining the slice of `RoleElevation` struct.
+type RoleElevations []*RoleElevation
+
+func (a RoleElevations) ToRoleIDs() []string {
+	var ids []string
+	for _, item := range a {
+		ids = append(ids, item.RoleID)
+	}
+	return ids
+}
+
+// Defining the data structure for requesting a `RoleElevation` struct.
+type RoleElevationForm struct {
+	RoleID        string `json:"role_id" binding:"required"`                // From Role.ID
+	Duration      int    `json:"duration" binding:"required,min=1"`         // Requested window (minutes)
+	Justification string `json:"justification" binding:"required,max=1024"` // Why the role is required (recorded in the audit log)
+}
+
+// A validation function for the `RoleElevationForm` struct.
+func (a *RoleElevationForm) Validate() error {
+	if maxDuration := config.C.Security.Elevation.MaxDuration; maxDuration > 0 && a.Duration > maxDuration {
+		return errors.BadRequest("", "The window of the elevation cannot exceed %d minutes", maxDuration)
+	}
+	return nil
+}
+
+func (a *RoleElevationForm) FillTo(roleElevation *RoleElevation) error {
+	roleElevation.RoleID = a.RoleID
+	roleElevation.Duration = a.Duration
+	roleElevation.Justification = a.Justification
+	return nil
+}
+
+// Defining the data structure for approving or rejecting a `RoleElevation` struct.
+type RoleElevationReviewForm struct {
+	Comment string `json:"comment" binding:"max=1024"` // Comment of the approver
+}
@@ -9,11 +9,12 @@ import (
 
 // Role permissions for RBAC
 type RoleMenu struct {
-	ID        string    `json:"id" gorm:"size:20;primarykey"` // Unique ID
-	RoleID    string    `json:"role_id" gorm:"size:20;index"` // From Role.ID
-	MenuID    string    `json:"menu_id" gorm:"size:20;index"` // From Menu.ID
-	CreatedAt time.Time `json:"created_at" gorm:"index;"`     // Create time
-	UpdatedAt time.Time `json:"updated_at" gorm:"index;"`     // Update time
+	ID        string    `json:"id" gorm:"size:20;primarykey"`              // Unique ID
+	TenantID  string    `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	RoleID    string    `json:"role_id" gorm:"size:20;index"`              // From Role.ID
+	MenuID    string    `json:"menu_id" gorm:"size:20;index"`              // From Menu.ID
+	CreatedAt time.Time `json:"created_at" gorm:"index;"`                  // Create time
+	UpdatedAt time.Time `json:"updated_at" gorm:"index;"`                  // Update time
 }
 
 func (a *RoleMenu) TableName() string {
@@ -23,7 +24,9 @@ func (a *RoleMenu) TableName() string {
 // Defining the query parameters for the `RoleMenu` struct.
 type RoleMenuQueryParam struct {
 	util.PaginationParam
-	RoleID string `form:"-"` // From Role.ID
+	RoleID    string   `form:"-"` // From Role.ID
+	InRoleIDs []string `form:"-"` // From Role.ID
+	InMenuIDs []string `form:"-"` // From Menu.ID
 }
 
 // Defining the query options for the `RoleMenu` struct.
@@ -40,6 +43,36 @@ type RoleMenuQueryResult struct {
 // Defining the slice of `RoleMenu` struct.
 type RoleMenus []*RoleMenu
 
+// Merge the role menus and remove the duplicated menus.
+func (a RoleMenus) Merge(list RoleMenus) RoleMenus {
+	menus := make(RoleMenus, 0, len(a)+len(list))
+	seen := make(map[string]bool)
+	for _, items := range []RoleMenus{a, list} {
+		for _, item := range items {
+			if seen[item.MenuID] {
+				continue
+			}
+			seen[item.MenuID] = true
+			menus = append(menus, item)
+		}
+	}
+	return menus
+}
+
+// Get the distinct role IDs of the role menus.
+func (a RoleMenus) ToRoleIDs() []string {
+	roleIDs := make([]string, 0, len(a))
+	seen := make(map[string]bool)
+	for _, item := range a {
+		if seen[item.RoleID] {
+			continue
+		}
+		seen[item.RoleID] = true
+		roleIDs = append(roleIDs, item.RoleID)
+	}
+	return roleIDs
+}
+
 // Defining the data structure for creating a `RoleMenu` struct.
 type RoleMenuForm struct {
 }
@@ -52,3 +85,17 @@ func (a *RoleMenuForm) Validate() error {
 func (a *RoleMenuForm) FillTo(roleMenu *RoleMenu) error {
 	return nil
 }
+
+// Get the distinct menu IDs of the role menus.
+func (a RoleMenus) ToMenuIDs() []string {
+	menuIDs := make([]string, 0, len(a))
+	seen := make(map[string]bool)
+	for _, item := range a {
+		if seen[item.MenuID] {
+			continue
+		}
+		seen[item.MenuID] = true
+		menuIDs = append(menuIDs, item.MenuID)
+	}
+	return menuIDs
+}
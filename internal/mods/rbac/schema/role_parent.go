@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+// Parent roles for RBAC, the role inherits all permissions of the parent roles
+type RoleParent struct {
+	ID         string    `json:"id" gorm:"size:20;primarykey"`              // Unique ID
+	TenantID   string    `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	RoleID     string    `json:"role_id" gorm:"size:20;index"`              // From Role.ID
+	ParentID   string    `json:"parent_id" gorm:"size:20;index"`            // From Role.ID
+	CreatedAt  time.Time `json:"created_at" gorm:"index;"`                  // Create time
+	UpdatedAt  time.Time `json:"updated_at" gorm:"index;"`                  // Update time
+	ParentName string    `json:"parent_name" gorm:"<-:false;-:migration;"`  // From Role.Name
+}
+
+func (a *RoleParent) TableName() string {
+	return config.C.FormatTableName("role_parent")
+}
+
+// Defining the query parameters for the `RoleParent` struct.
+type RoleParentQueryParam struct {
+	util.PaginationParam
+	RoleID   string `form:"-"` // From Role.ID
+	ParentID string `form:"-"` // From Role.ID
+}
+
+// Defining the query options for the `RoleParent` struct.
+type RoleParentQueryOptions struct {
+	util.QueryOptions
+	JoinParent bool // Join role table of the parent
+}
+
+// Defining the query result for the `RoleParent` struct.
+type RoleParentQueryResult struct {
+	Data       RoleParents
+	PageResult *util.PaginationResult
+}
+
+// Defining the slice of `RoleParent` struct.
+type RoleParents []*RoleParent
+
+func (a RoleParents) ToParentIDs() []string {
+	var ids []string
+	for _, item := range a {
+		ids = append(ids, item.ParentID)
+	}
+	return ids
+}
+
+// Get the IDs of all ancestors of the roles by the parent relations, the roles self are not included.
+func (a RoleParents) AncestorIDs(roleIDs ...string) []string {
+	parents := make(map[string][]string)
+	for _, item := range a {
+		parents[item.RoleID] = append(parents[item.RoleID], item.ParentID)
+	}
+
+	visited := make(map[string]bool)
+	for _, roleID := range roleIDs {
+		visited[roleID] = true
+	}
+
+	var ids []string
+	queue := append([]string{}, roleIDs...)
+	for len(queue) > 0 {
+		roleID := queue[0]
+		queue = queue[1:]
+		for _, parentID := range parents[roleID] {
+			if visited[parentID] {
+				continue
+			}
+			visited[parentID] = true
+			ids = append(ids, parentID)
+			queue = append(queue, parentID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,20 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+)
+
+// Password of the root user set by the CLI or the root user, which overrides the one in the configuration
+type RootCredential struct {
+	ID        string    `json:"id" gorm:"size:20;primarykey;"`             // From General.Root.ID
+	TenantID  string    `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	Password  string    `json:"-" gorm:"size:64;"`                         // Bcrypt hash of the password
+	CreatedAt time.Time `json:"created_at"`                                // Create time
+	UpdatedAt time.Time `json:"updated_at"`                                // Update time
+}
+
+func (a *RootCredential) TableName() string {
+	return config.C.FormatTableName("root_credential")
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"strings"
+
+	casbinutil "github.com/casbin/casbin/v2/util"
+)
+
+// API route registered on the HTTP server, which is referenced by the menu resources
+type Route struct {
+	Method    string `json:"method"`    // HTTP method
+	Path      string `json:"path"`      // API request path in the format of the menu resources (e.g. /api/v1/users/{id})
+	Handler   string `json:"handler"`   // Name of the handler function
+	Summary   string `json:"summary"`   // Summary of the API from the swagger document
+	Protected bool   `json:"protected"` // Whether the permission of the route is checked by casbin
+	Covered   bool   `json:"covered"`   // Whether the route is covered by any menu resource
+}
+
+// Check the route matches the path pattern of the menu resource.
+func (a *Route) Match(method, path string) bool {
+	if !strings.EqualFold(a.Method, method) {
+		return false
+	}
+	return casbinutil.KeyMatch2(a.Path, path) || casbinutil.KeyMatch3(a.Path, path)
+}
+
+// Defining the query parameters for the `Route` struct.
+type RouteQueryParam struct {
+	Method    string `form:"method"`    // HTTP method
+	LikePath  string `form:"path"`      // Path of the route
+	Protected bool   `form:"protected"` // Only the routes checked by casbin
+	Uncovered bool   `form:"uncovered"` // Only the routes not covered by any menu resource
+}
+
+// Defining the slice of `Route` struct.
+type Routes []*Route
+
+// Check any route matches the path pattern of the menu resource.
+func (a Routes) Match(method, path string) bool {
+	for _, item := range a {
+		if item.Match(method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert the path of the gin route (e.g. /users/:id) to the format of the menu resources (e.g. /users/{id}).
+func FormatRoutePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		} else if strings.HasPrefix(segment, "*") {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,37 @@
+package schema
+
+import "github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+
+// Active login session of a user (one per login, kept across token refreshes)
+type UserSession struct {
+	ID         string `json:"id"`           // Unique ID of the session
+	Device     string `json:"device"`       // Device of the client (e.g. Chrome on Windows)
+	IP         string `json:"ip"`           // Last seen client IP
+	UserAgent  string `json:"user_agent"`   // User agent of the client
+	Actor      string `json:"actor"`        // The real user of an impersonation session (From User.ID)
+	CreatedAt  int64  `json:"created_at"`   // Login time (Unit: second)
+	LastSeenAt int64  `json:"last_seen_at"` // Last seen time (Unit: second)
+	ExpiresAt  int64  `json:"expires_at"`   // Expired time (Unit: second)
+	Current    bool   `json:"current"`      // Whether the session is the one of the current request
+}
+
+// Defining the slice of `UserSession` struct.
+type UserSessions []*UserSession
+
+func NewUserSessions(sessions []*jwtx.Session, currentID string) UserSessions {
+	list := make(UserSessions, 0, len(sessions))
+	for _, item := range sessions {
+		list = append(list, &UserSession{
+			ID:         item.ID,
+			Device:     item.Device,
+			IP:         item.IP,
+			UserAgent:  item.UserAgent,
+			Actor:      item.Actor,
+			CreatedAt:  item.CreatedAt,
+			LastSeenAt: item.LastSeenAt,
+			ExpiresAt:  item.ExpiresAt,
+			Current:    item.ID == currentID,
+		})
+	}
+	return list
+}
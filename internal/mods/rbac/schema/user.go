@@ -4,7 +4,7 @@ import (
 	"time"
 
 	"github.com/LyricTian/gin-admin/v10/internal/config"
-	"github.com/LyricTian/gin-admin/v10/pkg/crypto/hash"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 	"github.com/go-playground/validator/v10"
@@ -15,31 +15,91 @@ const (
 	UserStatusFreezed   = "freezed"
 )
 
+const (
+	UserSourceLocal = "local"
+	UserSourceOIDC  = "oidc" // Provisioned by the OIDC login (oidc:{provider})
+	UserSourceLDAP  = "ldap" // Authenticated by LDAP, the password is managed by the directory
+)
+
 // User management for RBAC
 type User struct {
-	ID        string    `json:"id" gorm:"size:20;primarykey;"` // Unique ID
-	Username  string    `json:"username" gorm:"size:64;index"` // Username for login
-	Name      string    `json:"name" gorm:"size:64;index"`     // Name of user
-	Password  string    `json:"-" gorm:"size:64;"`             // Password for login (encrypted)
-	Phone     string    `json:"phone" gorm:"size:32;"`         // Phone number of user
-	Email     string    `json:"email" gorm:"size:128;"`        // Email of user
-	Remark    string    `json:"remark" gorm:"size:1024;"`      // Remark of user
-	Status    string    `json:"status" gorm:"size:20;index"`   // Status of user (activated, freezed)
-	CreatedAt time.Time `json:"created_at" gorm:"index;"`      // Create time
-	UpdatedAt time.Time `json:"updated_at" gorm:"index;"`      // Update time
-	Roles     UserRoles `json:"roles" gorm:"-"`                // Roles of user
+	ID                 string             `json:"id" gorm:"size:20;primarykey;"`             // Unique ID
+	TenantID           string             `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	Username           string             `json:"username" gorm:"size:64;index"`             // Username for login
+	Name               string             `json:"name" gorm:"size:64;index"`                 // Name of user
+	Password           string             `json:"-" gorm:"size:64;"`                         // Password for login (encrypted)
+	Phone              string             `json:"phone" gorm:"size:32;"`                     // Phone number of user
+	Email              string             `json:"email" gorm:"size:128;"`                    // Email of user
+	EmailVerified      bool               `json:"email_verified" gorm:"index;"`              // The email is confirmed by the user (or the identity provider)
+	Remark             string             `json:"remark" gorm:"size:1024;"`                  // Remark of user
+	Status             string             `json:"status" gorm:"size:20;index"`               // Status of user (activated, freezed)
+	Source             string             `json:"source" gorm:"size:64;index;"`              // Source of user (local, ldap, oidc:{provider})
+	LockedUntil        *time.Time         `json:"locked_until,omitempty" gorm:"index;"`      // Locked by failed logins until
+	PasswordUpdatedAt  *time.Time         `json:"password_updated_at,omitempty"`             // Last time the user changed the password
+	PasswordReset      bool               `json:"-"`                                         // The password is set by the administrator, must be changed on the next login
+	PasswordHistory    string             `json:"-" gorm:"type:text;"`                       // Hashes of the recent passwords (JSON array)
+	MustChangePassword bool               `json:"must_change_password,omitempty" gorm:"-"`   // The password is reset or expired, must be changed
+	CreatedAt          time.Time          `json:"created_at" gorm:"index;"`                  // Create time
+	UpdatedAt          time.Time          `json:"updated_at" gorm:"index;"`                  // Update time
+	Roles              UserRoles          `json:"roles" gorm:"-"`                            // Roles of user
+	Impersonation      *UserImpersonation `json:"impersonation,omitempty" gorm:"-"`          // The current user is impersonated (only for the current user)
 }
 
 func (a *User) TableName() string {
 	return config.C.FormatTableName("user")
 }
 
+// Check whether the user is managed locally (not provisioned by an identity provider).
+func (a *User) IsLocal() bool {
+	return a.Source == "" || a.Source == UserSourceLocal
+}
+
+// Check whether the password is reset by the administrator or expired.
+func (a *User) IsPasswordChangeRequired() bool {
+	if !a.IsLocal() {
+		return false
+	} else if a.PasswordReset {
+		return true
+	}
+
+	maxAge := config.C.Security.Password.MaxAge
+	if maxAge <= 0 {
+		return false
+	}
+
+	updatedAt := a.CreatedAt
+	if a.PasswordUpdatedAt != nil {
+		updatedAt = *a.PasswordUpdatedAt
+	}
+	return time.Since(updatedAt) > time.Duration(maxAge)*24*time.Hour
+}
+
+func (a *User) GetPasswordHistory() []string {
+	var list []string
+	if a.PasswordHistory != "" {
+		_ = json.Unmarshal([]byte(a.PasswordHistory), &list)
+	}
+	return list
+}
+
+// Record the password hash, only the last n hashes are kept.
+func (a *User) AddPasswordHistory(hashedPassword string, size int) {
+	list := append([]string{hashedPassword}, a.GetPasswordHistory()...)
+	if len(list) > size {
+		list = list[:size]
+	}
+	a.PasswordHistory = json.MarshalToString(list)
+}
+
 // Defining the query parameters for the `User` struct.
 type UserQueryParam struct {
 	util.PaginationParam
-	LikeUsername string `form:"username"`                                    // Username for login
-	LikeName     string `form:"name"`                                        // Name of user
-	Status       string `form:"status" binding:"oneof=activated freezed ''"` // Status of user (activated, freezed)
+	LikeUsername  string   `form:"username"`                                    // Username for login
+	LikeName      string   `form:"name"`                                        // Name of user
+	Status        string   `form:"status" binding:"oneof=activated freezed ''"` // Status of user (activated, freezed)
+	Locked        bool     `form:"locked"`                                      // Locked by failed logins
+	EmailVerified *bool    `form:"email_verified"`                              // The email is verified or not (users without email are excluded)
+	InIDs         []string `form:"-"`                                           // ID list
 }
 
 // Defining the query options for the `User` struct.
@@ -68,7 +128,7 @@ func (a Users) ToIDs() []string {
 type UserForm struct {
 	Username string    `json:"username" binding:"required,max=64"`                // Username for login
 	Name     string    `json:"name" binding:"required,max=64"`                    // Name of user
-	Password string    `json:"password" binding:"max=64"`                         // Password for login (md5 hash)
+	Password string    `json:"password" binding:"max=64"`                         // Password for login (md5 hash, or plain text with Security.Password.ClientHashed=false)
 	Phone    string    `json:"phone" binding:"max=32"`                            // Phone number of user
 	Email    string    `json:"email" binding:"max=128"`                           // Email of user
 	Remark   string    `json:"remark" binding:"max=1024"`                         // Remark of user
@@ -81,7 +141,7 @@ func (a *UserForm) Validate() error {
 	if a.Email != "" && validator.New().Var(a.Email, "email") != nil {
 		return errors.BadRequest("", "Invalid email address")
 	}
-	return nil
+	return a.Roles.Validate()
 }
 
 // Convert `UserForm` to `User` object.
@@ -89,17 +149,12 @@ func (a *UserForm) FillTo(user *User) error {
 	user.Username = a.Username
 	user.Name = a.Name
 	user.Phone = a.Phone
-	user.Email = a.Email
+	if user.Email != a.Email {
+		// The email set by the administrator is not verified
+		user.Email = a.Email
+		user.EmailVerified = false
+	}
 	user.Remark = a.Remark
 	user.Status = a.Status
-
-	if pass := a.Password; pass != "" {
-		hashPass, err := hash.GeneratePassword(pass)
-		if err != nil {
-			return errors.BadRequest("", "Failed to generate hash password: %s", err.Error())
-		}
-		user.Password = hashPass
-	}
-
 	return nil
 }
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+)
+
+// External identity (subject of the identity provider) linked to the user
+type UserIdentity struct {
+	ID        string    `json:"id" gorm:"size:20;primarykey;"`                                        // Unique ID
+	TenantID  string    `json:"tenant_id" gorm:"size:20;uniqueIndex:idx_provider_subject;default:''"` // From Tenant.ID (empty for the default tenant)
+	UserID    string    `json:"user_id" gorm:"size:20;index;"`                                        // From User.ID
+	Provider  string    `json:"provider" gorm:"size:64;uniqueIndex:idx_provider_subject"`             // Name of the identity provider
+	Subject   string    `json:"subject" gorm:"size:255;uniqueIndex:idx_provider_subject"`             // Subject (sub claim) of the identity provider
+	Email     string    `json:"email" gorm:"size:128;"`                                               // Email claim of the last login
+	Name      string    `json:"name" gorm:"size:128;"`                                                // Name claim of the last login
+	CreatedAt time.Time `json:"created_at" gorm:"index;"`                                             // Create time
+	UpdatedAt time.Time `json:"updated_at" gorm:"index;"`                                             // Update time
+}
+
+func (a *UserIdentity) TableName() string {
+	return config.C.FormatTableName("user_identity")
+}
+
+// Authorization URL of the external login
+type LoginOIDCAuthorization struct {
+	AuthURL string `json:"auth_url"` // Redirect the user agent to the URL
+	State   string `json:"state"`    // Returned with the code to the redirect URL
+}
+
+// Identity provider of the external login
+type LoginOIDCProvider struct {
+	Name        string `json:"name"`         // Unique name of the provider
+	DisplayName string `json:"display_name"` // Display name of the provider
+}
+
+type LoginOIDCCallbackForm struct {
+	Code  string `json:"code" binding:"required"`  // Authorization code
+	State string `json:"state" binding:"required"` // State of the authorization request
+}
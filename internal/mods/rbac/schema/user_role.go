@@ -4,17 +4,22 @@ import (
 	"time"
 
 	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 )
 
 // User roles for RBAC
 type UserRole struct {
-	ID        string    `json:"id" gorm:"size:20;primarykey"`           // Unique ID
-	UserID    string    `json:"user_id" gorm:"size:20;index"`           // From User.ID
-	RoleID    string    `json:"role_id" gorm:"size:20;index"`           // From Role.ID
-	CreatedAt time.Time `json:"created_at" gorm:"index;"`               // Create time
-	UpdatedAt time.Time `json:"updated_at" gorm:"index;"`               // Update time
-	RoleName  string    `json:"role_name" gorm:"<-:false;-:migration;"` // From Role.Name
+	ID        string     `json:"id" gorm:"size:20;primarykey"`                    // Unique ID
+	TenantID  string     `json:"tenant_id" gorm:"size:20;index;default:''"`       // From Tenant.ID (empty for the default tenant)
+	UserID    string     `json:"user_id" gorm:"size:20;index"`                    // From User.ID
+	RoleID    string     `json:"role_id" gorm:"size:20;index"`                    // From Role.ID
+	StartsAt  *time.Time `json:"starts_at" gorm:"index;"`                         // Time when the role takes effect (immediately if empty)
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index;"`                        // Time when the role ends (never if empty)
+	CreatedAt time.Time  `json:"created_at" gorm:"index;"`                        // Create time
+	UpdatedAt time.Time  `json:"updated_at" gorm:"index;"`                        // Update time
+	RoleName  string     `json:"role_name" gorm:"<-:false;-:migration;"`          // From Role.Name
+	Username  string     `json:"username,omitempty" gorm:"<-:false;-:migration;"` // From User.Username
 }
 
 func (a *UserRole) TableName() string {
@@ -24,15 +29,29 @@ func (a *UserRole) TableName() string {
 // Defining the query parameters for the `UserRole` struct.
 type UserRoleQueryParam struct {
 	util.PaginationParam
-	InUserIDs []string `form:"-"` // From User.ID
-	UserID    string   `form:"-"` // From User.ID
-	RoleID    string   `form:"-"` // From Role.ID
+	InUserIDs   []string   `form:"-"` // From User.ID
+	UserID      string     `form:"-"` // From User.ID
+	RoleID      string     `form:"-"` // From Role.ID
+	InRoleIDs   []string   `form:"-"` // From Role.ID
+	ActiveAt    *time.Time `form:"-"` // Only the roles in effect at the time
+	UnexpiredAt *time.Time `form:"-"` // Only the roles which do not end before the time (including the future ones)
+	ExpiresFrom *time.Time `form:"-"` // Only the roles which end after the time
+	ExpiresTo   *time.Time `form:"-"` // Only the roles which end before the time
+	ChangedFrom *time.Time `form:"-"` // Only the roles which start or end after the time
+	ChangedTo   *time.Time `form:"-"` // Only the roles which start or end before the time (inclusive)
+}
+
+// Defining the query parameters for the roles which end soon.
+type ExpiringUserRoleQueryParam struct {
+	util.PaginationParam
+	Days int `form:"days" binding:"min=0,max=365"` // The roles which end in the days (default 7)
 }
 
 // Defining the query options for the `UserRole` struct.
 type UserRoleQueryOptions struct {
 	util.QueryOptions
 	JoinRole bool // Join role table
+	JoinUser bool // Join user table
 }
 
 // Defining the query result for the `UserRole` struct.
@@ -52,6 +71,16 @@ func (a UserRoles) ToUserIDMap() map[string]UserRoles {
 	return m
 }
 
+// Check the time ranges of the roles.
+func (a UserRoles) Validate() error {
+	for _, item := range a {
+		if item.StartsAt != nil && item.ExpiresAt != nil && !item.ExpiresAt.After(*item.StartsAt) {
+			return errors.BadRequest("", "The expiration time of the role must be after its start time")
+		}
+	}
+	return nil
+}
+
 func (a UserRoles) ToRoleIDs() []string {
 	var ids []string
 	for _, item := range a {
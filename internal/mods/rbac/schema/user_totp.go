@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+)
+
+// TOTP two-factor authentication of the user
+type UserTOTP struct {
+	ID            string     `json:"id" gorm:"size:20;primarykey;"`             // Unique ID
+	TenantID      string     `json:"tenant_id" gorm:"size:20;index;default:''"` // From Tenant.ID (empty for the default tenant)
+	UserID        string     `json:"user_id" gorm:"size:20;index;"`             // From User.ID
+	Secret        string     `json:"-" gorm:"size:64;"`                         // Base32 encoded secret
+	Enabled       bool       `json:"enabled" gorm:"index;"`                     // Whether the enrollment is verified
+	RecoveryCodes string     `json:"-" gorm:"size:2048;"`                       // SHA256 hashes of the unused recovery codes (comma separated)
+	LastCounter   int64      `json:"-"`                                         // Time step of the last accepted code (replay protection)
+	EnabledAt     *time.Time `json:"enabled_at"`                                // Enable time
+	CreatedAt     time.Time  `json:"created_at" gorm:"index;"`                  // Create time
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"index;"`                  // Update time
+}
+
+func (a *UserTOTP) TableName() string {
+	return config.C.FormatTableName("user_totp")
+}
+
+// Hash a recovery code, only the hashes are stored
+func HashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *UserTOTP) SetRecoveryCodes(codes []string) {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = HashRecoveryCode(code)
+	}
+	a.RecoveryCodes = strings.Join(hashes, ",")
+}
+
+func (a *UserTOTP) RecoveryCodeHashes() []string {
+	if a.RecoveryCodes == "" {
+		return nil
+	}
+	return strings.Split(a.RecoveryCodes, ",")
+}
+
+// Consume a recovery code, returns false if the code is not found (or used).
+func (a *UserTOTP) UseRecoveryCode(code string) bool {
+	hashed := HashRecoveryCode(code)
+	hashes := a.RecoveryCodeHashes()
+	for i, h := range hashes {
+		if h == hashed {
+			a.RecoveryCodes = strings.Join(append(hashes[:i], hashes[i+1:]...), ",")
+			return true
+		}
+	}
+	return false
+}
+
+// Status of the TOTP two-factor authentication of the current user
+type UserTOTPStatus struct {
+	Enabled                bool       `json:"enabled"`                  // Whether the 2FA is enabled
+	Required               bool       `json:"required"`                 // Whether the 2FA is required by the roles of the user
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`     // Enable time
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"` // Number of unused recovery codes
+}
+
+// Secret for the authenticator app, the enrollment must be verified with a code to take effect
+type UserTOTPEnrollment struct {
+	Secret          string `json:"secret"`           // Base32 encoded secret (for manual entry)
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI, render it as a QR code
+}
+
+// One-time recovery codes, only shown once
+type UserTOTPRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"` // Recovery codes
+}
+
+type UserTOTPCodeForm struct {
+	Code string `json:"code" binding:"required,max=32"` // TOTP code (or a recovery code)
+}
+
+type LoginMFAForm struct {
+	MFAToken string `json:"mfa_token" binding:"required"`   // Challenge token from the login response
+	Code     string `json:"code" binding:"required,max=32"` // TOTP code (or a recovery code)
+}
+
+type LoginMFAEnrollForm struct {
+	MFAToken string `json:"mfa_token" binding:"required"` // Challenge token from the login response
+}
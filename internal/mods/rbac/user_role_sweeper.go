@@ -0,0 +1,56 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// Sweep the time-bounded role assignments periodically, the caches of the users are cleared when their roles start or
+// end so that the roles in effect are reloaded.
+type UserRoleSweeper struct {
+	ticker  *time.Ticker  `wire:"-"`
+	done    chan struct{} `wire:"-"`
+	UserBIZ *biz.User
+}
+
+func (a *UserRoleSweeper) Start(ctx context.Context) {
+	interval := config.C.Dictionary.UserRoleSweepInterval
+	if interval <= 0 {
+		return
+	}
+
+	a.ticker = time.NewTicker(time.Duration(interval) * time.Second)
+	a.done = make(chan struct{})
+	go func(ticker *time.Ticker, done <-chan struct{}) {
+		// The first sweep covers the roles which started or ended while the instance was stopped
+		var lastSwept time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			now := time.Now()
+			if err := a.UserBIZ.ClearRoleChangedCaches(ctx, lastSwept, now); err != nil {
+				logging.Context(ctx).Error("Failed to sweep the time-bounded roles", zap.Error(err))
+				continue
+			}
+			lastSwept = now
+		}
+	}(a.ticker, a.done)
+}
+
+func (a *UserRoleSweeper) Release(ctx context.Context) error {
+	if a.ticker != nil {
+		a.ticker.Stop()
+		close(a.done)
+		a.ticker = nil
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestUserRoleSweeper(t *testing.T) {
+	dictionary := config.C.Dictionary
+	t.Cleanup(func() { config.C.Dictionary = dictionary })
+	config.C.Dictionary.UserRoleSweepInterval = 1
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(new(schema.UserRole)); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	startsAt, expiresAt, laterAt := now.Add(-time.Minute), now.Add(1500*time.Millisecond), now.Add(time.Hour)
+	for _, item := range []*schema.UserRole{
+		{ID: "ur1", UserID: "started", RoleID: "r", StartsAt: &startsAt},
+		{ID: "ur2", UserID: "ends", RoleID: "r", ExpiresAt: &expiresAt},
+		{ID: "ur3", UserID: "later", RoleID: "r", StartsAt: &laterAt},
+	} {
+		if err := db.Create(item).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	cache := cachex.NewMemoryCache(cachex.MemoryConfig{})
+	for _, userID := range []string{"started", "ends", "later"} {
+		assert.NoError(t, cache.Set(ctx, config.CacheNSForUser, userID, "cached"))
+	}
+	cached := func(userID string) bool {
+		exists, err := cache.Exists(ctx, config.CacheNSForUser, userID)
+		assert.NoError(t, err)
+		return exists
+	}
+
+	sweeper := &UserRoleSweeper{UserBIZ: &biz.User{Cache: cache, UserRoleDAL: &dal.UserRole{DB: db}}}
+	sweeper.Start(ctx)
+	defer func() { assert.NoError(t, sweeper.Release(ctx)) }()
+
+	// The first sweep covers the roles which started before the sweeper, the next ones the roles ending later
+	assert.Eventually(t, func() bool { return !cached("started") }, 3*time.Second, 50*time.Millisecond)
+	assert.Eventually(t, func() bool { return !cached("ends") }, 3*time.Second, 50*time.Millisecond)
+	assert.True(t, cached("later"))
+}
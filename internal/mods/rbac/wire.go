@@ -11,6 +11,11 @@ import (
 var Set = wire.NewSet(
 	wire.Struct(new(RBAC), "*"),
 	wire.Struct(new(Casbinx), "*"),
+	wire.Struct(new(CasbinAdapter), "*"),
+	wire.Bind(new(biz.Enforcer), new(*Casbinx)),
+	wire.Bind(new(biz.PolicySyncer), new(*Casbinx)),
+	wire.Struct(new(dal.CasbinRule), "*"),
+	wire.Struct(new(dal.CasbinChange), "*"),
 	wire.Struct(new(dal.Menu), "*"),
 	wire.Struct(new(biz.Menu), "*"),
 	wire.Struct(new(api.Menu), "*"),
@@ -19,12 +24,37 @@ var Set = wire.NewSet(
 	wire.Struct(new(biz.Role), "*"),
 	wire.Struct(new(api.Role), "*"),
 	wire.Struct(new(dal.RoleMenu), "*"),
+	wire.Struct(new(dal.RoleParent), "*"),
+	wire.Struct(new(dal.RoleConstraint), "*"),
+	wire.Struct(new(biz.RoleConstraint), "*"),
+	wire.Struct(new(api.RoleConstraint), "*"),
+	wire.Struct(new(dal.RoleElevation), "*"),
+	wire.Struct(new(biz.RoleElevation), "*"),
+	wire.Struct(new(api.RoleElevation), "*"),
 	wire.Struct(new(dal.User), "*"),
 	wire.Struct(new(biz.User), "*"),
 	wire.Struct(new(api.User), "*"),
 	wire.Struct(new(dal.UserRole), "*"),
+	wire.Struct(new(UserRoleSweeper), "*"),
+	wire.Struct(new(dal.UserTOTP), "*"),
+	wire.Struct(new(biz.UserTOTP), "*"),
+	wire.Struct(new(dal.UserIdentity), "*"),
+	wire.Struct(new(biz.LoginOIDC), "*"),
+	wire.Struct(new(biz.LoginLock), "*"),
+	wire.Struct(new(biz.LoginLDAP), "*"),
 	wire.Struct(new(biz.Login), "*"),
+	wire.Struct(new(dal.APIKey), "*"),
+	wire.Struct(new(biz.APIKey), "*"),
+	wire.Struct(new(biz.PasswordReset), "*"),
+	wire.Struct(new(biz.EmailVerification), "*"),
+	wire.Struct(new(dal.RootCredential), "*"),
+	wire.Struct(new(biz.Root), "*"),
+	wire.Struct(new(biz.Impersonation), "*"),
 	wire.Struct(new(api.Login), "*"),
+	wire.Struct(new(biz.Permission), "*"),
+	wire.Struct(new(api.Permission), "*"),
+	wire.Struct(new(biz.Route), "*"),
+	wire.Struct(new(api.Route), "*"),
 	wire.Struct(new(api.Logger), "*"),
 	wire.Struct(new(biz.Logger), "*"),
 	wire.Struct(new(dal.Logger), "*"),
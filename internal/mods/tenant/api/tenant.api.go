@@ -0,0 +1,177 @@
+package api
+
+import (
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// Tenant management (root only)
+type Tenant struct {
+	TenantBIZ *biz.Tenant
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Query tenant list
+// @Param current query int true "pagination index" default(1)
+// @Param pageSize query int true "pagination size" default(10)
+// @Param code query string false "Code of tenant"
+// @Param name query string false "Name of tenant"
+// @Param status query string false "Status of tenant (activated, suspended)"
+// @Success 200 {object} util.ResponseResult{data=[]schema.Tenant}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/tenants [get]
+func (a *Tenant) Query(c *gin.Context) {
+	ctx := c.Request.Context()
+	var params schema.TenantQueryParam
+	if err := util.ParseQuery(c, &params); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.TenantBIZ.Query(ctx, params)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResPage(c, result.Data, result.PageResult)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Get tenant record by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult{data=schema.Tenant}
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/tenants/{id} [get]
+func (a *Tenant) Get(c *gin.Context) {
+	ctx := c.Request.Context()
+	item, err := a.TenantBIZ.Get(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, item)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Create tenant record with its administrator
+// @Param body body schema.TenantForm true "Request body"
+// @Success 200 {object} util.ResponseResult{data=schema.Tenant}
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/tenants [post]
+func (a *Tenant) Create(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.TenantForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	result, err := a.TenantBIZ.Create(ctx, item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResSuccess(c, result)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Update tenant record by ID
+// @Param id path string true "unique id"
+// @Param body body schema.TenantForm true "Request body"
+// @Success 200 {object} util.ResponseResult
+// @Failure 400 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/tenants/{id} [put]
+func (a *Tenant) Update(c *gin.Context) {
+	ctx := c.Request.Context()
+	item := new(schema.TenantForm)
+	if err := util.ParseJSON(c, item); err != nil {
+		util.ResError(c, err)
+		return
+	} else if err := item.Validate(); err != nil {
+		util.ResError(c, err)
+		return
+	}
+
+	err := a.TenantBIZ.Update(ctx, c.Param("id"), item)
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Suspend tenant by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/tenants/{id}/suspend [patch]
+func (a *Tenant) Suspend(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.TenantBIZ.Suspend(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Activate tenant by ID
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/tenants/{id}/activate [patch]
+func (a *Tenant) Activate(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.TenantBIZ.Activate(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
+
+// @Tags TenantAPI
+// @Security ApiKeyAuth
+// @Summary Delete tenant record by ID (including all its data except the logs)
+// @Param id path string true "unique id"
+// @Success 200 {object} util.ResponseResult
+// @Failure 401 {object} util.ResponseResult
+// @Failure 403 {object} util.ResponseResult
+// @Failure 500 {object} util.ResponseResult
+// @Router /api/v1/tenants/{id} [delete]
+func (a *Tenant) Delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := a.TenantBIZ.Delete(ctx, c.Param("id"))
+	if err != nil {
+		util.ResError(c, err)
+		return
+	}
+	util.ResOK(c)
+}
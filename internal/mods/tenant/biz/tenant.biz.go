@@ -0,0 +1,357 @@
+package biz
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	orgschema "github.com/LyricTian/gin-admin/v10/internal/mods/org/schema"
+	rbacbiz "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	rbacdal "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	rbacschema "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/schema"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/schema"
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"go.uber.org/zap"
+)
+
+var _ rbacbiz.TenantProvider = (*Tenant)(nil)
+
+// Tenant management, only the root user can manage the tenants
+type Tenant struct {
+	Cache        cachex.Cacher
+	Trans        *util.Trans
+	TenantDAL    *dal.Tenant
+	RoleDAL      *rbacdal.Role
+	UserBIZ      *rbacbiz.User
+	MenuBIZ      *rbacbiz.Menu
+	PolicySyncer rbacbiz.PolicySyncer
+}
+
+func (a *Tenant) checkRoot(ctx context.Context) error {
+	if !util.FromIsRootUser(ctx) {
+		return errors.Forbidden("", "Only the root user can manage tenants")
+	}
+	return nil
+}
+
+// Query tenants from the data access object based on the provided parameters and options.
+func (a *Tenant) Query(ctx context.Context, params schema.TenantQueryParam) (*schema.TenantQueryResult, error) {
+	if err := a.checkRoot(ctx); err != nil {
+		return nil, err
+	}
+
+	params.Pagination = true
+	result, err := a.TenantDAL.Query(ctx, params, schema.TenantQueryOptions{
+		QueryOptions: util.QueryOptions{
+			OrderFields: []util.OrderByParam{
+				{Field: "created_at", Direction: util.DESC},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Get the specified tenant from the data access object.
+func (a *Tenant) Get(ctx context.Context, id string) (*schema.Tenant, error) {
+	if err := a.checkRoot(ctx); err != nil {
+		return nil, err
+	}
+
+	tenant, err := a.TenantDAL.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if tenant == nil {
+		return nil, errors.NotFound("", "Tenant not found")
+	}
+	return tenant, nil
+}
+
+// Create a new tenant with its administrator, the menus of the tenant are initialized from the menu file.
+func (a *Tenant) Create(ctx context.Context, formItem *schema.TenantForm) (*schema.Tenant, error) {
+	if err := a.checkRoot(ctx); err != nil {
+		return nil, err
+	} else if formItem.AdminUsername == "" {
+		return nil, errors.BadRequest("", "Username of the administrator is required")
+	}
+
+	if exists, err := a.TenantDAL.ExistsCode(ctx, formItem.Code); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, errors.BadRequest("", "Code of tenant already exists")
+	}
+
+	tenant := &schema.Tenant{
+		ID:        util.NewXID(),
+		Status:    schema.TenantStatusActivated,
+		CreatedAt: time.Now(),
+	}
+	if err := formItem.FillTo(tenant); err != nil {
+		return nil, err
+	}
+
+	adminName := formItem.AdminName
+	if adminName == "" {
+		adminName = formItem.AdminUsername
+	}
+
+	err := a.Trans.Exec(ctx, func(ctx context.Context) error {
+		tenantCtx := util.NewTenantID(ctx, tenant.ID)
+		admin, err := a.UserBIZ.Create(tenantCtx, &rbacschema.UserForm{
+			Username: formItem.AdminUsername,
+			Name:     adminName,
+			Password: formItem.AdminPassword,
+			Status:   rbacschema.UserStatusActivated,
+		})
+		if err != nil {
+			return err
+		}
+
+		if name := config.C.General.MenuFile; name != "" {
+			fullPath := filepath.Join(config.C.General.WorkDir, name)
+			if err := a.MenuBIZ.InitFromFile(tenantCtx, fullPath); err != nil {
+				return err
+			}
+		}
+
+		tenant.AdminUserID = admin.ID
+		return a.TenantDAL.Create(ctx, tenant)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Context(logging.NewTag(ctx, logging.TagKeySecurity)).Info("Tenant created",
+		zap.String("tenant_id", tenant.ID), zap.String("code", tenant.Code))
+	return tenant, nil
+}
+
+// Update the specified tenant in the data access object, the code of the tenant cannot be changed.
+func (a *Tenant) Update(ctx context.Context, id string, formItem *schema.TenantForm) error {
+	if err := a.checkRoot(ctx); err != nil {
+		return err
+	}
+
+	tenant, err := a.TenantDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if tenant == nil {
+		return errors.NotFound("", "Tenant not found")
+	} else if tenant.Code != formItem.Code {
+		return errors.BadRequest("", "Code of tenant cannot be changed")
+	}
+
+	if err := formItem.FillTo(tenant); err != nil {
+		return err
+	}
+	tenant.UpdatedAt = time.Now()
+
+	if err := a.TenantDAL.Update(ctx, tenant); err != nil {
+		return err
+	}
+	return a.deleteCache(ctx, tenant)
+}
+
+// Suspend the specified tenant, the users of the tenant are rejected until it is activated again.
+func (a *Tenant) Suspend(ctx context.Context, id string) error {
+	return a.updateStatus(ctx, id, schema.TenantStatusSuspended)
+}
+
+// Activate the specified suspended tenant.
+func (a *Tenant) Activate(ctx context.Context, id string) error {
+	return a.updateStatus(ctx, id, schema.TenantStatusActivated)
+}
+
+func (a *Tenant) updateStatus(ctx context.Context, id, status string) error {
+	if err := a.checkRoot(ctx); err != nil {
+		return err
+	}
+
+	tenant, err := a.TenantDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if tenant == nil {
+		return errors.NotFound("", "Tenant not found")
+	} else if tenant.Status == status {
+		return nil
+	}
+
+	tenant.Status = status
+	tenant.UpdatedAt = time.Now()
+	if err := a.TenantDAL.Update(ctx, tenant); err != nil {
+		return err
+	}
+
+	logging.Context(logging.NewTag(ctx, logging.TagKeySecurity)).Info("Tenant status changed",
+		zap.String("tenant_id", tenant.ID), zap.String("status", status))
+	return a.deleteCache(ctx, tenant)
+}
+
+// The data of the tenant (except the logs) is deleted with it
+var tenantDataModels = []interface{}{
+	new(rbacschema.Menu),
+	new(rbacschema.MenuResource),
+	new(rbacschema.Role),
+	new(rbacschema.RoleMenu),
+	new(rbacschema.RoleParent),
+	new(rbacschema.RoleConstraint),
+	new(rbacschema.RoleElevation),
+	new(rbacschema.User),
+	new(rbacschema.UserRole),
+	new(rbacschema.UserTOTP),
+	new(rbacschema.UserIdentity),
+	new(rbacschema.APIKey),
+	new(rbacschema.RootCredential),
+	new(orgschema.Department),
+	new(orgschema.DepartmentUser),
+}
+
+// Delete the specified tenant and all its data, the policies of its roles are removed from casbin.
+func (a *Tenant) Delete(ctx context.Context, id string) error {
+	if err := a.checkRoot(ctx); err != nil {
+		return err
+	}
+
+	tenant, err := a.TenantDAL.Get(ctx, id)
+	if err != nil {
+		return err
+	} else if tenant == nil {
+		return errors.NotFound("", "Tenant not found")
+	}
+
+	tenantCtx := util.NewTenantID(ctx, tenant.ID)
+	roleResult, err := a.RoleDAL.Query(tenantCtx, rbacschema.RoleQueryParam{}, rbacschema.RoleQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id"}},
+	})
+	if err != nil {
+		return err
+	}
+	roleIDs := make([]string, 0, len(roleResult.Data))
+	for _, role := range roleResult.Data {
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	err = a.Trans.Exec(ctx, func(ctx context.Context) error {
+		if err := a.TenantDAL.Delete(ctx, tenant.ID); err != nil {
+			return err
+		}
+		return a.TenantDAL.DeleteData(util.NewTenantID(ctx, tenant.ID), tenant.ID, tenantDataModels...)
+	})
+	if err != nil {
+		return err
+	} else if err := a.deleteCache(ctx, tenant); err != nil {
+		return err
+	}
+
+	ctx = logging.NewTag(ctx, logging.TagKeySecurity)
+	logging.Context(ctx).Info("Tenant deleted", zap.String("tenant_id", tenant.ID), zap.String("code", tenant.Code))
+
+	// The remaining policies are removed on the next full reload if it fails
+	if err := a.PolicySyncer.SyncRoles(tenantCtx, roleIDs...); err != nil {
+		logging.Context(ctx).Error("Failed to sync casbin policies of the deleted tenant", zap.Error(err))
+	}
+	return nil
+}
+
+// The tenants are cached globally (not in the namespace of the tenant of the request)
+func (a *Tenant) deleteCache(ctx context.Context, tenant *schema.Tenant) error {
+	ctx = util.NewTenantUnscoped(ctx)
+	if err := a.Cache.Delete(ctx, config.CacheNSForTenant, tenant.ID); err != nil {
+		return err
+	}
+	return a.Cache.Delete(ctx, config.CacheNSForTenant, "code:"+tenant.Code)
+}
+
+func (a *Tenant) getCached(ctx context.Context, id string) (*schema.Tenant, error) {
+	ctx = util.NewTenantUnscoped(ctx)
+	if val, ok, err := a.Cache.Get(ctx, config.CacheNSForTenant, id); err != nil {
+		return nil, err
+	} else if ok {
+		tenant := new(schema.Tenant)
+		if err := json.Unmarshal([]byte(val), tenant); err == nil {
+			return tenant, nil
+		}
+	}
+
+	tenant, err := a.TenantDAL.Get(ctx, id)
+	if err != nil || tenant == nil {
+		return nil, err
+	}
+	if err := a.Cache.Set(ctx, config.CacheNSForTenant, id, json.MarshalToString(tenant)); err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// Check the tenant is activated and get the user ID of its administrator (empty for the default tenant).
+func (a *Tenant) CheckTenant(ctx context.Context, tenantID string) (string, error) {
+	if tenantID == "" {
+		return "", nil
+	}
+
+	tenant, err := a.getCached(ctx, tenantID)
+	if err != nil {
+		return "", err
+	} else if tenant == nil {
+		return "", errors.Unauthorized(config.ErrInvalidTokenID, "Tenant not found")
+	} else if tenant.Status != schema.TenantStatusActivated {
+		return "", errors.Forbidden(config.ErrTenantSuspendedID, "Tenant is suspended")
+	}
+	return tenant.AdminUserID, nil
+}
+
+// Get the ID of the activated tenant by its code (empty for the default tenant).
+func (a *Tenant) GetIDByCode(ctx context.Context, code string) (string, error) {
+	if code == "" {
+		return "", nil
+	}
+
+	ctx = util.NewTenantUnscoped(ctx)
+	tenantID, ok, err := a.Cache.Get(ctx, config.CacheNSForTenant, "code:"+code)
+	if err != nil {
+		return "", err
+	} else if !ok {
+		tenant, err := a.TenantDAL.GetByCode(ctx, code, schema.TenantQueryOptions{
+			QueryOptions: util.QueryOptions{SelectFields: []string{"id"}},
+		})
+		if err != nil {
+			return "", err
+		} else if tenant == nil {
+			return "", errors.BadRequest(config.ErrTenantNotFoundID, "Tenant not found")
+		}
+
+		tenantID = tenant.ID
+		if err := a.Cache.Set(ctx, config.CacheNSForTenant, "code:"+code, tenantID); err != nil {
+			return "", err
+		}
+	}
+
+	tenant, err := a.getCached(ctx, tenantID)
+	if err != nil {
+		return "", err
+	} else if tenant == nil {
+		return "", errors.BadRequest(config.ErrTenantNotFoundID, "Tenant not found")
+	} else if tenant.Status != schema.TenantStatusActivated {
+		return "", errors.Forbidden(config.ErrTenantSuspendedID, "Tenant is suspended")
+	}
+	return tenant.ID, nil
+}
+
+// Query the IDs of all tenants.
+func (a *Tenant) QueryTenantIDs(ctx context.Context) ([]string, error) {
+	result, err := a.TenantDAL.Query(util.NewTenantUnscoped(ctx), schema.TenantQueryParam{}, schema.TenantQueryOptions{
+		QueryOptions: util.QueryOptions{SelectFields: []string{"id"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Data.ToIDs(), nil
+}
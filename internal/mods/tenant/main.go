@@ -0,0 +1,49 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/api"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/schema"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Tenant struct {
+	DB        *gorm.DB
+	TenantAPI *api.Tenant
+}
+
+func (a *Tenant) AutoMigrate(ctx context.Context) error {
+	return a.DB.AutoMigrate(
+		new(schema.Tenant),
+	)
+}
+
+func (a *Tenant) Init(ctx context.Context) error {
+	if config.C.Storage.DB.AutoMigrate {
+		if err := a.AutoMigrate(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Tenant) RegisterV1Routers(ctx context.Context, v1 *gin.RouterGroup) error {
+	tenant := v1.Group("tenants")
+	{
+		tenant.GET("", a.TenantAPI.Query)
+		tenant.GET(":id", a.TenantAPI.Get)
+		tenant.POST("", a.TenantAPI.Create)
+		tenant.PUT(":id", a.TenantAPI.Update)
+		tenant.PATCH(":id/suspend", a.TenantAPI.Suspend)
+		tenant.PATCH(":id/activate", a.TenantAPI.Activate)
+		tenant.DELETE(":id", a.TenantAPI.Delete)
+	}
+	return nil
+}
+
+func (a *Tenant) Release(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/internal/config"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+)
+
+const (
+	TenantStatusActivated = "activated"
+	TenantStatusSuspended = "suspended"
+)
+
+var tenantCodeRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Tenant which owns its users, roles and menus, the data without tenant belongs to the default tenant
+type Tenant struct {
+	ID          string    `json:"id" gorm:"size:20;primarykey;"`       // Unique ID
+	Code        string    `json:"code" gorm:"size:32;uniqueIndex;"`    // Code of tenant (unique, specified by the tenant header of the requests)
+	Name        string    `json:"name" gorm:"size:128;index"`          // Display name of tenant
+	Description string    `json:"description" gorm:"size:1024"`        // Details about tenant
+	Status      string    `json:"status" gorm:"size:20;index"`         // Status of tenant (activated, suspended)
+	AdminUserID string    `json:"admin_user_id" gorm:"size:20;index;"` // Administrator of tenant (From User.ID)
+	CreatedAt   time.Time `json:"created_at" gorm:"index;"`            // Create time
+	UpdatedAt   time.Time `json:"updated_at" gorm:"index;"`            // Update time
+}
+
+func (a *Tenant) TableName() string {
+	return config.C.FormatTableName("tenant")
+}
+
+// Defining the query parameters for the `Tenant` struct.
+type TenantQueryParam struct {
+	util.PaginationParam
+	LikeCode string `form:"code"`                                          // Code of tenant
+	LikeName string `form:"name"`                                          // Display name of tenant
+	Status   string `form:"status" binding:"oneof=activated suspended ''"` // Status of tenant (activated, suspended)
+	Code     string `form:"-"`                                             // Code of tenant
+}
+
+// Defining the query options for the `Tenant` struct.
+type TenantQueryOptions struct {
+	util.QueryOptions
+}
+
+// Defining the query result for the `Tenant` struct.
+type TenantQueryResult struct {
+	Data       Tenants
+	PageResult *util.PaginationResult
+}
+
+// Defining the slice of `Tenant` struct.
+type Tenants []*Tenant
+
+func (a Tenants) ToIDs() []string {
+	ids := make([]string, 0, len(a))
+	for _, item := range a {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+// Defining the data structure for creating a `Tenant` struct.
+type TenantForm struct {
+	Code          string `json:"code" binding:"required,max=32"`  // Code of tenant (unique, cannot be changed)
+	Name          string `json:"name" binding:"required,max=128"` // Display name of tenant
+	Description   string `json:"description" binding:"max=1024"`  // Details about tenant
+	AdminUsername string `json:"admin_username" binding:"max=64"` // Username of the administrator (only for creating)
+	AdminName     string `json:"admin_name" binding:"max=64"`     // Name of the administrator (only for creating)
+	AdminPassword string `json:"admin_password" binding:"max=64"` // Password of the administrator (only for creating, the default password if empty)
+}
+
+// A validation function for the `TenantForm` struct.
+func (a *TenantForm) Validate() error {
+	if !tenantCodeRegexp.MatchString(a.Code) {
+		return errors.BadRequest("", "Code of tenant must consist of lowercase letters, digits, '-' and '_'")
+	}
+	return nil
+}
+
+// Convert `TenantForm` to `Tenant` object.
+func (a *TenantForm) FillTo(tenant *Tenant) error {
+	tenant.Code = a.Code
+	tenant.Name = a.Name
+	tenant.Description = a.Description
+	return nil
+}
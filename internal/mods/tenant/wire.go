@@ -0,0 +1,18 @@
+package tenant
+
+import (
+	rbacbiz "github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/api"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/biz"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant/dal"
+	"github.com/google/wire"
+)
+
+// Collection of wire providers
+var Set = wire.NewSet(
+	wire.Struct(new(Tenant), "*"),
+	wire.Struct(new(dal.Tenant), "*"),
+	wire.Struct(new(biz.Tenant), "*"),
+	wire.Bind(new(rbacbiz.TenantProvider), new(*biz.Tenant)),
+	wire.Struct(new(api.Tenant), "*"),
+)
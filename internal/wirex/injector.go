@@ -2,13 +2,19 @@ package wirex
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/LyricTian/gin-admin/v10/internal/config"
 	"github.com/LyricTian/gin-admin/v10/internal/mods"
 	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/captchax"
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
 	"github.com/LyricTian/gin-admin/v10/pkg/gormx"
 	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
 	"github.com/golang-jwt/jwt"
 	"gorm.io/gorm"
 )
@@ -81,16 +87,61 @@ func InitCacher(ctx context.Context) (cachex.Cacher, func(), error) {
 		}, cachex.WithDelimiter(cfg.Delimiter))
 	}
 
+	// The namespaces are separated by the tenant of the request
+	cache = cachex.NewPrefixCache(cache, func(ctx context.Context) string {
+		tenantID, _ := util.FromTenantID(ctx)
+		return tenantID
+	}, cachex.WithDelimiter(cfg.Delimiter))
+
 	return cache, func() {
 		_ = cache.Close(ctx)
 	}, nil
 }
 
+// It returns the captcha provider of the type in the configuration, the answers are kept in memory or redis
+func InitCaptcha(ctx context.Context) (captchax.Captcha, func(), error) {
+	cfg := config.C.Util.Captcha
+
+	var store cachex.Cacher
+	switch cfg.CacheType {
+	case "redis":
+		store = cachex.NewRedisCache(cachex.RedisConfig{
+			Addr:     cfg.Redis.Addr,
+			DB:       cfg.Redis.DB,
+			Username: cfg.Redis.Username,
+			Password: cfg.Redis.Password,
+		}, cachex.WithDelimiter(":"))
+	default:
+		store = cachex.NewMemoryCache(cachex.MemoryConfig{
+			CleanupInterval: time.Minute,
+		})
+	}
+
+	c, err := captchax.New(captchax.Config{
+		Type:            cfg.Type,
+		Length:          cfg.Length,
+		Width:           cfg.Width,
+		Height:          cfg.Height,
+		Expiration:      time.Duration(cfg.Expired) * time.Second,
+		Language:        cfg.Language,
+		SliderTolerance: cfg.SliderTolerance,
+		Namespace:       strings.TrimSuffix(cfg.Redis.KeyPrefix, ":"),
+	}, store)
+	if err != nil {
+		_ = store.Close(ctx)
+		return nil, nil, err
+	}
+
+	return c, func() {
+		_ = store.Close(ctx)
+	}, nil
+}
+
 func InitAuth(ctx context.Context) (jwtx.Auther, func(), error) {
 	cfg := config.C.Middleware.Auth
 	var opts []jwtx.Option
 	opts = append(opts, jwtx.SetExpired(cfg.Expired))
-	opts = append(opts, jwtx.SetSigningKey(cfg.SigningKey, cfg.OldSigningKey))
+	opts = append(opts, jwtx.SetRefreshExpired(cfg.RefreshExpired))
 
 	var method jwt.SigningMethod
 	switch cfg.SigningMethod {
@@ -98,11 +149,57 @@ func InitAuth(ctx context.Context) (jwtx.Auther, func(), error) {
 		method = jwt.SigningMethodHS256
 	case "HS384":
 		method = jwt.SigningMethodHS384
+	case "RS256":
+		method = jwt.SigningMethodRS256
+	case "RS384":
+		method = jwt.SigningMethodRS384
+	case "RS512":
+		method = jwt.SigningMethodRS512
+	case "PS256":
+		method = jwt.SigningMethodPS256
+	case "PS384":
+		method = jwt.SigningMethodPS384
+	case "PS512":
+		method = jwt.SigningMethodPS512
+	case "ES256":
+		method = jwt.SigningMethodES256
+	case "ES384":
+		method = jwt.SigningMethodES384
+	case "ES512":
+		method = jwt.SigningMethodES512
+	case "EdDSA":
+		method = jwt.SigningMethodEdDSA
 	default:
 		method = jwt.SigningMethodHS512
 	}
 	opts = append(opts, jwtx.SetSigningMethod(method))
 
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		opts = append(opts, jwtx.SetSigningKey(cfg.SigningKey, cfg.OldSigningKey))
+	} else {
+		buf, err := os.ReadFile(filepath.Join(config.C.General.WorkDir, cfg.PrivateKeyFile))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read private key file %s", cfg.PrivateKeyFile)
+		}
+		privateKey, err := jwtx.ParsePrivateKeyFromPEM(method, buf)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse private key file %s", cfg.PrivateKeyFile)
+		}
+		opts = append(opts, jwtx.SetPrivateKey(privateKey))
+	}
+
+	for _, name := range cfg.OldPublicKeyFiles {
+		buf, err := os.ReadFile(filepath.Join(config.C.General.WorkDir, name))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to read public key file %s", name)
+		}
+		publicKey, err := jwtx.ParsePublicKeyFromPEM(buf)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse public key file %s", name)
+		}
+		opts = append(opts, jwtx.SetOldPublicKeys(publicKey))
+	}
+
 	var cache cachex.Cacher
 	switch cfg.Store.Type {
 	case "redis":
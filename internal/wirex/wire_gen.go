@@ -9,10 +9,18 @@ package wirex
 import (
 	"context"
 	"github.com/LyricTian/gin-admin/v10/internal/mods"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/org"
+	api2 "github.com/LyricTian/gin-admin/v10/internal/mods/org/api"
+	biz2 "github.com/LyricTian/gin-admin/v10/internal/mods/org/biz"
+	dal2 "github.com/LyricTian/gin-admin/v10/internal/mods/org/dal"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/api"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/biz"
 	"github.com/LyricTian/gin-admin/v10/internal/mods/rbac/dal"
+	"github.com/LyricTian/gin-admin/v10/internal/mods/tenant"
+	api3 "github.com/LyricTian/gin-admin/v10/internal/mods/tenant/api"
+	biz3 "github.com/LyricTian/gin-admin/v10/internal/mods/tenant/biz"
+	dal3 "github.com/LyricTian/gin-admin/v10/internal/mods/tenant/dal"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 )
 
@@ -34,6 +42,13 @@ func BuildInjector(ctx context.Context) (*Injector, func(), error) {
 		cleanup()
 		return nil, nil, err
 	}
+	captcha, cleanup4, err := InitCaptcha(ctx)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	trans := &util.Trans{
 		DB: db,
 	}
@@ -46,54 +61,210 @@ func BuildInjector(ctx context.Context) (*Injector, func(), error) {
 	roleMenu := &dal.RoleMenu{
 		DB: db,
 	}
+	role := &dal.Role{
+		DB: db,
+	}
+	roleParent := &dal.RoleParent{
+		DB: db,
+	}
+	casbinRule := &dal.CasbinRule{
+		DB: db,
+	}
+	casbinChange := &dal.CasbinChange{
+		DB: db,
+	}
+	casbinAdapter := &rbac.CasbinAdapter{
+		Trans:           trans,
+		CasbinRuleDAL:   casbinRule,
+		CasbinChangeDAL: casbinChange,
+	}
+	casbinx := &rbac.Casbinx{
+		Cache:           cacher,
+		Adapter:         casbinAdapter,
+		MenuDAL:         menu,
+		MenuResourceDAL: menuResource,
+		RoleDAL:         role,
+		RoleParentDAL:   roleParent,
+		CasbinRuleDAL:   casbinRule,
+		CasbinChangeDAL: casbinChange,
+	}
 	bizMenu := &biz.Menu{
 		Cache:           cacher,
 		Trans:           trans,
 		MenuDAL:         menu,
 		MenuResourceDAL: menuResource,
 		RoleMenuDAL:     roleMenu,
+		PolicySyncer:    casbinx,
 	}
 	apiMenu := &api.Menu{
 		MenuBIZ: bizMenu,
 	}
-	role := &dal.Role{
+	userRole := &dal.UserRole{
 		DB: db,
 	}
-	userRole := &dal.UserRole{
+	department := &dal2.Department{
+		DB: db,
+	}
+	roleConstraint := &dal.RoleConstraint{
 		DB: db,
 	}
+	bizRoleConstraint := &biz.RoleConstraint{
+		RoleConstraintDAL: roleConstraint,
+		RoleDAL:           role,
+		RoleParentDAL:     roleParent,
+		UserRoleDAL:       userRole,
+	}
 	bizRole := &biz.Role{
-		Cache:       cacher,
-		Trans:       trans,
-		RoleDAL:     role,
-		RoleMenuDAL: roleMenu,
-		UserRoleDAL: userRole,
+		Cache:             cacher,
+		Trans:             trans,
+		RoleDAL:           role,
+		RoleMenuDAL:       roleMenu,
+		UserRoleDAL:       userRole,
+		RoleParentDAL:     roleParent,
+		RoleConstraintDAL: roleConstraint,
+		RoleConstraintBIZ: bizRoleConstraint,
+		DepartmentDAL:     department,
+		PolicySyncer:      casbinx,
 	}
 	apiRole := &api.Role{
 		RoleBIZ: bizRole,
 	}
+	apiRoleConstraint := &api.RoleConstraint{
+		RoleConstraintBIZ: bizRoleConstraint,
+	}
 	user := &dal.User{
 		DB: db,
 	}
+	userTOTP := &dal.UserTOTP{
+		DB: db,
+	}
+	userIdentity := &dal.UserIdentity{
+		DB: db,
+	}
+	apiKey := &dal.APIKey{
+		DB: db,
+	}
+	departmentUser := &dal2.DepartmentUser{
+		DB: db,
+	}
+	roleElevation := &dal.RoleElevation{
+		DB: db,
+	}
+	dataScope := &biz2.DataScope{
+		RoleDAL:           role,
+		DepartmentDAL:     department,
+		DepartmentUserDAL: departmentUser,
+	}
 	bizUser := &biz.User{
-		Cache:       cacher,
-		Trans:       trans,
+		Cache:             cacher,
+		Trans:             trans,
+		Auth:              auther,
+		UserDAL:           user,
+		UserRoleDAL:       userRole,
+		UserTOTPDAL:       userTOTP,
+		IdentityDAL:       userIdentity,
+		APIKeyDAL:         apiKey,
+		DataScopeBIZ:      dataScope,
+		DepartmentUserDAL: departmentUser,
+		RoleConstraintBIZ: bizRoleConstraint,
+		RoleElevationDAL:  roleElevation,
+	}
+	bizUserTOTP := &biz.UserTOTP{
 		UserDAL:     user,
-		UserRoleDAL: userRole,
+		UserTOTPDAL: userTOTP,
 	}
-	apiUser := &api.User{
+	loginLock := &biz.LoginLock{
+		Cache:   cacher,
+		UserDAL: user,
 		UserBIZ: bizUser,
 	}
+	apiUser := &api.User{
+		UserBIZ:      bizUser,
+		UserTOTPBIZ:  bizUserTOTP,
+		LoginLockBIZ: loginLock,
+	}
+	loginLDAP := &biz.LoginLDAP{
+		Trans:             trans,
+		UserDAL:           user,
+		UserRoleDAL:       userRole,
+		RoleDAL:           role,
+		RoleConstraintBIZ: bizRoleConstraint,
+	}
+	bizAPIKey := &biz.APIKey{
+		APIKeyDAL: apiKey,
+	}
+	emailVerification := &biz.EmailVerification{
+		Cache:   cacher,
+		UserDAL: user,
+	}
+	rootCredential := &dal.RootCredential{
+		DB: db,
+	}
+	root := &biz.Root{
+		RootCredentialDAL: rootCredential,
+		UserBIZ:           bizUser,
+	}
+	impersonation := &biz.Impersonation{
+		Auth:          auther,
+		Enforcer:      casbinx,
+		UserDAL:       user,
+		RoleDAL:       role,
+		RoleParentDAL: roleParent,
+		UserBIZ:       bizUser,
+	}
+	dalTenant := &dal3.Tenant{
+		DB: db,
+	}
+	bizTenant := &biz3.Tenant{
+		Cache:        cacher,
+		Trans:        trans,
+		TenantDAL:    dalTenant,
+		RoleDAL:      role,
+		UserBIZ:      bizUser,
+		MenuBIZ:      bizMenu,
+		PolicySyncer: casbinx,
+	}
 	login := &biz.Login{
-		Cache:       cacher,
-		Auth:        auther,
-		UserDAL:     user,
-		UserRoleDAL: userRole,
-		MenuDAL:     menu,
-		UserBIZ:     bizUser,
+		Cache:                cacher,
+		Auth:                 auther,
+		Captcha:              captcha,
+		UserDAL:              user,
+		UserRoleDAL:          userRole,
+		RoleParentDAL:        roleParent,
+		MenuDAL:              menu,
+		UserBIZ:              bizUser,
+		UserTOTPBIZ:          bizUserTOTP,
+		LoginLockBIZ:         loginLock,
+		LoginLDAPBIZ:         loginLDAP,
+		APIKeyBIZ:            bizAPIKey,
+		EmailVerificationBIZ: emailVerification,
+		RootBIZ:              root,
+		ImpersonationBIZ:     impersonation,
+		TenantProvider:       bizTenant,
+	}
+	loginOIDC := &biz.LoginOIDC{
+		Cache:             cacher,
+		Trans:             trans,
+		UserDAL:           user,
+		UserRoleDAL:       userRole,
+		RoleDAL:           role,
+		IdentityDAL:       userIdentity,
+		LoginBIZ:          login,
+		RoleConstraintBIZ: bizRoleConstraint,
+	}
+	passwordReset := &biz.PasswordReset{
+		Cache:        cacher,
+		UserDAL:      user,
+		UserBIZ:      bizUser,
+		LoginLockBIZ: loginLock,
 	}
 	apiLogin := &api.Login{
-		LoginBIZ: login,
+		LoginBIZ:             login,
+		LoginOIDCBIZ:         loginOIDC,
+		APIKeyBIZ:            bizAPIKey,
+		PasswordResetBIZ:     passwordReset,
+		EmailVerificationBIZ: emailVerification,
+		ImpersonationBIZ:     impersonation,
 	}
 	logger := &dal.Logger{
 		DB: db,
@@ -104,23 +275,80 @@ func BuildInjector(ctx context.Context) (*Injector, func(), error) {
 	apiLogger := &api.Logger{
 		LoggerBIZ: bizLogger,
 	}
-	casbinx := &rbac.Casbinx{
-		Cache:           cacher,
-		MenuDAL:         menu,
+	permission := &biz.Permission{
+		Enforcer:      casbinx,
+		UserDAL:       user,
+		UserRoleDAL:   userRole,
+		RoleDAL:       role,
+		RoleMenuDAL:   roleMenu,
+		RoleParentDAL: roleParent,
+		MenuDAL:       menu,
+		UserBIZ:       bizUser,
+	}
+	apiPermission := &api.Permission{
+		PermissionBIZ: permission,
+	}
+	bizRoute := &biz.Route{
 		MenuResourceDAL: menuResource,
-		RoleDAL:         role,
+	}
+	apiRoute := &api.Route{
+		RouteBIZ: bizRoute,
+	}
+	bizRoleElevation := &biz.RoleElevation{
+		Cache:             cacher,
+		Trans:             trans,
+		RoleElevationDAL:  roleElevation,
+		RoleDAL:           role,
+		RoleParentDAL:     roleParent,
+		UserRoleDAL:       userRole,
+		UserBIZ:           bizUser,
+		RoleConstraintBIZ: bizRoleConstraint,
+	}
+	apiRoleElevation := &api.RoleElevation{
+		RoleElevationBIZ: bizRoleElevation,
+	}
+	userRoleSweeper := &rbac.UserRoleSweeper{
+		UserBIZ: bizUser,
 	}
 	rbacRBAC := &rbac.RBAC{
+		DB:                db,
+		MenuAPI:           apiMenu,
+		RoleAPI:           apiRole,
+		RoleConstraintAPI: apiRoleConstraint,
+		RoleElevationAPI:  apiRoleElevation,
+		UserAPI:           apiUser,
+		LoginAPI:          apiLogin,
+		LoggerAPI:         apiLogger,
+		PermissionAPI:     apiPermission,
+		RouteAPI:          apiRoute,
+		Casbinx:           casbinx,
+		RoleSweeper:       userRoleSweeper,
+		Tenant:            bizTenant,
+	}
+	bizDepartment := &biz2.Department{
+		Trans:             trans,
+		DepartmentDAL:     department,
+		DepartmentUserDAL: departmentUser,
+		UserDAL:           user,
+	}
+	apiDepartment := &api2.Department{
+		DepartmentBIZ: bizDepartment,
+	}
+	orgORG := &org.ORG{
+		DB:            db,
+		DepartmentAPI: apiDepartment,
+	}
+	apiTenant := &api3.Tenant{
+		TenantBIZ: bizTenant,
+	}
+	tenantTenant := &tenant.Tenant{
 		DB:        db,
-		MenuAPI:   apiMenu,
-		RoleAPI:   apiRole,
-		UserAPI:   apiUser,
-		LoginAPI:  apiLogin,
-		LoggerAPI: apiLogger,
-		Casbinx:   casbinx,
+		TenantAPI: apiTenant,
 	}
 	modsMods := &mods.Mods{
-		RBAC: rbacRBAC,
+		RBAC:   rbacRBAC,
+		ORG:    orgORG,
+		Tenant: tenantTenant,
 	}
 	injector := &Injector{
 		DB:    db,
@@ -129,6 +357,7 @@ func BuildInjector(ctx context.Context) (*Injector, func(), error) {
 		M:     modsMods,
 	}
 	return injector, func() {
+		cleanup4()
 		cleanup3()
 		cleanup2()
 		cleanup()
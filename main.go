@@ -27,6 +27,7 @@ func main() {
 		cmd.StartCmd(),
 		cmd.StopCmd(),
 		cmd.VersionCmd(VERSION),
+		cmd.RootCmd(),
 	}
 	err := app.Run(os.Args)
 	if err != nil {
@@ -3,6 +3,7 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 	"unsafe"
@@ -120,22 +121,80 @@ func (a *badgerCache) Delete(ctx context.Context, ns, key string) error {
 	})
 }
 
+// Get and delete the key in the same transaction, the transaction conflicting with a concurrent one (which has got
+// and deleted the key) fails as if the key is not found.
 func (a *badgerCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
-	value, ok, err := a.Get(ctx, ns, key)
+	value := ""
+	ok := false
+	err := a.db.Update(func(txn *badger.Txn) error {
+		k := a.strToBytes(a.getKey(ns, key))
+		item, err := txn.Get(k)
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return nil
+			}
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		value = a.bytesToStr(val)
+		ok = true
+		return txn.Delete(k)
+	})
 	if err != nil {
+		if err == badger.ErrConflict {
+			return "", false, nil
+		}
 		return "", false, err
-	} else if !ok {
-		return "", false, nil
 	}
+	return value, ok, nil
+}
 
-	err = a.db.Update(func(txn *badger.Txn) error {
-		return txn.Delete(a.strToBytes(a.getKey(ns, key)))
-	})
-	if err != nil {
-		return "", false, err
+// Increment the integer value of the key by one in a transaction, the expiration is only set when the key is created.
+// The transaction is retried if it conflicts with a concurrent one.
+func (a *badgerCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	for {
+		n, err := a.incr(ns, key, expiration...)
+		if err == badger.ErrConflict {
+			continue
+		}
+		return n, err
 	}
+}
+
+func (a *badgerCache) incr(ns, key string, expiration ...time.Duration) (int64, error) {
+	var n int64
+	err := a.db.Update(func(txn *badger.Txn) error {
+		k := a.strToBytes(a.getKey(ns, key))
+		var expiresAt uint64
+		found := false
+		item, err := txn.Get(k)
+		if err == nil {
+			found = true
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if n, err = strconv.ParseInt(a.bytesToStr(val), 10, 64); err != nil {
+				return err
+			}
+			expiresAt = item.ExpiresAt()
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
 
-	return value, true, nil
+		n++
+		entry := badger.NewEntry(k, []byte(strconv.FormatInt(n, 10)))
+		if expiresAt > 0 {
+			entry.ExpiresAt = expiresAt
+		} else if !found && len(expiration) > 0 {
+			entry = entry.WithTTL(expiration[0])
+		}
+		return txn.SetEntry(entry)
+	})
+	return n, err
 }
 
 func (a *badgerCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -32,6 +33,26 @@ func TestBadgerCache(t *testing.T) {
 	assert.False(exists)
 	assert.Equal("", val)
 
+	err = cache.Set(ctx, "tt", "foo", "bar")
+	assert.Nil(err)
+	val, exists, err = cache.GetAndDelete(ctx, "tt", "foo")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal("bar", val)
+
+	val, exists, err = cache.GetAndDelete(ctx, "tt", "foo")
+	assert.Nil(err)
+	assert.False(exists)
+	assert.Equal("", val)
+
+	for i := int64(1); i <= 3; i++ {
+		n, err := cache.Incr(ctx, "tt", "counter", time.Minute)
+		assert.Nil(err)
+		assert.Equal(i, n)
+	}
+	err = cache.Delete(ctx, "tt", "counter")
+	assert.Nil(err)
+
 	tmap := make(map[string]bool)
 	for i := 0; i < 10; i++ {
 		key := fmt.Sprintf("foo%d", i)
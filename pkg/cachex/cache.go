@@ -3,7 +3,9 @@ package cachex
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -14,6 +16,7 @@ type Cacher interface {
 	Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error
 	Get(ctx context.Context, ns, key string) (string, bool, error)
 	GetAndDelete(ctx context.Context, ns, key string) (string, bool, error)
+	Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error)
 	Exists(ctx context.Context, ns, key string) (bool, error)
 	Delete(ctx context.Context, ns, key string) error
 	Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error
@@ -55,6 +58,7 @@ func NewMemoryCache(cfg MemoryConfig, opts ...Option) Cacher {
 
 type memCache struct {
 	opts  *options
+	mu    sync.Mutex // Serialize GetAndDelete and Incr
 	cache *cache.Cache
 }
 
@@ -91,6 +95,9 @@ func (a *memCache) Delete(ctx context.Context, ns, key string) error {
 }
 
 func (a *memCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	value, ok, err := a.Get(ctx, ns, key)
 	if err != nil {
 		return "", false, err
@@ -102,6 +109,36 @@ func (a *memCache) GetAndDelete(ctx context.Context, ns, key string) (string, bo
 	return value, true, nil
 }
 
+// Increment the integer value of the key by one, the expiration is only set when the key is created.
+func (a *memCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var n int64
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	k := a.getKey(ns, key)
+	if val, expiresAt, ok := a.cache.GetWithExpiration(k); ok {
+		v, err := strconv.ParseInt(val.(string), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		// Keep the expiration of the existing key
+		if expiresAt.IsZero() {
+			n, exp = v, 0
+		} else if d := time.Until(expiresAt); d > 0 {
+			n, exp = v, d
+		}
+	}
+
+	n++
+	a.cache.Set(k, strconv.FormatInt(n, 10), exp)
+	return n, nil
+}
+
 func (a *memCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
 	for k, v := range a.cache.Items() {
 		if strings.HasPrefix(k, a.getKey(ns, "")) {
@@ -0,0 +1,69 @@
+package cachex
+
+import (
+	"context"
+	"time"
+)
+
+// NewPrefixCache returns a Cacher which prefixes the namespaces with the value returned by the prefix function,
+// the namespaces are not changed if the prefix is empty.
+func NewPrefixCache(cache Cacher, prefix func(ctx context.Context) string, opts ...Option) Cacher {
+	defaultOpts := &options{
+		Delimiter: defaultDelimiter,
+	}
+
+	for _, o := range opts {
+		o(defaultOpts)
+	}
+
+	return &prefixCache{
+		opts:   defaultOpts,
+		cache:  cache,
+		prefix: prefix,
+	}
+}
+
+type prefixCache struct {
+	opts   *options
+	cache  Cacher
+	prefix func(ctx context.Context) string
+}
+
+func (a *prefixCache) getNS(ctx context.Context, ns string) string {
+	if prefix := a.prefix(ctx); prefix != "" {
+		return prefix + a.opts.Delimiter + ns
+	}
+	return ns
+}
+
+func (a *prefixCache) Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error {
+	return a.cache.Set(ctx, a.getNS(ctx, ns), key, value, expiration...)
+}
+
+func (a *prefixCache) Get(ctx context.Context, ns, key string) (string, bool, error) {
+	return a.cache.Get(ctx, a.getNS(ctx, ns), key)
+}
+
+func (a *prefixCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
+	return a.cache.GetAndDelete(ctx, a.getNS(ctx, ns), key)
+}
+
+func (a *prefixCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	return a.cache.Incr(ctx, a.getNS(ctx, ns), key, expiration...)
+}
+
+func (a *prefixCache) Exists(ctx context.Context, ns, key string) (bool, error) {
+	return a.cache.Exists(ctx, a.getNS(ctx, ns), key)
+}
+
+func (a *prefixCache) Delete(ctx context.Context, ns, key string) error {
+	return a.cache.Delete(ctx, a.getNS(ctx, ns), key)
+}
+
+func (a *prefixCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	return a.cache.Iterator(ctx, a.getNS(ctx, ns), fn)
+}
+
+func (a *prefixCache) Close(ctx context.Context) error {
+	return a.cache.Close(ctx)
+}
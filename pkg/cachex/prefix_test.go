@@ -0,0 +1,60 @@
+package cachex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type prefixCtx struct{}
+
+func TestPrefixCache(t *testing.T) {
+	assert := assert.New(t)
+
+	mem := NewMemoryCache(MemoryConfig{})
+	cache := NewPrefixCache(mem, func(ctx context.Context) string {
+		v, _ := ctx.Value(prefixCtx{}).(string)
+		return v
+	})
+
+	ctx := context.Background()
+	tctx := context.WithValue(ctx, prefixCtx{}, "t1")
+
+	err := cache.Set(tctx, "tt", "foo", "bar")
+	assert.Nil(err)
+
+	val, exists, err := cache.Get(tctx, "tt", "foo")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal("bar", val)
+
+	_, exists, err = cache.Get(ctx, "tt", "foo")
+	assert.Nil(err)
+	assert.False(exists)
+
+	val, exists, err = mem.Get(ctx, "t1:tt", "foo")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal("bar", val)
+
+	err = cache.Delete(tctx, "tt", "foo")
+	assert.Nil(err)
+
+	exists, err = cache.Exists(tctx, "tt", "foo")
+	assert.Nil(err)
+	assert.False(exists)
+
+	n, err := cache.Incr(tctx, "tt", "counter")
+	assert.Nil(err)
+	assert.Equal(int64(1), n)
+	n, err = cache.Incr(ctx, "tt", "counter")
+	assert.Nil(err)
+	assert.Equal(int64(1), n)
+	n, err = cache.Incr(tctx, "tt", "counter")
+	assert.Nil(err)
+	assert.Equal(int64(2), n)
+
+	err = cache.Close(ctx)
+	assert.Nil(err)
+}
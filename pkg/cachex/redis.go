@@ -54,6 +54,7 @@ func newRedisCache(cli redisClienter, opts ...Option) Cacher {
 }
 
 type redisClienter interface {
+	redis.Scripter
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Get(ctx context.Context, key string) *redis.StringCmd
 	Exists(ctx context.Context, keys ...string) *redis.IntCmd
@@ -115,19 +116,52 @@ func (a *redisCache) Delete(ctx context.Context, ns, key string) error {
 	return nil
 }
 
+// Get and delete the key in a script, which is atomic without GETDEL (requires Redis 6.2 or later).
+var redisGetAndDeleteScript = redis.NewScript(`
+local v = redis.call("GET", KEYS[1])
+if v then
+	redis.call("DEL", KEYS[1])
+end
+return v
+`)
+
+// Get and delete the key atomically, the value is only returned to one of the concurrent callers.
 func (a *redisCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
-	value, ok, err := a.Get(ctx, ns, key)
+	cmd := redisGetAndDeleteScript.Run(ctx, a.cli, []string{a.getKey(ns, key)})
+	if err := cmd.Err(); err != nil {
+		if err == redis.Nil {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	val, err := cmd.Text()
 	if err != nil {
 		return "", false, err
-	} else if !ok {
-		return "", false, nil
 	}
+	return val, true, nil
+}
 
-	cmd := a.cli.Del(ctx, a.getKey(ns, key))
-	if err := cmd.Err(); err != nil && err != redis.Nil {
-		return "", false, err
+// Increment the key and set the expiration only when it is created by the increment.
+var redisIncrScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Increment the integer value of the key by one, the expiration is only set when the key is created.
+func (a *redisCache) Incr(ctx context.Context, ns, key string, expiration ...time.Duration) (int64, error) {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+
+	cmd := redisIncrScript.Run(ctx, a.cli, []string{a.getKey(ns, key)}, exp.Milliseconds())
+	if err := cmd.Err(); err != nil {
+		return 0, err
 	}
-	return value, true, nil
+	return cmd.Int64()
 }
 
 func (a *redisCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
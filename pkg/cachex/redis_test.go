@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +34,24 @@ func TestRedisCache(t *testing.T) {
 	assert.False(exists)
 	assert.Equal("", val)
 
+	for i := int64(1); i <= 3; i++ {
+		n, err := cache.Incr(ctx, "tt", "counter", time.Minute)
+		assert.Nil(err)
+		assert.Equal(i, n)
+	}
+	err = cache.Delete(ctx, "tt", "counter")
+	assert.Nil(err)
+
+	err = cache.Set(ctx, "tt", "once", "bar")
+	assert.Nil(err)
+	val, exists, err = cache.GetAndDelete(ctx, "tt", "once")
+	assert.Nil(err)
+	assert.True(exists)
+	assert.Equal("bar", val)
+	_, exists, err = cache.GetAndDelete(ctx, "tt", "once")
+	assert.Nil(err)
+	assert.False(exists)
+
 	tmap := make(map[string]bool)
 	for i := 0; i < 10; i++ {
 		key := fmt.Sprintf("foo%d", i)
@@ -0,0 +1,156 @@
+package captchax
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/LyricTian/gin-admin/v10/pkg/crypto/rand"
+	"github.com/LyricTian/gin-admin/v10/pkg/encoding/json"
+)
+
+const (
+	TypeDigits = "digits" // Image of random digits
+	TypeMath   = "math"   // Image of an arithmetic question
+	TypeAudio  = "audio"  // Spoken random digits (for accessibility)
+	TypeSlider = "slider" // Slide the piece into the gap of the background image
+)
+
+const (
+	PartImage = "image"
+	PartAudio = "audio"
+	PartPiece = "piece"
+)
+
+var ErrNotFound = errors.New("captcha: not found")
+
+type Config struct {
+	Type            string
+	Length          int // Number of the digits
+	Width           int // Width of the images
+	Height          int // Height of the images
+	Expiration      time.Duration
+	Language        string // Language of the audio (en/ja/ru/zh)
+	SliderTolerance int    // Maximum distance of the slider answer (pixels)
+	Namespace       string // Namespace of the store
+}
+
+// The challenge returned to the client, the answer is kept in the store
+type Challenge struct {
+	ID   string
+	Type string
+	// The slider piece (PieceSize x PieceSize) is moved horizontally at PieceY
+	PieceSize int
+	PieceY    int
+}
+
+// Captcha provider, the challenge can only be verified once
+type Captcha interface {
+	Type() string
+	New(ctx context.Context) (*Challenge, error)
+	// Regenerate the answer of the challenge with the same ID
+	Reload(ctx context.Context, id string) error
+	// Write the part of the challenge (empty is the default part), returns the content type
+	Write(ctx context.Context, w io.Writer, id, part string) (string, error)
+	Verify(ctx context.Context, id, answer string) (bool, error)
+}
+
+// Create the captcha provider of the type, the state is kept in the store (memory or redis).
+func New(cfg Config, store cachex.Cacher) (Captcha, error) {
+	if cfg.Length <= 0 {
+		cfg.Length = 4
+	}
+	if cfg.Width <= 0 {
+		cfg.Width = 400
+	}
+	if cfg.Height <= 0 {
+		cfg.Height = 160
+	}
+	if cfg.Expiration <= 0 {
+		cfg.Expiration = 10 * time.Minute
+	}
+	if cfg.Language == "" {
+		cfg.Language = "en"
+	}
+	if cfg.SliderTolerance <= 0 {
+		cfg.SliderTolerance = 5
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "captcha"
+	}
+
+	b := &base{config: cfg, store: store}
+	switch cfg.Type {
+	case "", TypeDigits:
+		return &digitsCaptcha{base: b, typ: TypeDigits}, nil
+	case TypeAudio:
+		return &digitsCaptcha{base: b, typ: TypeAudio}, nil
+	case TypeMath:
+		return &mathCaptcha{base: b}, nil
+	case TypeSlider:
+		return &sliderCaptcha{base: b}, nil
+	}
+	return nil, fmt.Errorf("captcha: unknown type %s", cfg.Type)
+}
+
+// State of the challenge in the store
+type state struct {
+	Answer string `json:"a"`
+	Seed   int64  `json:"s,omitempty"` // Seed of the random rendering, the same image is rendered on each request
+	Y      int    `json:"y,omitempty"`
+}
+
+type base struct {
+	config Config
+	store  cachex.Cacher
+}
+
+func (a *base) newID() (string, error) {
+	return rand.Random(20, rand.LdigitAndLetter)
+}
+
+func (a *base) save(ctx context.Context, id string, st *state) error {
+	return a.store.Set(ctx, a.config.Namespace, id, json.MarshalToString(st), a.config.Expiration)
+}
+
+func (a *base) load(ctx context.Context, id string) (*state, error) {
+	val, ok, err := a.store.Get(ctx, a.config.Namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	st := new(state)
+	if !ok || json.Unmarshal([]byte(val), st) != nil {
+		return nil, ErrNotFound
+	}
+	return st, nil
+}
+
+// Take the state out of the store, so that each challenge is verified once.
+func (a *base) take(ctx context.Context, id string) (*state, error) {
+	if id == "" {
+		return nil, ErrNotFound
+	}
+	val, ok, err := a.store.GetAndDelete(ctx, a.config.Namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	st := new(state)
+	if !ok || json.Unmarshal([]byte(val), st) != nil {
+		return nil, ErrNotFound
+	}
+	return st, nil
+}
+
+func (a *base) reload(ctx context.Context, id string, newState func() (*state, error)) error {
+	if _, err := a.load(ctx, id); err != nil {
+		return err
+	}
+	st, err := newState()
+	if err != nil {
+		return err
+	}
+	return a.save(ctx, id, st)
+}
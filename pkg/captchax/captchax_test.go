@@ -0,0 +1,135 @@
+package captchax
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/cachex"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCaptcha(t *testing.T, typ string) (Captcha, *base) {
+	store := cachex.NewMemoryCache(cachex.MemoryConfig{CleanupInterval: time.Minute})
+	c, err := New(Config{Type: typ, Width: 240, Height: 80}, store)
+	assert.Nil(t, err)
+	assert.Equal(t, typ, c.Type())
+
+	switch v := c.(type) {
+	case *digitsCaptcha:
+		return c, v.base
+	case *mathCaptcha:
+		return c, v.base
+	case *sliderCaptcha:
+		return c, v.base
+	}
+	t.Fatalf("unknown captcha %T", c)
+	return nil, nil
+}
+
+func answerOf(t *testing.T, b *base, id string) string {
+	st, err := b.load(context.Background(), id)
+	assert.Nil(t, err)
+	answer, _, _ := strings.Cut(st.Answer, ":")
+	return answer
+}
+
+func TestCaptcha(t *testing.T) {
+	ctx := context.Background()
+	for _, typ := range []string{TypeDigits, TypeAudio, TypeMath, TypeSlider} {
+		t.Run(typ, func(t *testing.T) {
+			c, b := newTestCaptcha(t, typ)
+			challenge, err := c.New(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, typ, challenge.Type)
+
+			// The same image is rendered on each request
+			var buf1, buf2 bytes.Buffer
+			contentType, err := c.Write(ctx, &buf1, challenge.ID, "")
+			assert.Nil(t, err)
+			_, _ = c.Write(ctx, &buf2, challenge.ID, "")
+			assert.Equal(t, buf1.Bytes(), buf2.Bytes())
+			if typ == TypeAudio {
+				assert.Equal(t, "audio/wav", contentType)
+				assert.True(t, bytes.HasPrefix(buf1.Bytes(), []byte("RIFF")))
+			} else {
+				assert.Equal(t, "image/png", contentType)
+				img, err := png.Decode(&buf1)
+				assert.Nil(t, err)
+				assert.Equal(t, 240, img.Bounds().Dx())
+			}
+
+			_, err = c.Write(ctx, &buf1, "unknown", "")
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = c.Write(ctx, &buf1, challenge.ID, "unknown")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// Wrong answers invalidate the challenge as well
+			answer := answerOf(t, b, challenge.ID)
+			ok, err := c.Verify(ctx, challenge.ID, "wrong")
+			assert.Nil(t, err)
+			assert.False(t, ok)
+			ok, _ = c.Verify(ctx, challenge.ID, answer)
+			assert.False(t, ok)
+
+			challenge, _ = c.New(ctx)
+			answer = answerOf(t, b, challenge.ID)
+			ok, err = c.Verify(ctx, challenge.ID, " "+answer+" ")
+			assert.Nil(t, err)
+			assert.True(t, ok)
+			ok, _ = c.Verify(ctx, challenge.ID, answer)
+			assert.False(t, ok)
+
+			assert.ErrorIs(t, c.Reload(ctx, "unknown"), ErrNotFound)
+			challenge, _ = c.New(ctx)
+			assert.Nil(t, c.Reload(ctx, challenge.ID))
+			ok, _ = c.Verify(ctx, challenge.ID, answerOf(t, b, challenge.ID))
+			assert.True(t, ok)
+		})
+	}
+}
+
+func TestDigitsAudioPart(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCaptcha(t, TypeDigits)
+	challenge, _ := c.New(ctx)
+
+	var buf bytes.Buffer
+	contentType, err := c.Write(ctx, &buf, challenge.ID, PartAudio)
+	assert.Nil(t, err)
+	assert.Equal(t, "audio/wav", contentType)
+}
+
+func TestSlider(t *testing.T) {
+	ctx := context.Background()
+	c, b := newTestCaptcha(t, TypeSlider)
+	challenge, _ := c.New(ctx)
+	assert.Equal(t, 20, challenge.PieceSize)
+	assert.True(t, challenge.PieceY >= 0 && challenge.PieceY <= 80-20)
+
+	var buf bytes.Buffer
+	_, err := c.Write(ctx, &buf, challenge.ID, PartPiece)
+	assert.Nil(t, err)
+	img, err := png.Decode(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, img.Bounds().Dx())
+
+	// The offset is accepted within the tolerance
+	x, _ := strconv.Atoi(answerOf(t, b, challenge.ID))
+	ok, _ := c.Verify(ctx, challenge.ID, strconv.Itoa(x+5))
+	assert.True(t, ok)
+
+	challenge, _ = c.New(ctx)
+	x, _ = strconv.Atoi(answerOf(t, b, challenge.ID))
+	ok, _ = c.Verify(ctx, challenge.ID, strconv.Itoa(x-6))
+	assert.False(t, ok)
+}
+
+func TestUnknownType(t *testing.T) {
+	_, err := New(Config{Type: "unknown"}, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,84 @@
+package captchax
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/LyricTian/captcha"
+)
+
+// Random digits as an image, or spoken as an audio (both parts are available for each type)
+type digitsCaptcha struct {
+	*base
+	typ string
+}
+
+func (a *digitsCaptcha) Type() string {
+	return a.typ
+}
+
+func (a *digitsCaptcha) newState() (*state, error) {
+	digits := captcha.RandomDigits(a.config.Length)
+	answer := make([]byte, len(digits))
+	for i, d := range digits {
+		answer[i] = '0' + d
+	}
+	return &state{Answer: string(answer)}, nil
+}
+
+func (a *digitsCaptcha) New(ctx context.Context) (*Challenge, error) {
+	id, err := a.newID()
+	if err != nil {
+		return nil, err
+	}
+	st, err := a.newState()
+	if err != nil {
+		return nil, err
+	} else if err := a.save(ctx, id, st); err != nil {
+		return nil, err
+	}
+	return &Challenge{ID: id, Type: a.typ}, nil
+}
+
+func (a *digitsCaptcha) Reload(ctx context.Context, id string) error {
+	return a.reload(ctx, id, a.newState)
+}
+
+func (a *digitsCaptcha) Write(ctx context.Context, w io.Writer, id, part string) (string, error) {
+	st, err := a.load(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	digits := make([]byte, len(st.Answer))
+	for i := range st.Answer {
+		digits[i] = st.Answer[i] - '0'
+	}
+
+	if part == "" {
+		part = PartImage
+		if a.typ == TypeAudio {
+			part = PartAudio
+		}
+	}
+	switch part {
+	case PartImage:
+		_, err = captcha.NewImage(id, digits, a.config.Width, a.config.Height).WriteTo(w)
+		return "image/png", err
+	case PartAudio:
+		_, err = captcha.NewAudio(id, digits, a.config.Language).WriteTo(w)
+		return "audio/wav", err
+	}
+	return "", ErrNotFound
+}
+
+func (a *digitsCaptcha) Verify(ctx context.Context, id, answer string) (bool, error) {
+	st, err := a.take(ctx, id)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return st.Answer == strings.TrimSpace(answer), nil
+}
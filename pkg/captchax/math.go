@@ -0,0 +1,197 @@
+package captchax
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+)
+
+// 5x7 glyphs of the arithmetic questions
+var mathGlyphs = map[rune][7]string{
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'+': {".....", "..#..", "..#..", "#####", "..#..", "..#..", "....."},
+	'-': {".....", ".....", ".....", "#####", ".....", ".....", "....."},
+	'x': {".....", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "....."},
+	'=': {".....", ".....", "#####", ".....", "#####", ".....", "....."},
+	'?': {".###.", "#...#", "....#", "...#.", "..#..", ".....", "..#.."},
+}
+
+// Arithmetic question of single digits as an image, the answer is the result
+type mathCaptcha struct {
+	*base
+}
+
+func (a *mathCaptcha) Type() string {
+	return TypeMath
+}
+
+func (a *mathCaptcha) newState() (*state, error) {
+	x, err := randomInt(1, 9)
+	if err != nil {
+		return nil, err
+	}
+	y, err := randomInt(1, 9)
+	if err != nil {
+		return nil, err
+	}
+	op, err := randomInt(0, 2)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := randomSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	var question string
+	var result int
+	switch op {
+	case 0:
+		question, result = fmt.Sprintf("%d+%d=?", x, y), x+y
+	case 1:
+		if x < y {
+			x, y = y, x
+		}
+		question, result = fmt.Sprintf("%d-%d=?", x, y), x-y
+	default:
+		question, result = fmt.Sprintf("%dx%d=?", x, y), x*y
+	}
+	// The question is kept after the answer, so that the image can be rendered again
+	return &state{Answer: strconv.Itoa(result) + ":" + question, Seed: seed}, nil
+}
+
+func (a *mathCaptcha) New(ctx context.Context) (*Challenge, error) {
+	id, err := a.newID()
+	if err != nil {
+		return nil, err
+	}
+	st, err := a.newState()
+	if err != nil {
+		return nil, err
+	} else if err := a.save(ctx, id, st); err != nil {
+		return nil, err
+	}
+	return &Challenge{ID: id, Type: TypeMath}, nil
+}
+
+func (a *mathCaptcha) Reload(ctx context.Context, id string) error {
+	return a.reload(ctx, id, a.newState)
+}
+
+func (a *mathCaptcha) Write(ctx context.Context, w io.Writer, id, part string) (string, error) {
+	if part != "" && part != PartImage {
+		return "", ErrNotFound
+	}
+	st, err := a.load(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	_, question, _ := strings.Cut(st.Answer, ":")
+	img := drawText(question, a.config.Width, a.config.Height, mrand.New(mrand.NewSource(st.Seed))) // #nosec G404
+	return "image/png", png.Encode(w, img)
+}
+
+func (a *mathCaptcha) Verify(ctx context.Context, id, answer string) (bool, error) {
+	st, err := a.take(ctx, id)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	result, _, _ := strings.Cut(st.Answer, ":")
+	return result == strings.TrimSpace(answer), nil
+}
+
+// Draw the text with the glyphs on a noisy background.
+func drawText(text string, width, height int, rng *mrand.Rand) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillNoise(img, rng)
+
+	// Each glyph takes 6 columns (with the spacing)
+	scale := height * 6 / 10 / 7
+	if s := width * 9 / 10 / (len(text) * 6); s < scale {
+		scale = s
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	x := (width - len(text)*6*scale) / 2
+	baseY := (height - 7*scale) / 2
+	for _, r := range text {
+		glyph := mathGlyphs[r]
+		c := color.RGBA{uint8(rng.Intn(120)), uint8(rng.Intn(120)), uint8(rng.Intn(120)), 255}
+		y := baseY + rng.Intn(scale*2+1) - scale
+		for row, line := range glyph {
+			for col, bit := range line {
+				if bit != '#' {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.Set(x+col*scale+dx, y+row*scale+dy, c)
+					}
+				}
+			}
+		}
+		x += 6 * scale
+	}
+
+	// Strike through lines
+	for i := 0; i < 3; i++ {
+		drawLine(img, rng.Intn(width/4), rng.Intn(height), width-rng.Intn(width/4), rng.Intn(height),
+			color.RGBA{uint8(rng.Intn(160)), uint8(rng.Intn(160)), uint8(rng.Intn(160)), 255})
+	}
+	return img
+}
+
+func fillNoise(img *image.RGBA, rng *mrand.Rand) {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := uint8(220 + rng.Intn(36))
+			img.Set(x, y, color.RGBA{v, v, uint8(200 + rng.Intn(56)), 255})
+		}
+	}
+}
+
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := x1-x0, y1-y0
+	steps := abs(dx)
+	if abs(dy) > steps {
+		steps = abs(dy)
+	}
+	if steps == 0 {
+		img.Set(x0, y0, c)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		x := x0 + dx*i/steps
+		y := y0 + dy*i/steps
+		img.Set(x, y, c)
+		img.Set(x, y+1, c)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
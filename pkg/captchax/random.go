@@ -0,0 +1,26 @@
+package captchax
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// Random integer in [min, max].
+func randomInt(min, max int) (int, error) {
+	if max <= min {
+		return min, nil
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min+1)))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+func randomSeed() (int64, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return 0, err
+	}
+	return n.Int64(), nil
+}
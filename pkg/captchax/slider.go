@@ -0,0 +1,158 @@
+package captchax
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+)
+
+// Slide the piece into the gap of the background image, the answer is the X offset of the gap
+type sliderCaptcha struct {
+	*base
+}
+
+func (a *sliderCaptcha) Type() string {
+	return TypeSlider
+}
+
+func (a *sliderCaptcha) pieceSize() int {
+	size := a.config.Height / 4
+	if size < 20 {
+		size = 20
+	}
+	return size
+}
+
+func (a *sliderCaptcha) newState() (*state, error) {
+	size := a.pieceSize()
+	// The gap is kept away from the start position of the piece
+	x, err := randomInt(size*2, a.config.Width-size-1)
+	if err != nil {
+		return nil, err
+	}
+	y, err := randomInt(0, a.config.Height-size)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := randomSeed()
+	if err != nil {
+		return nil, err
+	}
+	return &state{Answer: strconv.Itoa(x), Seed: seed, Y: y}, nil
+}
+
+func (a *sliderCaptcha) New(ctx context.Context) (*Challenge, error) {
+	id, err := a.newID()
+	if err != nil {
+		return nil, err
+	}
+	st, err := a.newState()
+	if err != nil {
+		return nil, err
+	} else if err := a.save(ctx, id, st); err != nil {
+		return nil, err
+	}
+	return &Challenge{ID: id, Type: TypeSlider, PieceSize: a.pieceSize(), PieceY: st.Y}, nil
+}
+
+// The piece is moved to the new position on reload, the client should take the challenge again.
+func (a *sliderCaptcha) Reload(ctx context.Context, id string) error {
+	return a.reload(ctx, id, a.newState)
+}
+
+func (a *sliderCaptcha) Write(ctx context.Context, w io.Writer, id, part string) (string, error) {
+	st, err := a.load(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	x, _ := strconv.Atoi(st.Answer)
+	size := a.pieceSize()
+	bg := drawBackground(a.config.Width, a.config.Height, mrand.New(mrand.NewSource(st.Seed))) // #nosec G404
+	gap := image.Rect(x, st.Y, x+size, st.Y+size)
+
+	switch part {
+	case "", PartImage:
+		img := image.NewRGBA(bg.Bounds())
+		draw.Draw(img, img.Bounds(), bg, image.Point{}, draw.Src)
+		for py := gap.Min.Y; py < gap.Max.Y; py++ {
+			for px := gap.Min.X; px < gap.Max.X; px++ {
+				c := img.RGBAAt(px, py)
+				img.SetRGBA(px, py, color.RGBA{c.R / 3, c.G / 3, c.B / 3, 255})
+			}
+		}
+		drawBorder(img, gap, color.RGBA{255, 255, 255, 200})
+		return "image/png", png.Encode(w, img)
+	case PartPiece:
+		piece := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(piece, piece.Bounds(), bg, gap.Min, draw.Src)
+		drawBorder(piece, piece.Bounds(), color.RGBA{255, 255, 255, 255})
+		return "image/png", png.Encode(w, piece)
+	}
+	return "", ErrNotFound
+}
+
+// The answer is the X offset of the piece, the distance to the gap should be within the tolerance.
+func (a *sliderCaptcha) Verify(ctx context.Context, id, answer string) (bool, error) {
+	st, err := a.take(ctx, id)
+	if err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	x, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil {
+		return false, nil
+	}
+	expected, _ := strconv.Atoi(st.Answer)
+	return abs(x-expected) <= a.config.SliderTolerance, nil
+}
+
+// Random gradient with shapes, so that the gap cannot be found by a plain color.
+func drawBackground(width, height int, rng *mrand.Rand) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	from := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	to := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	for x := 0; x < width; x++ {
+		c := color.RGBA{
+			uint8(int(from.R) + (int(to.R)-int(from.R))*x/width),
+			uint8(int(from.G) + (int(to.G)-int(from.G))*x/width),
+			uint8(int(from.B) + (int(to.B)-int(from.B))*x/width),
+			255,
+		}
+		for y := 0; y < height; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	for i := 0; i < 12; i++ {
+		c := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+		cx, cy, r := rng.Intn(width), rng.Intn(height), 5+rng.Intn(height/3+1)
+		for y := cy - r; y <= cy+r; y++ {
+			for x := cx - r; x <= cx+r; x++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r && image.Pt(x, y).In(img.Bounds()) {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func drawBorder(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		img.SetRGBA(x, rect.Min.Y, c)
+		img.SetRGBA(x, rect.Max.Y-1, c)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		img.SetRGBA(rect.Min.X, y, c)
+		img.SetRGBA(rect.Max.X-1, y, c)
+	}
+}
@@ -45,3 +45,9 @@ func GeneratePassword(password string) (string, error) {
 func CompareHashAndPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+// Check whether the string is a bcrypt password hash
+func IsPasswordHash(s string) bool {
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
@@ -15,6 +15,12 @@ func TestGeneratePassword(t *testing.T) {
 	if err := CompareHashAndPassword(hashPwd, origin); err != nil {
 		t.Error("Unmatched password: ", err.Error())
 	}
+
+	if !IsPasswordHash(hashPwd) {
+		t.Error("Not a password hash: ", hashPwd)
+	} else if IsPasswordHash(MD5String(origin)) {
+		t.Error("MD5 hash is not a password hash")
+	}
 }
 
 func TestMD5(t *testing.T) {
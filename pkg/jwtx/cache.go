@@ -3,6 +3,8 @@ package jwtx
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -21,6 +23,7 @@ func NewMemoryCache(cfg MemoryConfig) Cacher {
 }
 
 type memCache struct {
+	mu    sync.Mutex // Serialize GetAndDelete
 	cache *cache.Cache
 }
 
@@ -46,6 +49,18 @@ func (a *memCache) Get(ctx context.Context, ns, key string) (string, bool, error
 	return val.(string), ok, nil
 }
 
+func (a *memCache) GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	val, ok := a.cache.Get(a.getKey(ns, key))
+	if !ok {
+		return "", false, nil
+	}
+	a.cache.Delete(a.getKey(ns, key))
+	return val.(string), true, nil
+}
+
 func (a *memCache) Exists(ctx context.Context, ns, key string) (bool, error) {
 	_, ok := a.cache.Get(a.getKey(ns, key))
 	return ok, nil
@@ -56,6 +71,18 @@ func (a *memCache) Delete(ctx context.Context, ns, key string) error {
 	return nil
 }
 
+func (a *memCache) Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error {
+	prefix := a.getKey(ns, "")
+	for k, v := range a.cache.Items() {
+		if strings.HasPrefix(k, prefix) {
+			if !fn(ctx, strings.TrimPrefix(k, prefix), v.Object.(string)) {
+				break
+			}
+		}
+	}
+	return nil
+}
+
 func (a *memCache) Close(ctx context.Context) error {
 	a.cache.Flush()
 	return nil
@@ -2,34 +2,80 @@ package jwtx
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	jsoniter "github.com/json-iterator/go"
 )
 
 type Auther interface {
 	// Generate a JWT (JSON Web Token) with the provided subject.
 	GenerateToken(ctx context.Context, subject string) (TokenInfo, error)
+	// Generate an access token of the subject on behalf of the actor, which expires in n seconds and cannot be
+	// refreshed. The session is recorded for both the subject and the actor, so it is revoked with the sessions of
+	// either of them.
+	GenerateImpersonationToken(ctx context.Context, subject, actor string, expired int) (TokenInfo, error)
+	// Exchange a refresh token for a new token pair. The presented refresh token is rotated,
+	// presenting an already rotated refresh token revokes the whole token family.
+	// The optional check function is called with the token subject before the new pair is issued.
+	RefreshToken(ctx context.Context, refreshToken string, check func(ctx context.Context, subject string) error) (TokenInfo, error)
 	// Invalidate a token by removing it from the token store.
 	DestroyToken(ctx context.Context, accessToken string) error
 	// Parse the subject (or user identifier) from a given access token.
 	ParseSubject(ctx context.Context, accessToken string) (string, error)
+	// Parse and validate the claims of a given access token, the last seen time of its session is updated.
+	ParseClaims(ctx context.Context, accessToken string) (*Claims, error)
+	// Query the active sessions (one per login) of the subject.
+	QuerySessions(ctx context.Context, subject string) ([]*Session, error)
+	// Revoke a session of the subject, all tokens issued within it are invalidated.
+	RevokeSession(ctx context.Context, subject, sessionID string) error
+	// Revoke all sessions of the subject, including the impersonation sessions in which the subject is the actor.
+	RevokeSessions(ctx context.Context, subject string) error
+	// Get the public keys used to verify the access tokens (empty for HMAC signing).
+	JWKS() *JSONWebKeySet
 	// Release any resources held by the JWTAuth instance.
 	Release(ctx context.Context) error
 }
 
 const defaultKey = "CG24SDVP8OHPK395GB5G"
 
-var ErrInvalidToken = errors.New("Invalid token")
+var (
+	ErrInvalidToken        = errors.New("Invalid token")
+	ErrRefreshTokenReused  = errors.New("Refresh token reused")
+	ErrRefreshNotSupported = errors.New("Refresh token requires a token store")
+	ErrSessionNotFound     = errors.New("Session not found")
+)
+
+// Claims of the access token, the family ID links all tokens issued by the same login (the session ID).
+type Claims struct {
+	jwt.StandardClaims
+	FamilyID string `json:"fid,omitempty"`
+	Actor    string `json:"act,omitempty"` // The real user acting as the subject (impersonation)
+	Tenant   string `json:"tid,omitempty"` // The tenant of the subject (empty for the default tenant)
+}
 
 type options struct {
-	signingMethod jwt.SigningMethod
-	signingKey    []byte
-	signingKey2   []byte
-	keyFuncs      []func(*jwt.Token) (interface{}, error)
-	expired       int
-	tokenType     string
+	signingMethod  jwt.SigningMethod
+	signingKey     interface{} // []byte (HMAC) or crypto.Signer
+	oldSigningKey  []byte
+	oldPublicKeys  []crypto.PublicKey
+	keyID          string
+	verifyKeys     []*verifyKey
+	expired        int
+	refreshExpired int
+	tokenType      string
+}
+
+// Key used to verify the signature of tokens, matched by the "kid" header
+type verifyKey struct {
+	kid string
+	key interface{} // []byte (HMAC) or crypto.PublicKey
 }
 
 type Option func(*options)
@@ -40,47 +86,71 @@ func SetSigningMethod(method jwt.SigningMethod) Option {
 	}
 }
 
+// Set the HMAC secret key, the old key is still accepted for verification
 func SetSigningKey(key, oldKey string) Option {
 	return func(o *options) {
 		o.signingKey = []byte(key)
 		if oldKey != "" && key != oldKey {
-			o.signingKey2 = []byte(oldKey)
+			o.oldSigningKey = []byte(oldKey)
 		}
 	}
 }
 
+// Set the private key for asymmetric signing methods (RSA, ECDSA or Ed25519)
+func SetPrivateKey(key crypto.Signer) Option {
+	return func(o *options) {
+		o.signingKey = key
+	}
+}
+
+// Set the public keys of retired key pairs, the tokens signed by them are still accepted
+func SetOldPublicKeys(keys ...crypto.PublicKey) Option {
+	return func(o *options) {
+		o.oldPublicKeys = append(o.oldPublicKeys, keys...)
+	}
+}
+
 func SetExpired(expired int) Option {
 	return func(o *options) {
 		o.expired = expired
 	}
 }
 
+// Set the lifetime of refresh tokens (seconds)
+func SetRefreshExpired(expired int) Option {
+	return func(o *options) {
+		o.refreshExpired = expired
+	}
+}
+
 func New(store Storer, opts ...Option) Auther {
 	o := options{
-		tokenType:     "Bearer",
-		expired:       7200,
-		signingMethod: jwt.SigningMethodHS512,
-		signingKey:    []byte(defaultKey),
+		tokenType:      "Bearer",
+		expired:        7200,
+		refreshExpired: 604800,
+		signingMethod:  jwt.SigningMethodHS512,
+		signingKey:     []byte(defaultKey),
 	}
 
 	for _, opt := range opts {
 		opt(&o)
 	}
 
-	o.keyFuncs = append(o.keyFuncs, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return o.signingKey, nil
-	})
+	var key interface{}
+	switch k := o.signingKey.(type) {
+	case crypto.Signer:
+		key = k.Public()
+	default:
+		key = k
+	}
+	o.keyID = KeyID(key)
+	o.verifyKeys = append(o.verifyKeys, &verifyKey{kid: o.keyID, key: key})
 
-	if o.signingKey2 != nil {
-		o.keyFuncs = append(o.keyFuncs, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, ErrInvalidToken
-			}
-			return o.signingKey2, nil
-		})
+	if o.oldSigningKey != nil {
+		o.verifyKeys = append(o.verifyKeys, &verifyKey{kid: KeyID(o.oldSigningKey), key: o.oldSigningKey})
+	}
+	for _, k := range o.oldPublicKeys {
+		o.verifyKeys = append(o.verifyKeys, &verifyKey{kid: KeyID(k), key: k})
 	}
 
 	return &JWTAuth{
@@ -94,18 +164,97 @@ type JWTAuth struct {
 	store Storer
 }
 
+// State of a refresh token kept in the token store
+type refreshTokenItem struct {
+	Subject   string `json:"sub"`
+	Tenant    string `json:"tid,omitempty"`
+	FamilyID  string `json:"fid"`
+	ExpiresAt int64  `json:"exp"`
+	Rotated   bool   `json:"rotated"`
+}
+
+func newRandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Refresh tokens are opaque, only their hashes are stored.
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
 func (a *JWTAuth) GenerateToken(ctx context.Context, subject string) (TokenInfo, error) {
+	familyID, err := newRandomString(16)
+	if err != nil {
+		return nil, err
+	}
+	return a.generateToken(ctx, subject, familyID)
+}
+
+func (a *JWTAuth) GenerateImpersonationToken(ctx context.Context, subject, actor string, expired int) (TokenInfo, error) {
+	familyID, err := newRandomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(expired) * time.Second).Unix()
+	tokenStr, err := a.signToken(&Claims{
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt,
+			NotBefore: now.Unix(),
+			Subject:   subject,
+		},
+		FamilyID: familyID,
+		Actor:    actor,
+		Tenant:   FromSessionMeta(ctx).Tenant,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if store := a.store; store != nil {
+		meta := FromSessionMeta(ctx)
+		meta.Actor = actor
+		if err := a.recordSession(NewSessionMeta(ctx, meta), subject, familyID, expiresAt); err != nil {
+			return nil, err
+		} else if err := a.recordActorSession(ctx, actor, familyID, expiresAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return &tokenInfo{
+		ExpiresAt:   expiresAt,
+		TokenType:   a.opts.tokenType,
+		AccessToken: tokenStr,
+	}, nil
+}
+
+func (a *JWTAuth) signToken(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(a.opts.signingMethod, claims)
+	token.Header["kid"] = a.opts.keyID
+	return token.SignedString(a.opts.signingKey)
+}
+
+func (a *JWTAuth) generateToken(ctx context.Context, subject, familyID string) (TokenInfo, error) {
 	now := time.Now()
 	expiresAt := now.Add(time.Duration(a.opts.expired) * time.Second).Unix()
 
-	token := jwt.NewWithClaims(a.opts.signingMethod, &jwt.StandardClaims{
-		IssuedAt:  now.Unix(),
-		ExpiresAt: expiresAt,
-		NotBefore: now.Unix(),
-		Subject:   subject,
+	tokenStr, err := a.signToken(&Claims{
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt,
+			NotBefore: now.Unix(),
+			Subject:   subject,
+		},
+		FamilyID: familyID,
+		Tenant:   FromSessionMeta(ctx).Tenant,
 	})
-
-	tokenStr, err := token.SignedString(a.opts.signingKey)
 	if err != nil {
 		return nil, err
 	}
@@ -115,17 +264,126 @@ func (a *JWTAuth) GenerateToken(ctx context.Context, subject string) (TokenInfo,
 		TokenType:   a.opts.tokenType,
 		AccessToken: tokenStr,
 	}
+
+	if store := a.store; store != nil {
+		refreshToken, err := newRandomString(32)
+		if err != nil {
+			return nil, err
+		}
+
+		refreshExpiration := time.Duration(a.opts.refreshExpired) * time.Second
+		item := refreshTokenItem{
+			Subject:   subject,
+			Tenant:    FromSessionMeta(ctx).Tenant,
+			FamilyID:  familyID,
+			ExpiresAt: now.Add(refreshExpiration).Unix(),
+		}
+		buf, err := jsoniter.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		err = store.SetRefreshToken(ctx, hashRefreshToken(refreshToken), string(buf), refreshExpiration)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.recordSession(ctx, subject, familyID, item.ExpiresAt); err != nil {
+			return nil, err
+		}
+		tokenInfo.RefreshToken = refreshToken
+		tokenInfo.RefreshExpiresAt = item.ExpiresAt
+	}
+
 	return tokenInfo, nil
 }
 
-func (a *JWTAuth) parseToken(tokenStr string) (*jwt.StandardClaims, error) {
+func (a *JWTAuth) RefreshToken(ctx context.Context, refreshToken string, check func(ctx context.Context, subject string) error) (TokenInfo, error) {
+	if a.store == nil {
+		return nil, ErrRefreshNotSupported
+	} else if refreshToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	// The token is claimed before it is checked, so a token is rotated by one of the concurrent refreshes only
+	tokenKey := hashRefreshToken(refreshToken)
+	val, ok, err := a.store.ClaimRefreshToken(ctx, tokenKey)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	var item refreshTokenItem
+	if err := jsoniter.UnmarshalFromString(val, &item); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	remaining := time.Until(time.Unix(item.ExpiresAt, 0))
+	if remaining <= 0 {
+		return nil, ErrInvalidToken
+	}
+
+	if revoked, err := a.store.CheckFamily(ctx, item.FamilyID); err != nil {
+		return nil, err
+	} else if revoked {
+		return nil, ErrInvalidToken
+	}
+
+	// A rotated refresh token is presented again, the family is considered compromised.
+	if item.Rotated {
+		err := a.store.RevokeFamily(ctx, item.FamilyID, time.Duration(a.opts.refreshExpired)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.store.DeleteSession(ctx, item.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	// The claimed token is saved as rotated to detect the reuse, it is restored if the subject fails the check
+	item.Rotated = true
+	buf, err := jsoniter.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.store.SetRefreshToken(ctx, tokenKey, string(buf), remaining); err != nil {
+		return nil, err
+	}
+
+	// The new token pair is issued in the tenant of the refresh token
+	meta := FromSessionMeta(ctx)
+	meta.Tenant = item.Tenant
+	ctx = NewSessionMeta(ctx, meta)
+
+	if check != nil {
+		if err := check(ctx, item.Subject); err != nil {
+			if err := a.store.SetRefreshToken(ctx, tokenKey, val, remaining); err != nil {
+				return nil, err
+			}
+			return nil, err
+		}
+	}
+
+	return a.generateToken(ctx, item.Subject, item.FamilyID)
+}
+
+func (a *JWTAuth) parseToken(tokenStr string) (*Claims, error) {
 	var (
 		token *jwt.Token
 		err   error
 	)
 
-	for _, keyFunc := range a.opts.keyFuncs {
-		token, err = jwt.ParseWithClaims(tokenStr, &jwt.StandardClaims{}, keyFunc)
+	for _, vk := range a.opts.verifyKeys {
+		token, err = jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+			// Tokens issued before key IDs were introduced have no "kid" header
+			if kid, ok := t.Header["kid"].(string); ok && kid != vk.kid {
+				return nil, ErrInvalidToken
+			} else if !isMethodKey(t.Method, vk.key) {
+				return nil, ErrInvalidToken
+			}
+			return vk.key, nil
+		})
 		if err != nil || token == nil || !token.Valid {
 			continue
 		}
@@ -136,7 +394,7 @@ func (a *JWTAuth) parseToken(tokenStr string) (*jwt.StandardClaims, error) {
 		return nil, ErrInvalidToken
 	}
 
-	return token.Claims.(*jwt.StandardClaims), nil
+	return token.Claims.(*Claims), nil
 }
 
 func (a *JWTAuth) callStore(fn func(Storer) error) error {
@@ -154,18 +412,38 @@ func (a *JWTAuth) DestroyToken(ctx context.Context, tokenStr string) error {
 
 	return a.callStore(func(store Storer) error {
 		expired := time.Until(time.Unix(claims.ExpiresAt, 0))
-		return store.Set(ctx, tokenStr, expired)
+		if err := store.Set(ctx, tokenStr, expired); err != nil {
+			return err
+		}
+
+		// Also invalidate the refresh tokens issued with this access token
+		if claims.FamilyID != "" {
+			err := store.RevokeFamily(ctx, claims.FamilyID, time.Duration(a.opts.refreshExpired)*time.Second)
+			if err != nil {
+				return err
+			}
+			return store.DeleteSession(ctx, claims.FamilyID)
+		}
+		return nil
 	})
 }
 
 func (a *JWTAuth) ParseSubject(ctx context.Context, tokenStr string) (string, error) {
+	claims, err := a.ParseClaims(ctx, tokenStr)
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
+
+func (a *JWTAuth) ParseClaims(ctx context.Context, tokenStr string) (*Claims, error) {
 	if tokenStr == "" {
-		return "", ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
 	claims, err := a.parseToken(tokenStr)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	err = a.callStore(func(store Storer) error {
@@ -174,13 +452,36 @@ func (a *JWTAuth) ParseSubject(ctx context.Context, tokenStr string) (string, er
 		} else if exists {
 			return ErrInvalidToken
 		}
+
+		if claims.FamilyID != "" {
+			if revoked, err := store.CheckFamily(ctx, claims.FamilyID); err != nil {
+				return err
+			} else if revoked {
+				return ErrInvalidToken
+			}
+			return a.touchSession(ctx, claims.FamilyID)
+		}
 		return nil
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return claims.Subject, nil
+	return claims, nil
+}
+
+func (a *JWTAuth) JWKS() *JSONWebKeySet {
+	set := &JSONWebKeySet{Keys: []JSONWebKey{}}
+	for i, vk := range a.opts.verifyKeys {
+		var alg string
+		if i == 0 {
+			alg = a.opts.signingMethod.Alg()
+		}
+		if jwk, ok := toJSONWebKey(vk.key, alg); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
 }
 
 func (a *JWTAuth) Release(ctx context.Context) error {
@@ -2,9 +2,20 @@ package jwtx
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,3 +46,322 @@ func TestAuth(t *testing.T) {
 	err = jwtAuth.Release(ctx)
 	assert.Nil(t, err)
 }
+
+func TestRefreshToken(t *testing.T) {
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Second})
+
+	store := NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := New(store)
+
+	userID := "test"
+	token, err := jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token.GetRefreshToken())
+	assert.Greater(t, token.GetRefreshExpiresAt(), token.GetExpiresAt())
+
+	// rotate the refresh token
+	newToken, err := jwtAuth.RefreshToken(ctx, token.GetRefreshToken(), func(ctx context.Context, subject string) error {
+		assert.Equal(t, userID, subject)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.NotEqual(t, token.GetRefreshToken(), newToken.GetRefreshToken())
+
+	id, err := jwtAuth.ParseSubject(ctx, newToken.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, userID, id)
+
+	// reuse of a rotated refresh token revokes the whole family
+	_, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken(), nil)
+	assert.EqualError(t, err, ErrRefreshTokenReused.Error())
+
+	_, err = jwtAuth.ParseSubject(ctx, newToken.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	_, err = jwtAuth.RefreshToken(ctx, newToken.GetRefreshToken(), nil)
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	_, err = jwtAuth.RefreshToken(ctx, "unknown", nil)
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	// destroying the access token also invalidates its refresh token
+	token, err = jwtAuth.GenerateToken(ctx, userID)
+	assert.Nil(t, err)
+	err = jwtAuth.DestroyToken(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	_, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken(), nil)
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestRefreshTokenClaim(t *testing.T) {
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Second})
+
+	store := NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := New(store)
+
+	// the token failing the check is kept for the next refresh
+	token, err := jwtAuth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	_, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken(), func(ctx context.Context, subject string) error {
+		return ErrInvalidToken
+	})
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	// only one of the concurrent refreshes rotates the token
+	var (
+		wg        sync.WaitGroup
+		succeeded int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := jwtAuth.RefreshToken(ctx, token.GetRefreshToken(), nil); err == nil {
+				atomic.AddInt32(&succeeded, 1)
+			} else if err != ErrInvalidToken && err != ErrRefreshTokenReused {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), succeeded)
+}
+
+func TestAsymmetricAuth(t *testing.T) {
+	ctx := context.Background()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		method jwt.SigningMethod
+		key    crypto.Signer
+		kty    string
+	}{
+		{jwt.SigningMethodRS256, rsaKey, "RSA"},
+		{jwt.SigningMethodPS384, rsaKey, "RSA"},
+		{jwt.SigningMethodES256, ecKey, "EC"},
+		{jwt.SigningMethodEdDSA, edKey, "OKP"},
+	}
+
+	for _, tt := range tests {
+		der, err := x509.MarshalPKCS8PrivateKey(tt.key)
+		assert.Nil(t, err)
+		signer, err := ParsePrivateKeyFromPEM(tt.method, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		assert.Nil(t, err)
+
+		jwtAuth := New(nil, SetSigningMethod(tt.method), SetPrivateKey(signer))
+		token, err := jwtAuth.GenerateToken(ctx, "test")
+		assert.Nil(t, err)
+
+		parsed, _ := jwt.Parse(token.GetAccessToken(), nil)
+		assert.Equal(t, tt.method.Alg(), parsed.Header["alg"])
+		assert.Equal(t, KeyID(signer.Public()), parsed.Header["kid"])
+
+		id, err := jwtAuth.ParseSubject(ctx, token.GetAccessToken())
+		assert.Nil(t, err)
+		assert.Equal(t, "test", id)
+
+		jwks := jwtAuth.JWKS()
+		assert.Len(t, jwks.Keys, 1)
+		assert.Equal(t, tt.kty, jwks.Keys[0].Kty)
+		assert.Equal(t, tt.method.Alg(), jwks.Keys[0].Alg)
+		assert.Equal(t, parsed.Header["kid"], jwks.Keys[0].Kid)
+
+		publicKey, err := jwks.Keys[0].PublicKey()
+		assert.Nil(t, err)
+		assert.Equal(t, signer.Public(), publicKey)
+	}
+
+	// The key type must match the signing method
+	der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	assert.Nil(t, err)
+	_, err = ParsePrivateKeyFromPEM(jwt.SigningMethodRS256, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	// Rotate the key pair, tokens signed by the retired key are still accepted
+	oldAuth := New(nil, SetSigningMethod(jwt.SigningMethodRS256), SetPrivateKey(rsaKey))
+	oldToken, err := oldAuth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+
+	newAuth := New(nil, SetSigningMethod(jwt.SigningMethodEdDSA), SetPrivateKey(edKey), SetOldPublicKeys(rsaKey.Public()))
+	id, err := newAuth.ParseSubject(ctx, oldToken.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "test", id)
+	assert.Len(t, newAuth.JWKS().Keys, 2)
+
+	// HMAC tokens are never verified with a public key
+	hmacAuth := New(nil, SetSigningMethod(jwt.SigningMethodHS256), SetSigningKey("secret", ""))
+	hmacToken, err := hmacAuth.GenerateToken(ctx, "test")
+	assert.Nil(t, err)
+	assert.Empty(t, hmacAuth.JWKS().Keys)
+	_, err = newAuth.ParseSubject(ctx, hmacToken.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestSessions(t *testing.T) {
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Second})
+
+	store := NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := New(store)
+
+	userID := "test"
+	token1, err := jwtAuth.GenerateToken(NewSessionMeta(ctx, SessionMeta{Device: "Chrome on Windows", IP: "10.0.0.1"}), userID)
+	assert.Nil(t, err)
+	token2, err := jwtAuth.GenerateToken(NewSessionMeta(ctx, SessionMeta{Device: "Safari on iOS", IP: "10.0.0.2"}), userID)
+	assert.Nil(t, err)
+
+	sessions, err := jwtAuth.QuerySessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+
+	claims, err := jwtAuth.ParseClaims(ctx, token1.GetAccessToken())
+	assert.Nil(t, err)
+	assert.NotEmpty(t, claims.FamilyID)
+
+	// refreshing keeps the session
+	token1, err = jwtAuth.RefreshToken(ctx, token1.GetRefreshToken(), nil)
+	assert.Nil(t, err)
+	sessions, err = jwtAuth.QuerySessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+
+	err = jwtAuth.RevokeSession(ctx, "other", claims.FamilyID)
+	assert.EqualError(t, err, ErrSessionNotFound.Error())
+
+	err = jwtAuth.RevokeSession(ctx, userID, claims.FamilyID)
+	assert.Nil(t, err)
+	_, err = jwtAuth.ParseSubject(ctx, token1.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+	_, err = jwtAuth.RefreshToken(ctx, token1.GetRefreshToken(), nil)
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	sessions, err = jwtAuth.QuerySessions(ctx, userID)
+	assert.Nil(t, err)
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, "Safari on iOS", sessions[0].Device)
+		assert.Equal(t, "10.0.0.2", sessions[0].IP)
+	}
+
+	err = jwtAuth.RevokeSessions(ctx, userID)
+	assert.Nil(t, err)
+	_, err = jwtAuth.ParseSubject(ctx, token2.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	sessions, err = jwtAuth.QuerySessions(ctx, userID)
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestImpersonationToken(t *testing.T) {
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Second})
+
+	store := NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := New(store)
+
+	token, err := jwtAuth.GenerateImpersonationToken(ctx, "test", "admin", 600)
+	assert.Nil(t, err)
+	assert.Empty(t, token.GetRefreshToken())
+	assert.InDelta(t, time.Now().Add(600*time.Second).Unix(), token.GetExpiresAt(), 1)
+
+	claims, err := jwtAuth.ParseClaims(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "test", claims.Subject)
+	assert.Equal(t, "admin", claims.Actor)
+
+	sessions, err := jwtAuth.QuerySessions(ctx, "test")
+	assert.Nil(t, err)
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, "admin", sessions[0].Actor)
+	}
+
+	// revoked with the sessions of the subject
+	err = jwtAuth.RevokeSessions(ctx, "test")
+	assert.Nil(t, err)
+	_, err = jwtAuth.ParseClaims(ctx, token.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+
+	// revoked with the sessions of the actor
+	token, err = jwtAuth.GenerateImpersonationToken(ctx, "test", "admin", 600)
+	assert.Nil(t, err)
+	err = jwtAuth.RevokeSessions(ctx, "admin")
+	assert.Nil(t, err)
+	_, err = jwtAuth.ParseClaims(ctx, token.GetAccessToken())
+	assert.EqualError(t, err, ErrInvalidToken.Error())
+	sessions, err = jwtAuth.QuerySessions(ctx, "test")
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Second})
+
+	store := NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := New(store)
+
+	// The concurrent logins (and impersonations by the same actor) are all indexed
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := jwtAuth.GenerateToken(ctx, "test")
+			assert.Nil(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := jwtAuth.GenerateImpersonationToken(ctx, "other", "admin", 600)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+
+	sessions, err := jwtAuth.QuerySessions(ctx, "test")
+	assert.Nil(t, err)
+	assert.Len(t, sessions, n)
+	sessions, err = jwtAuth.QuerySessions(ctx, "other")
+	assert.Nil(t, err)
+	assert.Len(t, sessions, n)
+
+	err = jwtAuth.RevokeSessions(ctx, "admin")
+	assert.Nil(t, err)
+	sessions, err = jwtAuth.QuerySessions(ctx, "other")
+	assert.Nil(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestTenantClaims(t *testing.T) {
+	cache := NewMemoryCache(MemoryConfig{CleanupInterval: time.Second})
+
+	store := NewStoreWithCache(cache)
+	ctx := context.Background()
+	jwtAuth := New(store)
+
+	token, err := jwtAuth.GenerateToken(NewSessionMeta(ctx, SessionMeta{Tenant: "t1"}), "test")
+	assert.Nil(t, err)
+
+	claims, err := jwtAuth.ParseClaims(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "t1", claims.Tenant)
+
+	// the refreshed token keeps the tenant, which is passed to the check function
+	token, err = jwtAuth.RefreshToken(ctx, token.GetRefreshToken(), func(ctx context.Context, subject string) error {
+		assert.Equal(t, "t1", FromSessionMeta(ctx).Tenant)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	claims, err = jwtAuth.ParseClaims(ctx, token.GetAccessToken())
+	assert.Nil(t, err)
+	assert.Equal(t, "t1", claims.Tenant)
+}
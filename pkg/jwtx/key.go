@@ -0,0 +1,251 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+	jsoniter "github.com/json-iterator/go"
+)
+
+var ErrInvalidKey = errors.New("Invalid key")
+
+// JSON Web Key (RFC 7517), only the public members are exported.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSON Web Key Set, served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Parse a PEM encoded private key (PKCS#1, PKCS#8 or SEC 1) and check that it can be used with the signing method.
+func ParsePrivateKeyFromPEM(method jwt.SigningMethod, b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM data found", ErrInvalidKey)
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok || !isMethodKey(method, signer.Public()) {
+		return nil, fmt.Errorf("%w: key type does not match signing method %s", ErrInvalidKey, method.Alg())
+	}
+	return signer, nil
+}
+
+// Parse a PEM encoded public key (PKIX or PKCS#1).
+func ParsePublicKeyFromPEM(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM data found", ErrInvalidKey)
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+		return key, nil
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+		return cert.PublicKey, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	return key, nil
+}
+
+// Check whether the key (HMAC secret or public key) can be used to verify the signing method.
+func isMethodKey(method jwt.SigningMethod, key interface{}) bool {
+	switch m := method.(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok := key.([]byte)
+		return ok
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		k, ok := key.(*ecdsa.PublicKey)
+		return ok && k.Curve.Params().BitSize == m.CurveBits
+	case *jwt.SigningMethodEd25519:
+		_, ok := key.(ed25519.PublicKey)
+		return ok
+	}
+	return false
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Encode the public key as JWK, alg is optional.
+func toJSONWebKey(key interface{}, alg string) (JSONWebKey, bool) {
+	var jwk JSONWebKey
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64URL(k.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		params := k.Curve.Params()
+		size := (params.BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = params.Name
+		jwk.X = encodeBase64URL(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64URL(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64URL(k)
+	default:
+		return jwk, false
+	}
+	jwk.Use = "sig"
+	jwk.Alg = alg
+	jwk.Kid = KeyID(key)
+	return jwk, true
+}
+
+// Compute the key ID: the JWK thumbprint (RFC 7638) for public keys, a truncated digest for HMAC secrets.
+func KeyID(key interface{}) string {
+	var members interface{}
+	switch k := key.(type) {
+	case []byte:
+		sum := sha256.Sum256(k)
+		return encodeBase64URL(sum[:])[:16]
+	case *rsa.PublicKey:
+		members = map[string]string{
+			"e":   encodeBase64URL(big.NewInt(int64(k.E)).Bytes()),
+			"kty": "RSA",
+			"n":   encodeBase64URL(k.N.Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		params := k.Curve.Params()
+		size := (params.BitSize + 7) / 8
+		members = map[string]string{
+			"crv": params.Name,
+			"kty": "EC",
+			"x":   encodeBase64URL(k.X.FillBytes(make([]byte, size))),
+			"y":   encodeBase64URL(k.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		members = map[string]string{
+			"crv": "Ed25519",
+			"kty": "OKP",
+			"x":   encodeBase64URL(k),
+		}
+	default:
+		return ""
+	}
+
+	// Map keys are sorted when encoding, which gives the canonical form required by RFC 7638.
+	buf, _ := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(members)
+	sum := sha256.Sum256(buf)
+	return encodeBase64URL(sum[:])
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Decode the public key of the JWK (RSA, EC or OKP/Ed25519).
+func (a JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch a.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(a.N)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+		e, err := decodeBase64URL(a.E)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: invalid RSA modulus or exponent", ErrInvalidKey)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch a.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrInvalidKey, a.Crv)
+		}
+		x, err := decodeBase64URL(a.X)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+		y, err := decodeBase64URL(a.Y)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("%w: point is not on curve %s", ErrInvalidKey, a.Crv)
+		}
+		return key, nil
+	case "OKP":
+		if a.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrInvalidKey, a.Crv)
+		}
+		x, err := decodeBase64URL(a.X)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		} else if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key size", ErrInvalidKey)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported key type %s", ErrInvalidKey, a.Kty)
+}
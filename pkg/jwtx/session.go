@@ -0,0 +1,216 @@
+package jwtx
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Session of a token family: the tokens issued by a login and its refreshes.
+type Session struct {
+	ID         string `json:"id"`
+	Subject    string `json:"subject"`
+	Device     string `json:"device"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	Actor      string `json:"actor,omitempty"` // The real user of an impersonation session
+	CreatedAt  int64  `json:"created_at"`
+	LastSeenAt int64  `json:"last_seen_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// Client info recorded with the session when a token is generated
+type SessionMeta struct {
+	Device    string
+	IP        string
+	UserAgent string
+	Actor     string
+	Tenant    string
+}
+
+type sessionMetaCtx struct{}
+
+func NewSessionMeta(ctx context.Context, meta SessionMeta) context.Context {
+	return context.WithValue(ctx, sessionMetaCtx{}, meta)
+}
+
+func FromSessionMeta(ctx context.Context) SessionMeta {
+	v := ctx.Value(sessionMetaCtx{})
+	if v != nil {
+		return v.(SessionMeta)
+	}
+	return SessionMeta{}
+}
+
+// The interval for writing the last seen time back to the store
+const sessionTouchInterval = 60
+
+func (a *JWTAuth) getSession(ctx context.Context, sessionID string) (*Session, error) {
+	val, ok, err := a.store.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, nil
+	}
+
+	session := new(Session)
+	if err := jsoniter.UnmarshalFromString(val, session); err != nil {
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (a *JWTAuth) saveSession(ctx context.Context, session *Session) error {
+	buf, err := jsoniter.MarshalToString(session)
+	if err != nil {
+		return err
+	}
+	return a.store.SetSession(ctx, session.ID, buf, time.Until(time.Unix(session.ExpiresAt, 0)))
+}
+
+// Add the impersonation session to the index of the actor, so that it is revoked with the sessions of the actor.
+func (a *JWTAuth) recordActorSession(ctx context.Context, actor, sessionID string, expiresAt int64) error {
+	return a.store.AddActorSession(ctx, actor, sessionID, time.Until(time.Unix(expiresAt, 0)))
+}
+
+// Create the session on login, or extend its lifetime on refresh.
+func (a *JWTAuth) recordSession(ctx context.Context, subject, sessionID string, expiresAt int64) error {
+	now := time.Now().Unix()
+	session, err := a.getSession(ctx, sessionID)
+	if err != nil {
+		return err
+	} else if session != nil {
+		session.LastSeenAt = now
+		session.ExpiresAt = expiresAt
+	} else {
+		meta := FromSessionMeta(ctx)
+		session = &Session{
+			ID:         sessionID,
+			Subject:    subject,
+			Device:     meta.Device,
+			IP:         meta.IP,
+			UserAgent:  meta.UserAgent,
+			Actor:      meta.Actor,
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  expiresAt,
+		}
+	}
+	if err := a.saveSession(ctx, session); err != nil {
+		return err
+	}
+	return a.store.AddSubjectSession(ctx, subject, sessionID, time.Until(time.Unix(expiresAt, 0)))
+}
+
+// Update the last seen time (and IP) of the session, writes are throttled.
+func (a *JWTAuth) touchSession(ctx context.Context, sessionID string) error {
+	session, err := a.getSession(ctx, sessionID)
+	if err != nil || session == nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if now-session.LastSeenAt < sessionTouchInterval {
+		return nil
+	}
+	session.LastSeenAt = now
+	if ip := FromSessionMeta(ctx).IP; ip != "" {
+		session.IP = ip
+	}
+	return a.saveSession(ctx, session)
+}
+
+func (a *JWTAuth) QuerySessions(ctx context.Context, subject string) ([]*Session, error) {
+	if a.store == nil {
+		return nil, nil
+	}
+
+	ids, err := a.store.GetSubjectSessions(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Session
+	for _, id := range ids {
+		session, err := a.getSession(ctx, id)
+		if err != nil {
+			return nil, err
+		} else if session == nil {
+			// Drop the expired or revoked session from the index
+			if err := a.store.DeleteSubjectSession(ctx, subject, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		list = append(list, session)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeenAt > list[j].LastSeenAt
+	})
+	return list, nil
+}
+
+func (a *JWTAuth) RevokeSession(ctx context.Context, subject, sessionID string) error {
+	if a.store == nil {
+		return nil
+	}
+
+	session, err := a.getSession(ctx, sessionID)
+	if err != nil {
+		return err
+	} else if session == nil || session.Subject != subject {
+		return ErrSessionNotFound
+	}
+
+	if err := a.store.RevokeFamily(ctx, sessionID, time.Duration(a.opts.refreshExpired)*time.Second); err != nil {
+		return err
+	} else if err := a.store.DeleteSession(ctx, sessionID); err != nil {
+		return err
+	} else if session.Actor != "" {
+		if err := a.store.DeleteActorSession(ctx, session.Actor, sessionID); err != nil {
+			return err
+		}
+	}
+	return a.store.DeleteSubjectSession(ctx, subject, sessionID)
+}
+
+func (a *JWTAuth) RevokeSessions(ctx context.Context, subject string) error {
+	if a.store == nil {
+		return nil
+	}
+
+	ids, err := a.store.GetSubjectSessions(ctx, subject)
+	if err != nil {
+		return err
+	}
+	// The subject impersonating other users is also the actor of their sessions
+	actorIDs, err := a.store.GetActorSessions(ctx, subject)
+	if err != nil {
+		return err
+	}
+
+	revoke := func(id string) error {
+		if err := a.store.RevokeFamily(ctx, id, time.Duration(a.opts.refreshExpired)*time.Second); err != nil {
+			return err
+		}
+		return a.store.DeleteSession(ctx, id)
+	}
+	for _, id := range ids {
+		if err := revoke(id); err != nil {
+			return err
+		} else if err := a.store.DeleteSubjectSession(ctx, subject, id); err != nil {
+			return err
+		}
+	}
+	for _, id := range actorIDs {
+		if err := revoke(id); err != nil {
+			return err
+		} else if err := a.store.DeleteActorSession(ctx, subject, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -10,11 +10,36 @@ type Storer interface {
 	Set(ctx context.Context, tokenStr string, expiration time.Duration) error
 	Delete(ctx context.Context, tokenStr string) error
 	Check(ctx context.Context, tokenStr string) (bool, error)
+	// Save the encoded state of a refresh token (keyed by its hash), it is claimed (got and deleted atomically) on
+	// the refresh so that only one of the concurrent refreshes gets it
+	SetRefreshToken(ctx context.Context, tokenKey, value string, expiration time.Duration) error
+	ClaimRefreshToken(ctx context.Context, tokenKey string) (string, bool, error)
+	// Revoke all tokens issued within a token family
+	RevokeFamily(ctx context.Context, familyID string, expiration time.Duration) error
+	CheckFamily(ctx context.Context, familyID string) (bool, error)
+	// Save the sessions (token families) and the session index of each subject
+	SetSession(ctx context.Context, sessionID, value string, expiration time.Duration) error
+	GetSession(ctx context.Context, sessionID string) (string, bool, error)
+	DeleteSession(ctx context.Context, sessionID string) error
+	// Index the sessions of each subject by one key per session, so that the concurrent logins do not overwrite
+	// each other's entries
+	AddSubjectSession(ctx context.Context, subject, sessionID string, expiration time.Duration) error
+	DeleteSubjectSession(ctx context.Context, subject, sessionID string) error
+	GetSubjectSessions(ctx context.Context, subject string) ([]string, error)
+	// Index the impersonation sessions of each actor in the same way
+	AddActorSession(ctx context.Context, actor, sessionID string, expiration time.Duration) error
+	DeleteActorSession(ctx context.Context, actor, sessionID string) error
+	GetActorSessions(ctx context.Context, actor string) ([]string, error)
 	Close(ctx context.Context) error
 }
 
 type storeOptions struct {
-	CacheNS string // default "jwt"
+	CacheNS        string // default "jwt"
+	RefreshCacheNS string // default "jwt-refresh"
+	FamilyCacheNS  string // default "jwt-family"
+	SessionCacheNS string // default "jwt-session"
+	SubjectCacheNS string // default "jwt-subject"
+	ActorCacheNS   string // default "jwt-actor"
 }
 
 type StoreOption func(*storeOptions)
@@ -22,14 +47,21 @@ type StoreOption func(*storeOptions)
 func WithCacheNS(ns string) StoreOption {
 	return func(o *storeOptions) {
 		o.CacheNS = ns
+		o.RefreshCacheNS = ns + "-refresh"
+		o.FamilyCacheNS = ns + "-family"
+		o.SessionCacheNS = ns + "-session"
+		o.SubjectCacheNS = ns + "-subject"
+		o.ActorCacheNS = ns + "-actor"
 	}
 }
 
 type Cacher interface {
 	Set(ctx context.Context, ns, key, value string, expiration ...time.Duration) error
 	Get(ctx context.Context, ns, key string) (string, bool, error)
+	GetAndDelete(ctx context.Context, ns, key string) (string, bool, error) // Must be atomic
 	Exists(ctx context.Context, ns, key string) (bool, error)
 	Delete(ctx context.Context, ns, key string) error
+	Iterator(ctx context.Context, ns string, fn func(ctx context.Context, key, value string) bool) error
 	Close(ctx context.Context) error
 }
 
@@ -37,7 +69,12 @@ func NewStoreWithCache(cache Cacher, opts ...StoreOption) Storer {
 	s := &storeImpl{
 		c: cache,
 		opts: &storeOptions{
-			CacheNS: "jwt",
+			CacheNS:        "jwt",
+			RefreshCacheNS: "jwt-refresh",
+			FamilyCacheNS:  "jwt-family",
+			SessionCacheNS: "jwt-session",
+			SubjectCacheNS: "jwt-subject",
+			ActorCacheNS:   "jwt-actor",
 		},
 	}
 	for _, opt := range opts {
@@ -63,6 +100,76 @@ func (s *storeImpl) Check(ctx context.Context, tokenStr string) (bool, error) {
 	return s.c.Exists(ctx, s.opts.CacheNS, tokenStr)
 }
 
+func (s *storeImpl) SetRefreshToken(ctx context.Context, tokenKey, value string, expiration time.Duration) error {
+	return s.c.Set(ctx, s.opts.RefreshCacheNS, tokenKey, value, expiration)
+}
+
+func (s *storeImpl) ClaimRefreshToken(ctx context.Context, tokenKey string) (string, bool, error) {
+	return s.c.GetAndDelete(ctx, s.opts.RefreshCacheNS, tokenKey)
+}
+
+func (s *storeImpl) RevokeFamily(ctx context.Context, familyID string, expiration time.Duration) error {
+	return s.c.Set(ctx, s.opts.FamilyCacheNS, familyID, "", expiration)
+}
+
+func (s *storeImpl) CheckFamily(ctx context.Context, familyID string) (bool, error) {
+	return s.c.Exists(ctx, s.opts.FamilyCacheNS, familyID)
+}
+
+func (s *storeImpl) SetSession(ctx context.Context, sessionID, value string, expiration time.Duration) error {
+	return s.c.Set(ctx, s.opts.SessionCacheNS, sessionID, value, expiration)
+}
+
+func (s *storeImpl) GetSession(ctx context.Context, sessionID string) (string, bool, error) {
+	return s.c.Get(ctx, s.opts.SessionCacheNS, sessionID)
+}
+
+func (s *storeImpl) DeleteSession(ctx context.Context, sessionID string) error {
+	return s.c.Delete(ctx, s.opts.SessionCacheNS, sessionID)
+}
+
+func (s *storeImpl) subjectNS(subject string) string {
+	return s.opts.SubjectCacheNS + defaultDelimiter + subject
+}
+
+func (s *storeImpl) actorNS(actor string) string {
+	return s.opts.ActorCacheNS + defaultDelimiter + actor
+}
+
+// Get the keys in the namespace.
+func (s *storeImpl) keys(ctx context.Context, ns string) ([]string, error) {
+	var keys []string
+	err := s.c.Iterator(ctx, ns, func(ctx context.Context, key, value string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys, err
+}
+
+func (s *storeImpl) AddSubjectSession(ctx context.Context, subject, sessionID string, expiration time.Duration) error {
+	return s.c.Set(ctx, s.subjectNS(subject), sessionID, "", expiration)
+}
+
+func (s *storeImpl) DeleteSubjectSession(ctx context.Context, subject, sessionID string) error {
+	return s.c.Delete(ctx, s.subjectNS(subject), sessionID)
+}
+
+func (s *storeImpl) GetSubjectSessions(ctx context.Context, subject string) ([]string, error) {
+	return s.keys(ctx, s.subjectNS(subject))
+}
+
+func (s *storeImpl) AddActorSession(ctx context.Context, actor, sessionID string, expiration time.Duration) error {
+	return s.c.Set(ctx, s.actorNS(actor), sessionID, "", expiration)
+}
+
+func (s *storeImpl) DeleteActorSession(ctx context.Context, actor, sessionID string) error {
+	return s.c.Delete(ctx, s.actorNS(actor), sessionID)
+}
+
+func (s *storeImpl) GetActorSessions(ctx context.Context, actor string) ([]string, error) {
+	return s.keys(ctx, s.actorNS(actor))
+}
+
 func (s *storeImpl) Close(ctx context.Context) error {
 	return s.c.Close(ctx)
 }
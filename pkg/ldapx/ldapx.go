@@ -0,0 +1,244 @@
+package ldapx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("Invalid LDAP credentials")
+	ErrUserNotFound       = errors.New("LDAP user not found")
+)
+
+type Config struct {
+	URL                string // ldap://host:389 or ldaps://host:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+	BindDN             string // Service account to search the users and groups (anonymous if empty)
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // %s is replaced by the escaped username, e.g. (&(objectClass=person)(uid=%s))
+	UsernameAttr       string
+	NameAttr           string
+	EmailAttr          string
+	PhoneAttr          string
+	MemberOfAttr       string // Groups of the user are taken from the attribute when GroupBaseDN is empty
+	GroupBaseDN        string
+	GroupFilter        string // %s is replaced by the escaped user DN, e.g. (&(objectClass=groupOfNames)(member=%s))
+	GroupNameAttr      string
+}
+
+// Entry of the authenticated user
+type User struct {
+	DN       string
+	Username string
+	Name     string
+	Email    string
+	Phone    string
+	Groups   []Group
+}
+
+type Group struct {
+	DN   string
+	Name string // The first RDN value (CN) of the DN when GroupNameAttr is missing
+}
+
+// LDAP authentication with bind + search
+type Client struct {
+	config Config
+}
+
+func New(config Config) *Client {
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.UserFilter == "" {
+		config.UserFilter = "(uid=%s)"
+	}
+	if config.UsernameAttr == "" {
+		config.UsernameAttr = "uid"
+	}
+	if config.NameAttr == "" {
+		config.NameAttr = "cn"
+	}
+	if config.EmailAttr == "" {
+		config.EmailAttr = "mail"
+	}
+	if config.PhoneAttr == "" {
+		config.PhoneAttr = "telephoneNumber"
+	}
+	if config.MemberOfAttr == "" {
+		config.MemberOfAttr = "memberOf"
+	}
+	if config.GroupFilter == "" {
+		config.GroupFilter = "(member=%s)"
+	}
+	if config.GroupNameAttr == "" {
+		config.GroupNameAttr = "cn"
+	}
+	return &Client{config: config}
+}
+
+func (c *Client) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.config.InsecureSkipVerify} // #nosec G402
+	conn, err := ldap.DialURL(c.config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: c.config.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(c.config.Timeout)
+
+	if c.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Bind with the service account, or stay anonymous.
+func (c *Client) bindService(conn *ldap.Conn) error {
+	if c.config.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(c.config.BindDN, c.config.BindPassword); err != nil {
+		return fmt.Errorf("ldap: failed to bind service account: %w", err)
+	}
+	return nil
+}
+
+// Search the user by the username and verify the password by binding as the user.
+func (c *Client) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	// An empty password is an unauthenticated bind, which always succeeds (RFC 4513)
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := c.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	attrs := []string{c.config.UsernameAttr, c.config.NameAttr, c.config.EmailAttr, c.config.PhoneAttr}
+	if c.config.GroupBaseDN == "" {
+		attrs = append(attrs, c.config.MemberOfAttr)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(c.config.Timeout.Seconds()), false,
+		fmt.Sprintf(c.config.UserFilter, ldap.EscapeFilter(username)), attrs, nil,
+	))
+	if err != nil {
+		return nil, err
+	} else if len(result.Entries) == 0 {
+		return nil, ErrUserNotFound
+	} else if len(result.Entries) > 1 {
+		return nil, fmt.Errorf("ldap: multiple entries match the username %s", username)
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	user := &User{
+		DN:       entry.DN,
+		Username: entry.GetEqualFoldAttributeValue(c.config.UsernameAttr),
+		Name:     entry.GetEqualFoldAttributeValue(c.config.NameAttr),
+		Email:    entry.GetEqualFoldAttributeValue(c.config.EmailAttr),
+		Phone:    entry.GetEqualFoldAttributeValue(c.config.PhoneAttr),
+	}
+	if user.Username == "" {
+		user.Username = username
+	}
+
+	if c.config.GroupBaseDN == "" {
+		for _, dn := range entry.GetEqualFoldAttributeValues(c.config.MemberOfAttr) {
+			user.Groups = append(user.Groups, Group{DN: dn, Name: firstRDNValue(dn)})
+		}
+		return user, nil
+	}
+
+	// The user may not be allowed to read the groups
+	if err := c.bindService(conn); err != nil {
+		return nil, err
+	}
+	groups, err := c.searchGroups(conn, entry.DN)
+	if err != nil {
+		return nil, err
+	}
+	user.Groups = groups
+	return user, nil
+}
+
+func (c *Client) searchGroups(conn *ldap.Conn, userDN string) ([]Group, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.config.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(c.config.Timeout.Seconds()), false,
+		fmt.Sprintf(c.config.GroupFilter, ldap.EscapeFilter(userDN)), []string{c.config.GroupNameAttr}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]Group, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		name := entry.GetEqualFoldAttributeValue(c.config.GroupNameAttr)
+		if name == "" {
+			name = firstRDNValue(entry.DN)
+		}
+		groups = append(groups, Group{DN: entry.DN, Name: name})
+	}
+	return groups, nil
+}
+
+func firstRDNValue(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
+
+// Check whether the group matches the pattern, which is the DN or name of the group (case-insensitive,
+// "*" matches any characters).
+func (a Group) Match(pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	return wildcardMatch(pattern, strings.ToLower(a.DN)) || wildcardMatch(pattern, strings.ToLower(a.Name))
+}
+
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
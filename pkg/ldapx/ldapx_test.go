@@ -0,0 +1,269 @@
+package ldapx
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	appBindRequest     = 0
+	appBindResponse    = 1
+	appUnbindRequest   = 2
+	appSearchRequest   = 3
+	appSearchEntry     = 4
+	appSearchDone      = 5
+	resultSuccess      = 0
+	resultInvalidCreds = 49
+
+	filterAnd      = 0
+	filterOr       = 1
+	filterNot      = 2
+	filterEquality = 3
+	filterPresent  = 7
+)
+
+type testEntry struct {
+	DN       string
+	Password string
+	Attrs    map[string][]string
+}
+
+// In-process LDAP server which supports simple bind and search with and/or/not/equality/present filters
+type testServer struct {
+	ln      net.Listener
+	entries []*testEntry
+}
+
+func newTestServer(t *testing.T, entries ...*testEntry) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := &testServer{ln: ln, entries: entries}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *testServer) URL() string {
+	return "ldap://" + s.ln.Addr().String()
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case appBindRequest:
+			code := resultInvalidCreds
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			if dn == "" && password == "" {
+				code = resultSuccess
+			} else if entry := s.find(dn); entry != nil && entry.Password != "" && entry.Password == password {
+				code = resultSuccess
+			}
+			s.write(conn, id, newResult(appBindResponse, code))
+		case appSearchRequest:
+			base := strings.ToLower(op.Children[0].Value.(string))
+			var attrs []string
+			for _, attr := range op.Children[7].Children {
+				attrs = append(attrs, attr.Value.(string))
+			}
+			for _, entry := range s.entries {
+				if strings.HasSuffix(strings.ToLower(entry.DN), base) && matchFilter(op.Children[6], entry) {
+					s.write(conn, id, newSearchEntry(entry, attrs))
+				}
+			}
+			s.write(conn, id, newResult(appSearchDone, resultSuccess))
+		case appUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *testServer) find(dn string) *testEntry {
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (s *testServer) write(conn net.Conn, id int64, op *ber.Packet) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	envelope.AppendChild(op)
+	_, _ = conn.Write(envelope.Bytes())
+}
+
+func newResult(tag ber.Tag, code int) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return p
+}
+
+func newSearchEntry(entry *testEntry, attrs []string) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, appSearchEntry, nil, "")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, ""))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	for name, values := range entry.Attrs {
+		if !containsFold(attrs, name) {
+			continue
+		}
+		attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		attr.AppendChild(set)
+		list.AppendChild(attr)
+	}
+	p.AppendChild(list)
+	return p
+}
+
+func matchFilter(f *ber.Packet, entry *testEntry) bool {
+	switch f.Tag {
+	case filterAnd:
+		for _, child := range f.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range f.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return !matchFilter(f.Children[0], entry)
+	case filterEquality:
+		name, value := f.Children[0].Value.(string), f.Children[1].Value.(string)
+		for attr, values := range entry.Attrs {
+			if strings.EqualFold(attr, name) && containsFold(values, value) {
+				return true
+			}
+		}
+		return false
+	case filterPresent:
+		name := f.Data.String()
+		for attr := range entry.Attrs {
+			if strings.EqualFold(attr, name) {
+				return true
+			}
+		}
+		return strings.EqualFold(name, "objectClass")
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	server := newTestServer(t,
+		&testEntry{DN: "cn=admin,dc=example,dc=com", Password: "admin"},
+		&testEntry{
+			DN:       "uid=jane,ou=people,dc=example,dc=com",
+			Password: "secret",
+			Attrs: map[string][]string{
+				"uid":             {"jane"},
+				"cn":              {"Jane Doe"},
+				"mail":            {"jane@example.com"},
+				"telephoneNumber": {"123456"},
+				"memberOf":        {"cn=Developers,ou=groups,dc=example,dc=com"},
+			},
+		},
+		&testEntry{
+			DN:    "cn=admins,ou=groups,dc=example,dc=com",
+			Attrs: map[string][]string{"cn": {"admins"}, "member": {"uid=jane,ou=people,dc=example,dc=com"}},
+		},
+	)
+
+	client := New(Config{
+		URL:          server.URL(),
+		BindDN:       "cn=admin,dc=example,dc=com",
+		BindPassword: "admin",
+		BaseDN:       "ou=people,dc=example,dc=com",
+	})
+	user, err := client.Authenticate(ctx, "jane", "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, "uid=jane,ou=people,dc=example,dc=com", user.DN)
+	assert.Equal(t, "Jane Doe", user.Name)
+	assert.Equal(t, "jane@example.com", user.Email)
+	assert.Equal(t, "123456", user.Phone)
+	assert.Equal(t, []Group{{DN: "cn=Developers,ou=groups,dc=example,dc=com", Name: "Developers"}}, user.Groups)
+
+	_, err = client.Authenticate(ctx, "jane", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = client.Authenticate(ctx, "jane", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = client.Authenticate(ctx, "john", "secret")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	// The username is escaped in the filter
+	_, err = client.Authenticate(ctx, "*", "secret")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	// Search the groups by member
+	client = New(Config{
+		URL:          server.URL(),
+		BindDN:       "cn=admin,dc=example,dc=com",
+		BindPassword: "admin",
+		BaseDN:       "ou=people,dc=example,dc=com",
+		UserFilter:   "(&(objectClass=*)(uid=%s))",
+		GroupBaseDN:  "ou=groups,dc=example,dc=com",
+	})
+	user, err = client.Authenticate(ctx, "jane", "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, []Group{{DN: "cn=admins,ou=groups,dc=example,dc=com", Name: "admins"}}, user.Groups)
+
+	// Wrong service account
+	client = New(Config{URL: server.URL(), BindDN: "cn=admin,dc=example,dc=com", BindPassword: "wrong"})
+	_, err = client.Authenticate(ctx, "jane", "secret")
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestGroupMatch(t *testing.T) {
+	group := Group{DN: "cn=Developers,ou=groups,dc=example,dc=com", Name: "Developers"}
+	assert.True(t, group.Match("developers"))
+	assert.True(t, group.Match("CN=developers,OU=Groups,DC=example,DC=com"))
+	assert.True(t, group.Match("dev*"))
+	assert.True(t, group.Match("*,ou=groups,dc=example,dc=com"))
+	assert.False(t, group.Match("admins"))
+	assert.False(t, group.Match("*,ou=roles,*"))
+}
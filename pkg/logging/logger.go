@@ -8,19 +8,23 @@ import (
 )
 
 const (
-	TagKeyMain     = "main"
-	TagKeyRecovery = "recovery"
-	TagKeyRequest  = "request"
-	TagKeyLogin    = "login"
-	TagKeyLogout   = "logout"
-	TagKeySystem   = "system"
-	TagKeyOperate  = "operate"
+	TagKeyMain      = "main"
+	TagKeyRecovery  = "recovery"
+	TagKeyRequest   = "request"
+	TagKeyLogin     = "login"
+	TagKeyLogout    = "logout"
+	TagKeySystem    = "system"
+	TagKeyOperate   = "operate"
+	TagKeySecurity  = "security"
+	TagKeyElevation = "elevation"
 )
 
 type (
 	ctxLoggerKey  struct{}
 	ctxTraceIDKey struct{}
 	ctxUserIDKey  struct{}
+	ctxActorIDKey struct{}
+	ctxTenantKey  struct{}
 	ctxTagKey     struct{}
 	ctxStackKey   struct{}
 )
@@ -67,6 +71,36 @@ func FromUserID(ctx context.Context) string {
 	return ""
 }
 
+// The real user when the user of the context is impersonated
+func NewImpersonatorID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, ctxActorIDKey{}, userID)
+}
+
+func FromImpersonatorID(ctx context.Context) string {
+	v := ctx.Value(ctxActorIDKey{})
+	if v != nil {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// The tenant of the user of the context
+func NewTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, ctxTenantKey{}, tenantID)
+}
+
+func FromTenantID(ctx context.Context) string {
+	v := ctx.Value(ctxTenantKey{})
+	if v != nil {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
 func NewTag(ctx context.Context, tag string) context.Context {
 	return context.WithValue(ctx, ctxTagKey{}, tag)
 }
@@ -103,6 +137,12 @@ func Context(ctx context.Context) *zap.Logger {
 	if v := FromUserID(ctx); v != "" {
 		fields = append(fields, zap.String("user_id", v))
 	}
+	if v := FromImpersonatorID(ctx); v != "" {
+		fields = append(fields, zap.String("impersonator_id", v))
+	}
+	if v := FromTenantID(ctx); v != "" {
+		fields = append(fields, zap.String("tenant_id", v))
+	}
 	if v := FromTag(ctx); v != "" {
 		fields = append(fields, zap.String("tag", v))
 	}
@@ -0,0 +1,121 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type capturedMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Local SMTP server which captures the received emails
+type captureServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	mails []*capturedMail
+}
+
+func newCaptureServer(t *testing.T) *captureServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	s := &captureServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *captureServer) sender() *SmtpSender {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &SmtpSender{SmtpHost: host, Port: p, FromName: "ginadmin", FromMail: "noreply@example.com"}
+}
+
+func (s *captureServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	item := new(capturedMail)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			item.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			item.To = append(item.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				} else if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			item.Data = data.String()
+			s.mu.Lock()
+			s.mails = append(s.mails, item)
+			s.mu.Unlock()
+			item = new(capturedMail)
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *captureServer) Mails() []*capturedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*capturedMail{}, s.mails...)
+}
+
+func TestSmtpSender(t *testing.T) {
+	server := newCaptureServer(t)
+	err := server.sender().SendTo(context.Background(), []string{"jane@example.com"}, "Hello", "<p>Hello Jane</p>")
+	assert.Nil(t, err)
+
+	mails := server.Mails()
+	if assert.Len(t, mails, 1) {
+		assert.Equal(t, "noreply@example.com", mails[0].From)
+		assert.Equal(t, []string{"jane@example.com"}, mails[0].To)
+		assert.Contains(t, mails[0].Data, "Subject: Hello")
+		assert.Contains(t, mails[0].Data, base64.StdEncoding.EncodeToString([]byte("<p>Hello Jane</p>")))
+	}
+}
@@ -1,10 +1,21 @@
 package middleware
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Knetic/govaluate"
 	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
 	"github.com/LyricTian/gin-admin/v10/pkg/util"
 	"github.com/casbin/casbin/v2"
+	casbinutil "github.com/casbin/casbin/v2/util"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 var ErrCasbinDenied = errors.Forbidden("com.casbin.denied", "Permission denied")
@@ -13,15 +24,35 @@ type CasbinConfig struct {
 	AllowedPathPrefixes []string
 	SkippedPathPrefixes []string
 	Skipper             func(c *gin.Context) bool
-	GetEnforcer         func(c *gin.Context) *casbin.Enforcer
+	GetEnforcer         func(c *gin.Context) *casbin.SyncedEnforcer
 	GetSubjects         func(c *gin.Context) []string
+	// The domain (e.g. the tenant) in which the policies of the subjects are effective
+	GetDomain func(c *gin.Context) string
+	// The scopes (e.g. of API keys) restrict the permissions of the subjects, ok is false if unrestricted
+	GetScopes func(c *gin.Context) (scopes []string, ok bool)
+	// The subjects granted temporarily (e.g. the elevated roles), OnElevated is called with the deciding policy
+	// when the request is allowed only because of them
+	GetElevatedSubjects func(c *gin.Context) []string
+	OnElevated          func(c *gin.Context, explain []string)
 }
 
 func CasbinWithConfig(config CasbinConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !AllowedPathPrefixes(c, config.AllowedPathPrefixes...) ||
-			SkippedPathPrefixes(c, config.SkippedPathPrefixes...) ||
-			(config.Skipper != nil && config.Skipper(c)) {
+			SkippedPathPrefixes(c, config.SkippedPathPrefixes...) {
+			c.Next()
+			return
+		}
+
+		// The request is allowed only if it is allowed by both the scopes and the enforcer
+		if config.GetScopes != nil {
+			if scopes, ok := config.GetScopes(c); ok && !MatchScopes(scopes, c.Request.URL.Path, c.Request.Method) {
+				util.ResError(c, ErrCasbinDenied)
+				return
+			}
+		}
+
+		if config.Skipper != nil && config.Skipper(c) {
 			c.Next()
 			return
 		}
@@ -32,15 +63,252 @@ func CasbinWithConfig(config CasbinConfig) gin.HandlerFunc {
 			return
 		}
 
-		for _, sub := range config.GetSubjects(c) {
-			if b, err := enforcer.Enforce(sub, c.Request.URL.Path, c.Request.Method); err != nil {
-				util.ResError(c, err)
-				return
-			} else if b {
-				c.Next()
-				return
+		var domain string
+		if config.GetDomain != nil {
+			domain = config.GetDomain(c)
+		}
+
+		subjects := config.GetSubjects(c)
+		attrs := NewCasbinAttributes(c)
+		allowed, explain, err := CasbinEnforce(enforcer, subjects, domain, c.Request.URL.Path, c.Request.Method, attrs)
+		if err != nil {
+			util.ResError(c, err)
+			return
+		} else if !allowed {
+			util.ResError(c, ErrCasbinDenied)
+			return
+		}
+
+		if config.GetElevatedSubjects != nil && config.OnElevated != nil {
+			if elevated := config.GetElevatedSubjects(c); len(elevated) > 0 {
+				allowed, _, err := CasbinEnforce(enforcer, excludeSubjects(subjects, elevated), domain, c.Request.URL.Path, c.Request.Method, attrs)
+				if err != nil {
+					util.ResError(c, err)
+					return
+				} else if !allowed {
+					config.OnElevated(c, explain)
+				}
 			}
 		}
-		util.ResError(c, ErrCasbinDenied)
+		c.Next()
+	}
+}
+
+func excludeSubjects(subjects, excluded []string) []string {
+	m := make(map[string]bool, len(excluded))
+	for _, sub := range excluded {
+		m[sub] = true
+	}
+
+	var result []string
+	for _, sub := range subjects {
+		if !m[sub] {
+			result = append(result, sub)
+		}
+	}
+	return result
+}
+
+// Enforce the request for the subjects in the domain, the request is allowed if any subject is allowed and no subject is denied
+// explicitly (by a policy with the deny effect). The explain is the policy which decides the result, it is empty
+// if no policy matches. The conditions of the policies are evaluated against the attributes, they are assumed to be met
+// if the attributes are nil (e.g. the permissions are checked without a request).
+func CasbinEnforce(enforcer *casbin.SyncedEnforcer, subjects []string, domain, path, method string, attrs *CasbinAttributes) (bool, []string, error) {
+	var (
+		allowed bool
+		explain []string
+	)
+	for _, sub := range subjects {
+		ok, subExplain, err := enforcer.EnforceEx(sub, domain, path, method, attrs)
+		if err != nil {
+			return false, nil, err
+		} else if ok {
+			if !allowed {
+				allowed = true
+				explain = subExplain
+			}
+			continue
+		}
+
+		if len(subExplain) > 0 {
+			// The request is denied explicitly
+			return false, subExplain, nil
+		}
+	}
+	return allowed, explain, nil
+}
+
+// Check whether any scope allows the request. The scope is "*" (any request) or "{METHOD} {path}",
+// the method can be "*" and the path is matched in the same way as the casbin policies (e.g. /api/v1/users/:id).
+func MatchScopes(scopes []string, path, method string) bool {
+	for _, scope := range scopes {
+		if scope == "*" {
+			return true
+		}
+
+		fields := strings.Fields(scope)
+		if len(fields) != 2 || (fields[0] != "*" && !strings.EqualFold(fields[0], method)) {
+			continue
+		}
+		if casbinutil.KeyMatch2(path, fields[1]) || casbinutil.KeyMatch3(path, fields[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// The casbin function which evaluates the condition of the policy against the attributes of the request, it should be
+// added to the enforcer and used in the matcher (e.g. condMatch(p.cond, r.attrs, p.eft)).
+const CasbinConditionFunction = "condMatch"
+
+// Attributes of the request for the conditions of the casbin policies. The variables of the conditions are user_id, ip,
+// method, path, hour (0-23), minute, weekday (0 is Sunday) and param_{name} for the path parameters (e.g. param_id of
+// /api/v1/users/:id), the function in_cidr(ip, cidr) checks the IP range. For example:
+//
+//	hour >= 9 && hour < 18 && weekday >= 1 && weekday <= 5
+//	in_cidr(ip, "10.0.0.0/8") || in_cidr(ip, "192.168.0.0/16")
+//	param_id == user_id
+type CasbinAttributes struct {
+	UserID string
+	IP     string
+	Method string
+	Path   string
+	Time   time.Time
+	Params map[string]string // Path parameters
+	ctx    context.Context
+}
+
+// Get the attributes of the request. The IP is the client IP resolved by gin, the X-Forwarded-For header is used
+// only if the request comes from the proxies trusted by engine.SetTrustedProxies (none should be trusted if the
+// server is exposed directly), otherwise the client could choose the IP which the conditions are evaluated against.
+func NewCasbinAttributes(c *gin.Context) *CasbinAttributes {
+	params := make(map[string]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = param.Value
+	}
+	return &CasbinAttributes{
+		UserID: util.FromUserID(c.Request.Context()),
+		IP:     c.ClientIP(),
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+		Time:   time.Now(),
+		Params: params,
+		ctx:    c.Request.Context(),
+	}
+}
+
+// Get the variable of the conditions, the numbers are float64 as the numbers in the expressions.
+func (a *CasbinAttributes) Get(name string) (interface{}, error) {
+	switch name {
+	case "user_id":
+		return a.UserID, nil
+	case "ip":
+		return a.IP, nil
+	case "method":
+		return a.Method, nil
+	case "path":
+		return a.Path, nil
+	case "hour":
+		return float64(a.Time.Hour()), nil
+	case "minute":
+		return float64(a.Time.Minute()), nil
+	case "weekday":
+		return float64(a.Time.Weekday()), nil
+	}
+	if key := strings.TrimPrefix(name, "param_"); key != name && key != "" {
+		return a.Params[key], nil
+	}
+	return nil, fmt.Errorf("unknown variable '%s'", name)
+}
+
+var (
+	casbinConditions         sync.Map // Compiled conditions by the expression
+	casbinConditionFunctions = map[string]govaluate.ExpressionFunction{
+		"in_cidr": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("in_cidr requires 2 arguments")
+			}
+			ip, _ := args[0].(string)
+			cidr, _ := args[1].(string)
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR '%s'", cidr)
+			}
+			parsedIP := net.ParseIP(ip)
+			return parsedIP != nil && ipNet.Contains(parsedIP), nil
+		},
+	}
+)
+
+func compileCasbinCondition(cond string) (*govaluate.EvaluableExpression, error) {
+	if v, ok := casbinConditions.Load(cond); ok {
+		return v.(*govaluate.EvaluableExpression), nil
+	}
+
+	expr, err := govaluate.NewEvaluableExpressionWithFunctions(cond, casbinConditionFunctions)
+	if err != nil {
+		return nil, err
+	}
+	casbinConditions.Store(cond, expr)
+	return expr, nil
+}
+
+// Evaluate the condition against the attributes of the request, the empty condition is always met.
+func EvalCasbinCondition(cond string, attrs *CasbinAttributes) (bool, error) {
+	if cond == "" {
+		return true, nil
+	}
+
+	expr, err := compileCasbinCondition(cond)
+	if err != nil {
+		return false, err
+	}
+	result, err := expr.Eval(attrs)
+	if err != nil {
+		return false, err
+	}
+	met, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("the result of the condition is not a boolean")
+	}
+	return met, nil
+}
+
+// Check the condition is valid by evaluating it against the attributes of a sample request.
+func ValidateCasbinCondition(cond string) error {
+	sample := &CasbinAttributes{IP: "127.0.0.1", Time: time.Now(), Params: map[string]string{}}
+	_, err := EvalCasbinCondition(cond, sample)
+	return err
+}
+
+// The casbin function of the conditions, the arguments are the condition of the policy, the attributes of the
+// request (nil if the condition is assumed to be met) and the effect of the policy. The condition which fails to be
+// evaluated (e.g. saved before the variables are changed) is logged and fails closed: it is met by the deny policy
+// and not met by the allow policy. The request fails if the effect is not passed.
+func CasbinConditionMatch(args ...interface{}) (interface{}, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("%s requires 2 or 3 arguments", CasbinConditionFunction)
+	}
+	cond, _ := args[0].(string)
+	attrs, _ := args[1].(*CasbinAttributes)
+	if attrs == nil {
+		return true, nil
+	}
+
+	met, err := EvalCasbinCondition(cond, attrs)
+	if err != nil {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("failed to evaluate the condition '%s': %w", cond, err)
+		}
+		effect, _ := args[2].(string)
+
+		ctx := attrs.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		logging.Context(ctx).Warn("Failed to evaluate the condition of casbin policy",
+			zap.String("condition", cond), zap.String("effect", effect), zap.Error(err))
+		return effect == "deny", nil
 	}
+	return met, nil
 }
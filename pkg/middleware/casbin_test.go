@@ -0,0 +1,351 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvalCasbinCondition(t *testing.T) {
+	attrs := &CasbinAttributes{
+		UserID: "u1",
+		IP:     "10.1.2.3",
+		Method: "PUT",
+		Path:   "/api/v1/users/u1",
+		Time:   time.Date(2024, 1, 8, 10, 30, 0, 0, time.Local), // Monday
+		Params: map[string]string{"id": "u1"},
+	}
+
+	tests := []struct {
+		cond string
+		met  bool
+	}{
+		{"", true},
+		{"hour >= 9 && hour < 18 && weekday >= 1 && weekday <= 5", true},
+		{"hour >= 18", false},
+		{`in_cidr(ip, "10.0.0.0/8")`, true},
+		{`in_cidr(ip, "192.168.0.0/16")`, false},
+		{"param_id == user_id", true},
+		{`param_name == ""`, true},
+		{`method == "PUT" && path == "/api/v1/users/u1"`, true},
+	}
+	for _, tt := range tests {
+		met, err := EvalCasbinCondition(tt.cond, attrs)
+		assert.Nil(t, err, tt.cond)
+		assert.Equal(t, tt.met, met, tt.cond)
+	}
+
+	for _, cond := range []string{"hour >=", "unknown == 1", "hour + 1", `in_cidr(ip, "10.0.0.0")`} {
+		assert.NotNil(t, ValidateCasbinCondition(cond), cond)
+	}
+	assert.Nil(t, ValidateCasbinCondition("param_id == user_id"))
+}
+
+const testCasbinModel = `
+[request_definition]
+r = sub, dom, obj, act, attrs
+
+[policy_definition]
+p = sub, dom, obj, act, eft, cond
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[role_definition]
+g = _, _, _
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && (keyMatch2(r.obj, p.obj) || keyMatch3(r.obj, p.obj)) && r.act == p.act && condMatch(p.cond, r.attrs, p.eft)
+`
+
+func newTestCasbinEngine(t *testing.T, trustedProxies []string) *gin.Engine {
+	m, err := model.NewModelFromString(testCasbinModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction(CasbinConditionFunction, CasbinConditionMatch)
+	_, err = enforcer.AddPolicies([][]string{
+		{"reader", "", "/api/v1/users/:id", "GET", "allow", "param_id == user_id"},
+		{"reader", "", "/api/v1/roles", "GET", "allow", `in_cidr(ip, "10.0.0.0/8")`},
+		{"reader", "", "/api/v1/menus", "GET", "allow", ""},
+		{"reader", "", "/api/v1/logs", "GET", "allow", ""},
+		{"reader", "", "/api/v1/tenants", "GET", "allow", "unknown == 1"},
+		{"blocked", "", "/api/v1/menus", "GET", "deny", ""},
+		{"broken", "", "/api/v1/logs", "GET", "deny", "unknown == 1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	if err := e.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	e.Use(func(c *gin.Context) {
+		ctx := util.NewUserID(c.Request.Context(), c.GetHeader("X-User-ID"))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+	e.Use(CasbinWithConfig(CasbinConfig{
+		AllowedPathPrefixes: []string{"/api/"},
+		GetEnforcer: func(c *gin.Context) *casbin.SyncedEnforcer {
+			return enforcer
+		},
+		GetSubjects: func(c *gin.Context) []string {
+			if v := c.GetHeader("X-Subjects"); v != "" {
+				return strings.Split(v, ",")
+			}
+			return nil
+		},
+	}))
+	for _, path := range []string{"/api/v1/users/:id", "/api/v1/roles", "/api/v1/menus", "/api/v1/logs", "/api/v1/tenants"} {
+		e.GET(path, func(c *gin.Context) {
+			util.ResOK(c)
+		})
+	}
+	return e
+}
+
+func TestCasbinWithConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		path           string
+		subjects       string
+		userID         string
+		remoteAddr     string
+		forwardedFor   string
+		code           int
+	}{
+		{"no subject", nil, "/api/v1/menus", "", "u1", "", "", http.StatusForbidden},
+		{"allowed", nil, "/api/v1/menus", "reader", "u1", "", "", http.StatusOK},
+		{"denied explicitly", nil, "/api/v1/menus", "reader,blocked", "u1", "", "", http.StatusForbidden},
+		{"not granted", nil, "/api/v1/menus", "broken", "u1", "", "", http.StatusForbidden},
+		{"condition met", nil, "/api/v1/users/u1", "reader", "u1", "", "", http.StatusOK},
+		{"condition not met", nil, "/api/v1/users/u2", "reader", "u1", "", "", http.StatusForbidden},
+		{"ip in range", nil, "/api/v1/roles", "reader", "u1", "10.1.2.3:8000", "", http.StatusOK},
+		{"ip out of range", nil, "/api/v1/roles", "reader", "u1", "", "", http.StatusForbidden},
+		{"untrusted forwarded ip", nil, "/api/v1/roles", "reader", "u1", "", "10.1.2.3", http.StatusForbidden},
+		{"trusted forwarded ip", []string{"192.0.2.0/24"}, "/api/v1/roles", "reader", "u1", "", "10.1.2.3", http.StatusOK},
+		{"broken allow condition", nil, "/api/v1/tenants", "reader", "u1", "", "", http.StatusForbidden},
+		{"broken deny condition", nil, "/api/v1/logs", "reader,broken", "u1", "", "", http.StatusForbidden},
+		{"broken deny condition not held", nil, "/api/v1/logs", "reader", "u1", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		e := newTestCasbinEngine(t, tt.trustedProxies)
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("X-Subjects", tt.subjects)
+		req.Header.Set("X-User-ID", tt.userID)
+		if tt.remoteAddr != "" {
+			req.RemoteAddr = tt.remoteAddr
+		}
+		if tt.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, tt.name)
+	}
+}
+
+func TestCasbinConditionMatch(t *testing.T) {
+	attrs := &CasbinAttributes{IP: "127.0.0.1", Time: time.Now(), Params: map[string]string{}}
+
+	// The condition failing to be evaluated is met by the deny policy only
+	met, err := CasbinConditionMatch("unknown == 1", attrs, "deny")
+	assert.Nil(t, err)
+	assert.Equal(t, true, met)
+	met, err = CasbinConditionMatch("unknown == 1", attrs, "allow")
+	assert.Nil(t, err)
+	assert.Equal(t, false, met)
+
+	// The request fails if the effect is unknown
+	_, err = CasbinConditionMatch("unknown == 1", attrs)
+	assert.NotNil(t, err)
+
+	met, err = CasbinConditionMatch("unknown == 1", nil, "allow")
+	assert.Nil(t, err)
+	assert.Equal(t, true, met)
+	met, err = CasbinConditionMatch("hour >= 0", attrs, "allow")
+	assert.Nil(t, err)
+	assert.Equal(t, true, met)
+}
+
+func TestCasbinEnforceInheritedDeny(t *testing.T) {
+	m, err := model.NewModelFromString(testCasbinModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction(CasbinConditionFunction, CasbinConditionMatch)
+	_, err = enforcer.AddPolicies([][]string{
+		{"editor", "d1", "/api/v1/menus", "GET", "allow", ""},
+		{"editor", "d2", "/api/v1/menus", "GET", "allow", ""},
+		{"restricted", "d1", "/api/v1/menus", "GET", "deny", ""},
+		{"restricted", "d2", "/api/v1/menus", "GET", "deny", ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = enforcer.AddGroupingPolicies([][]string{
+		{"auditor", "restricted", "d1"},
+		{"intern", "auditor", "d1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deny := []string{"restricted", "d1", "/api/v1/menus", "GET", "deny", ""}
+	tests := []struct {
+		name     string
+		subjects []string
+		domain   string
+		allowed  bool
+		explain  []string
+	}{
+		{"direct allow", []string{"editor"}, "d1", true, []string{"editor", "d1", "/api/v1/menus", "GET", "allow", ""}},
+		{"inherited deny", []string{"auditor"}, "d1", false, deny},
+		{"inherited deny beats direct allow", []string{"editor", "auditor"}, "d1", false, deny},
+		{"inherited deny beats direct allow in any order", []string{"auditor", "editor"}, "d1", false, deny},
+		{"deny inherited by two levels", []string{"editor", "intern"}, "d1", false, deny},
+		{"inheritance of another domain", []string{"editor", "intern"}, "d2", true, []string{"editor", "d2", "/api/v1/menus", "GET", "allow", ""}},
+	}
+	for _, tt := range tests {
+		allowed, explain, err := CasbinEnforce(enforcer, tt.subjects, tt.domain, "/api/v1/menus", "GET", nil)
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.allowed, allowed, tt.name)
+		assert.Equal(t, tt.explain, explain, tt.name)
+	}
+}
+
+func TestCasbinWithConfigElevated(t *testing.T) {
+	m, err := model.NewModelFromString(testCasbinModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction(CasbinConditionFunction, CasbinConditionMatch)
+	_, err = enforcer.AddPolicies([][]string{
+		{"staff", "", "/api/v1/menus", "GET", "allow", ""},
+		{"admin", "", "/api/v1/menus", "GET", "allow", ""},
+		{"admin", "", "/api/v1/roles", "GET", "allow", ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var used [][]string
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(CasbinWithConfig(CasbinConfig{
+		AllowedPathPrefixes: []string{"/api/"},
+		GetEnforcer: func(c *gin.Context) *casbin.SyncedEnforcer {
+			return enforcer
+		},
+		GetSubjects: func(c *gin.Context) []string {
+			return []string{"staff", "admin"}
+		},
+		GetElevatedSubjects: func(c *gin.Context) []string {
+			return []string{"admin"}
+		},
+		OnElevated: func(c *gin.Context, explain []string) {
+			used = append(used, explain)
+		},
+	}))
+	for _, path := range []string{"/api/v1/menus", "/api/v1/roles"} {
+		e.GET(path, func(c *gin.Context) {
+			util.ResOK(c)
+		})
+	}
+
+	// The use of the elevated role is reported only if the request is not allowed without it
+	for _, path := range []string{"/api/v1/menus", "/api/v1/roles"} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+	assert.Equal(t, [][]string{{"admin", "", "/api/v1/roles", "GET", "allow", ""}}, used)
+}
+
+func TestCasbinWithConfigScopes(t *testing.T) {
+	m, err := model.NewModelFromString(testCasbinModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enforcer.AddFunction(CasbinConditionFunction, CasbinConditionMatch)
+	_, err = enforcer.AddPolicies([][]string{
+		{"owner", "", "/api/v1/users/:id", "GET", "allow", ""},
+		{"owner", "", "/api/v1/users/:id", "DELETE", "allow", ""},
+		{"owner", "", "/api/v1/menus", "GET", "allow", ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(CasbinWithConfig(CasbinConfig{
+		AllowedPathPrefixes: []string{"/api/"},
+		GetEnforcer: func(c *gin.Context) *casbin.SyncedEnforcer {
+			return enforcer
+		},
+		GetSubjects: func(c *gin.Context) []string {
+			return []string{"owner"}
+		},
+		GetScopes: func(c *gin.Context) ([]string, bool) {
+			if v := c.GetHeader("X-Scopes"); v != "" {
+				return strings.Split(v, ","), true
+			}
+			return nil, false
+		},
+	}))
+	e.Any("/api/v1/*path", func(c *gin.Context) {
+		util.ResOK(c)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		scopes string
+		code   int
+	}{
+		{"unrestricted", http.MethodDelete, "/api/v1/users/u1", "", http.StatusOK},
+		{"in scope and granted", http.MethodGet, "/api/v1/users/u1", "GET /api/v1/users/:id", http.StatusOK},
+		{"any request in scope", http.MethodGet, "/api/v1/menus", "*", http.StatusOK},
+		{"any method in scope", http.MethodDelete, "/api/v1/users/u1", "* /api/v1/users/*", http.StatusOK},
+		{"granted but out of scope", http.MethodDelete, "/api/v1/users/u1", "GET /api/v1/users/:id", http.StatusForbidden},
+		{"in scope but not granted", http.MethodGet, "/api/v1/roles", "*", http.StatusForbidden},
+		{"in scope but not granted to the owner", http.MethodPost, "/api/v1/users/u1", "* /api/v1/users/*", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-Scopes", tt.scopes)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, tt.code, w.Code, tt.name)
+	}
+}
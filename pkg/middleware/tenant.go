@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"github.com/LyricTian/gin-admin/v10/pkg/errors"
+	"github.com/LyricTian/gin-admin/v10/pkg/logging"
+	"github.com/LyricTian/gin-admin/v10/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+var ErrTenantMismatch = errors.Forbidden("com.tenant.mismatch", "The tenant does not match the access token")
+
+type TenantConfig struct {
+	AllowedPathPrefixes []string
+	HeaderKey           string
+	// Get the tenant ID by the code in the request header, the empty code is the default tenant
+	ParseTenantID func(c *gin.Context, code string) (string, error)
+}
+
+var DefaultTenantConfig = TenantConfig{
+	HeaderKey: "X-Tenant",
+}
+
+// Scope the requests to the tenants, the tenant of the authenticated user is kept (it must match the header if
+// specified), the others are scoped to the tenant of the header.
+func TenantWithConfig(config TenantConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !AllowedPathPrefixes(c, config.AllowedPathPrefixes...) {
+			c.Next()
+			return
+		}
+
+		code := c.GetHeader(config.HeaderKey)
+		ctx := c.Request.Context()
+		current, ok := util.FromTenantID(ctx)
+		if ok && code == "" {
+			c.Next()
+			return
+		}
+
+		tenantID, err := config.ParseTenantID(c, code)
+		if err != nil {
+			util.ResError(c, err)
+			return
+		} else if ok {
+			if tenantID != current {
+				util.ResError(c, ErrTenantMismatch)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		ctx = util.NewTenantID(ctx, tenantID)
+		ctx = logging.NewTenantID(ctx, tenantID)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -41,6 +41,8 @@ func TraceWithConfig(config TraceConfig) gin.HandlerFunc {
 
 		ctx := util.NewTraceID(c.Request.Context(), traceID)
 		ctx = logging.NewTraceID(ctx, traceID)
+		ctx = util.NewClientIP(ctx, c.ClientIP())
+		ctx = util.NewUserAgent(ctx, c.Request.UserAgent())
 		c.Request = c.Request.WithContext(ctx)
 		c.Writer.Header().Set(config.ResponseTraceKey, traceID)
 		c.Next()
@@ -0,0 +1,406 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/golang-jwt/jwt"
+	jsoniter "github.com/json-iterator/go"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("Invalid ID token")
+	ErrUnknownKey     = errors.New("Unknown signing key")
+)
+
+const (
+	// Accepted clock difference between the provider and us
+	leeway = time.Minute
+	// Minimum interval of refetching the JWKS for an unknown key ID
+	keysRefreshInterval = time.Minute
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// The signing methods accepted for ID tokens (HMAC and none are never accepted)
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string // The "openid" scope is always requested
+}
+
+// OpenID provider metadata (OpenID Connect Discovery 1.0)
+type Metadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+}
+
+// Token endpoint response
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	IDToken      string `json:"id_token"`
+}
+
+// Error response of the token endpoint
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oidc: %s: %s", e.Code, e.Description)
+	}
+	return "oidc: " + e.Code
+}
+
+// The "aud" claim is either a string or an array of strings
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a Audience) Contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Verified claims of the ID token
+type IDToken struct {
+	Issuer            string                 `json:"iss"`
+	Subject           string                 `json:"sub"`
+	Audience          Audience               `json:"aud"`
+	AuthorizedParty   string                 `json:"azp,omitempty"`
+	ExpiresAt         int64                  `json:"exp"`
+	IssuedAt          int64                  `json:"iat"`
+	Nonce             string                 `json:"nonce,omitempty"`
+	Email             string                 `json:"email,omitempty"`
+	EmailVerified     bool                   `json:"email_verified,omitempty"`
+	Name              string                 `json:"name,omitempty"`
+	PreferredUsername string                 `json:"preferred_username,omitempty"`
+	Claims            map[string]interface{} `json:"-"` // All claims of the token
+}
+
+// Get the string claim by name.
+func (a *IDToken) GetString(name string) string {
+	s, _ := a.Claims[name].(string)
+	return s
+}
+
+type Option func(*Provider)
+
+func SetHTTPClient(client *http.Client) Option {
+	return func(p *Provider) {
+		p.client = client
+	}
+}
+
+// Use the clock to verify the expiration of ID tokens.
+func SetClock(now func() time.Time) Option {
+	return func(p *Provider) {
+		p.now = now
+	}
+}
+
+// OpenID Connect relying party of a provider, which uses the authorization code flow with PKCE.
+type Provider struct {
+	config   Config
+	metadata Metadata
+	client   *http.Client
+	now      func() time.Time
+
+	mu            sync.RWMutex
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// Create the provider with the discovery document of the issuer.
+func NewProvider(ctx context.Context, config Config, opts ...Option) (*Provider, error) {
+	p := &Provider{
+		config: config,
+		client: http.DefaultClient,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, discoveryURL, &p.metadata); err != nil {
+		return nil, err
+	}
+	if p.metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("oidc: issuer mismatch, expected %q got %q", config.Issuer, p.metadata.Issuer)
+	} else if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JWKSURI == "" {
+		return nil, errors.New("oidc: incomplete provider metadata")
+	}
+	return p, nil
+}
+
+func (p *Provider) Metadata() Metadata {
+	return p.metadata
+}
+
+func (p *Provider) scopes() []string {
+	scopes := []string{"openid"}
+	for _, scope := range p.config.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// Build the URL of the authorization endpoint, the user agent is redirected to it.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	values := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.scopes(), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	endpoint := p.metadata.AuthorizationEndpoint
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + values.Encode()
+	}
+	return endpoint + "?" + values.Encode()
+}
+
+// Exchange the authorization code for the tokens, the ID token is not verified.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	values := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+		"client_id":     {p.config.ClientID},
+	}
+
+	// client_secret_basic is the default authentication method of the token endpoint
+	basicAuth := p.config.ClientSecret != ""
+	if methods := p.metadata.TokenEndpointAuthMethodsSupported; basicAuth && len(methods) > 0 {
+		basicAuth = containsString(methods, "client_secret_basic")
+	}
+	if p.config.ClientSecret != "" && !basicAuth {
+		values.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		tokenErr := new(Error)
+		if err := json.Unmarshal(body, tokenErr); err != nil || tokenErr.Code == "" {
+			return nil, fmt.Errorf("oidc: token endpoint returned status %d", resp.StatusCode)
+		}
+		return nil, tokenErr
+	}
+
+	token := new(Token)
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("oidc: invalid token response: %s", err.Error())
+	} else if token.IDToken == "" {
+		return nil, errors.New("oidc: id_token is missing in the token response")
+	}
+	return token, nil
+}
+
+// Verify the signature and claims of the ID token, the nonce must match the one of the authorization request.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	parser := &jwt.Parser{
+		ValidMethods:         validMethods,
+		SkipClaimsValidation: true,
+	}
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.getKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err.Error())
+	}
+
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	token := &IDToken{Claims: claims}
+	if err := json.Unmarshal(buf, token); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err.Error())
+	}
+
+	now := p.now()
+	switch {
+	case token.Issuer != p.metadata.Issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, token.Issuer)
+	case !token.Audience.Contains(p.config.ClientID):
+		return nil, fmt.Errorf("%w: audience does not contain the client ID", ErrInvalidIDToken)
+	case len(token.Audience) > 1 && token.AuthorizedParty != "" && token.AuthorizedParty != p.config.ClientID:
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", ErrInvalidIDToken, token.AuthorizedParty)
+	case token.Subject == "":
+		return nil, fmt.Errorf("%w: subject is missing", ErrInvalidIDToken)
+	case token.ExpiresAt == 0 || now.Add(-leeway).Unix() > token.ExpiresAt:
+		return nil, fmt.Errorf("%w: token is expired", ErrInvalidIDToken)
+	case token.IssuedAt > now.Add(leeway).Unix():
+		return nil, fmt.Errorf("%w: token is issued in the future", ErrInvalidIDToken)
+	case nonce != "" && token.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	return token, nil
+}
+
+// Get the public key by ID, the JWKS is refetched (rate limited) when the key is unknown to support key rotation.
+func (p *Provider) getKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.RLock()
+	key, ok := p.lookupKey(kid)
+	fetchedAt := p.keysFetchedAt
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	} else if !fetchedAt.IsZero() && time.Since(fetchedAt) < keysRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keysFetchedAt.Equal(fetchedAt) {
+		var jwks jwtx.JSONWebKeySet
+		if err := p.getJSON(ctx, p.metadata.JWKSURI, &jwks); err != nil {
+			return nil, err
+		}
+
+		keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+		for _, jwk := range jwks.Keys {
+			if jwk.Use != "" && jwk.Use != "sig" {
+				continue
+			}
+			if key, err := jwk.PublicKey(); err == nil {
+				keys[jwk.Kid] = key
+			}
+		}
+		p.keys = keys
+		p.keysFetchedAt = time.Now()
+	}
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// A token without key ID can only be verified when the JWKS contains a single key.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s returned status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("oidc: invalid response of %s: %s", url, err.Error())
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate a random PKCE code verifier (RFC 7636).
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Compute the S256 code challenge of the code verifier.
+func CodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,196 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LyricTian/gin-admin/v10/pkg/jwtx"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// Local identity provider that supports the authorization code flow with PKCE
+type stubIdP struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+	subject  string
+	idClaims jwt.MapClaims // Extra claims (overwrite) of the issued ID tokens
+
+	mu    sync.Mutex
+	codes map[string]url.Values // code -> authorization request
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	idp := &stubIdP{
+		key:      key,
+		clientID: "ginadmin",
+		secret:   "secret",
+		subject:  "248289761001",
+		codes:    make(map[string]url.Values),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Metadata{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwtAuth := jwtx.New(nil, jwtx.SetSigningMethod(jwt.SigningMethodRS256), jwtx.SetPrivateKey(idp.key))
+		_ = json.NewEncoder(w).Encode(jwtAuth.JWKS())
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := "code-" + query.Get("state")
+		idp.mu.Lock()
+		idp.codes[code] = query
+		idp.mu.Unlock()
+		http.Redirect(w, r, query.Get("redirect_uri")+"?code="+code+"&state="+query.Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if id, secret, ok := r.BasicAuth(); !ok || id != idp.clientID || secret != idp.secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(Error{Code: "invalid_client"})
+			return
+		}
+
+		idp.mu.Lock()
+		authReq, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+		if !ok || CodeChallengeS256(r.PostFormValue("code_verifier")) != authReq.Get("code_challenge") ||
+			r.PostFormValue("redirect_uri") != authReq.Get("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(Error{Code: "invalid_grant"})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(Token{
+			AccessToken: "access",
+			TokenType:   "Bearer",
+			IDToken:     idp.sign(t, authReq.Get("nonce")),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (a *stubIdP) sign(t *testing.T, nonce string) string {
+	claims := jwt.MapClaims{
+		"iss":                a.URL,
+		"sub":                a.subject,
+		"aud":                []string{a.clientID},
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"email":              "jane@example.com",
+		"preferred_username": "jane",
+	}
+	for k, v := range a.idClaims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = jwtx.KeyID(a.key.Public())
+	s, err := token.SignedString(a.key)
+	assert.Nil(t, err)
+	return s
+}
+
+// Follow the authorization endpoint and return the code of the redirect.
+func authorize(t *testing.T, authURL string) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.Nil(t, err)
+	return location.Query().Get("code")
+}
+
+func TestProvider(t *testing.T) {
+	ctx := context.Background()
+	idp := newStubIdP(t)
+
+	provider, err := NewProvider(ctx, Config{
+		Issuer:       idp.URL,
+		ClientID:     idp.clientID,
+		ClientSecret: idp.secret,
+		RedirectURL:  "http://localhost/callback",
+		Scopes:       []string{"profile", "email"},
+	})
+	assert.Nil(t, err)
+
+	verifier, err := NewCodeVerifier()
+	assert.Nil(t, err)
+	authURL := provider.AuthCodeURL("state1", "nonce1", CodeChallengeS256(verifier))
+	u, err := url.Parse(authURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "openid profile email", u.Query().Get("scope"))
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+
+	token, err := provider.Exchange(ctx, authorize(t, authURL), verifier)
+	assert.Nil(t, err)
+	idToken, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce1")
+	assert.Nil(t, err)
+	assert.Equal(t, idp.subject, idToken.Subject)
+	assert.Equal(t, "jane", idToken.PreferredUsername)
+	assert.Equal(t, "jane@example.com", idToken.GetString("email"))
+
+	// The code is bound to the code challenge
+	_, err = provider.Exchange(ctx, authorize(t, provider.AuthCodeURL("state2", "nonce2", CodeChallengeS256(verifier))), "wrong")
+	assert.Equal(t, "invalid_grant", err.(*Error).Code)
+
+	// The nonce must match
+	_, err = provider.VerifyIDToken(ctx, token.IDToken, "other")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// The audience must contain the client ID
+	idp.idClaims = jwt.MapClaims{"aud": "other"}
+	_, err = provider.VerifyIDToken(ctx, idp.sign(t, "nonce1"), "nonce1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// Expired token
+	idp.idClaims = jwt.MapClaims{"exp": time.Now().Add(-2 * leeway).Unix()}
+	_, err = provider.VerifyIDToken(ctx, idp.sign(t, "nonce1"), "nonce1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// Token signed by an unknown key
+	idp.idClaims = nil
+	rawToken := idp.sign(t, "nonce1")
+	idp.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	_, err = provider.VerifyIDToken(ctx, idp.sign(t, "nonce1"), "nonce1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+	_, err = provider.VerifyIDToken(ctx, rawToken, "nonce1")
+	assert.Nil(t, err)
+
+	// The HMAC algorithm is never accepted
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": idp.URL}).SignedString([]byte("secret"))
+	assert.Nil(t, err)
+	_, err = provider.VerifyIDToken(ctx, hmacToken, "")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// The issuer of the discovery document must match
+	_, err = NewProvider(ctx, Config{Issuer: idp.URL + "/"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,214 @@
+// Package totp implements the time-based one-time password algorithm (RFC 6238),
+// compatible with the common authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+var (
+	ErrInvalidSecret    = errors.New("Invalid totp secret")
+	ErrInvalidAlgorithm = errors.New("Invalid totp algorithm")
+)
+
+var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type options struct {
+	digits    int
+	period    int64
+	skew      int64
+	algorithm Algorithm
+	now       func() time.Time
+}
+
+type Option func(*options)
+
+// Set the number of digits of the code (default 6)
+func SetDigits(digits int) Option {
+	return func(o *options) {
+		o.digits = digits
+	}
+}
+
+// Set the time step in seconds (default 30)
+func SetPeriod(period int64) Option {
+	return func(o *options) {
+		o.period = period
+	}
+}
+
+// Set the number of time steps accepted before and after the current one (default 1)
+func SetSkew(skew int64) Option {
+	return func(o *options) {
+		o.skew = skew
+	}
+}
+
+// Set the HMAC algorithm (default SHA1)
+func SetAlgorithm(algorithm Algorithm) Option {
+	return func(o *options) {
+		o.algorithm = algorithm
+	}
+}
+
+// Set the clock used to get the current time (default time.Now)
+func SetClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+type TOTP struct {
+	opts *options
+}
+
+func New(opts ...Option) *TOTP {
+	o := options{
+		digits:    6,
+		period:    30,
+		skew:      1,
+		algorithm: AlgorithmSHA1,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &TOTP{opts: &o}
+}
+
+// Generate a random base32 encoded secret (160 bits as recommended by RFC 4226)
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32NoPadding.EncodeToString(b), nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	b, err := b32NoPadding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(b) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return b, nil
+}
+
+func (t *TOTP) hashFunc() (func() hash.Hash, error) {
+	switch t.opts.algorithm {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, ErrInvalidAlgorithm
+}
+
+// The time step counter of the given time
+func (t *TOTP) Counter(at time.Time) int64 {
+	return at.Unix() / t.opts.period
+}
+
+func (t *TOTP) generate(key []byte, counter int64) (string, error) {
+	fn, err := t.hashFunc()
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(fn, key)
+	_, _ = mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := int64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+
+	mod := int64(1)
+	for i := 0; i < t.opts.digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.opts.digits, value%mod), nil
+}
+
+// Generate the code at the given time
+func (t *TOTP) GenerateAt(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.generate(key, t.Counter(at))
+}
+
+// Generate the code at the current time
+func (t *TOTP) Generate(secret string) (string, error) {
+	return t.GenerateAt(secret, t.opts.now())
+}
+
+// Validate the code at the current time (within the skew), the matched counter is returned
+// so the caller can reject the codes that have been used (replay).
+func (t *TOTP) Validate(secret, code string) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != t.opts.digits {
+		return 0, false
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := t.Counter(t.opts.now())
+	for i := -t.opts.skew; i <= t.opts.skew; i++ {
+		expected, err := t.generate(key, current+i)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return current + i, true
+		}
+	}
+	return 0, false
+}
+
+// Build the provisioning URI (otpauth://totp/...) that authenticator apps import from a QR code
+func (t *TOTP) ProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	if issuer != "" {
+		params.Set("issuer", issuer)
+	}
+	params.Set("algorithm", string(t.opts.algorithm))
+	params.Set("digits", fmt.Sprintf("%d", t.opts.digits))
+	params.Set("period", fmt.Sprintf("%d", t.opts.period))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
@@ -0,0 +1,81 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRFC6238(t *testing.T) {
+	// Test vectors from RFC 6238 Appendix B
+	secrets := map[Algorithm]string{
+		AlgorithmSHA1:   "12345678901234567890",
+		AlgorithmSHA256: "12345678901234567890123456789012",
+		AlgorithmSHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	vectors := []struct {
+		unix  int64
+		codes map[Algorithm]string
+	}{
+		{59, map[Algorithm]string{AlgorithmSHA1: "94287082", AlgorithmSHA256: "46119246", AlgorithmSHA512: "90693936"}},
+		{1111111109, map[Algorithm]string{AlgorithmSHA1: "07081804", AlgorithmSHA256: "68084774", AlgorithmSHA512: "25091201"}},
+		{1111111111, map[Algorithm]string{AlgorithmSHA1: "14050471", AlgorithmSHA256: "67062674", AlgorithmSHA512: "99943326"}},
+		{1234567890, map[Algorithm]string{AlgorithmSHA1: "89005924", AlgorithmSHA256: "91819424", AlgorithmSHA512: "93441116"}},
+		{2000000000, map[Algorithm]string{AlgorithmSHA1: "69279037", AlgorithmSHA256: "90698825", AlgorithmSHA512: "38618901"}},
+		{20000000000, map[Algorithm]string{AlgorithmSHA1: "65353130", AlgorithmSHA256: "77737706", AlgorithmSHA512: "47863826"}},
+	}
+
+	for alg, secret := range secrets {
+		encoded := base32.StdEncoding.EncodeToString([]byte(secret))
+		otp := New(SetAlgorithm(alg), SetDigits(8))
+		for _, v := range vectors {
+			code, err := otp.GenerateAt(encoded, time.Unix(v.unix, 0))
+			assert.Nil(t, err)
+			assert.Equal(t, v.codes[alg], code, "%s at %d", alg, v.unix)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	otp := New(SetClock(func() time.Time { return now }))
+
+	secret, err := GenerateSecret()
+	assert.Nil(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := otp.Generate(secret)
+	assert.Nil(t, err)
+	assert.Len(t, code, 6)
+
+	counter, ok := otp.Validate(secret, code)
+	assert.True(t, ok)
+	assert.Equal(t, otp.Counter(now), counter)
+
+	// accepted within the skew window
+	now = now.Add(30 * time.Second)
+	counter, ok = otp.Validate(secret, code)
+	assert.True(t, ok)
+	assert.Equal(t, otp.Counter(now)-1, counter)
+
+	// rejected outside the skew window
+	now = now.Add(60 * time.Second)
+	_, ok = otp.Validate(secret, code)
+	assert.False(t, ok)
+
+	_, ok = otp.Validate(secret, "12345")
+	assert.False(t, ok)
+	_, ok = otp.Validate("!invalid", code)
+	assert.False(t, ok)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := New().ProvisioningURI("gin-admin", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/gin-admin:alice@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=gin-admin")
+	assert.Contains(t, uri, "digits=6")
+}
@@ -8,13 +8,21 @@ import (
 )
 
 type (
-	traceIDCtx    struct{}
-	transCtx      struct{}
-	rowLockCtx    struct{}
-	userIDCtx     struct{}
-	userTokenCtx  struct{}
-	isRootUserCtx struct{}
-	userCacheCtx  struct{}
+	traceIDCtx     struct{}
+	transCtx       struct{}
+	rowLockCtx     struct{}
+	userIDCtx      struct{}
+	userTokenCtx   struct{}
+	isRootUserCtx  struct{}
+	userCacheCtx   struct{}
+	clientIPCtx    struct{}
+	userAgentCtx   struct{}
+	sessionIDCtx   struct{}
+	apiKeyCtx      struct{}
+	impersonCtx    struct{}
+	dataScopeCtx   struct{}
+	tenantIDCtx    struct{}
+	tenantAdminCtx struct{}
 )
 
 func NewTraceID(ctx context.Context, traceID string) context.Context {
@@ -74,6 +82,133 @@ func FromUserToken(ctx context.Context) string {
 	return ""
 }
 
+func NewClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, clientIPCtx{}, clientIP)
+}
+
+func FromClientIP(ctx context.Context) string {
+	v := ctx.Value(clientIPCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+func NewUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, userAgentCtx{}, userAgent)
+}
+
+func FromUserAgent(ctx context.Context) string {
+	v := ctx.Value(userAgentCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+// The session (token family) of the access token
+func NewSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDCtx{}, sessionID)
+}
+
+func FromSessionID(ctx context.Context) string {
+	v := ctx.Value(sessionIDCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+// The scopes of the API key used by the request
+func NewAPIKeyScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, apiKeyCtx{}, scopes)
+}
+
+// Get the scopes of the API key, ok is false if the request is not authenticated by an API key
+func FromAPIKeyScopes(ctx context.Context) ([]string, bool) {
+	v := ctx.Value(apiKeyCtx{})
+	if v != nil {
+		return v.([]string), true
+	}
+	return nil, false
+}
+
+// Impersonation of the request, the user ID of the context is the impersonated user
+type Impersonation struct {
+	ActorID   string // The real user
+	ExpiresAt int64  // Expired time of the impersonation token (Unit: second)
+}
+
+func NewImpersonation(ctx context.Context, impersonation Impersonation) context.Context {
+	return context.WithValue(ctx, impersonCtx{}, impersonation)
+}
+
+// Get the impersonation of the request, ok is false if the user is not impersonated
+func FromImpersonation(ctx context.Context) (Impersonation, bool) {
+	v := ctx.Value(impersonCtx{})
+	if v != nil {
+		return v.(Impersonation), true
+	}
+	return Impersonation{}, false
+}
+
+// Data scope of the request, the records are visible if they belong to the departments or to the user
+type DataScope struct {
+	DepartmentIDs []string // Visible departments
+	UserID        string   // The records of the user self are visible (empty if not)
+}
+
+func NewDataScope(ctx context.Context, dataScope DataScope) context.Context {
+	return context.WithValue(ctx, dataScopeCtx{}, dataScope)
+}
+
+// Get the data scope of the request, ok is false if all data is visible
+func FromDataScope(ctx context.Context) (DataScope, bool) {
+	v := ctx.Value(dataScopeCtx{})
+	if v != nil {
+		return v.(DataScope), true
+	}
+	return DataScope{}, false
+}
+
+// Set the tenant of the request, the database queries are scoped to the tenant (empty for the default tenant)
+func NewTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDCtx{}, &tenantID)
+}
+
+// Remove the tenant of the request, the database queries are not scoped to any tenant
+func NewTenantUnscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantIDCtx{}, (*string)(nil))
+}
+
+// Get the tenant of the request, ok is false if the request is not scoped to any tenant
+func FromTenantID(ctx context.Context) (string, bool) {
+	v, _ := ctx.Value(tenantIDCtx{}).(*string)
+	if v != nil {
+		return *v, true
+	}
+	return "", false
+}
+
+// Set the administrator of the tenant of the request, who has all permissions in the tenant like root
+func NewTenantAdminID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, tenantAdminCtx{}, userID)
+}
+
+func FromTenantAdminID(ctx context.Context) string {
+	v := ctx.Value(tenantAdminCtx{})
+	if v != nil {
+		return v.(string)
+	}
+	return ""
+}
+
+// Check whether the user of the request is the administrator of the tenant
+func FromIsTenantAdmin(ctx context.Context) bool {
+	adminID := FromTenantAdminID(ctx)
+	return adminID != "" && adminID == FromUserID(ctx)
+}
+
 func NewIsRootUser(ctx context.Context) context.Context {
 	return context.WithValue(ctx, isRootUserCtx{}, true)
 }
@@ -85,7 +220,9 @@ func FromIsRootUser(ctx context.Context) bool {
 
 // Set user cache object
 type UserCache struct {
-	RoleIDs []string `json:"rids"`
+	RoleIDs            []string `json:"rids"`
+	ElevatedRoleIDs    []string `json:"erids,omitempty"` // The roles among RoleIDs granted temporarily
+	MustChangePassword bool     `json:"mcp,omitempty"`
 }
 
 func ParseUserCache(s string) UserCache {
@@ -31,6 +31,9 @@ func GetDB(ctx context.Context, defDB *gorm.DB) *gorm.DB {
 	if FromRowLock(ctx) {
 		db = db.Clauses(clause.Locking{Strength: "UPDATE"})
 	}
+	if tenantID, ok := FromTenantID(ctx); ok {
+		db = db.Scopes(TenantScope(tenantID))
+	}
 	return db.WithContext(ctx)
 }
 
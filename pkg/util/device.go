@@ -0,0 +1,56 @@
+package util
+
+import "strings"
+
+var (
+	browserKeywords = []struct{ keyword, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"MSIE ", "Internet Explorer"},
+		{"Trident/", "Internet Explorer"},
+		{"curl/", "curl"},
+		{"PostmanRuntime/", "Postman"},
+	}
+	osKeywords = []struct{ keyword, name string }{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// Parse a short device description (e.g. "Chrome on Windows") from the user agent.
+func ParseDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown"
+	}
+
+	var browser, os string
+	for _, item := range browserKeywords {
+		if strings.Contains(userAgent, item.keyword) {
+			browser = item.name
+			break
+		}
+	}
+	for _, item := range osKeywords {
+		if strings.Contains(userAgent, item.keyword) {
+			os = item.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+	return "Unknown"
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"bytes"
+	"encoding/csv"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -79,6 +81,35 @@ func ResJSON(c *gin.Context, status int, v interface{}) {
 	c.Abort()
 }
 
+// Respond the rows as a CSV file attachment
+func ResCSV(c *gin.Context, filename string, rows [][]string) {
+	escaped := make([][]string, len(rows))
+	for i, row := range rows {
+		escaped[i] = make([]string, len(row))
+		for j, cell := range row {
+			escaped[i][j] = EscapeCSVCell(cell)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(escaped); err != nil {
+		ResError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	c.Abort()
+}
+
+// Escape the cell which would be interpreted as a formula by the spreadsheet applications (CSV injection).
+func EscapeCSVCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
 func ResSuccess(c *gin.Context, v interface{}) {
 	ResJSON(c, http.StatusOK, ResponseResult{
 		Success: true,
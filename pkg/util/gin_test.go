@@ -0,0 +1,21 @@
+package util
+
+import "testing"
+
+func TestEscapeCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":                  "",
+		"admin":             "admin",
+		"=SUM(A1:A2)":       "'=SUM(A1:A2)",
+		"+1":                "'+1",
+		"-1":                "'-1",
+		"@cmd":              "'@cmd",
+		"\t=1":              "'\t=1",
+		"/api/v1/users/:id": "/api/v1/users/:id",
+	}
+	for cell, want := range tests {
+		if got := EscapeCSVCell(cell); got != want {
+			t.Errorf("EscapeCSVCell(%q) = %q, want %q", cell, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// The field of the models which belong to a tenant
+const TenantIDField = "TenantID"
+
+// Scope the statement to the tenant if its model has the tenant field, the records of the other tenants are
+// filtered out and the tenant field of the records to create is set.
+func TenantScope(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		stmt := db.Statement
+		model := stmt.Model
+		if model == nil {
+			model = stmt.Dest
+		}
+		if model == nil {
+			return db
+		} else if err := stmt.Parse(model); err != nil || stmt.Schema == nil {
+			return db
+		}
+
+		field := stmt.Schema.LookUpField(TenantIDField)
+		if field == nil || field.DBName == "" {
+			return db
+		}
+		setTenantField(stmt, field, tenantID)
+
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			Value:  tenantID,
+		})
+	}
+}
+
+func setTenantField(stmt *gorm.Statement, field *schema.Field, tenantID string) {
+	if stmt.Dest == nil {
+		return
+	}
+
+	set := func(rv reflect.Value) {
+		rv = reflect.Indirect(rv)
+		if rv.Kind() == reflect.Struct && rv.Type() == stmt.Schema.ModelType && rv.CanAddr() {
+			_ = field.Set(stmt.Context, rv, tenantID)
+		}
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+	switch rv.Kind() {
+	case reflect.Struct:
+		set(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(rv.Index(i))
+		}
+	}
+}
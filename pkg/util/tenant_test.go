@@ -0,0 +1,96 @@
+package util
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type tenantItem struct {
+	ID       string `gorm:"size:20;primarykey"`
+	TenantID string `gorm:"size:20;index;default:''"`
+	Name     string `gorm:"size:64"`
+}
+
+type sharedItem struct {
+	ID   string `gorm:"size:20;primarykey"`
+	Name string `gorm:"size:64"`
+}
+
+func TestTenantScope(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tenant.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, db.AutoMigrate(new(tenantItem), new(sharedItem)))
+
+	ctx := context.Background()
+	t1 := NewTenantID(ctx, "t1")
+	t2 := NewTenantID(ctx, "t2")
+	def := NewTenantID(ctx, "")
+	unscoped := NewTenantUnscoped(t1)
+
+	// The tenant field of the records to create is set by the tenant of the context
+	assert.Nil(t, GetDB(t1, db).Create(&tenantItem{ID: "a1", Name: "a1"}).Error)
+	assert.Nil(t, GetDB(t1, db).Create(&tenantItem{ID: "a2", TenantID: "t2", Name: "a2"}).Error)
+	assert.Nil(t, GetDB(t2, db).Create([]*tenantItem{{ID: "b1", Name: "b1"}, {ID: "b2", Name: "b2"}}).Error)
+	assert.Nil(t, GetDB(def, db).Create(&tenantItem{ID: "c1", Name: "c1"}).Error)
+	assert.Nil(t, GetDB(t1, db).Create(&sharedItem{ID: "s1", Name: "s1"}).Error)
+
+	queryIDs := func(ctx context.Context) []string {
+		var ids []string
+		assert.Nil(t, GetDB(ctx, db).Model(new(tenantItem)).Order("id").Pluck("id", &ids).Error)
+		return ids
+	}
+	assert.Equal(t, []string{"a1", "a2"}, queryIDs(t1))
+	assert.Equal(t, []string{"b1", "b2"}, queryIDs(t2))
+	assert.Equal(t, []string{"c1"}, queryIDs(def))
+	assert.Equal(t, []string{"a1", "a2", "b1", "b2", "c1"}, queryIDs(unscoped))
+
+	var item tenantItem
+	ok, err := FindOne(t1, GetDB(t1, db).Model(new(tenantItem)).Where("id=?", "b1"), QueryOptions{}, &item)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	exists, err := Exists(t2, GetDB(t2, db).Model(new(tenantItem)).Where("id=?", "b1"))
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	// The records of the other tenants are not updated or deleted
+	result := GetDB(t1, db).Model(new(tenantItem)).Where("id=?", "b1").Update("name", "x")
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(0), result.RowsAffected)
+	result = GetDB(t1, db).Model(new(tenantItem)).Where("name <> ?", "").Update("name", "x")
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(2), result.RowsAffected)
+
+	result = GetDB(t2, db).Where("id=?", "a1").Delete(new(tenantItem))
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(0), result.RowsAffected)
+	result = GetDB(t2, db).Where("id <> ?", "").Delete(new(tenantItem))
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(2), result.RowsAffected)
+
+	var names []string
+	assert.Nil(t, GetDB(unscoped, db).Model(new(tenantItem)).Order("id").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"x", "x", "c1"}, names)
+
+	// The unscoped context updates and deletes the records of all tenants
+	result = GetDB(unscoped, db).Model(new(tenantItem)).Where("name <> ?", "").Update("name", "y")
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(3), result.RowsAffected)
+	result = GetDB(unscoped, db).Where("id <> ?", "").Delete(new(tenantItem))
+	assert.Nil(t, result.Error)
+	assert.Equal(t, int64(3), result.RowsAffected)
+
+	// The models without the tenant field are not scoped
+	var count int64
+	assert.Nil(t, GetDB(t2, db).Model(new(sharedItem)).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}